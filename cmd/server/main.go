@@ -2,87 +2,314 @@ package main
 
 import (
 	"context"
-	"flag"
+	"errors"
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/UkralStul/graphql-comments-service/graph"
-	"github.com/UkralStul/graphql-comments-service/graph/generated"
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/bodylimit"
+	"github.com/UkralStul/graphql-comments-service/internal/config"
 	"github.com/UkralStul/graphql-comments-service/internal/dataloader"
+	"github.com/UkralStul/graphql-comments-service/internal/export"
+	"github.com/UkralStul/graphql-comments-service/internal/httptimeout"
+	"github.com/UkralStul/graphql-comments-service/internal/mention"
+	"github.com/UkralStul/graphql-comments-service/internal/notify"
+	"github.com/UkralStul/graphql-comments-service/internal/querylog"
 	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/hybrid"
 	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/metrics"
 	"github.com/UkralStul/graphql-comments-service/internal/storage/postgres"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
 	"github.com/gorilla/websocket"
 )
 
-const defaultPort = "8080"
-
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
-	}
-
-	storageType := flag.String("storage", "in-memory", "Storage type (in-memory or postgres)")
-	flag.Parse()
+	cfg := config.Load()
 
 	var store storage.Storage
 	var err error
+	storeOpts := []storage.Option{
+		storage.WithMaxDepth(cfg.MaxCommentDepth),
+		// Резолверы запрашивают страницу с запасом, см. graph.MaxPageOverfetch
+		storage.WithMaxPageSize(cfg.MaxPageSize + graph.MaxPageOverfetch),
+		storage.WithMaxCommentLength(cfg.MaxCommentLength),
+		storage.WithMaxAttachments(cfg.MaxAttachments),
+		storage.WithAllowedControlChars(cfg.CommentAllowedControlChars),
+		storage.WithNormalization(cfg.NormalizeComments, storage.DefaultMaxBlankLines),
+		storage.WithDuplicateWindow(cfg.DuplicateCommentWindow),
+		storage.WithUniqueComments(cfg.UniqueComments),
+		storage.WithReportHideThreshold(cfg.ReportHideThreshold),
+		storage.WithPremoderation(cfg.Premoderation),
+		storage.WithDraftPosts(cfg.DraftPosts),
+		storage.WithCascadeDeletes(cfg.CascadeDeletes),
+		storage.WithLogLevel(cfg.DBLogLevel),
+		storage.WithSlowQueryThreshold(cfg.DBSlowQueryThreshold),
+	}
+	if cfg.TimeOrderedIDs {
+		storeOpts = append(storeOpts, storage.WithIDGenerator(storage.UUIDv7))
+	}
 
-	log.Printf("Starting server with %s storage", *storageType)
-	if *storageType == "postgres" {
-		dsn := os.Getenv("DATABASE_URL")
-		if dsn == "" {
-			log.Fatal("DATABASE_URL must be set for postgres storage")
+	log.Printf("Starting server with %s storage (production: %t)", cfg.StorageType, cfg.Production)
+	if cfg.StorageType == "postgres" || cfg.StorageType == "hybrid" {
+		if cfg.DatabaseURL == "" {
+			log.Fatalf("DATABASE_URL must be set for %s storage", cfg.StorageType)
 		}
-		store, err = postgres.New(dsn)
+		store, err = postgres.New(cfg.DatabaseURL, storeOpts...)
 		if err != nil {
 			log.Fatalf("failed to connect to postgres: %v", err)
 		}
+		if cfg.StorageType == "hybrid" {
+			// Чтение из памяти, запись в postgres с зеркалированием в память
+			store, err = hybrid.New(context.Background(), store, storeOpts...)
+			if err != nil {
+				log.Fatalf("failed to start hybrid storage: %v", err)
+			}
+		}
 	} else {
-		store = inmemory.New()
+		store = inmemory.New(storeOpts...)
 		// Заполним данными для тестов
 		fillWithMockData(store)
 	}
+	// Декоратор оборачивает итоговое хранилище, поэтому для hybrid измеряются чтения из кеша
+	var storageMetrics *metrics.Collector
+	if cfg.StorageMetrics {
+		storageMetrics = metrics.NewCollector()
+		store = metrics.New(store, storageMetrics)
+	}
 
 	router := chi.NewRouter()
 	router.Use(middleware.Logger)
 	router.Use(middleware.RequestID)
 	router.Use(middleware.Recoverer)
+	// Без явного списка origin'ов CORS-заголовки не отдаются, и браузер применяет same-origin.
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		router.Use(cors.Handler(cors.Options{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+			AllowCredentials: true,
+			MaxAge:           300,
+		}))
+	}
+	router.Use(auth.Middleware)
+
+	var mentionValidator mention.Validator
+	if cfg.MentionUserServiceURL != "" {
+		mentionValidator = mention.NewHTTPValidator(cfg.MentionUserServiceURL)
+	}
+	mentions, err := mention.NewParser(cfg.MentionPattern, mentionValidator)
+	if err != nil {
+		log.Fatalf("failed to configure mentions: %v", err)
+	}
+
+	// Рассылка уведомлений подписчикам постов. Пока доставка только пишет в лог.
+	notifier := notify.NewDispatcher(store, notify.LogSender{}, notify.DefaultQueueSize)
+	go notifier.Run(context.Background())
 
+	// Один ограничитель на все подписки: общий лимит горутин рассылки
+	fanOut := graph.WithFanOut(graph.NewFanOut(cfg.SubscriptionFanOutLimit))
+	// Пропуски событий из-за медленных подписчиков раз в минуту пишутся в лог
+	drops := graph.NewDropCounter()
+	go drops.Run(context.Background(), time.Minute)
+	onDrop := graph.WithDropHook(drops.Inc)
+	errorFormat, err := graph.ParseErrorFormat(cfg.ErrorExtensions)
+	if err != nil {
+		log.Fatalf("config: ERROR_EXTENSIONS: %v", err)
+	}
+	readOnly := graph.NewReadOnlyMode(cfg.ReadOnly, cfg.ReadOnlyMutations)
+	readOnly.SetErrorPresenter(errorFormat.Present)
 	resolver := &graph.Resolver{
-		Storage:  store,
-		Observer: graph.NewCommentObserver(),
+		Storage:         store,
+		Observer:        graph.NewCommentObserver(fanOut, onDrop),
+		PostObserver:    graph.NewPostObserver(fanOut, onDrop),
+		MentionObserver: graph.NewMentionObserver(fanOut, onDrop),
+		Mentions:        mentions,
+		Notifier:        notifier,
+		ReadOnly:        readOnly,
+		Config:          cfg,
+	}
+	if cfg.SubscriptionPostCacheTTL > 0 {
+		resolver.PostCache = graph.NewPostStatusCache(store, cfg.SubscriptionPostCacheTTL)
 	}
-	schema := generated.NewExecutableSchema(generated.Config{Resolvers: resolver})
+	if cfg.CommentPostCacheTTL > 0 {
+		resolver.CommentPostCache = graph.NewPostStatusCache(store, cfg.CommentPostCacheTTL)
+	}
+	if cfg.SearchRateLimit > 0 {
+		resolver.SearchLimiter = graph.NewRateLimiter(cfg.SearchRateLimit, cfg.SearchRateBurst)
+	}
+	schema := graph.NewSchema(resolver)
 
 	srv := handler.NewDefaultServer(schema)
+	srv.SetErrorPresenter(errorFormat.Present)
+	// При добавлении транспорта подписок обновите subscriptionTransports в graph/resolver.go
 	srv.AddTransport(&transport.Websocket{
 		Upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			CheckOrigin: checkOrigin(cfg.CORSAllowedOrigins),
 		},
 		KeepAlivePingInterval: 10 * time.Second,
 	})
+	// dataloader.Middleware создает лоадеры один раз на соединение,
+	// а события подписок должны загружаться без устаревшего кеша.
+	srv.AroundResponses(dataloader.SubscriptionMiddleware(store, cfg.LoaderChildrenLimit))
+
+	// Скаляр CommentContent нормализует и проверяет текст по тем же настройкам, что и хранилище
+	commentOptions := storage.NewOptions(storeOpts...)
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(model.WithCommentOptions(ctx, commentOptions))
+	})
+	// Медленные SQL-запросы пишутся в лог вместе с операцией, которая их вызвала
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		oc := graphql.GetOperationContext(ctx)
+		if oc.Operation == nil {
+			return next(ctx)
+		}
+		operation := string(oc.Operation.Operation)
+		if oc.Operation.Name != "" {
+			operation += " " + oc.Operation.Name
+		}
+		return next(storage.WithOperation(ctx, operation))
+	})
+	srv.AroundOperations(readOnly.Middleware)
+	if cfg.ReadOnly {
+		log.Printf("read-only mode: mutations are rejected")
+	}
+
+	// Рекурсивные children - основной способ построить дорогой запрос, поэтому операции
+	// дороже лимита отклоняются до выполнения
+	if cfg.QueryComplexityLimit > 0 {
+		srv.Use(extension.FixedComplexityLimit(cfg.QueryComplexityLimit))
+	}
+
+	if cfg.SlowQueryThreshold > 0 {
+		srv.Use(querylog.New(cfg.SlowQueryThreshold))
+	}
 
-	router.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	router.Handle("/query", dataloader.Middleware(store, srv))
+	// NewDefaultServer всегда подключает extension.Introspection,
+	// поэтому в продакшене запрещаем интроспекцию на уровне каждой операции.
+	if !cfg.IntrospectionEnabled {
+		srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+			graphql.GetOperationContext(ctx).DisableIntrospection = true
+			return next(ctx)
+		})
+	}
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("server failed to start: %v", err)
+	// Подписки по websocket живут дольше таймаутов сервера, поэтому с них дедлайны снимаются
+	router.With(httptimeout.Middleware, bodylimit.Middleware(cfg.MaxRequestBodyBytes)).Handle("/query", dataloader.Middleware(store, cfg.LoaderChildrenLimit, srv))
+	router.Get("/posts/{id}/export", export.Handler(store))
+	router.Get("/posts/{id}/comments.ndjson", export.StreamHandler(store))
+	// Импорт пишет в хранилище в обход GraphQL, поэтому режим только для чтения проверяется отдельно
+	router.With(readOnly.HTTPMiddleware("importThread"), bodylimit.Middleware(cfg.MaxImportBodyBytes)).Post("/posts/import", export.ImportHandler(store))
+	if storageMetrics != nil {
+		router.With(moderatorOnly).Get("/metrics/storage", storageMetrics.ServeHTTP)
 	}
+
+	if cfg.PlaygroundEnabled {
+		// Путь playground настраивается, поэтому не даем ему перекрыть маршруты API
+		if router.Match(chi.NewRouteContext(), http.MethodGet, cfg.PlaygroundPath) {
+			log.Fatalf("PLAYGROUND_PATH %s conflicts with an API route", cfg.PlaygroundPath)
+		}
+		var playgroundHandler http.Handler = playground.Handler("GraphQL playground", "/query")
+		if cfg.PlaygroundUser != "" {
+			playgroundHandler = middleware.BasicAuth("GraphQL playground", map[string]string{
+				cfg.PlaygroundUser: cfg.PlaygroundPassword,
+			})(playgroundHandler)
+		} else if cfg.Production {
+			log.Printf("warning: GraphQL playground is enabled in production without basic auth")
+		}
+		router.Handle(cfg.PlaygroundPath, playgroundHandler)
+		log.Printf("connect to http://localhost:%s%s for GraphQL playground", cfg.Port, cfg.PlaygroundPath)
+	}
+
+	// Таймауты защищают от медленных клиентов, держащих соединения (slowloris).
+	// WriteTimeout ограничивает и время выполнения обычного запроса, включая тяжелые GraphQL-запросы
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Shutdown не ждет websocket-соединений, поэтому подписки завершаем сами:
+	// клиенты получат ошибку вместо зависшей подписки
+	httpServer.RegisterOnShutdown(resolver.Observer.CloseAll)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		log.Printf("listening on :%s", cfg.Port)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server failed to start: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("graceful shutdown failed: %v", err)
+	}
+}
+
+// shutdownTimeout - сколько остановка сервера ждет завершения текущих запросов.
+const shutdownTimeout = 10 * time.Second
+
+// checkOrigin возвращает проверку Origin для websocket-апгрейда, согласованную с CORS.
+// Для пустого списка разрешен только same-origin: иначе чужой сайт откроет подписку
+// от имени пользователя с его cookie.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			// Не-браузерные клиенты не присылают Origin
+			return true
+		}
+		if len(allowed) == 0 {
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		}
+		for _, o := range allowed {
+			if strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// moderatorOnly пропускает к служебным маршрутам только модераторов.
+func moderatorOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.IsModerator(r.Context()) {
+			http.Error(w, auth.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func fillWithMockData(s storage.Storage) {
-	ctx := context.Background()
+	// Данные датируются прошлым, как настоящая история обсуждения
+	ctx := storage.PreserveCreatedAt(context.Background())
+	start := time.Now().Add(-2 * time.Hour)
 
 	// 1. Создаем пост и явно включаем комментарии. Проверяем ошибку.
 	post, err := s.CreatePost(ctx, &domain.Post{
@@ -90,6 +317,7 @@ func fillWithMockData(s storage.Storage) {
 		Content:         "Это содержимое тестового поста. Здесь мы обсуждаем GraphQL и Go.",
 		AuthorID:        "user-1",
 		CommentsEnabled: true,
+		CreatedAt:       start,
 	})
 	if err != nil {
 		log.Fatalf("fillWithMockData: failed to create post: %v", err)
@@ -97,9 +325,10 @@ func fillWithMockData(s storage.Storage) {
 
 	// 2. Создаем первый корневой комментарий и проверяем ошибку.
 	c1, err := s.CreateComment(ctx, &domain.Comment{
-		PostID:   post.ID,
-		AuthorID: "user-2",
-		Content:  "Отличный пост! Очень информативно.",
+		PostID:    post.ID,
+		AuthorID:  "user-2",
+		Content:   "Отличный пост! Очень информативно.",
+		CreatedAt: start.Add(10 * time.Minute),
 	})
 	if err != nil {
 		log.Fatalf("fillWithMockData: failed to create comment 1: %v", err)
@@ -107,10 +336,11 @@ func fillWithMockData(s storage.Storage) {
 
 	// 3. Создаем вложенный комментарий (ответ на первый) и проверяем ошибку.
 	_, err = s.CreateComment(ctx, &domain.Comment{
-		PostID:   post.ID,
-		ParentID: &c1.ID, // Указываем родителя
-		AuthorID: "user-1",
-		Content:  "Спасибо! Рад, что вам понравилось.",
+		PostID:    post.ID,
+		ParentID:  &c1.ID, // Указываем родителя
+		AuthorID:  "user-1",
+		Content:   "Спасибо! Рад, что вам понравилось.",
+		CreatedAt: start.Add(25 * time.Minute),
 	})
 	if err != nil {
 		log.Fatalf("fillWithMockData: failed to create nested comment: %v", err)
@@ -118,9 +348,10 @@ func fillWithMockData(s storage.Storage) {
 
 	// 4. Создаем второй корневой комментарий и проверяем ошибку.
 	_, err = s.CreateComment(ctx, &domain.Comment{
-		PostID:   post.ID,
-		AuthorID: "user-3",
-		Content:  "А как насчет производительности при большой вложенности?",
+		PostID:    post.ID,
+		AuthorID:  "user-3",
+		Content:   "А как насчет производительности при большой вложенности?",
+		CreatedAt: start.Add(40 * time.Minute),
 	})
 	if err != nil {
 		log.Fatalf("fillWithMockData: failed to create comment 2: %v", err)
@@ -132,6 +363,7 @@ func fillWithMockData(s storage.Storage) {
 		Content:         "К этому посту нельзя оставлять комментарии.",
 		AuthorID:        "user-admin",
 		CommentsEnabled: false, // <-- Явно выключаем комментарии
+		CreatedAt:       start.Add(time.Hour),
 	})
 	if err != nil {
 		log.Fatalf("fillWithMockData: failed to create disabled post: %v", err)
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	// dial открывает websocket к серверу с апгрейдером, настроенным как в main
+	dial := func(t *testing.T, allowed []string, origin string) int {
+		upgrader := websocket.Upgrader{CheckOrigin: checkOrigin(allowed)}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				conn.Close()
+			}
+		}))
+		defer ts.Close()
+
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), header)
+		if err == nil {
+			conn.Close()
+		}
+		require.NotNil(t, resp)
+		return resp.StatusCode
+	}
+
+	t.Run("allow-list", func(t *testing.T) {
+		allowed := []string{"https://app.example.com"}
+		assert.Equal(t, http.StatusSwitchingProtocols, dial(t, allowed, "https://app.example.com"))
+		assert.Equal(t, http.StatusSwitchingProtocols, dial(t, allowed, "HTTPS://APP.EXAMPLE.COM"))
+		assert.Equal(t, http.StatusForbidden, dial(t, allowed, "https://evil.example.com"))
+		// Не-браузерные клиенты не присылают Origin
+		assert.Equal(t, http.StatusSwitchingProtocols, dial(t, allowed, ""))
+	})
+
+	t.Run("same-origin by default", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, dial(t, nil, "https://evil.example.com"))
+		assert.Equal(t, http.StatusSwitchingProtocols, dial(t, nil, ""))
+	})
+
+	t.Run("same-origin host", func(t *testing.T) {
+		check := checkOrigin(nil)
+		r := httptest.NewRequest(http.MethodGet, "http://api.example.com/query", nil)
+		r.Header.Set("Origin", "http://API.example.com")
+		assert.True(t, check(r))
+		r.Header.Set("Origin", "http://evil.example.com")
+		assert.False(t, check(r))
+	})
+
+	t.Run("wildcard is not an allow-all", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, dial(t, []string{"*"}, "https://evil.example.com"))
+	})
+}
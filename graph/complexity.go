@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"github.com/UkralStul/graphql-comments-service/graph/generated"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+// Стоимость списочных полей комментариев растет вместе с размером страницы:
+//
+//	cost = 1 + limit * childComplexity
+//
+// где childComplexity - стоимость выборки внутри connection, а limit - запрошенный размер
+// страницы (или default из схемы). Так children(limit: 100) внутри children(limit: 100)
+// стоит порядка 100*100 узлов, а не 2, как при подсчете по умолчанию (1 + childComplexity).
+// Запрос дороже QUERY_COMPLEXITY_LIMIT отклоняется до выполнения.
+
+// withComplexity регистрирует функции стоимости для рекурсивных списков комментариев.
+func withComplexity(c *generated.ComplexityRoot) {
+	c.Comment.Children = func(childComplexity int, limit *int, cursor *string) int {
+		return pageComplexity(childComplexity, limit, defaultChildrenLimit)
+	}
+	c.Post.Comments = func(childComplexity int, limit *int, cursor *string, last *int, before *string, orderBy *domain.CommentOrder) int {
+		// При пагинации назад размер страницы задает last
+		if last != nil {
+			return pageComplexity(childComplexity, last, defaultCommentsLimit)
+		}
+		return pageComplexity(childComplexity, limit, defaultCommentsLimit)
+	}
+	c.Query.CommentsWithPreview = func(childComplexity int, postID string, limit *int, cursor *string, repliesPerComment *int) int {
+		// Выборка edge считается один раз, хотя выполняется для комментария и каждого его ответа
+		n := defaultPreviewLimit
+		if repliesPerComment != nil && *repliesPerComment > 0 {
+			n = *repliesPerComment
+		}
+		return pageComplexity(childComplexity*(1+n), limit, defaultCommentsLimit)
+	}
+}
+
+// pageComplexity считает стоимость страницы из limit элементов стоимостью childComplexity.
+func pageComplexity(childComplexity int, limit *int, def int) int {
+	l := def
+	if limit != nil {
+		l = *limit
+	}
+	// Отрицательный limit отклонит резолвер, а пустая страница стоит как одно поле
+	if l < 1 {
+		l = 1
+	}
+	return 1 + l*childComplexity
+}
@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+func TestComplexity_ChildrenMultipliedByLimit(t *testing.T) {
+	r := newTestResolver(t)
+	srv := handler.NewDefaultServer(NewSchema(r))
+	srv.Use(extension.FixedComplexityLimit(5000))
+	c := client.New(srv)
+
+	post, err := r.Storage.CreatePost(context.Background(), &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	// Страница поста с двумя уровнями ответов при limit по умолчанию стоит 1302 и укладывается в лимит
+	var resp map[string]interface{}
+	err = c.Post(`query($id: ID!) { post(id: $id) { comments { edges { node { id content
+		children { edges { node { id content children { edges { node { id content } } } } } } } } } } }`,
+		&resp, client.Var("id", post.ID))
+	require.NoError(t, err)
+
+	// 100 ответов, у каждого по 100 ответов, стоят больше 30000,
+	// хотя без множителя запрос стоил бы меньше десяти
+	err = c.Post(`query($id: ID!) { post(id: $id) { comments(limit: 1) { edges { node {
+		children(limit: 100) { edges { node { children(limit: 100) { edges { node { id } } } } } } } } } } }`,
+		&resp, client.Var("id", post.ID))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "operation has complexity")
+
+	// Пагинация назад оценивается по last
+	err = c.Post(`query($id: ID!) { post(id: $id) { comments(last: 100) { edges { node {
+		children(limit: 100) { edges { node { id } } } } } } } }`,
+		&resp, client.Var("id", post.ID))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "operation has complexity")
+}
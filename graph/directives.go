@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/UkralStul/graphql-comments-service/graph/generated"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// NewSchema собирает исполняемую схему с резолверами r, реализациями директив схемы
+// и стоимостью списочных полей. Без реализации директивы gqlgen отклоняет любые входные данные с ней.
+func NewSchema(r *Resolver) graphql.ExecutableSchema {
+	cfg := generated.Config{
+		Resolvers: r,
+		Directives: generated.DirectiveRoot{
+			Constraint: Constraint,
+		},
+	}
+	withComplexity(&cfg.Complexity)
+	return generated.NewExecutableSchema(cfg)
+}
+
+// Constraint реализует директиву @constraint: обрезает пробелы по краям строкового
+// поля и проверяет результат. Резолвер получает уже обрезанное значение,
+// а нарушение ограничения возвращается как ValidationError с именем поля из схемы.
+func Constraint(ctx context.Context, obj interface{}, next graphql.Resolver, maxLength *int, notBlank *bool) (interface{}, error) {
+	v, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s := v.(type) {
+	case string:
+		s = strings.TrimSpace(s)
+		return s, checkConstraint(ctx, s, maxLength, notBlank)
+	case *string:
+		// null в необязательном поле означает "не менять" и не проверяется
+		if s == nil {
+			return s, nil
+		}
+		trimmed := strings.TrimSpace(*s)
+		return &trimmed, checkConstraint(ctx, trimmed, maxLength, notBlank)
+	default:
+		return nil, fmt.Errorf("@constraint is not supported on %T", v)
+	}
+}
+
+func checkConstraint(ctx context.Context, s string, maxLength *int, notBlank *bool) error {
+	field := constraintField(ctx)
+	if notBlank != nil && *notBlank && s == "" {
+		return &storage.ValidationError{Field: field, Code: storage.ValidationCodeBlank,
+			Err: fmt.Errorf("%s cannot be empty", field)}
+	}
+	if maxLength != nil && utf8.RuneCountInString(s) > *maxLength {
+		return &storage.ValidationError{Field: field, Code: storage.ValidationCodeTooLong, Limit: *maxLength,
+			Err: fmt.Errorf("%s must be at most %d characters", field, *maxLength)}
+	}
+	return nil
+}
+
+// constraintField возвращает имя проверяемого поля входного объекта.
+func constraintField(ctx context.Context) string {
+	if pc := graphql.GetPathContext(ctx); pc != nil && pc.Field != nil {
+		return *pc.Field
+	}
+	return ""
+}
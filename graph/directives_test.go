@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+func TestConstraintDirective(t *testing.T) {
+	r := newTestResolver(t)
+	srv := handler.NewDefaultServer(NewSchema(r))
+	srv.SetErrorPresenter(ErrorPresenter)
+	c := client.New(srv)
+
+	create := func(title, content string) (string, error) {
+		var resp struct {
+			CreatePost struct{ Title string }
+		}
+		err := c.Post(`mutation($title: String!, $content: String!) {
+			createPost(input: {title: $title, content: $content, authorId: "user-1"}) { title }
+		}`, &resp, client.Var("title", title), client.Var("content", content))
+		return resp.CreatePost.Title, err
+	}
+
+	// Резолвер получает уже обрезанное значение
+	title, err := create("  Post  ", "Content")
+	require.NoError(t, err)
+	assert.Equal(t, "Post", title)
+
+	// Ограничения проверяются после обрезки, до резолвера
+	_, err = create("Post", "   ")
+	assert.Equal(t, storage.ValidationCodeBlank, errorCode(t, err))
+	_, err = create(" "+strings.Repeat("a", 256), "Content")
+	assert.Equal(t, storage.ValidationCodeTooLong, errorCode(t, err))
+	_, err = create(" "+strings.Repeat("a", 255)+" ", "Content")
+	require.NoError(t, err)
+
+	posts, err := r.Storage.GetPosts(context.Background(), 10, 0, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+}
+
+func TestConstraint_NullableField(t *testing.T) {
+	notBlank := true
+	next := func(ctx context.Context) (interface{}, error) { return (*string)(nil), nil }
+
+	// null в необязательном поле пропускается без проверки
+	v, err := Constraint(context.Background(), nil, next, nil, &notBlank)
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	blank := "  "
+	next = func(ctx context.Context) (interface{}, error) { return &blank, nil }
+	_, err = Constraint(context.Background(), nil, next, nil, &notBlank)
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, storage.ValidationCodeBlank, validationErr.Code)
+}
@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DropCounter считает события подписок, пропущенные из-за заполненного буфера подписчика.
+// Пропуски не видны ни клиенту, ни серверу, поэтому без счетчика медленные клиенты и
+// недостаточный размер буфера незаметны. Inc подходит как hook для WithDropHook.
+type DropCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewDropCounter создает пустой счетчик.
+func NewDropCounter() *DropCounter {
+	return &DropCounter{counts: make(map[string]int64)}
+}
+
+// Inc учитывает один пропуск события подписки subscription.
+func (d *DropCounter) Inc(subscription string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.counts[subscription]++
+}
+
+// Counts возвращает копию счетчиков по подпискам с момента запуска.
+func (d *DropCounter) Counts() map[string]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return maps.Clone(d.counts)
+}
+
+// Run раз в interval пишет счетчики в лог, если с прошлой записи были новые пропуски,
+// и работает до отмены ctx.
+func (d *DropCounter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var logged map[string]int64
+	for {
+		select {
+		case <-ticker.C:
+			counts := d.Counts()
+			if maps.Equal(counts, logged) {
+				continue
+			}
+			log.Printf("subscriptions: dropped messages (total since start): %s", formatDropCounts(counts))
+			logged = counts
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// formatDropCounts выводит счетчики в стабильном порядке: "commentAdded=3 postAdded=1".
+func formatDropCounts(counts map[string]int64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.FormatInt(counts[name], 10)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// ErrorFormat задает, какие расширения получают типизированные ошибки GraphQL. Одним клиентам
+// удобнее разбирать extensions, другим хватает сообщения, поэтому набор задается на экземпляр
+// сервиса и не меняется от запроса к запросу - это контракт с клиентами.
+// userErrors в ответах мутаций от формата не зависят: их поля описаны в схеме.
+type ErrorFormat struct {
+	// Field - поле входных данных, к которому относится ошибка валидации
+	Field bool
+	// Code - машиночитаемый код ошибки (TOO_LONG, NOT_FOUND, READ_ONLY и т. д.)
+	Code bool
+	// Limit - нарушенное ограничение, например максимальная длина комментария
+	Limit bool
+}
+
+// DefaultErrorFormat включает все расширения.
+var DefaultErrorFormat = ErrorFormat{Field: true, Code: true, Limit: true}
+
+// ParseErrorFormat строит формат из списка расширений: "field", "code", "limit".
+// Единственный элемент "none" оставляет у ошибок только сообщение.
+func ParseErrorFormat(names []string) (ErrorFormat, error) {
+	var f ErrorFormat
+	if len(names) == 1 && names[0] == "none" {
+		return f, nil
+	}
+	for _, name := range names {
+		switch name {
+		case "field":
+			f.Field = true
+		case "code":
+			f.Code = true
+		case "limit":
+			f.Limit = true
+		default:
+			return ErrorFormat{}, fmt.Errorf("unknown error extension %q", name)
+		}
+	}
+	return f, nil
+}
+
+// Present дополняет ошибку включенными в формате расширениями. Ошибки валидации получают
+// field, code и limit, чтобы клиент мог показать ошибку рядом с нужным полем формы,
+// ошибки "не найдено" - code NOT_FOUND, ErrReadOnly - code READ_ONLY, ErrRateLimited - RATE_LIMITED.
+// Подходит как gqlgen ErrorPresenterFunc.
+func (f ErrorFormat) Present(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var validationErr *storage.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		if f.Field {
+			setExtension(gqlErr, "field", validationErr.Field)
+		}
+		if f.Code {
+			setExtension(gqlErr, "code", validationErr.Code)
+		}
+		if f.Limit && validationErr.Limit > 0 {
+			setExtension(gqlErr, "limit", validationErr.Limit)
+		}
+	case errors.Is(err, storage.ErrPostNotFound), errors.Is(err, storage.ErrCommentNotFound):
+		if f.Code {
+			setExtension(gqlErr, "code", codeNotFound)
+		}
+	case errors.Is(err, ErrReadOnly):
+		if f.Code {
+			setExtension(gqlErr, "code", codeReadOnly)
+		}
+	case errors.Is(err, ErrRateLimited):
+		if f.Code {
+			setExtension(gqlErr, "code", codeRateLimited)
+		}
+	}
+	return gqlErr
+}
+
+// ErrorPresenter оформляет ошибки в формате DefaultErrorFormat.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	return DefaultErrorFormat.Present(ctx, err)
+}
+
+// codeNotFound совпадает с UserErrorCode NOT_FOUND в схеме.
+const codeNotFound = "NOT_FOUND"
+
+func setExtension(gqlErr *gqlerror.Error, key string, value interface{}) {
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions[key] = value
+}
+
+// userErrorFrom преобразует ошибку хранилища в ошибку валидации для клиента.
+// Возвращает nil, если ошибка системная.
+func userErrorFrom(err error) *model.UserError {
+	field := func(name string) *string { return &name }
+
+	var validationErr *storage.ValidationError
+	if errors.As(err, &validationErr) {
+		userErr := &model.UserError{
+			Field:   field(validationErr.Field),
+			Message: err.Error(),
+			Code:    model.UserErrorCode(validationErr.Code),
+		}
+		if validationErr.Limit > 0 {
+			userErr.Limit = &validationErr.Limit
+		}
+		return userErr
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrPostNotFound):
+		return &model.UserError{Field: field("postId"), Message: err.Error(), Code: model.UserErrorCodeNotFound}
+	case errors.Is(err, storage.ErrParentNotFound):
+		return &model.UserError{Field: field("parentId"), Message: err.Error(), Code: model.UserErrorCodeNotFound}
+	case errors.Is(err, storage.ErrCommentsDisabled):
+		return &model.UserError{Message: err.Error(), Code: model.UserErrorCodeCommentsDisabled}
+	case errors.Is(err, storage.ErrAuthorBlocked):
+		return &model.UserError{Field: field("authorId"), Message: err.Error(), Code: model.UserErrorCodeAuthorBlocked}
+	case errors.Is(err, storage.ErrThreadLocked):
+		return &model.UserError{Field: field("parentId"), Message: err.Error(), Code: model.UserErrorCodeThreadLocked}
+	case errors.Is(err, storage.ErrDuplicateComment):
+		return &model.UserError{Field: field("content"), Message: err.Error(), Code: model.UserErrorCodeDuplicate}
+	}
+	return nil
+}
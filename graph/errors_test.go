@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+func TestErrorPresenter_ValidationExtensions(t *testing.T) {
+	err := storage.ValidateCommentContent("too long content", 5)
+
+	gqlErr := ErrorPresenter(context.Background(), err)
+	assert.Equal(t, "comment content is too long", gqlErr.Message)
+	assert.Equal(t, "content", gqlErr.Extensions["field"])
+	assert.Equal(t, storage.ValidationCodeTooLong, gqlErr.Extensions["code"])
+	assert.Equal(t, 5, gqlErr.Extensions["limit"])
+}
+
+func TestErrorFormat(t *testing.T) {
+	f, err := ParseErrorFormat([]string{"code"})
+	require.NoError(t, err)
+	assert.Equal(t, ErrorFormat{Code: true}, f)
+
+	// Выключенные расширения не попадают в ответ, сообщение остается
+	gqlErr := f.Present(context.Background(), storage.ValidateCommentContent("too long content", 5))
+	assert.Equal(t, "comment content is too long", gqlErr.Message)
+	assert.Equal(t, map[string]interface{}{"code": storage.ValidationCodeTooLong}, gqlErr.Extensions)
+	assert.Equal(t, codeReadOnly, f.Present(context.Background(), ErrReadOnly).Extensions["code"])
+	assert.Equal(t, codeRateLimited, f.Present(context.Background(), ErrRateLimited).Extensions["code"])
+
+	f, err = ParseErrorFormat([]string{"none"})
+	require.NoError(t, err)
+	gqlErr = f.Present(context.Background(), storage.ErrPostNotFound)
+	assert.Equal(t, "post not found", gqlErr.Message)
+	assert.Empty(t, gqlErr.Extensions)
+
+	_, err = ParseErrorFormat([]string{"code", "stack"})
+	assert.Error(t, err)
+}
+
+func TestQueryResolver_Comment_NotFound(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	comment, err := r.Query().Comment(ctx, "non-existent-id")
+	assert.Nil(t, comment)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+
+	gqlErr := ErrorPresenter(ctx, err)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+}
+
+func TestCommentContentScalar(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	srv := handler.NewDefaultServer(NewSchema(r))
+	srv.SetErrorPresenter(ErrorPresenter)
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(model.WithMaxCommentLength(ctx, 10))
+	})
+	c := client.New(srv)
+
+	create := func(content string) error {
+		var resp map[string]interface{}
+		return c.Post(fmt.Sprintf(`mutation { createComment(input: {postId: %q, authorId: "user-1", content: %q}) {
+			comment { id } userErrors { code }
+		} }`, post.ID, content), &resp)
+	}
+
+	// Невалидный текст отклоняется ошибкой GraphQL до резолвера - в хранилище ничего не попадает
+	assert.Equal(t, storage.ValidationCodeBlank, errorCode(t, create("   ")))
+	assert.Equal(t, storage.ValidationCodeTooLong, errorCode(t, create(strings.Repeat("a", 11))))
+	comments, err := r.Storage.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+
+	require.NoError(t, create(strings.Repeat("a", 10)))
+	// Длина считается после нормализации, как в хранилище: пробелы по краям не учитываются
+	require.NoError(t, create("  "+strings.Repeat("b", 10)+"\n\n"))
+}
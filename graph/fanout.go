@@ -0,0 +1,70 @@
+package graph
+
+// DefaultFanOutLimit - число одновременных рассылок событий подписчикам по умолчанию.
+const DefaultFanOutLimit = 64
+
+// FanOut ограничивает число горутин, одновременно рассылающих события подписчикам.
+// Без ограничения всплеск мутаций при большом числе подписчиков порождал бы
+// по горутине на каждое событие.
+type FanOut struct {
+	sem chan struct{}
+}
+
+// NewFanOut создает ограничитель на limit одновременных рассылок (не меньше одной).
+func NewFanOut(limit int) *FanOut {
+	if limit < 1 {
+		limit = 1
+	}
+	return &FanOut{sem: make(chan struct{}, limit)}
+}
+
+// Go запускает рассылку в отдельной горутине. Если уже идут limit рассылок, Go ждет,
+// пока одна из них закончится: мутация притормаживает, но число горутин не растет.
+// Рассылки не блокируются на медленных клиентах, поэтому ожидание короткое.
+func (f *FanOut) Go(deliver func()) {
+	f.sem <- struct{}{}
+	go func() {
+		defer func() { <-f.sem }()
+		deliver()
+	}()
+}
+
+// ObserverOption настраивает наблюдатель подписок.
+type ObserverOption func(*observerOptions)
+
+type observerOptions struct {
+	fanOut *FanOut
+	onDrop func(subscription string)
+}
+
+// WithFanOut задает общий для наблюдателей ограничитель рассылок. Без него каждый
+// наблюдатель получает свой ограничитель на DefaultFanOutLimit рассылок.
+func WithFanOut(f *FanOut) ObserverOption {
+	return func(o *observerOptions) {
+		o.fanOut = f
+	}
+}
+
+// WithDropHook задает функцию, которую наблюдатель вызывает на каждое событие, пропущенное
+// из-за заполненного буфера подписчика. subscription - имя подписки в схеме (commentAdded,
+// commentsAdded, postAdded, commentMentioned). Hook вызывается из горутин рассылки и должен быть быстрым,
+// например DropCounter.Inc.
+func WithDropHook(hook func(subscription string)) ObserverOption {
+	return func(o *observerOptions) {
+		o.onDrop = hook
+	}
+}
+
+func newObserverOptions(opts []ObserverOption) observerOptions {
+	o := observerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.fanOut == nil {
+		o.fanOut = NewFanOut(DefaultFanOutLimit)
+	}
+	if o.onDrop == nil {
+		o.onDrop = func(string) {}
+	}
+	return o
+}
@@ -7,10 +7,12 @@ import (
 	"context"
 	"errors"
 	"sync/atomic"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/introspection"
 	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
 	gqlparser "github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 )
@@ -34,6 +36,7 @@ type Config struct {
 
 type ResolverRoot interface {
 	Comment() CommentResolver
+	CreateCommentPayload() CreateCommentPayloadResolver
 	Mutation() MutationResolver
 	Post() PostResolver
 	Query() QueryResolver
@@ -41,300 +44,1357 @@ type ResolverRoot interface {
 }
 
 type DirectiveRoot struct {
+	Constraint func(ctx context.Context, obj interface{}, next graphql.Resolver, maxLength *int, notBlank *bool) (res interface{}, err error)
 }
 
 type ComplexityRoot struct {
-	Comment struct {
-		AuthorID  func(childComplexity int) int
-		Children  func(childComplexity int, limit *int, cursor *string) int
-		Content   func(childComplexity int) int
-		CreatedAt func(childComplexity int) int
-		ID        func(childComplexity int) int
-		Parent    func(childComplexity int) int
-		PostID    func(childComplexity int) int
+	ActivityConnection struct {
+		Edges    func(childComplexity int) int
+		PageInfo func(childComplexity int) int
 	}
 
-	CommentConnection struct {
+	ActivityEdge struct {
+		Cursor func(childComplexity int) int
+		Node   func(childComplexity int) int
+	}
+
+	AdminPostConnection struct {
 		Edges    func(childComplexity int) int
 		PageInfo func(childComplexity int) int
 	}
 
-	CommentEdge struct {
+	AdminPostEdge struct {
 		Cursor func(childComplexity int) int
 		Node   func(childComplexity int) int
+		Stats  func(childComplexity int) int
+	}
+
+	Attachment struct {
+		Title func(childComplexity int) int
+		Type  func(childComplexity int) int
+		URL   func(childComplexity int) int
+	}
+
+	Comment struct {
+		Ancestors   func(childComplexity int) int
+		Attachments func(childComplexity int) int
+		AuthorID    func(childComplexity int) int
+		Children    func(childComplexity int, limit *int, cursor *string) int
+		Content     func(childComplexity int) int
+		CreatedAt   func(childComplexity int) int
+		Hidden      func(childComplexity int) int
+		ID          func(childComplexity int) int
+		Locked      func(childComplexity int) int
+		Mentions    func(childComplexity int) int
+		Parent      func(childComplexity int) int
+		ParentID    func(childComplexity int) int
+		Post        func(childComplexity int) int
+		PostID      func(childComplexity int) int
+		Reactions   func(childComplexity int) int
+		Siblings    func(childComplexity int, limit *int, cursor *string) int
+		Status      func(childComplexity int) int
+	}
+
+	CommentConnection struct {
+		Edges      func(childComplexity int) int
+		PageInfo   func(childComplexity int) int
+		TotalCount func(childComplexity int) int
+	}
+
+	CommentEdge struct {
+		Cursor  func(childComplexity int) int
+		Node    func(childComplexity int) int
+		Replies func(childComplexity int) int
+	}
+
+	CommentReplies struct {
+		Comments func(childComplexity int) int
+		HasMore  func(childComplexity int) int
+		ParentID func(childComplexity int) int
+	}
+
+	CommentSearchEdge struct {
+		Highlight func(childComplexity int) int
+		Node      func(childComplexity int) int
+		Score     func(childComplexity int) int
+	}
+
+	CommentSearchResults struct {
+		Edges func(childComplexity int) int
+	}
+
+	CreateCommentPayload struct {
+		Ancestors  func(childComplexity int) int
+		Comment    func(childComplexity int) int
+		Cursor     func(childComplexity int) int
+		Depth      func(childComplexity int) int
+		UserErrors func(childComplexity int) int
+	}
+
+	DepthCount struct {
+		Count func(childComplexity int) int
+		Depth func(childComplexity int) int
 	}
 
 	Mutation struct {
-		CreateComment  func(childComplexity int, input model.NewComment) int
-		CreatePost     func(childComplexity int, input model.NewPost) int
-		ToggleComments func(childComplexity int, postID string, enable bool) int
+		AddReaction             func(childComplexity int, commentID string, typeArg domain.ReactionType) int
+		ApproveComment          func(childComplexity int, id string) int
+		BlockAuthorOnPost       func(childComplexity int, postID string, authorID string, blocked *bool) int
+		CreateComment           func(childComplexity int, input model.NewComment) int
+		CreatePost              func(childComplexity int, input model.NewPost) int
+		CreatePostWithComments  func(childComplexity int, post model.NewPost, comments []*model.NewComment) int
+		DeletePost              func(childComplexity int, id string) int
+		FollowPost              func(childComplexity int, postID string, channel *domain.NotificationChannel) int
+		LockThread              func(childComplexity int, commentID string, locked bool) int
+		MergePosts              func(childComplexity int, sourceID string, targetID string) int
+		PublishPost             func(childComplexity int, id string) int
+		RejectComment           func(childComplexity int, id string) int
+		ReportComment           func(childComplexity int, commentID string, reason *string) int
+		SetCommentHidden        func(childComplexity int, id string, hidden bool) int
+		SetDefaultCommentOrder  func(childComplexity int, postID string, order domain.CommentOrder) int
+		ToggleComments          func(childComplexity int, postID string, enable bool) int
+		ToggleCommentsBulk      func(childComplexity int, postIds []string, enable bool) int
+		ToggleCommentsForAuthor func(childComplexity int, authorID string, enable bool) int
+		UnfollowPost            func(childComplexity int, postID string) int
+		UpdateComment           func(childComplexity int, id string, content model.CommentContent) int
+		UpdatePost              func(childComplexity int, id string, input model.UpdatePostInput) int
 	}
 
 	PageInfo struct {
-		EndCursor   func(childComplexity int) int
-		HasNextPage func(childComplexity int) int
+		EndCursor       func(childComplexity int) int
+		HasNextPage     func(childComplexity int) int
+		HasPreviousPage func(childComplexity int) int
+		StartCursor     func(childComplexity int) int
 	}
 
 	Post struct {
-		AuthorID        func(childComplexity int) int
-		Comments        func(childComplexity int, limit *int, cursor *string) int
-		CommentsEnabled func(childComplexity int) int
-		Content         func(childComplexity int) int
-		CreatedAt       func(childComplexity int) int
-		ID              func(childComplexity int) int
-		Title           func(childComplexity int) int
+		AuthorID            func(childComplexity int) int
+		CanComment          func(childComplexity int) int
+		CommentCount        func(childComplexity int) int
+		Comments            func(childComplexity int, limit *int, cursor *string, last *int, before *string, orderBy *domain.CommentOrder) int
+		CommentsEnabled     func(childComplexity int) int
+		Content             func(childComplexity int) int
+		CreatedAt           func(childComplexity int) int
+		DefaultCommentOrder func(childComplexity int) int
+		DepthHistogram      func(childComplexity int) int
+		ID                  func(childComplexity int) int
+		LastCommentAt       func(childComplexity int) int
+		Published           func(childComplexity int) int
+		Title               func(childComplexity int) int
+	}
+
+	PostConnection struct {
+		Edges    func(childComplexity int) int
+		PageInfo func(childComplexity int) int
+	}
+
+	PostEdge struct {
+		Cursor func(childComplexity int) int
+		Node   func(childComplexity int) int
+	}
+
+	PostStats struct {
+		CommentCount   func(childComplexity int) int
+		LastActivityAt func(childComplexity int) int
+		PendingCount   func(childComplexity int) int
+		ReportCount    func(childComplexity int) int
+	}
+
+	PostSubscription struct {
+		Channel   func(childComplexity int) int
+		CreatedAt func(childComplexity int) int
+		PostID    func(childComplexity int) int
+		UserID    func(childComplexity int) int
 	}
 
 	Query struct {
-		Post  func(childComplexity int, id string) int
-		Posts func(childComplexity int, limit *int, offset *int) int
+		AdminPosts          func(childComplexity int, limit *int, cursor *string) int
+		AuthorActivity      func(childComplexity int, authorID string, limit *int, cursor *string) int
+		ChildrenOf          func(childComplexity int, commentIds []string, limitPerParent *int) int
+		Comment             func(childComplexity int, id string) int
+		CommentsSince       func(childComplexity int, postID string, since *time.Time, after *string) int
+		CommentsWithPreview func(childComplexity int, postID string, limit *int, cursor *string, repliesPerComment *int) int
+		FlatComments        func(childComplexity int, postID string, limit *int, cursor *string) int
+		PendingComments     func(childComplexity int, limit *int, cursor *string) int
+		Post                func(childComplexity int, id string) int
+		Posts               func(childComplexity int, limit *int, offset *int, commentsEnabled *bool, orderBy *model.PostOrder) int
+		PostsConnection     func(childComplexity int, limit *int, cursor *string, commentsEnabled *bool, orderBy *model.PostOrder) int
+		RecentComments      func(childComplexity int, postIds []string, limit *int) int
+		SearchComments      func(childComplexity int, query string, postID *string, limit *int) int
+		ServerInfo          func(childComplexity int) int
+		UnansweredComments  func(childComplexity int, postID string, byAuthorID string, limit *int, cursor *string) int
+	}
+
+	ReactionCount struct {
+		Count func(childComplexity int) int
+		Type  func(childComplexity int) int
+	}
+
+	ServerInfo struct {
+		MaxAttachments         func(childComplexity int) int
+		MaxCommentDepth        func(childComplexity int) int
+		MaxCommentLength       func(childComplexity int) int
+		MaxOffset              func(childComplexity int) int
+		MaxPageSize            func(childComplexity int) int
+		SubscriptionTransports func(childComplexity int) int
+		Version                func(childComplexity int) int
+	}
+
+	Subscription struct {
+		CommentAdded     func(childComplexity int, postID string, lastSeenCursor *string) int
+		CommentMentioned func(childComplexity int, username string) int
+		CommentsAdded    func(childComplexity int, postIds []string) int
+		PostAdded        func(childComplexity int) int
+	}
+
+	UserError struct {
+		Code    func(childComplexity int) int
+		Field   func(childComplexity int) int
+		Limit   func(childComplexity int) int
+		Message func(childComplexity int) int
+	}
+}
+
+type executableSchema struct {
+	schema     *ast.Schema
+	resolvers  ResolverRoot
+	directives DirectiveRoot
+	complexity ComplexityRoot
+}
+
+func (e *executableSchema) Schema() *ast.Schema {
+	if e.schema != nil {
+		return e.schema
 	}
+	return parsedSchema
+}
+
+func (e *executableSchema) Complexity(typeName, field string, childComplexity int, rawArgs map[string]interface{}) (int, bool) {
+	ec := executionContext{nil, e, 0, 0, nil}
+	_ = ec
+	switch typeName + "." + field {
+
+	case "ActivityConnection.edges":
+		if e.complexity.ActivityConnection.Edges == nil {
+			break
+		}
+
+		return e.complexity.ActivityConnection.Edges(childComplexity), true
+
+	case "ActivityConnection.pageInfo":
+		if e.complexity.ActivityConnection.PageInfo == nil {
+			break
+		}
+
+		return e.complexity.ActivityConnection.PageInfo(childComplexity), true
+
+	case "ActivityEdge.cursor":
+		if e.complexity.ActivityEdge.Cursor == nil {
+			break
+		}
+
+		return e.complexity.ActivityEdge.Cursor(childComplexity), true
+
+	case "ActivityEdge.node":
+		if e.complexity.ActivityEdge.Node == nil {
+			break
+		}
+
+		return e.complexity.ActivityEdge.Node(childComplexity), true
+
+	case "AdminPostConnection.edges":
+		if e.complexity.AdminPostConnection.Edges == nil {
+			break
+		}
+
+		return e.complexity.AdminPostConnection.Edges(childComplexity), true
+
+	case "AdminPostConnection.pageInfo":
+		if e.complexity.AdminPostConnection.PageInfo == nil {
+			break
+		}
+
+		return e.complexity.AdminPostConnection.PageInfo(childComplexity), true
+
+	case "AdminPostEdge.cursor":
+		if e.complexity.AdminPostEdge.Cursor == nil {
+			break
+		}
+
+		return e.complexity.AdminPostEdge.Cursor(childComplexity), true
+
+	case "AdminPostEdge.node":
+		if e.complexity.AdminPostEdge.Node == nil {
+			break
+		}
+
+		return e.complexity.AdminPostEdge.Node(childComplexity), true
+
+	case "AdminPostEdge.stats":
+		if e.complexity.AdminPostEdge.Stats == nil {
+			break
+		}
+
+		return e.complexity.AdminPostEdge.Stats(childComplexity), true
+
+	case "Attachment.title":
+		if e.complexity.Attachment.Title == nil {
+			break
+		}
+
+		return e.complexity.Attachment.Title(childComplexity), true
+
+	case "Attachment.type":
+		if e.complexity.Attachment.Type == nil {
+			break
+		}
+
+		return e.complexity.Attachment.Type(childComplexity), true
+
+	case "Attachment.url":
+		if e.complexity.Attachment.URL == nil {
+			break
+		}
+
+		return e.complexity.Attachment.URL(childComplexity), true
+
+	case "Comment.ancestors":
+		if e.complexity.Comment.Ancestors == nil {
+			break
+		}
+
+		return e.complexity.Comment.Ancestors(childComplexity), true
+
+	case "Comment.attachments":
+		if e.complexity.Comment.Attachments == nil {
+			break
+		}
+
+		return e.complexity.Comment.Attachments(childComplexity), true
+
+	case "Comment.authorId":
+		if e.complexity.Comment.AuthorID == nil {
+			break
+		}
+
+		return e.complexity.Comment.AuthorID(childComplexity), true
+
+	case "Comment.children":
+		if e.complexity.Comment.Children == nil {
+			break
+		}
+
+		args, err := ec.field_Comment_children_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Comment.Children(childComplexity, args["limit"].(*int), args["cursor"].(*string)), true
+
+	case "Comment.content":
+		if e.complexity.Comment.Content == nil {
+			break
+		}
+
+		return e.complexity.Comment.Content(childComplexity), true
+
+	case "Comment.createdAt":
+		if e.complexity.Comment.CreatedAt == nil {
+			break
+		}
+
+		return e.complexity.Comment.CreatedAt(childComplexity), true
+
+	case "Comment.hidden":
+		if e.complexity.Comment.Hidden == nil {
+			break
+		}
+
+		return e.complexity.Comment.Hidden(childComplexity), true
+
+	case "Comment.id":
+		if e.complexity.Comment.ID == nil {
+			break
+		}
+
+		return e.complexity.Comment.ID(childComplexity), true
+
+	case "Comment.locked":
+		if e.complexity.Comment.Locked == nil {
+			break
+		}
+
+		return e.complexity.Comment.Locked(childComplexity), true
+
+	case "Comment.mentions":
+		if e.complexity.Comment.Mentions == nil {
+			break
+		}
+
+		return e.complexity.Comment.Mentions(childComplexity), true
+
+	case "Comment.parent":
+		if e.complexity.Comment.Parent == nil {
+			break
+		}
+
+		return e.complexity.Comment.Parent(childComplexity), true
+
+	case "Comment.parentId":
+		if e.complexity.Comment.ParentID == nil {
+			break
+		}
+
+		return e.complexity.Comment.ParentID(childComplexity), true
+
+	case "Comment.post":
+		if e.complexity.Comment.Post == nil {
+			break
+		}
+
+		return e.complexity.Comment.Post(childComplexity), true
+
+	case "Comment.postId":
+		if e.complexity.Comment.PostID == nil {
+			break
+		}
+
+		return e.complexity.Comment.PostID(childComplexity), true
+
+	case "Comment.reactions":
+		if e.complexity.Comment.Reactions == nil {
+			break
+		}
+
+		return e.complexity.Comment.Reactions(childComplexity), true
+
+	case "Comment.siblings":
+		if e.complexity.Comment.Siblings == nil {
+			break
+		}
+
+		args, err := ec.field_Comment_siblings_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Comment.Siblings(childComplexity, args["limit"].(*int), args["cursor"].(*string)), true
+
+	case "Comment.status":
+		if e.complexity.Comment.Status == nil {
+			break
+		}
+
+		return e.complexity.Comment.Status(childComplexity), true
+
+	case "CommentConnection.edges":
+		if e.complexity.CommentConnection.Edges == nil {
+			break
+		}
+
+		return e.complexity.CommentConnection.Edges(childComplexity), true
+
+	case "CommentConnection.pageInfo":
+		if e.complexity.CommentConnection.PageInfo == nil {
+			break
+		}
+
+		return e.complexity.CommentConnection.PageInfo(childComplexity), true
+
+	case "CommentConnection.totalCount":
+		if e.complexity.CommentConnection.TotalCount == nil {
+			break
+		}
+
+		return e.complexity.CommentConnection.TotalCount(childComplexity), true
+
+	case "CommentEdge.cursor":
+		if e.complexity.CommentEdge.Cursor == nil {
+			break
+		}
+
+		return e.complexity.CommentEdge.Cursor(childComplexity), true
+
+	case "CommentEdge.node":
+		if e.complexity.CommentEdge.Node == nil {
+			break
+		}
+
+		return e.complexity.CommentEdge.Node(childComplexity), true
+
+	case "CommentEdge.replies":
+		if e.complexity.CommentEdge.Replies == nil {
+			break
+		}
+
+		return e.complexity.CommentEdge.Replies(childComplexity), true
+
+	case "CommentReplies.comments":
+		if e.complexity.CommentReplies.Comments == nil {
+			break
+		}
+
+		return e.complexity.CommentReplies.Comments(childComplexity), true
+
+	case "CommentReplies.hasMore":
+		if e.complexity.CommentReplies.HasMore == nil {
+			break
+		}
+
+		return e.complexity.CommentReplies.HasMore(childComplexity), true
+
+	case "CommentReplies.parentId":
+		if e.complexity.CommentReplies.ParentID == nil {
+			break
+		}
+
+		return e.complexity.CommentReplies.ParentID(childComplexity), true
+
+	case "CommentSearchEdge.highlight":
+		if e.complexity.CommentSearchEdge.Highlight == nil {
+			break
+		}
+
+		return e.complexity.CommentSearchEdge.Highlight(childComplexity), true
+
+	case "CommentSearchEdge.node":
+		if e.complexity.CommentSearchEdge.Node == nil {
+			break
+		}
+
+		return e.complexity.CommentSearchEdge.Node(childComplexity), true
+
+	case "CommentSearchEdge.score":
+		if e.complexity.CommentSearchEdge.Score == nil {
+			break
+		}
+
+		return e.complexity.CommentSearchEdge.Score(childComplexity), true
+
+	case "CommentSearchResults.edges":
+		if e.complexity.CommentSearchResults.Edges == nil {
+			break
+		}
+
+		return e.complexity.CommentSearchResults.Edges(childComplexity), true
+
+	case "CreateCommentPayload.ancestors":
+		if e.complexity.CreateCommentPayload.Ancestors == nil {
+			break
+		}
+
+		return e.complexity.CreateCommentPayload.Ancestors(childComplexity), true
+
+	case "CreateCommentPayload.comment":
+		if e.complexity.CreateCommentPayload.Comment == nil {
+			break
+		}
+
+		return e.complexity.CreateCommentPayload.Comment(childComplexity), true
+
+	case "CreateCommentPayload.cursor":
+		if e.complexity.CreateCommentPayload.Cursor == nil {
+			break
+		}
+
+		return e.complexity.CreateCommentPayload.Cursor(childComplexity), true
+
+	case "CreateCommentPayload.depth":
+		if e.complexity.CreateCommentPayload.Depth == nil {
+			break
+		}
+
+		return e.complexity.CreateCommentPayload.Depth(childComplexity), true
+
+	case "CreateCommentPayload.userErrors":
+		if e.complexity.CreateCommentPayload.UserErrors == nil {
+			break
+		}
+
+		return e.complexity.CreateCommentPayload.UserErrors(childComplexity), true
+
+	case "DepthCount.count":
+		if e.complexity.DepthCount.Count == nil {
+			break
+		}
+
+		return e.complexity.DepthCount.Count(childComplexity), true
+
+	case "DepthCount.depth":
+		if e.complexity.DepthCount.Depth == nil {
+			break
+		}
+
+		return e.complexity.DepthCount.Depth(childComplexity), true
+
+	case "Mutation.addReaction":
+		if e.complexity.Mutation.AddReaction == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_addReaction_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.AddReaction(childComplexity, args["commentId"].(string), args["type"].(domain.ReactionType)), true
+
+	case "Mutation.approveComment":
+		if e.complexity.Mutation.ApproveComment == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_approveComment_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.ApproveComment(childComplexity, args["id"].(string)), true
+
+	case "Mutation.blockAuthorOnPost":
+		if e.complexity.Mutation.BlockAuthorOnPost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_blockAuthorOnPost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.BlockAuthorOnPost(childComplexity, args["postId"].(string), args["authorId"].(string), args["blocked"].(*bool)), true
+
+	case "Mutation.createComment":
+		if e.complexity.Mutation.CreateComment == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_createComment_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.CreateComment(childComplexity, args["input"].(model.NewComment)), true
+
+	case "Mutation.createPost":
+		if e.complexity.Mutation.CreatePost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_createPost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.CreatePost(childComplexity, args["input"].(model.NewPost)), true
+
+	case "Mutation.createPostWithComments":
+		if e.complexity.Mutation.CreatePostWithComments == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_createPostWithComments_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.CreatePostWithComments(childComplexity, args["post"].(model.NewPost), args["comments"].([]*model.NewComment)), true
+
+	case "Mutation.deletePost":
+		if e.complexity.Mutation.DeletePost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_deletePost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.DeletePost(childComplexity, args["id"].(string)), true
+
+	case "Mutation.followPost":
+		if e.complexity.Mutation.FollowPost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_followPost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.FollowPost(childComplexity, args["postId"].(string), args["channel"].(*domain.NotificationChannel)), true
+
+	case "Mutation.lockThread":
+		if e.complexity.Mutation.LockThread == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_lockThread_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.LockThread(childComplexity, args["commentId"].(string), args["locked"].(bool)), true
+
+	case "Mutation.mergePosts":
+		if e.complexity.Mutation.MergePosts == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_mergePosts_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.MergePosts(childComplexity, args["sourceId"].(string), args["targetId"].(string)), true
+
+	case "Mutation.publishPost":
+		if e.complexity.Mutation.PublishPost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_publishPost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.PublishPost(childComplexity, args["id"].(string)), true
+
+	case "Mutation.rejectComment":
+		if e.complexity.Mutation.RejectComment == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_rejectComment_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.RejectComment(childComplexity, args["id"].(string)), true
+
+	case "Mutation.reportComment":
+		if e.complexity.Mutation.ReportComment == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_reportComment_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.ReportComment(childComplexity, args["commentId"].(string), args["reason"].(*string)), true
+
+	case "Mutation.setCommentHidden":
+		if e.complexity.Mutation.SetCommentHidden == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_setCommentHidden_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.SetCommentHidden(childComplexity, args["id"].(string), args["hidden"].(bool)), true
+
+	case "Mutation.setDefaultCommentOrder":
+		if e.complexity.Mutation.SetDefaultCommentOrder == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_setDefaultCommentOrder_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.SetDefaultCommentOrder(childComplexity, args["postId"].(string), args["order"].(domain.CommentOrder)), true
+
+	case "Mutation.toggleComments":
+		if e.complexity.Mutation.ToggleComments == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_toggleComments_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.ToggleComments(childComplexity, args["postId"].(string), args["enable"].(bool)), true
+
+	case "Mutation.toggleCommentsBulk":
+		if e.complexity.Mutation.ToggleCommentsBulk == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_toggleCommentsBulk_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.ToggleCommentsBulk(childComplexity, args["postIds"].([]string), args["enable"].(bool)), true
+
+	case "Mutation.toggleCommentsForAuthor":
+		if e.complexity.Mutation.ToggleCommentsForAuthor == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_toggleCommentsForAuthor_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.ToggleCommentsForAuthor(childComplexity, args["authorId"].(string), args["enable"].(bool)), true
+
+	case "Mutation.unfollowPost":
+		if e.complexity.Mutation.UnfollowPost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_unfollowPost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.UnfollowPost(childComplexity, args["postId"].(string)), true
+
+	case "Mutation.updateComment":
+		if e.complexity.Mutation.UpdateComment == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_updateComment_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.UpdateComment(childComplexity, args["id"].(string), args["content"].(model.CommentContent)), true
+
+	case "Mutation.updatePost":
+		if e.complexity.Mutation.UpdatePost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_updatePost_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.UpdatePost(childComplexity, args["id"].(string), args["input"].(model.UpdatePostInput)), true
+
+	case "PageInfo.endCursor":
+		if e.complexity.PageInfo.EndCursor == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.EndCursor(childComplexity), true
+
+	case "PageInfo.hasNextPage":
+		if e.complexity.PageInfo.HasNextPage == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.HasNextPage(childComplexity), true
+
+	case "PageInfo.hasPreviousPage":
+		if e.complexity.PageInfo.HasPreviousPage == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.HasPreviousPage(childComplexity), true
+
+	case "PageInfo.startCursor":
+		if e.complexity.PageInfo.StartCursor == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.StartCursor(childComplexity), true
+
+	case "Post.authorId":
+		if e.complexity.Post.AuthorID == nil {
+			break
+		}
+
+		return e.complexity.Post.AuthorID(childComplexity), true
+
+	case "Post.canComment":
+		if e.complexity.Post.CanComment == nil {
+			break
+		}
+
+		return e.complexity.Post.CanComment(childComplexity), true
+
+	case "Post.commentCount":
+		if e.complexity.Post.CommentCount == nil {
+			break
+		}
+
+		return e.complexity.Post.CommentCount(childComplexity), true
+
+	case "Post.comments":
+		if e.complexity.Post.Comments == nil {
+			break
+		}
+
+		args, err := ec.field_Post_comments_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Post.Comments(childComplexity, args["limit"].(*int), args["cursor"].(*string), args["last"].(*int), args["before"].(*string), args["orderBy"].(*domain.CommentOrder)), true
+
+	case "Post.commentsEnabled":
+		if e.complexity.Post.CommentsEnabled == nil {
+			break
+		}
+
+		return e.complexity.Post.CommentsEnabled(childComplexity), true
+
+	case "Post.content":
+		if e.complexity.Post.Content == nil {
+			break
+		}
+
+		return e.complexity.Post.Content(childComplexity), true
+
+	case "Post.createdAt":
+		if e.complexity.Post.CreatedAt == nil {
+			break
+		}
+
+		return e.complexity.Post.CreatedAt(childComplexity), true
+
+	case "Post.defaultCommentOrder":
+		if e.complexity.Post.DefaultCommentOrder == nil {
+			break
+		}
+
+		return e.complexity.Post.DefaultCommentOrder(childComplexity), true
+
+	case "Post.depthHistogram":
+		if e.complexity.Post.DepthHistogram == nil {
+			break
+		}
+
+		return e.complexity.Post.DepthHistogram(childComplexity), true
+
+	case "Post.id":
+		if e.complexity.Post.ID == nil {
+			break
+		}
+
+		return e.complexity.Post.ID(childComplexity), true
+
+	case "Post.lastCommentAt":
+		if e.complexity.Post.LastCommentAt == nil {
+			break
+		}
+
+		return e.complexity.Post.LastCommentAt(childComplexity), true
+
+	case "Post.published":
+		if e.complexity.Post.Published == nil {
+			break
+		}
+
+		return e.complexity.Post.Published(childComplexity), true
+
+	case "Post.title":
+		if e.complexity.Post.Title == nil {
+			break
+		}
+
+		return e.complexity.Post.Title(childComplexity), true
+
+	case "PostConnection.edges":
+		if e.complexity.PostConnection.Edges == nil {
+			break
+		}
+
+		return e.complexity.PostConnection.Edges(childComplexity), true
+
+	case "PostConnection.pageInfo":
+		if e.complexity.PostConnection.PageInfo == nil {
+			break
+		}
+
+		return e.complexity.PostConnection.PageInfo(childComplexity), true
+
+	case "PostEdge.cursor":
+		if e.complexity.PostEdge.Cursor == nil {
+			break
+		}
+
+		return e.complexity.PostEdge.Cursor(childComplexity), true
+
+	case "PostEdge.node":
+		if e.complexity.PostEdge.Node == nil {
+			break
+		}
+
+		return e.complexity.PostEdge.Node(childComplexity), true
+
+	case "PostStats.commentCount":
+		if e.complexity.PostStats.CommentCount == nil {
+			break
+		}
+
+		return e.complexity.PostStats.CommentCount(childComplexity), true
+
+	case "PostStats.lastActivityAt":
+		if e.complexity.PostStats.LastActivityAt == nil {
+			break
+		}
+
+		return e.complexity.PostStats.LastActivityAt(childComplexity), true
+
+	case "PostStats.pendingCount":
+		if e.complexity.PostStats.PendingCount == nil {
+			break
+		}
+
+		return e.complexity.PostStats.PendingCount(childComplexity), true
+
+	case "PostStats.reportCount":
+		if e.complexity.PostStats.ReportCount == nil {
+			break
+		}
+
+		return e.complexity.PostStats.ReportCount(childComplexity), true
+
+	case "PostSubscription.channel":
+		if e.complexity.PostSubscription.Channel == nil {
+			break
+		}
+
+		return e.complexity.PostSubscription.Channel(childComplexity), true
 
-	Subscription struct {
-		CommentAdded func(childComplexity int, postID string) int
-	}
-}
+	case "PostSubscription.createdAt":
+		if e.complexity.PostSubscription.CreatedAt == nil {
+			break
+		}
 
-type executableSchema struct {
-	schema     *ast.Schema
-	resolvers  ResolverRoot
-	directives DirectiveRoot
-	complexity ComplexityRoot
-}
+		return e.complexity.PostSubscription.CreatedAt(childComplexity), true
 
-func (e *executableSchema) Schema() *ast.Schema {
-	if e.schema != nil {
-		return e.schema
-	}
-	return parsedSchema
-}
+	case "PostSubscription.postId":
+		if e.complexity.PostSubscription.PostID == nil {
+			break
+		}
 
-func (e *executableSchema) Complexity(typeName, field string, childComplexity int, rawArgs map[string]interface{}) (int, bool) {
-	ec := executionContext{nil, e, 0, 0, nil}
-	_ = ec
-	switch typeName + "." + field {
+		return e.complexity.PostSubscription.PostID(childComplexity), true
 
-	case "Comment.authorId":
-		if e.complexity.Comment.AuthorID == nil {
+	case "PostSubscription.userId":
+		if e.complexity.PostSubscription.UserID == nil {
 			break
 		}
 
-		return e.complexity.Comment.AuthorID(childComplexity), true
+		return e.complexity.PostSubscription.UserID(childComplexity), true
 
-	case "Comment.children":
-		if e.complexity.Comment.Children == nil {
+	case "Query.adminPosts":
+		if e.complexity.Query.AdminPosts == nil {
 			break
 		}
 
-		args, err := ec.field_Comment_children_args(context.TODO(), rawArgs)
+		args, err := ec.field_Query_adminPosts_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Comment.Children(childComplexity, args["limit"].(*int), args["cursor"].(*string)), true
+		return e.complexity.Query.AdminPosts(childComplexity, args["limit"].(*int), args["cursor"].(*string)), true
 
-	case "Comment.content":
-		if e.complexity.Comment.Content == nil {
+	case "Query.authorActivity":
+		if e.complexity.Query.AuthorActivity == nil {
 			break
 		}
 
-		return e.complexity.Comment.Content(childComplexity), true
+		args, err := ec.field_Query_authorActivity_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Comment.createdAt":
-		if e.complexity.Comment.CreatedAt == nil {
+		return e.complexity.Query.AuthorActivity(childComplexity, args["authorId"].(string), args["limit"].(*int), args["cursor"].(*string)), true
+
+	case "Query.childrenOf":
+		if e.complexity.Query.ChildrenOf == nil {
 			break
 		}
 
-		return e.complexity.Comment.CreatedAt(childComplexity), true
+		args, err := ec.field_Query_childrenOf_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Comment.id":
-		if e.complexity.Comment.ID == nil {
+		return e.complexity.Query.ChildrenOf(childComplexity, args["commentIds"].([]string), args["limitPerParent"].(*int)), true
+
+	case "Query.comment":
+		if e.complexity.Query.Comment == nil {
 			break
 		}
 
-		return e.complexity.Comment.ID(childComplexity), true
+		args, err := ec.field_Query_comment_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Comment.parent":
-		if e.complexity.Comment.Parent == nil {
+		return e.complexity.Query.Comment(childComplexity, args["id"].(string)), true
+
+	case "Query.commentsSince":
+		if e.complexity.Query.CommentsSince == nil {
 			break
 		}
 
-		return e.complexity.Comment.Parent(childComplexity), true
+		args, err := ec.field_Query_commentsSince_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Comment.postId":
-		if e.complexity.Comment.PostID == nil {
+		return e.complexity.Query.CommentsSince(childComplexity, args["postId"].(string), args["since"].(*time.Time), args["after"].(*string)), true
+
+	case "Query.commentsWithPreview":
+		if e.complexity.Query.CommentsWithPreview == nil {
 			break
 		}
 
-		return e.complexity.Comment.PostID(childComplexity), true
+		args, err := ec.field_Query_commentsWithPreview_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "CommentConnection.edges":
-		if e.complexity.CommentConnection.Edges == nil {
+		return e.complexity.Query.CommentsWithPreview(childComplexity, args["postId"].(string), args["limit"].(*int), args["cursor"].(*string), args["repliesPerComment"].(*int)), true
+
+	case "Query.flatComments":
+		if e.complexity.Query.FlatComments == nil {
 			break
 		}
 
-		return e.complexity.CommentConnection.Edges(childComplexity), true
+		args, err := ec.field_Query_flatComments_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "CommentConnection.pageInfo":
-		if e.complexity.CommentConnection.PageInfo == nil {
+		return e.complexity.Query.FlatComments(childComplexity, args["postId"].(string), args["limit"].(*int), args["cursor"].(*string)), true
+
+	case "Query.pendingComments":
+		if e.complexity.Query.PendingComments == nil {
 			break
 		}
 
-		return e.complexity.CommentConnection.PageInfo(childComplexity), true
+		args, err := ec.field_Query_pendingComments_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "CommentEdge.cursor":
-		if e.complexity.CommentEdge.Cursor == nil {
+		return e.complexity.Query.PendingComments(childComplexity, args["limit"].(*int), args["cursor"].(*string)), true
+
+	case "Query.post":
+		if e.complexity.Query.Post == nil {
 			break
 		}
 
-		return e.complexity.CommentEdge.Cursor(childComplexity), true
+		args, err := ec.field_Query_post_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "CommentEdge.node":
-		if e.complexity.CommentEdge.Node == nil {
+		return e.complexity.Query.Post(childComplexity, args["id"].(string)), true
+
+	case "Query.posts":
+		if e.complexity.Query.Posts == nil {
 			break
 		}
 
-		return e.complexity.CommentEdge.Node(childComplexity), true
+		args, err := ec.field_Query_posts_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Mutation.createComment":
-		if e.complexity.Mutation.CreateComment == nil {
+		return e.complexity.Query.Posts(childComplexity, args["limit"].(*int), args["offset"].(*int), args["commentsEnabled"].(*bool), args["orderBy"].(*model.PostOrder)), true
+
+	case "Query.postsConnection":
+		if e.complexity.Query.PostsConnection == nil {
 			break
 		}
 
-		args, err := ec.field_Mutation_createComment_args(context.TODO(), rawArgs)
+		args, err := ec.field_Query_postsConnection_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Mutation.CreateComment(childComplexity, args["input"].(model.NewComment)), true
+		return e.complexity.Query.PostsConnection(childComplexity, args["limit"].(*int), args["cursor"].(*string), args["commentsEnabled"].(*bool), args["orderBy"].(*model.PostOrder)), true
 
-	case "Mutation.createPost":
-		if e.complexity.Mutation.CreatePost == nil {
+	case "Query.recentComments":
+		if e.complexity.Query.RecentComments == nil {
 			break
 		}
 
-		args, err := ec.field_Mutation_createPost_args(context.TODO(), rawArgs)
+		args, err := ec.field_Query_recentComments_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Mutation.CreatePost(childComplexity, args["input"].(model.NewPost)), true
+		return e.complexity.Query.RecentComments(childComplexity, args["postIds"].([]string), args["limit"].(*int)), true
 
-	case "Mutation.toggleComments":
-		if e.complexity.Mutation.ToggleComments == nil {
+	case "Query.searchComments":
+		if e.complexity.Query.SearchComments == nil {
 			break
 		}
 
-		args, err := ec.field_Mutation_toggleComments_args(context.TODO(), rawArgs)
+		args, err := ec.field_Query_searchComments_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Mutation.ToggleComments(childComplexity, args["postId"].(string), args["enable"].(bool)), true
+		return e.complexity.Query.SearchComments(childComplexity, args["query"].(string), args["postId"].(*string), args["limit"].(*int)), true
 
-	case "PageInfo.endCursor":
-		if e.complexity.PageInfo.EndCursor == nil {
+	case "Query.serverInfo":
+		if e.complexity.Query.ServerInfo == nil {
 			break
 		}
 
-		return e.complexity.PageInfo.EndCursor(childComplexity), true
+		return e.complexity.Query.ServerInfo(childComplexity), true
 
-	case "PageInfo.hasNextPage":
-		if e.complexity.PageInfo.HasNextPage == nil {
+	case "Query.unansweredComments":
+		if e.complexity.Query.UnansweredComments == nil {
 			break
 		}
 
-		return e.complexity.PageInfo.HasNextPage(childComplexity), true
+		args, err := ec.field_Query_unansweredComments_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Post.authorId":
-		if e.complexity.Post.AuthorID == nil {
+		return e.complexity.Query.UnansweredComments(childComplexity, args["postId"].(string), args["byAuthorId"].(string), args["limit"].(*int), args["cursor"].(*string)), true
+
+	case "ReactionCount.count":
+		if e.complexity.ReactionCount.Count == nil {
 			break
 		}
 
-		return e.complexity.Post.AuthorID(childComplexity), true
+		return e.complexity.ReactionCount.Count(childComplexity), true
 
-	case "Post.comments":
-		if e.complexity.Post.Comments == nil {
+	case "ReactionCount.type":
+		if e.complexity.ReactionCount.Type == nil {
 			break
 		}
 
-		args, err := ec.field_Post_comments_args(context.TODO(), rawArgs)
-		if err != nil {
-			return 0, false
+		return e.complexity.ReactionCount.Type(childComplexity), true
+
+	case "ServerInfo.maxAttachments":
+		if e.complexity.ServerInfo.MaxAttachments == nil {
+			break
 		}
 
-		return e.complexity.Post.Comments(childComplexity, args["limit"].(*int), args["cursor"].(*string)), true
+		return e.complexity.ServerInfo.MaxAttachments(childComplexity), true
 
-	case "Post.commentsEnabled":
-		if e.complexity.Post.CommentsEnabled == nil {
+	case "ServerInfo.maxCommentDepth":
+		if e.complexity.ServerInfo.MaxCommentDepth == nil {
 			break
 		}
 
-		return e.complexity.Post.CommentsEnabled(childComplexity), true
+		return e.complexity.ServerInfo.MaxCommentDepth(childComplexity), true
 
-	case "Post.content":
-		if e.complexity.Post.Content == nil {
+	case "ServerInfo.maxCommentLength":
+		if e.complexity.ServerInfo.MaxCommentLength == nil {
 			break
 		}
 
-		return e.complexity.Post.Content(childComplexity), true
+		return e.complexity.ServerInfo.MaxCommentLength(childComplexity), true
 
-	case "Post.createdAt":
-		if e.complexity.Post.CreatedAt == nil {
+	case "ServerInfo.maxOffset":
+		if e.complexity.ServerInfo.MaxOffset == nil {
 			break
 		}
 
-		return e.complexity.Post.CreatedAt(childComplexity), true
+		return e.complexity.ServerInfo.MaxOffset(childComplexity), true
 
-	case "Post.id":
-		if e.complexity.Post.ID == nil {
+	case "ServerInfo.maxPageSize":
+		if e.complexity.ServerInfo.MaxPageSize == nil {
 			break
 		}
 
-		return e.complexity.Post.ID(childComplexity), true
+		return e.complexity.ServerInfo.MaxPageSize(childComplexity), true
 
-	case "Post.title":
-		if e.complexity.Post.Title == nil {
+	case "ServerInfo.subscriptionTransports":
+		if e.complexity.ServerInfo.SubscriptionTransports == nil {
 			break
 		}
 
-		return e.complexity.Post.Title(childComplexity), true
+		return e.complexity.ServerInfo.SubscriptionTransports(childComplexity), true
 
-	case "Query.post":
-		if e.complexity.Query.Post == nil {
+	case "ServerInfo.version":
+		if e.complexity.ServerInfo.Version == nil {
 			break
 		}
 
-		args, err := ec.field_Query_post_args(context.TODO(), rawArgs)
+		return e.complexity.ServerInfo.Version(childComplexity), true
+
+	case "Subscription.commentAdded":
+		if e.complexity.Subscription.CommentAdded == nil {
+			break
+		}
+
+		args, err := ec.field_Subscription_commentAdded_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Query.Post(childComplexity, args["id"].(string)), true
+		return e.complexity.Subscription.CommentAdded(childComplexity, args["postId"].(string), args["lastSeenCursor"].(*string)), true
 
-	case "Query.posts":
-		if e.complexity.Query.Posts == nil {
+	case "Subscription.commentMentioned":
+		if e.complexity.Subscription.CommentMentioned == nil {
 			break
 		}
 
-		args, err := ec.field_Query_posts_args(context.TODO(), rawArgs)
+		args, err := ec.field_Subscription_commentMentioned_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Query.Posts(childComplexity, args["limit"].(*int), args["offset"].(*int)), true
+		return e.complexity.Subscription.CommentMentioned(childComplexity, args["username"].(string)), true
 
-	case "Subscription.commentAdded":
-		if e.complexity.Subscription.CommentAdded == nil {
+	case "Subscription.commentsAdded":
+		if e.complexity.Subscription.CommentsAdded == nil {
 			break
 		}
 
-		args, err := ec.field_Subscription_commentAdded_args(context.TODO(), rawArgs)
+		args, err := ec.field_Subscription_commentsAdded_args(context.TODO(), rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Subscription.CommentAdded(childComplexity, args["postId"].(string)), true
+		return e.complexity.Subscription.CommentsAdded(childComplexity, args["postIds"].([]string)), true
+
+	case "Subscription.postAdded":
+		if e.complexity.Subscription.PostAdded == nil {
+			break
+		}
+
+		return e.complexity.Subscription.PostAdded(childComplexity), true
+
+	case "UserError.code":
+		if e.complexity.UserError.Code == nil {
+			break
+		}
+
+		return e.complexity.UserError.Code(childComplexity), true
+
+	case "UserError.field":
+		if e.complexity.UserError.Field == nil {
+			break
+		}
+
+		return e.complexity.UserError.Field(childComplexity), true
+
+	case "UserError.limit":
+		if e.complexity.UserError.Limit == nil {
+			break
+		}
+
+		return e.complexity.UserError.Limit(childComplexity), true
+
+	case "UserError.message":
+		if e.complexity.UserError.Message == nil {
+			break
+		}
+
+		return e.complexity.UserError.Message(childComplexity), true
 
 	}
 	return 0, false
@@ -344,8 +1404,10 @@ func (e *executableSchema) Exec(ctx context.Context) graphql.ResponseHandler {
 	rc := graphql.GetOperationContext(ctx)
 	ec := executionContext{rc, e, 0, 0, make(chan graphql.DeferredResult)}
 	inputUnmarshalMap := graphql.BuildUnmarshalerMap(
+		ec.unmarshalInputAttachmentInput,
 		ec.unmarshalInputNewComment,
 		ec.unmarshalInputNewPost,
+		ec.unmarshalInputUpdatePostInput,
 	)
 	first := true
 
@@ -461,6 +1523,16 @@ func (ec *executionContext) introspectType(name string) (*introspection.Type, er
 
 var sources = []*ast.Source{
 	{Name: "../schema.graphqls", Input: `scalar Time
+# Текст комментария: непустой и не длиннее MAX_COMMENT_LENGTH символов (см. serverInfo.maxCommentLength).
+# Нарушение отклоняет запрос ошибкой с extensions code TOO_LONG или BLANK.
+scalar CommentContent
+
+# Добавляет тег к полю сгенерированной Go-структуры. Теги validate проверяются
+# в резолверах через go-playground/validator (см. graph/validate.go).
+directive @goTag(key: String!, value: String) on INPUT_FIELD_DEFINITION | FIELD_DEFINITION
+
+# Обрезает пробелы по краям строки и проверяет ее до вызова резолвера
+directive @constraint(maxLength: Int, notBlank: Boolean) on INPUT_FIELD_DEFINITION
 
 type Post {
     id: ID!
@@ -468,9 +1540,29 @@ type Post {
     content: String!
     authorId: String!
     commentsEnabled: Boolean!
+    # false у черновика: в списках постов его видят только автор и модераторы,
+    # подписчики postAdded получают пост в момент публикации (publishPost)
+    published: Boolean!
+    # Порядок комментариев, выбранный автором поста (setDefaultCommentOrder)
+    defaultCommentOrder: CommentOrder!
     createdAt: Time!
-    # Пагинированный список комментариев верхнего уровня
-    comments(limit: Int = 10, cursor: ID): CommentConnection!
+    # Время последнего комментария, null - комментариев еще нет
+    lastCommentAt: Time
+    # Число комментариев поста вместе с ответами; скрытые учитываются только для модераторов.
+    # В запросе post(id:) считается тем же обращением к хранилищу, что и сам пост,
+    # в списках постов - одним батчем через Dataloader.
+    commentCount: Int!
+    # Число комментариев на каждом уровне вложенности (0 - верхний уровень) по возрастанию глубины,
+    # с тем же учетом скрытых, что и commentCount. Уровни без комментариев не выводятся.
+    depthHistogram: [DepthCount!]!
+    # Может ли текущий пользователь оставить комментарий верхнего уровня: комментарии включены,
+    # сервис не в режиме только для чтения, и пользователь не заблокирован в посте.
+    # Закрытые ветки (lockThread) проверяются при ответе и здесь не учитываются.
+    canComment: Boolean!
+    # Пагинированный список комментариев верхнего уровня. limit/cursor листают вперед,
+    # last/before - назад от конца списка; смешивать аргументы двух направлений нельзя.
+    # Без orderBy комментарии идут в порядке defaultCommentOrder поста.
+    comments(limit: Int = 10, cursor: ID, last: Int, before: ID, orderBy: CommentOrder): CommentConnection!
 }
 
 type Comment {
@@ -479,54 +1571,412 @@ type Comment {
     authorId: String!
     content: String!
     createdAt: Time!
-    # Родительский комментарий
+    # Пользователи, упомянутые в тексте через @handle
+    mentions: [String!]!
+    # Вложения в порядке, заданном автором; пустой список, если их нет
+    attachments: [Attachment!]!
+    # Количество реакций каждого типа (загружается батчем через Dataloader)
+    reactions: [ReactionCount!]!
+    # Скрыт по жалобам пользователей. Скрытые комментарии в списках видят только модераторы.
+    hidden: Boolean!
+    # Статус премодерации. Не одобренные комментарии в списках видят только модераторы.
+    status: CommentStatus!
+    # Ветка закрыта модератором: на комментарий и его ответы любой глубины нельзя ответить
+    locked: Boolean!
+    # Пост, к которому относится комментарий (загружается батчем через Dataloader)
+    post: Post!
+    # ID родительского комментария, null у корневых. В отличие от parent не требует загрузки
+    # родителя - например, для сборки дерева из flatComments.
+    parentId: ID
+    # Родительский комментарий; null, если он не виден пользователю (не одобрен или скрыт)
     parent: Comment
+    # Цепочка предков от ближайшего родителя до корневого комментария без невидимых пользователю
+    ancestors: [Comment!]!
     # Дочерние комментарии (также с пагинацией)
     children(limit: Int = 5, cursor: ID): CommentConnection!
+    # Другие комментарии того же уровня (без самого комментария)
+    siblings(limit: Int = 10, cursor: ID): CommentConnection
+}
+
+enum CommentStatus {
+    # Ждет проверки модератором (при включенной премодерации)
+    PENDING
+    APPROVED
+    REJECTED
+}
+
+enum ReactionType {
+    LIKE
+    DISLIKE
+}
+
+type ReactionCount {
+    type: ReactionType!
+    count: Int!
+}
+
+# Изображение или ссылка, для которой клиент показывает превью
+type Attachment {
+    url: String!
+    type: AttachmentType!
+    title: String
+}
+
+enum AttachmentType {
+    IMAGE
+    LINK
+}
+
+type DepthCount {
+    depth: Int!
+    count: Int!
 }
 
 # Структуры для пагинации
 type CommentConnection {
     edges: [CommentEdge!]!
     pageInfo: PageInfo!
+    # Число комментариев списка на всех страницах. Считается только для Post.comments
+    # (тем же запросом к БД, что и страница), в остальных списках - null.
+    totalCount: Int
 }
 
 type CommentEdge {
     cursor: ID!
     node: Comment!
+    # Первые ответы на комментарий. Загружаются только в commentsWithPreview, в остальных списках - null.
+    replies: CommentReplies
+}
+
+# Ответы на один комментарий в результате childrenOf и commentsWithPreview
+type CommentReplies {
+    parentId: ID!
+    comments: [Comment!]!
+    # Есть ли ответы сверх limitPerParent - их можно получить через Comment.children
+    hasMore: Boolean!
+}
+
+type PostConnection {
+    edges: [PostEdge!]!
+    pageInfo: PageInfo!
+}
+
+type PostEdge {
+    cursor: ID!
+    node: Post!
+}
+
+type AdminPostConnection {
+    edges: [AdminPostEdge!]!
+    pageInfo: PageInfo!
+}
+
+type AdminPostEdge {
+    cursor: ID!
+    node: Post!
+    stats: PostStats!
+}
+
+# Статистика поста для модераторов. Скрытые и не одобренные комментарии учитываются.
+type PostStats {
+    # Комментарии поста вместе с ответами
+    commentCount: Int!
+    # Комментарии, ждущие премодерации
+    pendingCount: Int!
+    # Жалобы на комментарии поста
+    reportCount: Int!
+    # Время последнего события: создания поста, комментария или жалобы
+    lastActivityAt: Time!
+}
+
+# Результаты поиска комментариев. Выдача ограничена limit лучшими совпадениями, без пагинации.
+type CommentSearchResults {
+    edges: [CommentSearchEdge!]!
+}
+
+type CommentSearchEdge {
+    node: Comment!
+    # Текст комментария, экранированный для HTML, с совпадениями в <mark>...</mark>
+    highlight: String!
+    # Релевантность: больше - выше в выдаче. Шкала зависит от хранилища,
+    # сравнивать значения можно только в пределах одного ответа.
+    score: Float!
+}
+
+# Запись ленты активности автора
+union ActivityItem = Post | Comment
+
+type ActivityConnection {
+    edges: [ActivityEdge!]!
+    pageInfo: PageInfo!
+}
+
+type ActivityEdge {
+    cursor: ID!
+    node: ActivityItem!
 }
 
 type PageInfo {
     hasNextPage: Boolean!
+    # Есть ли записи перед страницей; вычисляется только при пагинации назад
+    hasPreviousPage: Boolean!
+    startCursor: ID
     endCursor: ID
 }
 
+enum CommentOrder {
+    # Сначала старые комментарии, в порядке создания
+    OLDEST_FIRST
+    # Сначала новые комментарии
+    NEWEST_FIRST
+    # Сначала комментарии с наибольшим рейтингом (лайки минус дизлайки), при равенстве в порядке создания.
+    # Курсор хранит рейтинг на момент выдачи: если рейтинг комментария меняется между страницами,
+    # комментарий может повториться или быть пропущен, но постраничный обход всегда продвигается
+    TOP
+}
+
+enum PostOrder {
+    # Сначала новые посты
+    CREATED_DESC
+    # Сначала посты со свежими комментариями; посты без комментариев - по времени создания
+    LAST_ACTIVITY_DESC
+}
+
 type Query {
-    posts(limit: Int = 10, offset: Int = 0): [Post!]!
+    # commentsEnabled - необязательный фильтр по состоянию комментариев.
+    # Черновики в списках постов видят только их авторы и модераторы.
+    posts(limit: Int = 10, offset: Int = 0, commentsEnabled: Boolean, orderBy: PostOrder = CREATED_DESC): [Post!]!
+        @deprecated(reason: "Use postsConnection: offset pagination gets slower with every page.")
+    # Курсорная пагинация постов; курсор кодирует ключ сортировки, поэтому глубокие страницы не дорожают
+    postsConnection(limit: Int = 10, cursor: ID, commentsEnabled: Boolean, orderBy: PostOrder = CREATED_DESC): PostConnection!
     post(id: ID!): Post
+    # Комментарий по ID, например для постоянной ссылки на комментарий
+    # Не одобренные и скрытые комментарии видят только модераторы, остальным возвращается NOT_FOUND
+    comment(id: ID!): Comment
+    # Очередь премодерации: комментарии всех постов со статусом PENDING, сначала самые старые.
+    # Только для модераторов.
+    pendingComments(limit: Int = 20, cursor: ID): CommentConnection!
+    # Все посты, включая черновики, сначала новые, вместе со статистикой комментариев и жалоб.
+    # Статистика всей страницы считается одним запросом к хранилищу. Только для модераторов.
+    adminPosts(limit: Int = 10, cursor: ID): AdminPostConnection!
+    # Входящие автора: комментарии верхнего уровня поста, на которые byAuthorId еще не ответил,
+    # от старых к новым. Ответом считается прямой ответ на комментарий, даже скрытый или ждущий
+    # премодерации. Комментарии самого byAuthorId в список не попадают.
+    unansweredComments(postId: ID!, byAuthorId: ID!, limit: Int = 10, cursor: ID): CommentConnection!
+    # Самые новые комментарии (включая ответы) из нескольких постов, от новых к старым -
+    # например, для ленты по отслеживаемым постам. Постов - не больше serverInfo.maxPageSize.
+    recentComments(postIds: [ID!]!, limit: Int = 20): [Comment!]!
+    # Комментарии поста любой глубины, созданные строго после since, от старых к новым -
+    # для клиентов, которые опрашивают сервер вместо подписки. Возвращается не больше
+    # serverInfo.maxPageSize комментариев; если список заполнен целиком, следующий запрос
+    # делается с after - ID последнего комментария (или курсором): так не теряются
+    # комментарии с тем же временем создания. Нужно передать ровно одно из since и after.
+    commentsSince(postId: ID!, since: Time, after: ID): [Comment!]!
+    # Посты и комментарии автора вместе, сначала новые. Скрытые и не одобренные
+    # комментарии видны только модераторам, черновики - автору и модераторам.
+    authorActivity(authorId: String!, limit: Int = 10, cursor: ID): ActivityConnection!
+    # Первые ответы на каждый из комментариев - для раскрытия нескольких веток одним запросом.
+    # Результат идет в порядке commentIds, повторы ID схлопываются, для несуществующих
+    # комментариев возвращается пустой список.
+    childrenOf(commentIds: [ID!]!, limitPerParent: Int = 5): [CommentReplies!]!
+    # Все комментарии поста любой глубины одним плоским списком в порядке обхода дерева:
+    # родитель перед своими ответами, ответы одного уровня по времени создания. Клиент
+    # собирает дерево по parentId за один проход. Ветки скрытых комментариев видны только модераторам.
+    flatComments(postId: ID!, limit: Int = 50, cursor: ID): CommentConnection!
+    # Комментарии верхнего уровня поста в порядке defaultCommentOrder, у каждого в edge.replies -
+    # первые repliesPerComment ответов. Ответы всей страницы загружаются одним запросом, поэтому
+    # для превью веток не нужен отдельный запрос children на каждый комментарий.
+    commentsWithPreview(postId: ID!, limit: Int = 10, cursor: ID, repliesPerComment: Int = 3): CommentConnection!
+    # Поиск комментариев, содержащих все слова query (не длиннее 200 символов), от самых
+    # релевантных. postId ограничивает поиск одним постом. Скрытые и не одобренные комментарии
+    # находят только модераторы. Частота поиска ограничена для каждого пользователя
+    # (SEARCH_RATE_LIMIT), превышение отклоняет запрос ошибкой с extensions code RATE_LIMITED.
+    searchComments(query: String!, postId: ID, limit: Int = 20): CommentSearchResults!
+    # Сведения о сервере для определения возможностей API клиентом
+    serverInfo: ServerInfo!
+}
+
+type ServerInfo {
+    # Версия схемы в формате semver
+    version: String!
+    # Максимальная длина комментария в символах
+    maxCommentLength: Int!
+    # Максимальная глубина вложенности комментариев
+    maxCommentDepth: Int!
+    # Максимальное число вложений комментария
+    maxAttachments: Int!
+    # Максимальный размер страницы; большие limit обрезаются до него
+    maxPageSize: Int!
+    # Максимальный offset для устаревшего запроса posts
+    maxOffset: Int!
+    # Транспорты, по которым доступны подписки
+    subscriptionTransports: [SubscriptionTransport!]!
+}
+
+enum SubscriptionTransport {
+    WEBSOCKET
+    SSE
 }
 
 input NewPost {
-    title: String!
-    content: String!
-    authorId: String!
+    title: String! @constraint(notBlank: true, maxLength: 255)
+    content: String! @constraint(notBlank: true)
+    authorId: String! @goTag(key: "validate", value: "notblank,max=255")
+    # false создает пост с закрытыми комментариями без отдельного toggleComments
+    commentsEnabled: Boolean = true
 }
 
-input NewComment {
+enum NotificationChannel {
+    EMAIL
+    PUSH
+}
+
+# Постоянная подписка на новые комментарии поста (в отличие от GraphQL-подписок).
+type PostSubscription {
     postId: ID!
-    parentId: ID # Может быть null для комментариев верхнего уровня
-    authorId: String!
-    content: String!
+    userId: String!
+    channel: NotificationChannel!
+    createdAt: Time!
+}
+
+input UpdatePostInput {
+    title: String @constraint(notBlank: true, maxLength: 255)
+    content: String @constraint(notBlank: true)
+    commentsEnabled: Boolean
+}
+
+input NewComment {
+    postId: ID! @goTag(key: "validate", value: "uuid")
+    parentId: ID @goTag(key: "validate", value: "omitempty,uuid") # Может быть null для комментариев верхнего уровня
+    authorId: String! @goTag(key: "validate", value: "notblank,max=255")
+    content: CommentContent!
+    # Необязательные вложения, не больше serverInfo.maxAttachments. Ссылка должна быть
+    # абсолютным http или https URL, иначе комментарий отклоняется с userErrors.
+    attachments: [AttachmentInput!]
+}
+
+input AttachmentInput {
+    url: String!
+    type: AttachmentType!
+    title: String
+}
+
+# Ошибка валидации, которую клиент может исправить сам.
+# Системные ошибки по-прежнему возвращаются как ошибки GraphQL верхнего уровня.
+type UserError {
+    # Поле ввода, к которому относится ошибка, если применимо
+    field: String
+    message: String!
+    code: UserErrorCode!
+    # Значение нарушенного ограничения (например, максимальная длина), если применимо
+    limit: Int
+}
+
+enum UserErrorCode {
+    NOT_FOUND
+    COMMENTS_DISABLED
+    # Родитель или один из его предков закрыт для ответов (lockThread)
+    THREAD_LOCKED
+    # Автор заблокирован в посте (blockAuthorOnPost)
+    AUTHOR_BLOCKED
+    TOO_LONG
+    BLANK
+    DUPLICATE
+    # Значение в неверном формате, например ID не является UUID
+    INVALID
+}
+
+type CreateCommentPayload {
+    # null, если комментарий не создан из-за ошибок валидации
+    comment: Comment
+    userErrors: [UserError!]!
+    # Поля ниже позволяют клиенту сразу вставить комментарий на место в дереве
+    # без повторного запроса; null вместе с comment.
+    # Курсор комментария в списке ответов родителя (или корневых комментариев поста)
+    cursor: ID
+    # Глубина вложенности: 0 у корневого комментария
+    depth: Int
+    # Цепочка предков от ближайшего родителя до корневого комментария
+    ancestors: [Comment!]
 }
 
 type Mutation {
     createPost(input: NewPost!): Post!
+    # Создает пост вместе с первыми комментариями одной транзакцией - для импорта и постов
+    # по шаблону. Если хотя бы один комментарий не прошел проверку, не сохраняется ничего.
+    # postId комментариев игнорируется: все они относятся к новому посту и создаются
+    # верхнего уровня, parentId не поддерживается.
+    createPostWithComments(post: NewPost!, comments: [NewComment!]!): Post
+    # Удаляет пост со всеми комментариями. Доступно автору поста и модераторам.
+    # Активные подписки на пост завершаются с ошибкой.
+    deletePost(id: ID!): Boolean!
+    # Объединяет дубликаты: переносит все комментарии поста sourceId в пост targetId (ответы
+    # сохраняют ветки, комментарии встают по времени создания) и удаляет sourceId одной
+    # транзакцией. Подписчики sourceId переходят к targetId, активные подписки commentAdded
+    # на sourceId завершаются с ошибкой. Доступно только модераторам.
+    mergePosts(sourceId: ID!, targetId: ID!): Post
+    # Атомарно изменяет переданные поля поста, остальные остаются как есть.
+    # Доступно автору поста и модераторам.
+    updatePost(id: ID!, input: UpdatePostInput!): Post!
+    # Публикует черновик. Доступно автору поста и модераторам; опубликованный пост
+    # возвращается без изменений.
+    publishPost(id: ID!): Post!
+    # Задает порядок комментариев поста по умолчанию. Доступно автору поста и модераторам.
+    setDefaultCommentOrder(postId: ID!, order: CommentOrder!): Post!
+    # Сокращение для updatePost с одним полем commentsEnabled. Доступно автору поста и модераторам.
     toggleComments(postId: ID!, enable: Boolean!): Post!
-    createComment(input: NewComment!): Comment!
+    # Переключает комментарии на всех постах автора. Только для модераторов.
+    # Возвращает количество затронутых постов.
+    toggleCommentsForAuthor(authorId: String!, enable: Boolean!): Int!
+    # Переключает комментарии на наборе постов одной операцией. Только для модераторов.
+    # Несуществующие ID пропускаются и перечисляются в ошибке NOT_FOUND рядом с результатом.
+    toggleCommentsBulk(postIds: [ID!]!, enable: Boolean!): [Post!]!
+    # Запрещает автору комментировать пост (blocked: false снимает запрет). Доступно автору поста
+    # и модераторам. Уже написанные комментарии автора остаются.
+    blockAuthorOnPost(postId: ID!, authorId: String!, blocked: Boolean = true): Post!
+    createComment(input: NewComment!): CreateCommentPayload!
+    # Изменяет текст комментария. Доступно автору комментария и модераторам.
+    updateComment(id: ID!, content: CommentContent!): Comment!
+    # Ставит реакцию от текущего пользователя, заменяя его предыдущую реакцию.
+    addReaction(commentId: ID!, type: ReactionType!): Comment!
+    # Жалоба текущего пользователя на комментарий. Повторная жалоба не увеличивает счетчик.
+    # После превышения порога жалоб комментарий скрывается до проверки модератором.
+    reportComment(commentId: ID!, reason: String): Comment!
+    # Скрывает или возвращает комментарий. Только для модераторов; возврат сбрасывает жалобы.
+    setCommentHidden(id: ID!, hidden: Boolean!): Comment!
+    # Закрывает ветку комментария для новых ответов или открывает ее. Только для модераторов.
+    # Остальные ветки поста остаются открытыми.
+    lockThread(commentId: ID!, locked: Boolean!): Comment!
+    # Одобряет комментарий, ожидающий премодерации. Только для модераторов.
+    # Подписчики поста получают комментарий в момент одобрения.
+    approveComment(id: ID!): Comment!
+    # Отклоняет комментарий. Только для модераторов.
+    rejectComment(id: ID!): Comment!
+    # Подписывает текущего пользователя на уведомления о новых комментариях поста,
+    # которые доставляются, даже когда он не в сети. Повторный вызов меняет канал.
+    followPost(postId: ID!, channel: NotificationChannel = EMAIL): PostSubscription!
+    # Отменяет подписку. Возвращает false, если подписки не было.
+    unfollowPost(postId: ID!): Boolean!
 }
 
 type Subscription {
-    commentAdded(postId: ID!): Comment!
+    # Новые видимые комментарии поста любой глубины в порядке создания.
+    # lastSeenCursor - курсор (или ID) последнего комментария, полученного до обрыва связи:
+    # сначала приходят все комментарии, созданные после него, затем подписка продолжается
+    # вживую без пропусков на стыке, и каждый комментарий приходит не больше одного раза.
+    # Если на сервере задан SUBSCRIPTION_MAX_LIFETIME, по его истечении подписка завершается
+    # с ошибкой, и клиент переподписывается с lastSeenCursor.
+    commentAdded(postId: ID!, lastSeenCursor: ID): Comment!
+    # Новые видимые комментарии нескольких постов одним потоком - вместо отдельной подписки
+    # commentAdded на каждый пост. Пост события указан в поле postId комментария. Порядок
+    # создания соблюдается в пределах поста. Удаление или объединение любого из постов
+    # завершает подписку с ошибкой. Не больше 50 постов, восстановления по курсору нет.
+    commentsAdded(postIds: [ID!]!): Comment!
+    # Все новые посты сайта (живая лента)
+    postAdded: Post!
+    # Комментарии, в которых упомянут пользователь с указанным handle.
+    # Пользователь может подписаться только на свой handle, модератор - на любой.
+    commentMentioned(username: String!): Comment!
 }`, BuiltIn: false},
 }
 var parsedSchema = gqlparser.MustLoadSchema(sources...)
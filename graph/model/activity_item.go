@@ -0,0 +1,6 @@
+package model
+
+// ActivityItem - элемент ленты активности автора: *domain.Post или *domain.Comment.
+// Union из схемы привязан к этому интерфейсу в gqlgen.yml, чтобы доменным типам
+// не требовались маркерные методы GraphQL.
+type ActivityItem interface{}
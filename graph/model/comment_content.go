@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// CommentContent - текст комментария во входных данных. Пустой и слишком длинный текст
+// отклоняется еще при разборе аргументов, до резолвера и хранилища, с той же ошибкой
+// валидации, что вернуло бы хранилище: проверка - та же storage.Options.PrepareCommentContent,
+// поэтому длина и пустота считаются уже после нормализации. Хранилище проверяет текст повторно.
+type CommentContent string
+
+type commentOptionsKey struct{}
+
+// WithCommentOptions задает для операций с контекстом ctx правила CommentContent - настройки
+// хранилища, которыми оно нормализует и проверяет текст. Без них действуют storage.NewOptions().
+func WithCommentOptions(ctx context.Context, opts storage.Options) context.Context {
+	return context.WithValue(ctx, commentOptionsKey{}, opts)
+}
+
+// WithMaxCommentLength задает лимит длины CommentContent при остальных настройках по умолчанию.
+func WithMaxCommentLength(ctx context.Context, maxLength int) context.Context {
+	return WithCommentOptions(ctx, storage.NewOptions(storage.WithMaxCommentLength(maxLength)))
+}
+
+func (c *CommentContent) UnmarshalGQLContext(ctx context.Context, v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("CommentContent must be a string, got %T", v)
+	}
+	opts, ok := ctx.Value(commentOptionsKey{}).(storage.Options)
+	if !ok {
+		opts = storage.NewOptions()
+	}
+	// Нормализованный текст не сохраняется: хранилище нормализует его само
+	if _, err := opts.PrepareCommentContent(s); err != nil {
+		return err
+	}
+	*c = CommentContent(s)
+	return nil
+}
+
+func (c CommentContent) MarshalGQLContext(ctx context.Context, w io.Writer) error {
+	graphql.MarshalString(string(c)).MarshalGQL(w)
+	return nil
+}
@@ -3,42 +3,282 @@
 package model
 
 import (
+	"fmt"
+	"io"
+	"strconv"
+
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
 )
 
+type ActivityConnection struct {
+	Edges    []*ActivityEdge `json:"edges"`
+	PageInfo *PageInfo       `json:"pageInfo"`
+}
+
+type ActivityEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   ActivityItem `json:"node"`
+}
+
+type AdminPostConnection struct {
+	Edges    []*AdminPostEdge `json:"edges"`
+	PageInfo *PageInfo        `json:"pageInfo"`
+}
+
+type AdminPostEdge struct {
+	Cursor string             `json:"cursor"`
+	Node   *domain.Post       `json:"node"`
+	Stats  *storage.PostStats `json:"stats"`
+}
+
+type AttachmentInput struct {
+	URL   string                `json:"url"`
+	Type  domain.AttachmentType `json:"type"`
+	Title *string               `json:"title,omitempty"`
+}
+
 type CommentConnection struct {
-	Edges    []*CommentEdge `json:"edges"`
-	PageInfo *PageInfo      `json:"pageInfo"`
+	Edges      []*CommentEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount *int           `json:"totalCount,omitempty"`
 }
 
 type CommentEdge struct {
-	Cursor string          `json:"cursor"`
-	Node   *domain.Comment `json:"node"`
+	Cursor  string          `json:"cursor"`
+	Node    *domain.Comment `json:"node"`
+	Replies *CommentReplies `json:"replies,omitempty"`
+}
+
+type CommentReplies struct {
+	ParentID string            `json:"parentId"`
+	Comments []*domain.Comment `json:"comments"`
+	HasMore  bool              `json:"hasMore"`
+}
+
+type CommentSearchEdge struct {
+	Node      *domain.Comment `json:"node"`
+	Highlight string          `json:"highlight"`
+	Score     float64         `json:"score"`
+}
+
+type CommentSearchResults struct {
+	Edges []*CommentSearchEdge `json:"edges"`
+}
+
+type CreateCommentPayload struct {
+	Comment    *domain.Comment   `json:"comment,omitempty"`
+	UserErrors []*UserError      `json:"userErrors"`
+	Cursor     *string           `json:"cursor,omitempty"`
+	Depth      *int              `json:"depth,omitempty"`
+	Ancestors  []*domain.Comment `json:"ancestors,omitempty"`
+}
+
+type DepthCount struct {
+	Depth int `json:"depth"`
+	Count int `json:"count"`
 }
 
 type Mutation struct {
 }
 
 type NewComment struct {
-	PostID   string  `json:"postId"`
-	ParentID *string `json:"parentId,omitempty"`
-	AuthorID string  `json:"authorId"`
-	Content  string  `json:"content"`
+	PostID      string             `json:"postId" validate:"uuid"`
+	ParentID    *string            `json:"parentId,omitempty" validate:"omitempty,uuid"`
+	AuthorID    string             `json:"authorId" validate:"notblank,max=255"`
+	Content     CommentContent     `json:"content"`
+	Attachments []*AttachmentInput `json:"attachments,omitempty"`
 }
 
 type NewPost struct {
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	AuthorID string `json:"authorId"`
+	Title           string `json:"title"`
+	Content         string `json:"content"`
+	AuthorID        string `json:"authorId" validate:"notblank,max=255"`
+	CommentsEnabled *bool  `json:"commentsEnabled,omitempty"`
 }
 
 type PageInfo struct {
-	HasNextPage bool    `json:"hasNextPage"`
-	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
+type PostConnection struct {
+	Edges    []*PostEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type PostEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *domain.Post `json:"node"`
 }
 
 type Query struct {
 }
 
+type ReactionCount struct {
+	Type  domain.ReactionType `json:"type"`
+	Count int                 `json:"count"`
+}
+
+type ServerInfo struct {
+	Version                string                  `json:"version"`
+	MaxCommentLength       int                     `json:"maxCommentLength"`
+	MaxCommentDepth        int                     `json:"maxCommentDepth"`
+	MaxAttachments         int                     `json:"maxAttachments"`
+	MaxPageSize            int                     `json:"maxPageSize"`
+	MaxOffset              int                     `json:"maxOffset"`
+	SubscriptionTransports []SubscriptionTransport `json:"subscriptionTransports"`
+}
+
 type Subscription struct {
 }
+
+type UpdatePostInput struct {
+	Title           *string `json:"title,omitempty"`
+	Content         *string `json:"content,omitempty"`
+	CommentsEnabled *bool   `json:"commentsEnabled,omitempty"`
+}
+
+type UserError struct {
+	Field   *string       `json:"field,omitempty"`
+	Message string        `json:"message"`
+	Code    UserErrorCode `json:"code"`
+	Limit   *int          `json:"limit,omitempty"`
+}
+
+type PostOrder string
+
+const (
+	PostOrderCreatedDesc      PostOrder = "CREATED_DESC"
+	PostOrderLastActivityDesc PostOrder = "LAST_ACTIVITY_DESC"
+)
+
+var AllPostOrder = []PostOrder{
+	PostOrderCreatedDesc,
+	PostOrderLastActivityDesc,
+}
+
+func (e PostOrder) IsValid() bool {
+	switch e {
+	case PostOrderCreatedDesc, PostOrderLastActivityDesc:
+		return true
+	}
+	return false
+}
+
+func (e PostOrder) String() string {
+	return string(e)
+}
+
+func (e *PostOrder) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrder", str)
+	}
+	return nil
+}
+
+func (e PostOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SubscriptionTransport string
+
+const (
+	SubscriptionTransportWebsocket SubscriptionTransport = "WEBSOCKET"
+	SubscriptionTransportSse       SubscriptionTransport = "SSE"
+)
+
+var AllSubscriptionTransport = []SubscriptionTransport{
+	SubscriptionTransportWebsocket,
+	SubscriptionTransportSse,
+}
+
+func (e SubscriptionTransport) IsValid() bool {
+	switch e {
+	case SubscriptionTransportWebsocket, SubscriptionTransportSse:
+		return true
+	}
+	return false
+}
+
+func (e SubscriptionTransport) String() string {
+	return string(e)
+}
+
+func (e *SubscriptionTransport) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SubscriptionTransport(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SubscriptionTransport", str)
+	}
+	return nil
+}
+
+func (e SubscriptionTransport) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type UserErrorCode string
+
+const (
+	UserErrorCodeNotFound         UserErrorCode = "NOT_FOUND"
+	UserErrorCodeCommentsDisabled UserErrorCode = "COMMENTS_DISABLED"
+	UserErrorCodeThreadLocked     UserErrorCode = "THREAD_LOCKED"
+	UserErrorCodeAuthorBlocked    UserErrorCode = "AUTHOR_BLOCKED"
+	UserErrorCodeTooLong          UserErrorCode = "TOO_LONG"
+	UserErrorCodeBlank            UserErrorCode = "BLANK"
+	UserErrorCodeDuplicate        UserErrorCode = "DUPLICATE"
+	UserErrorCodeInvalid          UserErrorCode = "INVALID"
+)
+
+var AllUserErrorCode = []UserErrorCode{
+	UserErrorCodeNotFound,
+	UserErrorCodeCommentsDisabled,
+	UserErrorCodeThreadLocked,
+	UserErrorCodeAuthorBlocked,
+	UserErrorCodeTooLong,
+	UserErrorCodeBlank,
+	UserErrorCodeDuplicate,
+	UserErrorCodeInvalid,
+}
+
+func (e UserErrorCode) IsValid() bool {
+	switch e {
+	case UserErrorCodeNotFound, UserErrorCodeCommentsDisabled, UserErrorCodeThreadLocked, UserErrorCodeAuthorBlocked, UserErrorCodeTooLong, UserErrorCodeBlank, UserErrorCodeDuplicate, UserErrorCodeInvalid:
+		return true
+	}
+	return false
+}
+
+func (e UserErrorCode) String() string {
+	return string(e)
+}
+
+func (e *UserErrorCode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserErrorCode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserErrorCode", str)
+	}
+	return nil
+}
+
+func (e UserErrorCode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
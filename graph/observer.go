@@ -0,0 +1,353 @@
+package graph
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+// CommentObserver хранит каналы для подписчиков на комментарии.
+type CommentObserver struct {
+	mu sync.RWMutex
+	//          map[postID] map[subscriberID] subscriber
+	subs   map[string]map[string]*subscriber
+	fanOut *FanOut
+	onDrop func(subscription string)
+
+	queueMu sync.Mutex
+	// queues - события поста, ожидающие рассылки. Пост есть в карте, пока его события
+	// рассылает горутина drain, - так у каждого поста не больше одной рассылки за раз.
+	queues map[string][]*domain.Comment
+}
+
+// commentEventBuffer - сколько событий подписчик может не прочитать, прежде чем
+// следующие начнут пропускаться. Быстро созданные подряд комментарии не теряются.
+const commentEventBuffer = 16
+
+// subscriber - одна активная подписка на комментарии поста или нескольких постов.
+type subscriber struct {
+	id string
+	// subscription - имя подписки в схеме для учета пропущенных событий.
+	subscription string
+	ch           chan *domain.Comment
+	// done закрывается, когда подписка принудительно завершена сервером (например, пост удален).
+	done chan struct{}
+	// err - причина завершения, доступна после закрытия done.
+	err       error
+	closeOnce sync.Once
+}
+
+// close завершает подписку с причиной err. Подписчик commentsAdded зарегистрирован
+// под несколькими постами, поэтому close может вызываться повторно - действует первый вызов.
+func (s *subscriber) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// NewCommentObserver - конструктор для нашего наблюдателя.
+func NewCommentObserver(opts ...ObserverOption) *CommentObserver {
+	options := newObserverOptions(opts)
+	return &CommentObserver{
+		subs:   make(map[string]map[string]*subscriber),
+		fanOut: options.fanOut,
+		onDrop: options.onDrop,
+		queues: make(map[string][]*domain.Comment),
+	}
+}
+
+// subscribe регистрирует нового подписчика на комментарии поста.
+func (o *CommentObserver) subscribe(postID string) *subscriber {
+	return o.register("commentAdded", []string{postID})
+}
+
+// subscribeMany регистрирует одного подписчика на комментарии нескольких постов: события
+// всех постов приходят в один канал. Порядок создания сохраняется в пределах поста,
+// события разных постов могут перемежаться. Завершение любого из постов (closePost)
+// завершает подписку целиком.
+func (o *CommentObserver) subscribeMany(postIDs []string) *subscriber {
+	return o.register("commentsAdded", postIDs)
+}
+
+func (o *CommentObserver) register(subscription string, postIDs []string) *subscriber {
+	sub := &subscriber{
+		id:           uuid.NewString(),
+		subscription: subscription,
+		ch:           make(chan *domain.Comment, commentEventBuffer),
+		done:         make(chan struct{}),
+	}
+
+	o.mu.Lock()
+	for _, postID := range postIDs {
+		if o.subs[postID] == nil {
+			o.subs[postID] = make(map[string]*subscriber)
+		}
+		o.subs[postID][sub.id] = sub
+	}
+	o.mu.Unlock()
+
+	return sub
+}
+
+// unsubscribe удаляет подписчика. Повторный вызов безопасен.
+func (o *CommentObserver) unsubscribe(postID, subID string) {
+	o.unsubscribeMany([]string{postID}, subID)
+}
+
+// unsubscribeMany удаляет подписчика из всех постов, под которыми он зарегистрирован
+// subscribeMany. Повторный вызов безопасен.
+func (o *CommentObserver) unsubscribeMany(postIDs []string, subID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, postID := range postIDs {
+		if postSubs, ok := o.subs[postID]; ok {
+			delete(postSubs, subID)
+			if len(postSubs) == 0 {
+				delete(o.subs, postID)
+			}
+		}
+	}
+}
+
+// publish асинхронно рассылает комментарий подписчикам его поста. События одного поста
+// рассылаются по очереди в порядке вызовов publish, поэтому подписчик получает комментарии
+// в порядке создания, даже если они созданы почти одновременно. Разные посты рассылаются
+// параллельно в пределах лимита FanOut.
+func (o *CommentObserver) publish(c *domain.Comment) {
+	o.mu.RLock()
+	hasSubs := len(o.subs[c.PostID]) > 0
+	o.mu.RUnlock()
+	if !hasSubs {
+		return
+	}
+
+	o.queueMu.Lock()
+	pending, running := o.queues[c.PostID]
+	o.queues[c.PostID] = append(pending, c)
+	o.queueMu.Unlock()
+
+	// Уже работающая рассылка поста заберет событие сама. Go вызывается без блокировок:
+	// он может ждать освобождения места в FanOut.
+	if !running {
+		o.fanOut.Go(func() { o.drain(c.PostID) })
+	}
+}
+
+// drain рассылает накопившиеся события поста, пока очередь не опустеет.
+func (o *CommentObserver) drain(postID string) {
+	for {
+		o.queueMu.Lock()
+		batch := o.queues[postID]
+		if len(batch) == 0 {
+			delete(o.queues, postID)
+			o.queueMu.Unlock()
+			return
+		}
+		o.queues[postID] = nil
+		o.queueMu.Unlock()
+
+		for _, c := range batch {
+			o.deliver(c)
+		}
+	}
+}
+
+// deliver отправляет событие текущим подписчикам поста.
+// Под блокировкой только копируется список подписчиков, сама рассылка идет
+// без блокировки и не мешает параллельным подпискам и отпискам.
+func (o *CommentObserver) deliver(c *domain.Comment) {
+	o.mu.RLock()
+	postSubs := o.subs[c.PostID]
+	targets := make([]*subscriber, 0, len(postSubs))
+	for _, sub := range postSubs {
+		targets = append(targets, sub)
+	}
+	o.mu.RUnlock()
+
+	// Отписавшийся после копирования подписчик может получить еще одно событие
+	// в свой буфер - канал не закрывается, поэтому это безопасно.
+	for _, sub := range targets {
+		select {
+		case sub.ch <- c:
+		default:
+			// Клиент не успевает читать - пропускаем событие и учитываем пропуск
+			o.onDrop(sub.subscription)
+		}
+	}
+}
+
+// closePost завершает все подписки на пост с указанной причиной.
+// Подписчики получают err через subscriber.err после закрытия subscriber.done.
+func (o *CommentObserver) closePost(postID string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, sub := range o.subs[postID] {
+		sub.close(err)
+	}
+	delete(o.subs, postID)
+}
+
+// CloseAll завершает все подписки на комментарии, например при остановке сервера.
+// Каналы событий не закрываются - в них еще может писать publish; подписку завершает
+// закрытие subscriber.done, после которого горутина резолвера закрывает канал клиента.
+// Подписчики удаляются из subs под той же блокировкой, поэтому повторное завершение
+// (CloseAll, closePost или unsubscribe из горутины резолвера) безопасно.
+func (o *CommentObserver) CloseAll() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, postSubs := range o.subs {
+		for _, sub := range postSubs {
+			sub.close(errServerShutdown)
+		}
+	}
+	o.subs = make(map[string]map[string]*subscriber)
+}
+
+// PostObserver рассылает новые посты подписчикам ленты всего сайта.
+// В отличие от CommentObserver, подписка не привязана к конкретному посту.
+type PostObserver struct {
+	mu sync.RWMutex
+	//   map[subscriberID] channel
+	subs   map[string]chan *domain.Post
+	fanOut *FanOut
+	onDrop func(subscription string)
+}
+
+// NewPostObserver - конструктор наблюдателя за новыми постами.
+func NewPostObserver(opts ...ObserverOption) *PostObserver {
+	options := newObserverOptions(opts)
+	return &PostObserver{
+		subs:   make(map[string]chan *domain.Post),
+		fanOut: options.fanOut,
+		onDrop: options.onDrop,
+	}
+}
+
+// subscribe регистрирует подписчика и возвращает его ID и канал событий.
+func (o *PostObserver) subscribe() (string, chan *domain.Post) {
+	id := uuid.NewString()
+	ch := make(chan *domain.Post, 1)
+
+	o.mu.Lock()
+	o.subs[id] = ch
+	o.mu.Unlock()
+
+	return id, ch
+}
+
+// unsubscribe удаляет подписчика.
+func (o *PostObserver) unsubscribe(id string) {
+	o.mu.Lock()
+	delete(o.subs, id)
+	o.mu.Unlock()
+}
+
+// publish асинхронно рассылает пост всем подписчикам.
+// Как и в CommentObserver, под блокировкой только копируется список каналов.
+func (o *PostObserver) publish(p *domain.Post) {
+	o.mu.RLock()
+	targets := make([]chan *domain.Post, 0, len(o.subs))
+	for _, ch := range o.subs {
+		targets = append(targets, ch)
+	}
+	o.mu.RUnlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	o.fanOut.Go(func() {
+		for _, ch := range targets {
+			select {
+			case ch <- p:
+			default:
+				// Клиент не успевает читать - пропускаем событие
+				o.onDrop("postAdded")
+			}
+		}
+	})
+}
+
+// MentionObserver уведомляет пользователей о комментариях, в которых они упомянуты.
+type MentionObserver struct {
+	mu sync.RWMutex
+	//   map[handle] map[subscriberID] channel
+	subs   map[string]map[string]chan *domain.Comment
+	fanOut *FanOut
+	onDrop func(subscription string)
+}
+
+// NewMentionObserver - конструктор наблюдателя за упоминаниями.
+func NewMentionObserver(opts ...ObserverOption) *MentionObserver {
+	options := newObserverOptions(opts)
+	return &MentionObserver{
+		subs:   make(map[string]map[string]chan *domain.Comment),
+		fanOut: options.fanOut,
+		onDrop: options.onDrop,
+	}
+}
+
+// subscribe регистрирует подписчика на упоминания handle.
+func (o *MentionObserver) subscribe(handle string) (string, chan *domain.Comment) {
+	id := uuid.NewString()
+	ch := make(chan *domain.Comment, 1)
+
+	o.mu.Lock()
+	if o.subs[handle] == nil {
+		o.subs[handle] = make(map[string]chan *domain.Comment)
+	}
+	o.subs[handle][id] = ch
+	o.mu.Unlock()
+
+	return id, ch
+}
+
+// unsubscribe удаляет подписчика.
+func (o *MentionObserver) unsubscribe(handle, id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if handleSubs, ok := o.subs[handle]; ok {
+		delete(handleSubs, id)
+		if len(handleSubs) == 0 {
+			delete(o.subs, handle)
+		}
+	}
+}
+
+// publish асинхронно рассылает комментарий всем упомянутым в нем пользователям.
+func (o *MentionObserver) publish(c *domain.Comment) {
+	if len(c.Mentions) == 0 {
+		return
+	}
+
+	o.mu.RLock()
+	var targets []chan *domain.Comment
+	for _, handle := range c.Mentions {
+		for _, ch := range o.subs[handle] {
+			targets = append(targets, ch)
+		}
+	}
+	o.mu.RUnlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	o.fanOut.Go(func() {
+		for _, ch := range targets {
+			select {
+			case ch <- c:
+			default:
+				// Клиент не успевает читать - пропускаем событие
+				o.onDrop("commentMentioned")
+			}
+		}
+	})
+}
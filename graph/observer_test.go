@@ -0,0 +1,227 @@
+package graph
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+// BenchmarkCommentObserver_Publish измеряет публикацию при большом числе подписчиков на один пост
+// с параллельными публикациями.
+func BenchmarkCommentObserver_Publish(b *testing.B) {
+	for _, n := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			o := NewCommentObserver()
+			for i := 0; i < n; i++ {
+				o.subscribe("post-1")
+			}
+			c := &domain.Comment{ID: "c-1", PostID: "post-1"}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					o.publish(c)
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCommentObserver_SubscribeUnderPublish измеряет подписку/отписку,
+// пока другие горутины непрерывно публикуют в тот же пост.
+func BenchmarkCommentObserver_SubscribeUnderPublish(b *testing.B) {
+	o := NewCommentObserver()
+	for i := 0; i < 10000; i++ {
+		o.subscribe("post-1")
+	}
+	c := &domain.Comment{ID: "c-1", PostID: "post-1"}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					o.publish(c)
+				}
+			}
+		}()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sub := o.subscribe("post-1")
+		o.unsubscribe("post-1", sub.id)
+	}
+	b.StopTimer()
+
+	close(stop)
+	wg.Wait()
+}
+
+// BenchmarkCommentObserver_FanOut измеряет полную доставку одного события N подписчикам.
+func BenchmarkCommentObserver_FanOut(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			o := NewCommentObserver()
+			subs := make([]*subscriber, n)
+			for i := range subs {
+				subs[i] = o.subscribe("post-1")
+			}
+			c := &domain.Comment{ID: "c-1", PostID: "post-1"}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				o.publish(c)
+				for _, sub := range subs {
+					<-sub.ch
+				}
+			}
+		})
+	}
+}
+
+func TestCommentObserver_ConcurrentSubscribePublish(t *testing.T) {
+	o := NewCommentObserver()
+	c := &domain.Comment{ID: "c-1", PostID: "post-1"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			sub := o.subscribe("post-1")
+			o.unsubscribe("post-1", sub.id)
+		}()
+		go func() {
+			defer wg.Done()
+			o.publish(c)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFanOut_BoundsConcurrency(t *testing.T) {
+	f := NewFanOut(2)
+	release := make(chan struct{})
+	var running, maxRunning atomic.Int32
+	var wg sync.WaitGroup
+
+	submitted := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			f.Go(func() {
+				defer wg.Done()
+				n := running.Add(1)
+				for {
+					if m := maxRunning.Load(); n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+			})
+		}
+		close(submitted)
+	}()
+
+	// Третья рассылка ждет, пока не освободится место
+	select {
+	case <-submitted:
+		t.Fatal("Go must wait while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-submitted
+	wg.Wait()
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+// BenchmarkCommentObserver_PublishBurst публикует комментарии во много постов параллельно
+// и сообщает пиковое число горутин сверх исходного: оно ограничено лимитом FanOut
+// и числом публикующих горутин, а не числом событий.
+func BenchmarkCommentObserver_PublishBurst(b *testing.B) {
+	const limit = 8
+	o := NewCommentObserver(WithFanOut(NewFanOut(limit)))
+	for p := 0; p < 100; p++ {
+		for i := 0; i < 100; i++ {
+			o.subscribe(fmt.Sprintf("post-%d", p))
+		}
+	}
+
+	baseline := runtime.NumGoroutine()
+	var peak atomic.Int64
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			if n := int64(runtime.NumGoroutine() - baseline); n > peak.Load() {
+				peak.Store(n)
+			}
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	var seq atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := seq.Add(1)
+			o.publish(&domain.Comment{ID: fmt.Sprint(i), PostID: fmt.Sprintf("post-%d", i%100)})
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	<-sampled
+	b.ReportMetric(float64(peak.Load()), "peak-goroutines")
+}
+
+func TestObservers_DropHook(t *testing.T) {
+	drops := NewDropCounter()
+	hook := WithDropHook(drops.Inc)
+
+	comments := NewCommentObserver(hook)
+	comments.subscribe("post-1")
+	for i := 0; i < commentEventBuffer+2; i++ {
+		comments.publish(&domain.Comment{ID: fmt.Sprintf("c-%d", i), PostID: "post-1"})
+	}
+
+	posts := NewPostObserver(hook)
+	posts.subscribe()
+	mentions := NewMentionObserver(hook)
+	mentions.subscribe("alice")
+	for i := 0; i < 3; i++ {
+		posts.publish(&domain.Post{ID: fmt.Sprintf("p-%d", i)})
+		mentions.publish(&domain.Comment{ID: fmt.Sprintf("m-%d", i), Mentions: []string{"alice"}})
+	}
+
+	// Рассылка асинхронная, поэтому ждем, пока все лишние события будут учтены
+	want := map[string]int64{"commentAdded": 2, "postAdded": 2, "commentMentioned": 2}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, drops.Counts())
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "commentAdded=2 commentMentioned=2 postAdded=2", formatDropCounts(drops.Counts()))
+}
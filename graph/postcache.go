@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// PostStatusCache ненадолго запоминает, существует ли пост и открыт ли он для комментариев.
+// Подписка commentAdded проверяет пост при подключении, а createComment - перед записью, и
+// всплеск подключений или комментариев к популярному посту без кеша дает по запросу к хранилищу
+// на каждый из них. Dataloader здесь не помогает: каждая подписка - отдельное соединение со своим
+// контекстом, а мутации одного поста приходят в разных запросах. Одновременные проверки одного
+// поста выполняются одним запросом, результат живет ttl, поэтому изменение поста в обход процесса
+// видно не позже чем через ttl.
+type PostStatusCache struct {
+	store storage.Storage
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*postStatusEntry
+}
+
+// PostStatus - состояние поста, важное для проверок перед комментированием.
+type PostStatus struct {
+	Exists          bool
+	CommentsEnabled bool
+}
+
+// postStatusEntry - результат проверки одного поста.
+type postStatusEntry struct {
+	// ready закрывается, когда проверка завершена и поля ниже заполнены
+	ready   chan struct{}
+	status  PostStatus
+	err     error
+	expires time.Time
+}
+
+// NewPostStatusCache создает кеш поверх хранилища с временем жизни записи ttl.
+func NewPostStatusCache(store storage.Storage, ttl time.Duration) *PostStatusCache {
+	return &PostStatusCache{
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*postStatusEntry),
+	}
+}
+
+// Exists сообщает, существует ли пост.
+func (c *PostStatusCache) Exists(ctx context.Context, id string) (bool, error) {
+	status, err := c.Status(ctx, id)
+	return status.Exists, err
+}
+
+// Status возвращает состояние поста. Ошибки хранилища, кроме ErrPostNotFound, не кешируются.
+func (c *PostStatusCache) Status(ctx context.Context, id string) (PostStatus, error) {
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	if !ok || c.expired(e) {
+		e = &postStatusEntry{ready: make(chan struct{})}
+		c.entries[id] = e
+		c.sweep()
+		c.mu.Unlock()
+
+		// Проверку делит несколько запросов, поэтому отмена первого из них не должна ее прерывать
+		post, err := c.store.GetPostByID(context.WithoutCancel(ctx), id)
+		if err == nil {
+			e.status = PostStatus{Exists: true, CommentsEnabled: post.CommentsEnabled}
+		} else if !errors.Is(err, storage.ErrPostNotFound) {
+			e.err = err
+		}
+		e.expires = c.now().Add(c.ttl)
+		close(e.ready)
+		if e.err != nil {
+			c.mu.Lock()
+			if c.entries[id] == e {
+				delete(c.entries, id)
+			}
+			c.mu.Unlock()
+		}
+	} else {
+		c.mu.Unlock()
+	}
+
+	select {
+	case <-e.ready:
+		return e.status, e.err
+	case <-ctx.Done():
+		return PostStatus{}, ctx.Err()
+	}
+}
+
+// Forget удаляет запись о посте, например после его изменения или удаления через этот процесс.
+func (c *PostStatusCache) Forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// Reset удаляет все записи - для изменений, затрагивающих заранее неизвестный набор постов.
+func (c *PostStatusCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// expired сообщает, что проверка завершена и ее результат устарел. Вызывается под c.mu.
+func (c *PostStatusCache) expired(e *postStatusEntry) bool {
+	select {
+	case <-e.ready:
+		return !c.now().Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// sweep удаляет устаревшие записи. Вызывается под c.mu при каждом промахе, то есть не чаще
+// раза в ttl на пост, поэтому размер кеша ограничен постами, проверенными за последний ttl.
+func (c *PostStatusCache) sweep() {
+	for id, e := range c.entries {
+		if c.expired(e) {
+			delete(c.entries, id)
+		}
+	}
+}
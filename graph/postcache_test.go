@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+// postLookupStore считает обращения к GetPostByID и может задержать их до закрытия release.
+type postLookupStore struct {
+	storage.Storage
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *postLookupStore) GetPostByID(ctx context.Context, id string) (*domain.Post, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Storage.GetPostByID(ctx, id)
+}
+
+func TestPostStatusCache(t *testing.T) {
+	store := &postLookupStore{Storage: inmemory.New(), release: make(chan struct{})}
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewPostStatusCache(store, 5*time.Second)
+	cache.now = func() time.Time { return now }
+
+	// Одновременные подписки на один пост проверяют его одним запросом
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exists, err := cache.Exists(ctx, post.ID)
+			assert.NoError(t, err)
+			assert.True(t, exists)
+		}()
+	}
+	require.Eventually(t, func() bool { return store.calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(store.release)
+	wg.Wait()
+	assert.EqualValues(t, 1, store.calls.Load())
+
+	exists, err := cache.Exists(ctx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.EqualValues(t, 1, store.calls.Load())
+
+	// Удаление в обход кеша становится видно после ttl
+	require.NoError(t, store.DeletePost(ctx, post.ID))
+	exists, err = cache.Exists(ctx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	now = now.Add(5 * time.Second)
+	exists, err = cache.Exists(ctx, post.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.EqualValues(t, 2, store.calls.Load())
+
+	// Отсутствие поста тоже кешируется, Forget сбрасывает запись сразу
+	exists, err = cache.Exists(ctx, post.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.EqualValues(t, 2, store.calls.Load())
+	cache.Forget(post.ID)
+	_, err = cache.Exists(ctx, post.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, store.calls.Load())
+}
+
+func TestSubscriptionResolver_CommentAdded_PostCache(t *testing.T) {
+	r := newTestResolver(t)
+	store := &postLookupStore{Storage: r.Storage}
+	r.Storage = store
+	r.PostCache = NewPostStatusCache(store, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := r.Subscription().CommentAdded(ctx, post.ID, nil)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, store.calls.Load())
+
+	_, err = r.Subscription().CommentAdded(ctx, "missing", nil)
+	assert.EqualError(t, err, "post not found")
+}
+
+func TestMutationResolver_CreateComment_PostCache(t *testing.T) {
+	r := newTestResolver(t)
+	store := &postLookupStore{Storage: r.Storage}
+	r.Storage = store
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.CommentPostCache = NewPostStatusCache(store, 5*time.Second)
+	r.CommentPostCache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	create := func(content string) *model.CreateCommentPayload {
+		payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: model.CommentContent(content)})
+		require.NoError(t, err)
+		return payload
+	}
+
+	// Состояние поста читается один раз на ttl
+	for _, content := range []string{"a", "b", "c"} {
+		assert.Empty(t, create(content).UserErrors)
+	}
+	assert.EqualValues(t, 1, store.calls.Load())
+
+	// Выключение через мутацию сбрасывает кеш, отказ дальше не доходит до хранилища
+	_, err = r.Mutation().ToggleComments(auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser}), post.ID, false)
+	require.NoError(t, err)
+	calls := store.calls.Load()
+	for range 2 {
+		payload := create("d")
+		require.Len(t, payload.UserErrors, 1)
+		assert.Equal(t, model.UserErrorCodeCommentsDisabled, payload.UserErrors[0].Code)
+	}
+	assert.Equal(t, calls+1, store.calls.Load())
+
+	// Включение в обход процесса видно после ttl
+	enabled := true
+	_, err = store.UpdatePost(ctx, post.ID, storage.PostUpdate{CommentsEnabled: &enabled})
+	require.NoError(t, err)
+	assert.NotEmpty(t, create("d").UserErrors)
+	now = now.Add(5 * time.Second)
+	assert.Empty(t, create("d").UserErrors)
+
+	// Выключение в обход процесса хранилище замечает сразу, несмотря на кеш
+	disabled := false
+	_, err = store.UpdatePost(ctx, post.ID, storage.PostUpdate{CommentsEnabled: &disabled})
+	require.NoError(t, err)
+	payload := create("e")
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, model.UserErrorCodeCommentsDisabled, payload.UserErrors[0].Code)
+
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: "00000000-0000-0000-0000-000000000000", AuthorID: "user-2", Content: "f"})
+	require.NoError(t, err)
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, model.UserErrorCodeNotFound, payload.UserErrors[0].Code)
+}
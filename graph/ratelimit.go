@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited возвращается, когда пользователь превысил лимит частоты запросов.
+var ErrRateLimited = errors.New("rate limit exceeded, try again later")
+
+// codeRateLimited - код ошибки ErrRateLimited в extensions.
+const codeRateLimited = "RATE_LIMITED"
+
+// RateLimiter ограничивает частоту дорогой операции для каждого пользователя по алгоритму
+// token bucket: подряд проходит до burst запросов, дальше - не больше perMinute в минуту.
+// Лимит живет в памяти процесса, поэтому при нескольких экземплярах сервиса действует
+// на каждый экземпляр отдельно.
+type RateLimiter struct {
+	// interval - время, за которое в ведре появляется один токен
+	interval time.Duration
+	burst    int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+// rateBucket - токены одного пользователя на момент updated.
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter создает ограничитель на perMinute запросов в минуту с запасом burst.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	return &RateLimiter{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    max(burst, 1),
+		now:      time.Now,
+		buckets:  make(map[string]*rateBucket),
+	}
+}
+
+// Allow расходует токен пользователя key и сообщает, можно ли выполнить запрос.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: float64(l.burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(l.burst), b.tokens+float64(now.Sub(b.updated))/float64(l.interval))
+	b.updated = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep удаляет ведра, которые успели наполниться: они не отличаются от новых. Вызывается
+// под l.mu не чаще раза за время наполнения ведра, поэтому размер карты ограничен
+// пользователями, обращавшимися за это время.
+func (l *RateLimiter) sweep(now time.Time) {
+	full := l.interval * time.Duration(l.burst)
+	if now.Sub(l.lastSweep) < full {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
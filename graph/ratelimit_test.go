@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	// Запас расходуется подряд, дальше запросы ждут пополнения
+	assert.True(t, l.Allow("user-1"))
+	assert.True(t, l.Allow("user-1"))
+	assert.False(t, l.Allow("user-1"))
+	// У другого пользователя свое ведро
+	assert.True(t, l.Allow("user-2"))
+
+	now = now.Add(time.Second)
+	assert.True(t, l.Allow("user-1"))
+	assert.False(t, l.Allow("user-1"))
+
+	// Наполненные ведра удаляются, лимит после этого начинается заново
+	now = now.Add(time.Minute)
+	assert.True(t, l.Allow("user-3"))
+	assert.Len(t, l.buckets, 1)
+	assert.True(t, l.Allow("user-1"))
+	assert.True(t, l.Allow("user-1"))
+	assert.False(t, l.Allow("user-1"))
+}
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrReadOnly возвращается на мутации, пока сервис в режиме только для чтения.
+var ErrReadOnly = errors.New("service is read-only")
+
+// codeReadOnly - код ошибки ErrReadOnly в extensions.
+const codeReadOnly = "READ_ONLY"
+
+// ReadOnlyMode отклоняет мутации на время обслуживания (например, миграций),
+// пропуская запросы и подписки. Режим переключается без перезапуска.
+type ReadOnlyMode struct {
+	enabled atomic.Bool
+	// blocked - запрещенные мутации; пустой набор запрещает все
+	blocked map[string]struct{}
+	// presenter оформляет ErrReadOnly: middleware работает до выполнения операции,
+	// где презентер сервера еще недоступен
+	presenter graphql.ErrorPresenterFunc
+}
+
+// NewReadOnlyMode создает переключатель режима. blocked - имена мутаций
+// (например, "createComment"), которые запрещены в режиме; пустой список запрещает все.
+func NewReadOnlyMode(enabled bool, blocked []string) *ReadOnlyMode {
+	m := &ReadOnlyMode{blocked: make(map[string]struct{}, len(blocked)), presenter: ErrorPresenter}
+	for _, name := range blocked {
+		m.blocked[name] = struct{}{}
+	}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// SetEnabled включает или выключает режим.
+func (m *ReadOnlyMode) SetEnabled(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// SetErrorPresenter задает оформление ошибки отклоненной мутации - тот же презентер,
+// что и у сервера, чтобы ошибка была в одном формате с остальными.
+func (m *ReadOnlyMode) SetErrorPresenter(presenter graphql.ErrorPresenterFunc) {
+	m.presenter = presenter
+}
+
+// Enabled сообщает, включен ли режим.
+func (m *ReadOnlyMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Blocks сообщает, отклоняется ли сейчас мутация name: режим включен и она в списке запрещенных.
+func (m *ReadOnlyMode) Blocks(name string) bool {
+	return m.Enabled() && m.blocks(name)
+}
+
+// blocks сообщает, запрещена ли мутация name во включенном режиме.
+func (m *ReadOnlyMode) blocks(name string) bool {
+	if len(m.blocked) == 0 {
+		return true
+	}
+	_, ok := m.blocked[name]
+	return ok
+}
+
+// Middleware подключается через handler.Server.AroundOperations. Мутация, в которой есть
+// хотя бы одно запрещенное поле, отклоняется целиком - ни одно ее поле не выполняется.
+func (m *ReadOnlyMode) Middleware(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	if !m.Enabled() {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil || oc.Operation.Operation != ast.Mutation {
+		return next(ctx)
+	}
+
+	for _, field := range graphql.CollectFields(oc, oc.Operation.SelectionSet, []string{"Mutation"}) {
+		if field.Name != "__typename" && m.blocks(field.Name) {
+			return graphql.OneShot(&graphql.Response{Errors: gqlerror.List{m.presenter(ctx, ErrReadOnly)}})
+		}
+	}
+	return next(ctx)
+}
+
+// HTTPMiddleware отклоняет запросы к пишущему HTTP-маршруту (например, импорту) с 503,
+// пока режим блокирует name - имя маршрута в том же списке, что и мутации.
+func (m *ReadOnlyMode) HTTPMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.Blocks(name) {
+				http.Error(w, ErrReadOnly.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const createPostMutation = `mutation { createPost(input: {title: "Post", content: "Content", authorId: "user-1"}) { id } }`
+
+func newReadOnlyClient(t *testing.T, mode *ReadOnlyMode) *client.Client {
+	t.Helper()
+	srv := handler.NewDefaultServer(NewSchema(newTestResolver(t)))
+	srv.SetErrorPresenter(ErrorPresenter)
+	srv.AroundOperations(mode.Middleware)
+	return client.New(srv)
+}
+
+// errorCode возвращает code из extensions первой ошибки ответа.
+func errorCode(t *testing.T, err error) string {
+	t.Helper()
+	require.Error(t, err)
+	var errs []struct {
+		Extensions map[string]interface{} `json:"extensions"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(err.Error()), &errs))
+	require.NotEmpty(t, errs)
+	code, _ := errs[0].Extensions["code"].(string)
+	return code
+}
+
+func TestReadOnlyMode_Toggle(t *testing.T) {
+	mode := NewReadOnlyMode(true, nil)
+	c := newReadOnlyClient(t, mode)
+
+	var resp map[string]interface{}
+	err := c.Post(createPostMutation, &resp)
+	assert.Equal(t, codeReadOnly, errorCode(t, err))
+
+	// Чтение в режиме обслуживания продолжает работать
+	require.NoError(t, c.Post(`{ postsConnection { edges { node { id } } } }`, &resp))
+
+	mode.SetEnabled(false)
+	require.NoError(t, c.Post(createPostMutation, &resp))
+
+	mode.SetEnabled(true)
+	assert.Error(t, c.Post(createPostMutation, &resp))
+
+	// Пишущие HTTP-маршруты отклоняются вместе с мутациями
+	importHandler := mode.HTTPMiddleware("importThread")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	importRequest := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		importHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/posts/import", nil))
+		return rec
+	}
+	rec := importRequest()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrReadOnly.Error())
+
+	mode.SetEnabled(false)
+	assert.Equal(t, http.StatusCreated, importRequest().Code)
+}
+
+func TestReadOnlyMode_BlocksOnlyConfiguredMutations(t *testing.T) {
+	c := newReadOnlyClient(t, NewReadOnlyMode(true, []string{"createComment"}))
+
+	var created struct {
+		CreatePost struct{ ID string }
+	}
+	require.NoError(t, c.Post(createPostMutation, &created))
+
+	var resp map[string]interface{}
+	err := c.Post(`mutation($postId: ID!) {
+		createComment(input: {postId: $postId, authorId: "user-1", content: "text"}) { comment { id } }
+	}`, &resp, client.Var("postId", created.CreatePost.ID))
+	assert.Equal(t, codeReadOnly, errorCode(t, err))
+}
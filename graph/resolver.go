@@ -3,32 +3,439 @@
 package graph
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/config"
+	"github.com/UkralStul/graphql-comments-service/internal/dataloader"
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/mention"
+	"github.com/UkralStul/graphql-comments-service/internal/notify"
 	"github.com/UkralStul/graphql-comments-service/internal/storage"
-	"sync"
 )
 
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
-// CommentObserver хранит каналы для подписчиков на комментарии.
-type CommentObserver struct {
-	mu sync.RWMutex
-	//          map[postID] map[subscriberID] channel
-	subs map[string]map[string]chan *domain.Comment
-}
+// Значения limit по умолчанию. Должны совпадать с default-значениями аргументов в схеме,
+// это проверяет TestSchemaDefaultLimitsMatchResolvers.
+const (
+	defaultPostsLimit    = 10
+	defaultCommentsLimit = 10
+	defaultChildrenLimit = 5
+	defaultSiblingsLimit = 10
+	defaultPendingLimit  = 20
+	defaultRecentLimit   = 20
+	defaultActivityLimit = 10
+	defaultFlatLimit     = 50
+	defaultPreviewLimit  = 3
+	defaultSearchLimit   = 20
+)
 
-// NewCommentObserver - конструктор для нашего наблюдателя.
-func NewCommentObserver() *CommentObserver {
-	return &CommentObserver{
-		subs: make(map[string]map[string]chan *domain.Comment),
-	}
-}
+// maxReportReasonLength совпадает с размером колонки reports.reason в postgres.
+const maxReportReasonLength = 500
+
+// maxSearchQueryLength ограничивает поисковый запрос: длинный запрос дает много слов,
+// и каждое из них проверяется по всем найденным комментариям.
+const maxSearchQueryLength = 200
+
+// maxCommentsAddedPosts ограничивает число постов в одной подписке commentsAdded.
+const maxCommentsAddedPosts = 50
 
 // Resolver - это корневая структура резолвера.
 // Она содержит все зависимости, которые нужны для выполнения запросов.
 type Resolver struct {
-	Storage  storage.Storage
-	Observer *CommentObserver
+	Storage      storage.Storage
+	Observer     *CommentObserver
+	PostObserver *PostObserver
+	// MentionObserver уведомляет пользователей об упоминаниях
+	MentionObserver *MentionObserver
+	// Mentions - парсер упоминаний, nil отключает их обработку
+	Mentions *mention.Parser
+	// Notifier рассылает уведомления подписчикам постов, nil отключает рассылку
+	Notifier *notify.Dispatcher
+	// ReadOnly - режим только для чтения, по которому Post.canComment узнает о запрете мутаций.
+	// nil - режим не используется.
+	ReadOnly *ReadOnlyMode
+	// PostCache проверяет существование поста при подписке на комментарии,
+	// nil - каждая подписка обращается к хранилищу.
+	PostCache *PostStatusCache
+	// CommentPostCache проверяет пост перед созданием комментария и отклоняет комментарии
+	// к отсутствующим постам и постам с выключенными комментариями без обращения к хранилищу.
+	// Хранилище все равно проверяет пост под блокировкой его строки, поэтому кеш не пропустит
+	// комментарий к закрытому посту, но может ошибочно отклонить комментарий к посту, открытому
+	// в обход процесса меньше ttl назад. nil - строгий режим: пост проверяет только хранилище.
+	CommentPostCache *PostStatusCache
+	// SearchLimiter ограничивает частоту searchComments для каждого пользователя,
+	// nil - без ограничения
+	SearchLimiter *RateLimiter
+	Config        *config.Config
+}
+
+// Резолверы запрашивают у хранилища страницу с запасом: pageOverfetch элементов,
+// чтобы вычислить hasNextPage, а siblings - еще один на сам комментарий,
+// который исключается из выдачи.
+const (
+	pageOverfetch     = 1
+	siblingsOverfetch = pageOverfetch + 1
+)
+
+// MaxPageOverfetch - наибольший запас, с которым резолверы запрашивают страницу.
+// Лимит хранилища (storage.WithMaxPageSize) должен быть не меньше
+// MaxPageSize + MaxPageOverfetch, иначе полная страница потеряет hasNextPage.
+const MaxPageOverfetch = siblingsOverfetch
+
+// newCommentConnection строит страницу комментариев. comments должен содержать
+// на один элемент больше limit, если за страницей есть еще данные.
+func newCommentConnection(comments []*domain.Comment, limit int) *model.CommentConnection {
+	hasNextPage := len(comments) > limit
+	if hasNextPage {
+		comments = comments[:limit] // Убираем лишний элемент
+	}
+
+	conn := commentConnection(comments)
+	conn.PageInfo.HasNextPage = hasNextPage
+	return conn
+}
+
+// newBackwardCommentConnection строит страницу при пагинации назад. comments должен
+// содержать на один элемент больше limit (в начале), если перед страницей есть еще данные.
+// hasNextPage при пагинации назад не вычисляется и всегда false, как допускает Relay.
+func newBackwardCommentConnection(comments []*domain.Comment, limit int) *model.CommentConnection {
+	hasPreviousPage := len(comments) > limit
+	if hasPreviousPage {
+		comments = comments[len(comments)-limit:] // Убираем лишний элемент
+	}
+
+	conn := commentConnection(comments)
+	conn.PageInfo.HasPreviousPage = hasPreviousPage
+	return conn
+}
+
+// commentConnection строит ребра и курсоры границ страницы.
+func commentConnection(comments []*domain.Comment) *model.CommentConnection {
+	edges := make([]*model.CommentEdge, len(comments))
+	for i, c := range comments {
+		edges[i] = &model.CommentEdge{Node: c, Cursor: storage.EncodeCursor(c)}
+	}
+
+	pageInfo := &model.PageInfo{}
+	if len(edges) > 0 {
+		pageInfo.StartCursor = &edges[0].Cursor
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+	return &model.CommentConnection{Edges: edges, PageInfo: pageInfo}
+}
+
+// newPostConnection строит страницу постов. posts должен содержать
+// на один элемент больше limit, если за страницей есть еще данные.
+func newPostConnection(posts []*domain.Post, limit int, order storage.PostOrder) *model.PostConnection {
+	hasNextPage := len(posts) > limit
+	if hasNextPage {
+		posts = posts[:limit] // Убираем лишний элемент
+	}
+
+	edges := make([]*model.PostEdge, len(posts))
+	for i, p := range posts {
+		edges[i] = &model.PostEdge{Node: p, Cursor: storage.PostCursor(p, order).Encode()}
+	}
+
+	var startCursor, endCursor *string
+	if len(edges) > 0 {
+		startCursor = &edges[0].Cursor
+		endCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return &model.PostConnection{
+		Edges: edges,
+		PageInfo: &model.PageInfo{
+			HasNextPage: hasNextPage,
+			StartCursor: startCursor,
+			EndCursor:   endCursor,
+		},
+	}
+}
+
+// newActivityConnection строит страницу ленты активности. items должен содержать
+// на один элемент больше limit, если за страницей есть еще данные.
+func newActivityConnection(items []storage.ActivityItem, limit int) *model.ActivityConnection {
+	hasNextPage := len(items) > limit
+	if hasNextPage {
+		items = items[:limit] // Убираем лишний элемент
+	}
+
+	edges := make([]*model.ActivityEdge, len(items))
+	for i, item := range items {
+		edge := &model.ActivityEdge{Cursor: item.Cursor().Encode()}
+		if item.Post != nil {
+			edge.Node = item.Post
+		} else {
+			edge.Node = item.Comment
+		}
+		edges[i] = edge
+	}
+
+	pageInfo := &model.PageInfo{HasNextPage: hasNextPage}
+	if len(edges) > 0 {
+		pageInfo.StartCursor = &edges[0].Cursor
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+	return &model.ActivityConnection{Edges: edges, PageInfo: pageInfo}
+}
+
+// postCommentsPage загружает страницу комментариев верхнего уровня поста. Если клиент
+// запросил totalCount, их общее число приходит тем же обращением к хранилищу, иначе - nil.
+func (r *Resolver) postCommentsPage(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, *int, error) {
+	if !fieldSelected(ctx, "totalCount") {
+		comments, err := r.Storage.GetCommentsByPostID(ctx, postID, args)
+		return comments, nil, err
+	}
+	comments, total, err := r.Storage.GetCommentsPageByPostID(ctx, postID, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return comments, &total, nil
+}
+
+// commentReplies обрезает ответы родителя до limit. comments должен содержать на один
+// элемент больше limit, если за ними есть еще ответы.
+func commentReplies(parentID string, comments []*domain.Comment, limit int) *model.CommentReplies {
+	hasMore := len(comments) > limit
+	if hasMore {
+		comments = comments[:limit]
+	}
+	if comments == nil {
+		comments = []*domain.Comment{}
+	}
+	return &model.CommentReplies{ParentID: parentID, Comments: comments, HasMore: hasMore}
+}
+
+// postFilter строит фильтр списка постов: черновики видят модераторы и, свои, их авторы.
+func postFilter(ctx context.Context, commentsEnabled *bool, orderBy *model.PostOrder) storage.PostFilter {
+	filter := storage.PostFilter{CommentsEnabled: commentsEnabled}
+	if orderBy != nil {
+		filter.Order = storage.PostOrder(*orderBy)
+	}
+	if user := auth.ForContext(ctx); user != nil {
+		filter.IncludeDrafts = user.Role == auth.RoleModerator
+		filter.DraftsAuthorID = user.ID
+	}
+	return filter
+}
+
+// canSeeComment сообщает, можно ли показать комментарий текущему пользователю: не одобренные
+// и скрытые по жалобам комментарии видят только модераторы - и в списках, и по ID.
+func canSeeComment(ctx context.Context, c *domain.Comment) bool {
+	return c.Visible() || auth.IsModerator(ctx)
+}
+
+// attachmentsFromInput переводит вложения из входных данных мутации, nil остается nil.
+func attachmentsFromInput(input []*model.AttachmentInput) []domain.Attachment {
+	if input == nil {
+		return nil
+	}
+	attachments := make([]domain.Attachment, len(input))
+	for i, a := range input {
+		attachments[i] = domain.Attachment{URL: a.URL, Type: a.Type, Title: a.Title}
+	}
+	return attachments
+}
+
+// postFromInput строит пост из входных данных мутации.
+func postFromInput(input model.NewPost) *domain.Post {
+	// Явный null в commentsEnabled трактуется как значение по умолчанию
+	commentsEnabled := true
+	if input.CommentsEnabled != nil {
+		commentsEnabled = *input.CommentsEnabled
+	}
+	return &domain.Post{
+		Title:           input.Title,
+		Content:         input.Content,
+		AuthorID:        input.AuthorID,
+		CommentsEnabled: commentsEnabled,
+	}
+}
+
+// commentInputError относит ошибку валидации к i-му комментарию списка: поле
+// получает вид comments[i].content, чтобы клиент нашел нужный элемент формы.
+func commentInputError(i int, err *storage.ValidationError) *storage.ValidationError {
+	return &storage.ValidationError{
+		Field: fmt.Sprintf("comments[%d].%s", i, err.Field),
+		Code:  err.Code,
+		Limit: err.Limit,
+		Err:   err.Err,
+	}
+}
+
+// errMixedPaginationDirection возвращается, когда в одном запросе заданы аргументы
+// пагинации вперед (limit/cursor) и назад (last/before): по Relay такой запрос не определен.
+var errMixedPaginationDirection = errors.New("limit and cursor cannot be combined with last and before")
+
+// errCommentsSinceBounds возвращается, если в commentsSince не передано ровно одно из since и after.
+var errCommentsSinceBounds = errors.New("exactly one of since and after must be set")
+
+// argumentGiven сообщает, передал ли клиент аргумент поля явно, а не получил его
+// default-значение из схемы. Без контекста поля (прямой вызов резолвера) считается, что передал.
+func argumentGiven(ctx context.Context, name string) bool {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || fc.Field.Field == nil {
+		return true
+	}
+	return fc.Field.Arguments.ForName(name) != nil
+}
+
+// fieldSelected сообщает, запросил ли клиент поле name у объекта, который возвращает текущий резолвер.
+// Без контекста операции (прямой вызов резолвера) считается, что не запросил.
+func fieldSelected(ctx context.Context, name string) bool {
+	if graphql.GetFieldContext(ctx) == nil || !graphql.HasOperationContext(ctx) {
+		return false
+	}
+	for _, f := range graphql.CollectFieldsCtx(ctx, nil) {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionTransports - транспорты подписок, подключенные в cmd/server.
+var subscriptionTransports = []model.SubscriptionTransport{model.SubscriptionTransportWebsocket}
+
+// errPostDeleted - причина завершения подписок на удаленный пост.
+var errPostDeleted = errors.New("post was deleted")
+
+// errPostMerged - причина завершения подписок на пост, объединенный с другим.
+var errPostMerged = errors.New("post was merged into another post")
+
+// errServerShutdown - причина завершения подписок при остановке сервера.
+var errServerShutdown = errors.New("server is shutting down")
+
+// errSubscriptionExpired - причина завершения подписки по истечении SubscriptionMaxLifetime.
+var errSubscriptionExpired = errors.New("subscription lifetime exceeded, resubscribe with lastSeenCursor")
+
+// addSubscriptionError передает клиенту ошибку, с которой завершилась подписка.
+// Websocket-транспорт gqlgen отправит ее сообщением error вместо complete.
+func addSubscriptionError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	// Контекст ошибок подписки есть только у websocket-транспорта,
+	// для остальных транспортов (и в тестах) AddSubscriptionError паникует.
+	defer func() { _ = recover() }()
+	transport.AddSubscriptionError(ctx, gqlerror.WrapPath(graphql.GetPath(ctx), err))
+}
+
+// publishComment асинхронно уведомляет о новом видимом комментарии подписчиков поста,
+// упомянутых пользователей и постоянных подписчиков.
+func (r *Resolver) publishComment(c *domain.Comment) {
+	r.Observer.publish(c)
+	r.MentionObserver.publish(c)
+	if r.Notifier != nil {
+		r.Notifier.CommentAdded(c)
+	}
+}
+
+// postExists проверяет существование поста через PostCache, если он подключен.
+func (r *Resolver) postExists(ctx context.Context, id string) (bool, error) {
+	if r.PostCache != nil {
+		return r.PostCache.Exists(ctx, id)
+	}
+	if _, err := r.Storage.GetPostByID(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrPostNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// checkCommentPost отклоняет комментарий к посту, который по данным CommentPostCache
+// не существует или закрыт для комментариев. Без кеша проверку выполняет только хранилище.
+func (r *Resolver) checkCommentPost(ctx context.Context, postID string) error {
+	if r.CommentPostCache == nil {
+		return nil
+	}
+	status, err := r.CommentPostCache.Status(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if !status.Exists {
+		return storage.ErrPostNotFound
+	}
+	if !status.CommentsEnabled {
+		return storage.ErrCommentsDisabled
+	}
+	return nil
+}
+
+// forgetPosts сбрасывает кешированное состояние постов после их изменения или удаления.
+func (r *Resolver) forgetPosts(ids ...string) {
+	for _, cache := range []*PostStatusCache{r.PostCache, r.CommentPostCache} {
+		if cache == nil {
+			continue
+		}
+		for _, id := range ids {
+			cache.Forget(id)
+		}
+	}
+}
+
+// lastSeenCursor возвращает позицию (CreatedAt, ID) последнего комментария, полученного подписчиком.
+// Как и в пагинации, вместо курсора принимается ID комментария - его клиент знает из события.
+func (r *Resolver) lastSeenCursor(ctx context.Context, postID, raw string) (storage.Cursor, error) {
+	if cursor, ok := storage.DecodeCursor(raw); ok {
+		return storage.Cursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID}, nil
+	}
+	c, err := r.Storage.GetCommentByID(ctx, raw)
+	if errors.Is(err, storage.ErrCommentNotFound) || (err == nil && c.PostID != postID) {
+		return storage.Cursor{}, storage.ErrInvalidCursor
+	}
+	if err != nil {
+		return storage.Cursor{}, err
+	}
+	return storage.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}, nil
+}
+
+// replayComments отправляет через send видимые комментарии поста, идущие после курсора after,
+// в порядке (CreatedAt, ID) и возвращает их ID. Время создания внутри поста может совпадать
+// (импорт с исходным временем, объединение постов), поэтому следующая страница продолжается
+// с полного ключа последнего комментария предыдущей, а не с его времени. Если send вернул
+// false, возвращает ctx.Err().
+func (r *Resolver) replayComments(ctx context.Context, postID string, after storage.Cursor, send func(*domain.Comment) bool) (map[string]struct{}, error) {
+	sent := make(map[string]struct{})
+	for {
+		page, err := r.Storage.GetCommentsSince(ctx, postID, after, r.Config.MaxPageSize, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay comments: %w", err)
+		}
+		for _, c := range page {
+			if !send(c) {
+				return nil, ctx.Err()
+			}
+			sent[c.ID] = struct{}{}
+		}
+		if len(page) == 0 || len(page) < r.Config.MaxPageSize {
+			return sent, nil
+		}
+		last := page[len(page)-1]
+		after = storage.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+// loadersOrNil возвращает лоадеры запроса или nil, если dataloader.Middleware не применялся.
+// В этом случае резолверы обращаются к хранилищу напрямую.
+func loadersOrNil(ctx context.Context) *dataloader.Loaders {
+	loaders, ok := dataloader.For(ctx)
+	if !ok {
+		return nil
+	}
+	return loaders
 }
@@ -4,209 +4,1269 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/google/uuid"
+	"github.com/99designs/gqlgen/graphql"
 
 	"github.com/UkralStul/graphql-comments-service/graph/generated"
 	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/schemaversion"
 	"github.com/UkralStul/graphql-comments-service/internal/storage"
 )
 
 // === Comment Resolvers ===
 
+// Post резолвер для получения поста комментария.
+// Используем Dataloader, чтобы список комментариев из разных постов загружал посты одним запросом.
+func (r *commentResolver) Post(ctx context.Context, obj *domain.Comment) (*domain.Post, error) {
+	loaders := loadersOrNil(ctx)
+	if loaders == nil {
+		return r.Storage.GetPostByID(ctx, obj.PostID)
+	}
+	return loaders.LoadPost(ctx, obj.PostID)
+}
+
+// Reactions резолвер для счетчиков реакций. Счетчики всех комментариев
+// в ответе загружаются одним запросом через Dataloader.
+func (r *commentResolver) Reactions(ctx context.Context, obj *domain.Comment) ([]*model.ReactionCount, error) {
+	var counts map[domain.ReactionType]int
+	if loaders := loadersOrNil(ctx); loaders != nil {
+		var err error
+		if counts, err = loaders.LoadReactionCounts(ctx, obj.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		byComment, err := r.Storage.GetReactionCountsByCommentIDs(ctx, []string{obj.ID})
+		if err != nil {
+			return nil, err
+		}
+		counts = byComment[obj.ID]
+	}
+
+	// Отдаем все типы в фиксированном порядке, включая нулевые
+	reactions := make([]*model.ReactionCount, len(domain.ReactionTypes))
+	for i, t := range domain.ReactionTypes {
+		reactions[i] = &model.ReactionCount{Type: t, Count: counts[t]}
+	}
+	return reactions, nil
+}
+
 // Parent резолвер для получения родительского комментария.
-// В простом случае, как наш, где вложенность неглубокая, Dataloader не обязателен.
-// В продакшене для глубоких деревьев мог бы понадобиться.
+// Родители всех комментариев списка загружаются одним запросом через Dataloader.
 func (r *commentResolver) Parent(ctx context.Context, obj *domain.Comment) (*domain.Comment, error) {
 	if obj.ParentID == nil {
 		return nil, nil
 	}
-	// Эта реализация вызовет N+1 проблему, если запрашивать родителя для списка комментариев.
-	// Для получения одного родителя это приемлемо.
-	// Правильное решение - использовать Dataloader, как для Children.
-	// panic("not implemented, use Dataloader")
-	return r.Storage.GetCommentByID(ctx, *obj.ParentID)
+	var parent *domain.Comment
+	var err error
+	if loaders := loadersOrNil(ctx); loaders != nil {
+		parent, err = loaders.LoadComment(ctx, *obj.ParentID)
+	} else {
+		parent, err = r.Storage.GetCommentByID(ctx, *obj.ParentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Родитель, который пользователю не виден, отдается как null
+	if !canSeeComment(ctx, parent) {
+		return nil, nil
+	}
+	return parent, nil
+}
+
+// Ancestors резолвер для получения цепочки предков (например, для "хлебных крошек" треда).
+func (r *commentResolver) Ancestors(ctx context.Context, obj *domain.Comment) ([]*domain.Comment, error) {
+	if obj.ParentID == nil {
+		return []*domain.Comment{}, nil
+	}
+	ancestors, err := r.Storage.GetCommentAncestors(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	// Невидимые пользователю предки пропускаются
+	visible := ancestors[:0]
+	for _, a := range ancestors {
+		if canSeeComment(ctx, a) {
+			visible = append(visible, a)
+		}
+	}
+	return visible, nil
 }
 
 // Children резолвер для получения дочерних комментариев.
 func (r *commentResolver) Children(ctx context.Context, obj *domain.Comment, limit *int, cursor *string) (*model.CommentConnection, error) {
-	// Для этого поля мы НЕ используем Dataloader, т.к. нам нужна пагинация,
-	// а Dataloader обычно загружает ВСЕ дочерние элементы.
-	// Будем делать прямой запрос к хранилищу.
-	l := 5 // Default limit from schema
+	l := defaultChildrenLimit
 	if limit != nil {
 		l = *limit
 	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	// Первую страницу ответов для всех комментариев списка загружаем одним запросом
+	// через Dataloader. Следующие страницы зависят от курсора, а страницы больше ChildrenLimit
+	// не помещаются в лоадер - они идут напрямую в хранилище.
+	if loaders := loadersOrNil(ctx); loaders != nil && cursor == nil && l+1 <= loaders.ChildrenLimit {
+		children, err := loaders.LoadChildren(ctx, obj.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get children comments: %w", err)
+		}
+		if len(children) > l+1 {
+			children = children[:l+1]
+		}
+		return newCommentConnection(children, l), nil
+	}
 
 	// Запрашиваем на один элемент больше, чтобы определить, есть ли следующая страница
-	comments, err := r.Storage.GetCommentsByParentID(ctx, obj.ID, storage.PaginationArgs{Limit: l + 1, Cursor: cursor})
+	args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor, IncludeHidden: auth.IsModerator(ctx)}
+	comments, err := r.Storage.GetCommentsByParentID(ctx, obj.ID, args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get children comments: %w", err)
 	}
 
-	hasNextPage := len(comments) > l
-	if hasNextPage {
-		comments = comments[:l] // Убираем лишний элемент
+	return newCommentConnection(comments, l), nil
+}
+
+// Siblings резолвер для комментариев того же уровня (тот же родитель или корень того же поста).
+func (r *commentResolver) Siblings(ctx context.Context, obj *domain.Comment, limit *int, cursor *string) (*model.CommentConnection, error) {
+	l := defaultSiblingsLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
 	}
 
-	edges := make([]*model.CommentEdge, len(comments))
-	for i, c := range comments {
-		edges[i] = &model.CommentEdge{Node: c, Cursor: c.ID}
+	// Запрашиваем на два элемента больше: один для hasNextPage и один на случай,
+	// если в выборку попадет сам комментарий. Так страница остается полной,
+	// даже когда комментарий оказывается на ее границе.
+	args := storage.PaginationArgs{Limit: l + siblingsOverfetch, Cursor: cursor, IncludeHidden: auth.IsModerator(ctx)}
+
+	var comments []*domain.Comment
+	var err error
+	if obj.ParentID != nil {
+		comments, err = r.Storage.GetCommentsByParentID(ctx, *obj.ParentID, args)
+	} else {
+		comments, err = r.Storage.GetCommentsByPostID(ctx, obj.PostID, args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sibling comments: %w", err)
 	}
 
-	var endCursor *string
-	if len(edges) > 0 {
-		endCursor = &edges[len(edges)-1].Cursor
+	siblings := make([]*domain.Comment, 0, len(comments))
+	for _, c := range comments {
+		if c.ID != obj.ID {
+			siblings = append(siblings, c)
+		}
 	}
 
-	return &model.CommentConnection{
-		Edges: edges,
-		PageInfo: &model.PageInfo{
-			HasNextPage: hasNextPage,
-			EndCursor:   endCursor,
-		},
-	}, nil
+	return newCommentConnection(siblings, l), nil
+}
+
+// === CreateCommentPayload Resolvers ===
+
+// Depth и Ancestors загружают цепочку предков, только если клиент их запросил.
+// Глубина считается по всей цепочке, включая предков, невидимых пользователю.
+func (r *createCommentPayloadResolver) Depth(ctx context.Context, obj *model.CreateCommentPayload) (*int, error) {
+	if obj.Comment == nil {
+		return nil, nil
+	}
+	var ancestors []*domain.Comment
+	if obj.Comment.ParentID != nil {
+		var err error
+		if ancestors, err = r.Storage.GetCommentAncestors(ctx, obj.Comment.ID); err != nil {
+			return nil, err
+		}
+	}
+	depth := len(ancestors)
+	return &depth, nil
+}
+
+func (r *createCommentPayloadResolver) Ancestors(ctx context.Context, obj *model.CreateCommentPayload) ([]*domain.Comment, error) {
+	if obj.Comment == nil {
+		return nil, nil
+	}
+	return r.Comment().Ancestors(ctx, obj.Comment)
 }
 
 // === Mutation Resolvers ===
 
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.NewPost) (*domain.Post, error) {
-	post := &domain.Post{
+	// Первая ошибка возвращается как результат поля, остальные добавляются рядом с ней
+	if errs := validateInput(input); len(errs) > 0 {
+		for _, e := range errs[1:] {
+			graphql.AddError(ctx, e)
+		}
+		return nil, errs[0]
+	}
+
+	newPost, err := r.Storage.CreatePost(ctx, postFromInput(input))
+	if err != nil {
+		return nil, err
+	}
+
+	// Асинхронно уведомляем подписчиков ленты; о черновике они узнают при публикации
+	if !newPost.Draft {
+		r.PostObserver.publish(newPost)
+	}
+
+	return newPost, nil
+}
+
+func (r *mutationResolver) CreatePostWithComments(ctx context.Context, post model.NewPost, comments []*model.NewComment) (*domain.Post, error) {
+	// Все входные данные проверяются до транзакции, ошибки комментариев помечаются их индексом
+	errs := validateInput(post)
+	for i, input := range comments {
+		for _, e := range validateInput(input) {
+			// postId заменяется ID нового поста, поэтому его значение не проверяется
+			if e.Field != "postId" {
+				errs = append(errs, commentInputError(i, e))
+			}
+		}
+		if input.ParentID != nil {
+			errs = append(errs, commentInputError(i, &storage.ValidationError{Field: "parentId", Code: storage.ValidationCodeInvalid,
+				Err: errors.New("parentId is not supported for initial comments")}))
+		}
+	}
+	if len(errs) > 0 {
+		for _, e := range errs[1:] {
+			graphql.AddError(ctx, e)
+		}
+		return nil, errs[0]
+	}
+
+	// Упоминания ищем до транзакции: сервис пользователей может отвечать долго
+	drafts := make([]*domain.Comment, len(comments))
+	for i, input := range comments {
+		drafts[i] = &domain.Comment{AuthorID: input.AuthorID, Content: string(input.Content),
+			Attachments: attachmentsFromInput(input.Attachments)}
+		if r.Mentions != nil {
+			mentions, err := r.Mentions.Extract(ctx, drafts[i].Content)
+			if err != nil {
+				log.Printf("createPostWithComments: failed to extract mentions: %v", err)
+			}
+			drafts[i].Mentions = mentions
+		}
+	}
+
+	var newPost *domain.Post
+	created := make([]*domain.Comment, 0, len(drafts))
+	err := r.Storage.WithTransaction(ctx, func(tx storage.Storage) error {
+		var err error
+		if newPost, err = tx.CreatePost(ctx, postFromInput(post)); err != nil {
+			return err
+		}
+		for i, comment := range drafts {
+			comment.PostID = newPost.ID
+			c, err := tx.CreateComment(ctx, comment)
+			if err != nil {
+				var validationErr *storage.ValidationError
+				if errors.As(err, &validationErr) {
+					return commentInputError(i, validationErr)
+				}
+				return fmt.Errorf("comments[%d]: %w", i, err)
+			}
+			created = append(created, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Уведомления отправляем только после фиксации транзакции
+	if !newPost.Draft {
+		r.PostObserver.publish(newPost)
+	}
+	for _, c := range created {
+		if c.Status == domain.CommentApproved {
+			r.publishComment(c)
+		}
+	}
+	return newPost, nil
+}
+
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, error) {
+	post, err := r.Storage.GetPostByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != post.AuthorID && user.Role != auth.RoleModerator) {
+		return false, auth.ErrForbidden
+	}
+
+	if err := r.Storage.DeletePost(ctx, id); err != nil {
+		return false, err
+	}
+
+	r.forgetPosts(id)
+	// Сообщаем подписчикам, почему их поток завершился
+	r.Observer.closePost(id, errPostDeleted)
+	return true, nil
+}
+
+func (r *mutationResolver) MergePosts(ctx context.Context, sourceID string, targetID string) (*domain.Post, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+
+	post, err := r.Storage.MergePosts(ctx, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.forgetPosts(sourceID, targetID)
+	// Комментарии исходного поста теперь приходят подписчикам целевого
+	r.Observer.closePost(sourceID, errPostMerged)
+	return post, nil
+}
+
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, input model.UpdatePostInput) (*domain.Post, error) {
+	post, err := r.Storage.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != post.AuthorID && user.Role != auth.RoleModerator) {
+		return nil, auth.ErrForbidden
+	}
+
+	updated, err := r.Storage.UpdatePost(ctx, id, storage.PostUpdate{
 		Title:           input.Title,
 		Content:         input.Content,
-		AuthorID:        input.AuthorID,
-		CommentsEnabled: true,
+		CommentsEnabled: input.CommentsEnabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.forgetPosts(id)
+	return updated, nil
+}
+
+func (r *mutationResolver) PublishPost(ctx context.Context, id string) (*domain.Post, error) {
+	post, err := r.Storage.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != post.AuthorID && user.Role != auth.RoleModerator) {
+		return nil, auth.ErrForbidden
 	}
-	return r.Storage.CreatePost(ctx, post)
+	if !post.Draft {
+		return post, nil
+	}
+
+	draft := false
+	published, err := r.Storage.UpdatePost(ctx, id, storage.PostUpdate{Draft: &draft})
+	if err != nil {
+		return nil, err
+	}
+	// Для подписчиков ленты пост появляется в момент публикации
+	r.PostObserver.publish(published)
+	return published, nil
+}
+
+func (r *mutationResolver) SetDefaultCommentOrder(ctx context.Context, postID string, order domain.CommentOrder) (*domain.Post, error) {
+	post, err := r.Storage.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != post.AuthorID && user.Role != auth.RoleModerator) {
+		return nil, auth.ErrForbidden
+	}
+
+	return r.Storage.UpdatePost(ctx, postID, storage.PostUpdate{DefaultCommentOrder: &order})
 }
 
 func (r *mutationResolver) ToggleComments(ctx context.Context, postID string, enable bool) (*domain.Post, error) {
-	// Добавим проверку на существование поста
-	_, err := r.Storage.GetPostByID(ctx, postID)
+	// Права и проверки общие с updatePost
+	return r.UpdatePost(ctx, postID, model.UpdatePostInput{CommentsEnabled: &enable})
+}
+
+func (r *mutationResolver) ToggleCommentsForAuthor(ctx context.Context, authorID string, enable bool) (int, error) {
+	if !auth.IsModerator(ctx) {
+		return 0, auth.ErrForbidden
+	}
+	n, err := r.Storage.ToggleCommentsForAuthor(ctx, authorID, enable)
 	if err != nil {
-		return nil, errors.New("post not found")
+		return 0, err
+	}
+	// Какие посты изменились, неизвестно
+	if r.CommentPostCache != nil {
+		r.CommentPostCache.Reset()
+	}
+	return n, nil
+}
+
+func (r *mutationResolver) ToggleCommentsBulk(ctx context.Context, postIds []string, enable bool) ([]*domain.Post, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+
+	posts, err := r.Storage.ToggleCommentsBulk(ctx, postIds, enable)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пропущенные ID сообщаем ошибкой рядом с данными, не отменяя весь батч
+	updated := make(map[string]struct{}, len(posts))
+	for _, p := range posts {
+		updated[p.ID] = struct{}{}
+		r.forgetPosts(p.ID)
+	}
+	var missing []string
+	for _, id := range postIds {
+		if _, ok := updated[id]; !ok {
+			missing = append(missing, id)
+			updated[id] = struct{}{} // не дублируем ID в сообщении
+		}
+	}
+	if len(missing) > 0 {
+		graphql.AddError(ctx, fmt.Errorf("%w: %s", storage.ErrPostNotFound, strings.Join(missing, ", ")))
+	}
+
+	return posts, nil
+}
+
+func (r *mutationResolver) BlockAuthorOnPost(ctx context.Context, postID string, authorID string, blocked *bool) (*domain.Post, error) {
+	post, err := r.Storage.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != post.AuthorID && user.Role != auth.RoleModerator) {
+		return nil, auth.ErrForbidden
+	}
+
+	if blocked == nil || *blocked {
+		err = r.Storage.BlockAuthor(ctx, postID, authorID)
+	} else {
+		err = r.Storage.UnblockAuthor(ctx, postID, authorID)
 	}
-	return r.Storage.ToggleComments(ctx, postID, enable)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
 }
 
-func (r *mutationResolver) CreateComment(ctx context.Context, input model.NewComment) (*domain.Comment, error) {
+func (r *mutationResolver) CreateComment(ctx context.Context, input model.NewComment) (*model.CreateCommentPayload, error) {
+	if errs := validateInput(input); len(errs) > 0 {
+		userErrors := make([]*model.UserError, len(errs))
+		for i, e := range errs {
+			userErrors[i] = userErrorFrom(e)
+		}
+		return &model.CreateCommentPayload{UserErrors: userErrors}, nil
+	}
+
+	// Быстрый отказ по кешу: отклоненный комментарий не ждет блокировку строки поста
+	if err := r.checkCommentPost(ctx, input.PostID); err != nil {
+		if userErr := userErrorFrom(err); userErr != nil {
+			return &model.CreateCommentPayload{UserErrors: []*model.UserError{userErr}}, nil
+		}
+		return nil, err
+	}
+
 	comment := &domain.Comment{
-		PostID:   input.PostID,
-		ParentID: input.ParentID,
-		AuthorID: input.AuthorID,
-		Content:  input.Content,
+		PostID:      input.PostID,
+		ParentID:    input.ParentID,
+		AuthorID:    input.AuthorID,
+		Content:     string(input.Content),
+		Attachments: attachmentsFromInput(input.Attachments),
+	}
+
+	if r.Mentions != nil {
+		mentions, err := r.Mentions.Extract(ctx, comment.Content)
+		if err != nil {
+			// Недоступность сервиса пользователей не должна мешать комментированию
+			log.Printf("createComment: failed to extract mentions: %v", err)
+		}
+		comment.Mentions = mentions
 	}
 
 	newComment, err := r.Storage.CreateComment(ctx, comment)
 	if err != nil {
-		return nil, err // Ошибки (пост не найден, комменты выключены) обрабатываются в слое Storage
+		// Ошибки валидации (пост не найден, комменты выключены) отдаем как userErrors,
+		// остальные считаем системными
+		if userErr := userErrorFrom(err); userErr != nil {
+			return &model.CreateCommentPayload{UserErrors: []*model.UserError{userErr}}, nil
+		}
+		return nil, err
 	}
 
-	// Асинхронно уведомляем подписчиков
-	r.Observer.mu.RLock()
-	if postSubs, ok := r.Observer.subs[newComment.PostID]; ok {
-		// Запускаем в горутине, чтобы не блокировать мутацию
-		go func(c *domain.Comment) {
-			for _, ch := range postSubs {
-				select {
-				case ch <- c:
-				default:
-					// Клиент не успевает читать, можно пропустить или закрыть канал
-				}
-			}
-		}(newComment)
+	// При премодерации подписчики узнают о комментарии только после его одобрения
+	if newComment.Status == domain.CommentApproved {
+		r.publishComment(newComment)
+	}
+
+	cursor := storage.EncodeCursor(newComment)
+	return &model.CreateCommentPayload{Comment: newComment, Cursor: &cursor, UserErrors: []*model.UserError{}}, nil
+}
+
+func (r *mutationResolver) UpdateComment(ctx context.Context, id string, content model.CommentContent) (*domain.Comment, error) {
+	comment, err := r.Storage.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, storage.ErrCommentNotFound
+	}
+
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != comment.AuthorID && user.Role != auth.RoleModerator) {
+		return nil, auth.ErrForbidden
+	}
+
+	// Ошибки валидации превращаются в расширения ошибки GraphQL в ErrorPresenter
+	return r.Storage.UpdateComment(ctx, id, string(content))
+}
+
+func (r *mutationResolver) FollowPost(ctx context.Context, postID string, channel *domain.NotificationChannel) (*domain.PostSubscription, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, auth.ErrForbidden
+	}
+
+	ch := domain.NotificationEmail
+	if channel != nil {
+		ch = *channel
+	}
+	return r.Storage.FollowPost(ctx, postID, user.ID, ch)
+}
+
+func (r *mutationResolver) UnfollowPost(ctx context.Context, postID string) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, auth.ErrForbidden
+	}
+	return r.Storage.UnfollowPost(ctx, postID, user.ID)
+}
+
+func (r *mutationResolver) AddReaction(ctx context.Context, commentID string, typeArg domain.ReactionType) (*domain.Comment, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, auth.ErrForbidden
+	}
+
+	if err := r.Storage.AddReaction(ctx, commentID, user.ID, typeArg); err != nil {
+		return nil, err
+	}
+	return r.Storage.GetCommentByID(ctx, commentID)
+}
+
+func (r *mutationResolver) ReportComment(ctx context.Context, commentID string, reason *string) (*domain.Comment, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, auth.ErrForbidden
+	}
+
+	text := ""
+	if reason != nil {
+		text = strings.TrimSpace(*reason)
+	}
+	if utf8.RuneCountInString(text) > maxReportReasonLength {
+		return nil, fmt.Errorf("reason must not exceed %d characters", maxReportReasonLength)
+	}
+	return r.Storage.ReportComment(ctx, commentID, user.ID, text)
+}
+
+func (r *mutationResolver) SetCommentHidden(ctx context.Context, id string, hidden bool) (*domain.Comment, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+	return r.Storage.SetCommentHidden(ctx, id, hidden)
+}
+
+func (r *mutationResolver) LockThread(ctx context.Context, commentID string, locked bool) (*domain.Comment, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+	return r.Storage.SetCommentLocked(ctx, commentID, locked)
+}
+
+func (r *mutationResolver) ApproveComment(ctx context.Context, id string) (*domain.Comment, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+	comment, err := r.Storage.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	wasApproved := comment.Status == domain.CommentApproved
+
+	approved, err := r.Storage.SetCommentStatus(ctx, id, domain.CommentApproved)
+	if err != nil {
+		return nil, err
 	}
-	r.Observer.mu.RUnlock()
+	// Повторное одобрение не рассылает комментарий второй раз
+	if !wasApproved {
+		r.publishComment(approved)
+	}
+	return approved, nil
+}
 
-	return newComment, nil
+func (r *mutationResolver) RejectComment(ctx context.Context, id string) (*domain.Comment, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+	return r.Storage.SetCommentStatus(ctx, id, domain.CommentRejected)
 }
 
 // === Post Resolvers ===
 
-func (r *postResolver) Comments(ctx context.Context, obj *domain.Post, limit *int, cursor *string) (*model.CommentConnection, error) {
+func (r *postResolver) CanComment(ctx context.Context, obj *domain.Post) (bool, error) {
+	if !obj.CommentsEnabled {
+		return false, nil
+	}
+	if r.ReadOnly != nil && r.ReadOnly.Blocks("createComment") {
+		return false, nil
+	}
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return true, nil
+	}
+	blocked, err := r.Storage.IsAuthorBlocked(ctx, obj.ID, user.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check author block: %w", err)
+	}
+	return !blocked, nil
+}
+
+func (r *postResolver) CommentCount(ctx context.Context, obj *domain.Post) (int, error) {
+	if loaders := loadersOrNil(ctx); loaders != nil {
+		return loaders.LoadCommentCount(ctx, obj.ID)
+	}
+	counts, err := r.Storage.CountCommentsByPostIDs(ctx, []string{obj.ID}, auth.IsModerator(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count post comments: %w", err)
+	}
+	return counts[obj.ID], nil
+}
+
+func (r *postResolver) DepthHistogram(ctx context.Context, obj *domain.Post) ([]*model.DepthCount, error) {
+	histogram, err := r.Storage.GetDepthHistogram(ctx, obj.ID, auth.IsModerator(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get depth histogram: %w", err)
+	}
+
+	counts := make([]*model.DepthCount, 0, len(histogram))
+	for _, depth := range slices.Sorted(maps.Keys(histogram)) {
+		counts = append(counts, &model.DepthCount{Depth: depth, Count: histogram[depth]})
+	}
+	return counts, nil
+}
+
+func (r *postResolver) Comments(ctx context.Context, obj *domain.Post, limit *int, cursor *string, last *int, before *string, orderBy *domain.CommentOrder) (*model.CommentConnection, error) {
 	// Это резолвер для комментариев ВЕРХНЕГО уровня.
-	l := 10 // Default limit from schema
+	order := obj.DefaultCommentOrder
+	if orderBy != nil {
+		order = *orderBy
+	}
+	if last != nil || before != nil {
+		if cursor != nil || (limit != nil && argumentGiven(ctx, "limit")) {
+			return nil, errMixedPaginationDirection
+		}
+		l := defaultCommentsLimit
+		if last != nil {
+			l = *last
+		}
+		if l < 0 {
+			return nil, errors.New("last must not be negative")
+		}
+		if l > r.Config.MaxPageSize {
+			l = r.Config.MaxPageSize
+		}
+
+		args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: before, Backward: true, IncludeHidden: auth.IsModerator(ctx), Order: order}
+		comments, total, err := r.postCommentsPage(ctx, obj.ID, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get post comments: %w", err)
+		}
+		conn := newBackwardCommentConnection(comments, l)
+		conn.TotalCount = total
+		return conn, nil
+	}
+
+	l := defaultCommentsLimit
 	if limit != nil {
 		l = *limit
 	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
 
 	// Запрашиваем на один элемент больше для определения hasNextPage
-	comments, err := r.Storage.GetCommentsByPostID(ctx, obj.ID, storage.PaginationArgs{Limit: l + 1, Cursor: cursor})
+	args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor, IncludeHidden: auth.IsModerator(ctx), Order: order}
+	comments, total, err := r.postCommentsPage(ctx, obj.ID, args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get post comments: %w", err)
 	}
 
-	hasNextPage := len(comments) > l
-	if hasNextPage {
-		comments = comments[:l]
+	conn := newCommentConnection(comments, l)
+	conn.TotalCount = total
+	return conn, nil
+}
+
+// === Query Resolvers ===
+
+func (r *queryResolver) Posts(ctx context.Context, limit *int, offset *int, commentsEnabled *bool, orderBy *model.PostOrder) ([]*domain.Post, error) {
+	l, o := defaultPostsLimit, 0
+	if limit != nil {
+		l = *limit
+	}
+	if offset != nil {
+		o = *offset
 	}
 
-	edges := make([]*model.CommentEdge, len(comments))
-	for i, c := range comments {
-		edges[i] = &model.CommentEdge{Node: c, Cursor: c.ID}
+	if l < 0 || o < 0 {
+		return nil, errors.New("limit and offset must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
 	}
+	// Offset-пагинация стоит O(n) на каждую страницу, поэтому глубокие страницы запрещаем
+	if o > r.Config.MaxOffset {
+		return nil, fmt.Errorf("offset must not exceed %d, use cursor pagination for deep pages", r.Config.MaxOffset)
+	}
+	return r.Storage.GetPosts(ctx, l, o, postFilter(ctx, commentsEnabled, orderBy))
+}
 
-	var endCursor *string
-	if len(edges) > 0 {
-		endCursor = &edges[len(edges)-1].Cursor
+func (r *queryResolver) PostsConnection(ctx context.Context, limit *int, cursor *string, commentsEnabled *bool, orderBy *model.PostOrder) (*model.PostConnection, error) {
+	l := defaultPostsLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
 	}
 
-	return &model.CommentConnection{
-		Edges: edges,
-		PageInfo: &model.PageInfo{
-			HasNextPage: hasNextPage,
-			EndCursor:   endCursor,
-		},
-	}, nil
+	filter := postFilter(ctx, commentsEnabled, orderBy)
+
+	// Запрашиваем на один элемент больше, чтобы определить, есть ли следующая страница
+	posts, err := r.Storage.GetPostsAfter(ctx, l+1, cursor, filter)
+	if err != nil {
+		return nil, err
+	}
+	return newPostConnection(posts, l, filter.Order), nil
 }
 
-// === Query Resolvers ===
+func (r *queryResolver) Post(ctx context.Context, id string) (*domain.Post, error) {
+	// Если запрошен commentCount, считаем его вместе с постом и кладем в лоадер,
+	// чтобы резолвер поля не делал второй запрос.
+	loaders := loadersOrNil(ctx)
+	if loaders == nil || !fieldSelected(ctx, "commentCount") {
+		return r.Storage.GetPostByID(ctx, id)
+	}
+	post, count, err := r.Storage.GetPostWithCommentCount(ctx, id, auth.IsModerator(ctx))
+	if err != nil {
+		return nil, err
+	}
+	loaders.PrimeCommentCount(ctx, post.ID, count)
+	return post, nil
+}
+
+func (r *queryResolver) Comment(ctx context.Context, id string) (*domain.Comment, error) {
+	c, err := r.Storage.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canSeeComment(ctx, c) {
+		return nil, storage.ErrCommentNotFound
+	}
+	return c, nil
+}
 
-func (r *queryResolver) Posts(ctx context.Context, limit *int, offset *int) ([]*domain.Post, error) {
-	l, o := 10, 0
+func (r *queryResolver) PendingComments(ctx context.Context, limit *int, cursor *string) (*model.CommentConnection, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+	l := defaultPendingLimit
 	if limit != nil {
 		l = *limit
 	}
-	if offset != nil {
-		o = *offset
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	comments, err := r.Storage.GetPendingComments(ctx, storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending comments: %w", err)
 	}
-	return r.Storage.GetPosts(ctx, l, o)
+	return newCommentConnection(comments, l), nil
 }
 
-func (r *queryResolver) Post(ctx context.Context, id string) (*domain.Post, error) {
-	return r.Storage.GetPostByID(ctx, id)
+func (r *queryResolver) AdminPosts(ctx context.Context, limit *int, cursor *string) (*model.AdminPostConnection, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, auth.ErrForbidden
+	}
+	l := defaultPostsLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	filter := storage.PostFilter{IncludeDrafts: true}
+	posts, err := r.Storage.GetPostsAfter(ctx, l+1, cursor, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := newPostConnection(posts, l, filter.Order)
+
+	ids := make([]string, len(page.Edges))
+	for i, edge := range page.Edges {
+		ids[i] = edge.Node.ID
+	}
+	stats, err := r.Storage.GetPostStats(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post stats: %w", err)
+	}
+
+	edges := make([]*model.AdminPostEdge, len(page.Edges))
+	for i, edge := range page.Edges {
+		// Пост мог быть удален между запросами - статистика тогда нулевая
+		postStats, ok := stats[edge.Node.ID]
+		if !ok {
+			postStats = storage.PostStats{LastActivityAt: edge.Node.CreatedAt}
+		}
+		edges[i] = &model.AdminPostEdge{Cursor: edge.Cursor, Node: edge.Node, Stats: &postStats}
+	}
+	return &model.AdminPostConnection{Edges: edges, PageInfo: page.PageInfo}, nil
+}
+
+func (r *queryResolver) UnansweredComments(ctx context.Context, postID string, byAuthorID string, limit *int, cursor *string) (*model.CommentConnection, error) {
+	l := defaultCommentsLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor, IncludeHidden: auth.IsModerator(ctx)}
+	comments, err := r.Storage.GetUnansweredComments(ctx, postID, byAuthorID, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unanswered comments: %w", err)
+	}
+	return newCommentConnection(comments, l), nil
+}
+
+func (r *queryResolver) RecentComments(ctx context.Context, postIds []string, limit *int) ([]*domain.Comment, error) {
+	l := defaultRecentLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+	// Оценка сложности не учитывает число постов, поэтому список ограничен отдельно
+	if len(postIds) > r.Config.MaxPageSize {
+		return nil, &storage.ValidationError{Field: "postIds", Code: storage.ValidationCodeTooLong, Limit: r.Config.MaxPageSize,
+			Err: fmt.Errorf("postIds must contain at most %d posts", r.Config.MaxPageSize)}
+	}
+
+	comments, err := r.Storage.GetRecentCommentsByPostIDs(ctx, postIds, l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent comments: %w", err)
+	}
+	return comments, nil
+}
+
+func (r *queryResolver) CommentsSince(ctx context.Context, postID string, since *time.Time, after *string) ([]*domain.Comment, error) {
+	if (since == nil) == (after == nil) {
+		return nil, errCommentsSinceBounds
+	}
+	var cursor storage.Cursor
+	if since != nil {
+		cursor = storage.Cursor{CreatedAt: *since}
+	} else {
+		var err error
+		if cursor, err = r.lastSeenCursor(ctx, postID, *after); err != nil {
+			return nil, err
+		}
+	}
+
+	comments, err := r.Storage.GetCommentsSince(ctx, postID, cursor, r.Config.MaxPageSize, auth.IsModerator(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments since: %w", err)
+	}
+	return comments, nil
+}
+
+func (r *queryResolver) AuthorActivity(ctx context.Context, authorID string, limit *int, cursor *string) (*model.ActivityConnection, error) {
+	l := defaultActivityLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor, IncludeHidden: auth.IsModerator(ctx)}
+	items, err := r.Storage.GetAuthorActivity(ctx, authorID, args, postFilter(ctx, nil, nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author activity: %w", err)
+	}
+	return newActivityConnection(items, l), nil
+}
+
+func (r *queryResolver) ChildrenOf(ctx context.Context, commentIds []string, limitPerParent *int) ([]*model.CommentReplies, error) {
+	l := defaultChildrenLimit
+	if limitPerParent != nil {
+		l = *limitPerParent
+	}
+	if l < 0 {
+		return nil, errors.New("limitPerParent must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	parentIDs := make([]string, 0, len(commentIds))
+	seen := make(map[string]bool, len(commentIds))
+	for _, id := range commentIds {
+		if !seen[id] {
+			seen[id] = true
+			parentIDs = append(parentIDs, id)
+		}
+	}
+
+	// Один запрос на все ветки; лишний ответ на родителя показывает, есть ли продолжение
+	children, err := r.Storage.GetCommentsByParentIDs(ctx, parentIDs, l+1, auth.IsModerator(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get children comments: %w", err)
+	}
+
+	result := make([]*model.CommentReplies, len(parentIDs))
+	for i, parentID := range parentIDs {
+		result[i] = commentReplies(parentID, children[parentID], l)
+	}
+	return result, nil
+}
+
+func (r *queryResolver) FlatComments(ctx context.Context, postID string, limit *int, cursor *string) (*model.CommentConnection, error) {
+	l := defaultFlatLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor, IncludeHidden: auth.IsModerator(ctx)}
+	comments, err := r.Storage.GetCommentTreePage(ctx, postID, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flat comments: %w", err)
+	}
+	return newCommentConnection(comments, l), nil
+}
+
+func (r *queryResolver) CommentsWithPreview(ctx context.Context, postID string, limit *int, cursor *string, repliesPerComment *int) (*model.CommentConnection, error) {
+	l := defaultCommentsLimit
+	if limit != nil {
+		l = *limit
+	}
+	n := defaultPreviewLimit
+	if repliesPerComment != nil {
+		n = *repliesPerComment
+	}
+	if l < 0 || n < 0 {
+		return nil, errors.New("limit and repliesPerComment must not be negative")
+	}
+	l, n = min(l, r.Config.MaxPageSize), min(n, r.Config.MaxPageSize)
+
+	post, err := r.Storage.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	includeHidden := auth.IsModerator(ctx)
+	args := storage.PaginationArgs{Limit: l + pageOverfetch, Cursor: cursor, IncludeHidden: includeHidden, Order: post.DefaultCommentOrder}
+	comments, total, err := r.postCommentsPage(ctx, postID, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post comments: %w", err)
+	}
+	conn := newCommentConnection(comments, l)
+	conn.TotalCount = total
+	if len(conn.Edges) == 0 {
+		return conn, nil
+	}
+
+	// Ответы всех комментариев страницы одним запросом; лишний ответ показывает, есть ли продолжение
+	parentIDs := make([]string, len(conn.Edges))
+	for i, e := range conn.Edges {
+		parentIDs[i] = e.Node.ID
+	}
+	children, err := r.Storage.GetCommentsByParentIDs(ctx, parentIDs, n+1, includeHidden)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get children comments: %w", err)
+	}
+	for _, e := range conn.Edges {
+		e.Replies = commentReplies(e.Node.ID, children[e.Node.ID], n)
+	}
+	return conn, nil
+}
+
+func (r *queryResolver) SearchComments(ctx context.Context, query string, postID *string, limit *int) (*model.CommentSearchResults, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, &storage.ValidationError{Field: "query", Code: storage.ValidationCodeBlank,
+			Err: errors.New("query cannot be empty")}
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return nil, &storage.ValidationError{Field: "query", Code: storage.ValidationCodeTooLong, Limit: maxSearchQueryLength,
+			Err: fmt.Errorf("query must be at most %d characters", maxSearchQueryLength)}
+	}
+	l := defaultSearchLimit
+	if limit != nil {
+		l = *limit
+	}
+	if l < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if l > r.Config.MaxPageSize {
+		l = r.Config.MaxPageSize
+	}
+
+	// Анонимные пользователи делят один лимит
+	if r.SearchLimiter != nil {
+		var key string
+		if user := auth.ForContext(ctx); user != nil {
+			key = user.ID
+		}
+		if !r.SearchLimiter.Allow(key) {
+			return nil, ErrRateLimited
+		}
+	}
+
+	hits, err := r.Storage.SearchComments(ctx, query, storage.SearchArgs{PostID: postID, Limit: l, IncludeHidden: auth.IsModerator(ctx)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search comments: %w", err)
+	}
+	edges := make([]*model.CommentSearchEdge, len(hits))
+	for i, hit := range hits {
+		edges[i] = &model.CommentSearchEdge{Node: hit.Comment, Highlight: hit.Highlight, Score: hit.Score}
+	}
+	return &model.CommentSearchResults{Edges: edges}, nil
+}
+
+func (r *queryResolver) ServerInfo(ctx context.Context) (*model.ServerInfo, error) {
+	// Лимиты берем из конфигурации, чтобы клиенты подстраивались под настройки оператора
+	return &model.ServerInfo{
+		Version:                schemaversion.Version,
+		MaxCommentLength:       r.Config.MaxCommentLength,
+		MaxCommentDepth:        r.Config.MaxCommentDepth,
+		MaxAttachments:         r.Config.MaxAttachments,
+		MaxPageSize:            r.Config.MaxPageSize,
+		MaxOffset:              r.Config.MaxOffset,
+		SubscriptionTransports: subscriptionTransports,
+	}, nil
 }
 
 // === Subscription Resolvers ===
 
-func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *domain.Comment, error) {
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string, lastSeenCursor *string) (<-chan *domain.Comment, error) {
 	// Проверяем, существует ли пост, прежде чем подписываться
-	if _, err := r.Storage.GetPostByID(ctx, postID); err != nil {
+	if exists, err := r.postExists(ctx, postID); err != nil || !exists {
 		return nil, errors.New("post not found")
 	}
+	var lastSeen *storage.Cursor
+	if lastSeenCursor != nil {
+		cursor, err := r.lastSeenCursor(ctx, postID, *lastSeenCursor)
+		if err != nil {
+			return nil, err
+		}
+		lastSeen = &cursor
+	}
+
+	// Подписываемся до чтения пропущенных комментариев: комментарий, сохраненный во время
+	// восстановления, придет живым событием, а не потеряется между выборкой и подпиской.
+	sub := r.Observer.subscribe(postID)
+	out := make(chan *domain.Comment, 1)
+	send := func(c *domain.Comment) bool {
+		select {
+		case out <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// Горутина пересылает события клиенту и отвечает за очистку.
+	// Закрытие out завершает подписку: при отключении клиента - молча,
+	// при завершении со стороны сервера - с ошибкой, которую увидит клиент.
+	go func() {
+		defer close(out)
+		defer r.Observer.unsubscribe(postID, sub.id)
+
+		// По истечении срока жизни подписка завершается, и клиент переподключается с курсором
+		// последнего полученного комментария: восстановление отдаст все, что появилось после него,
+		// включая события, которые эта подписка не успела отправить.
+		var expired <-chan time.Time
+		if lifetime := r.Config.SubscriptionMaxLifetime; lifetime > 0 {
+			timer := time.NewTimer(lifetime)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		var replayed map[string]struct{}
+		if lastSeen != nil {
+			var err error
+			if replayed, err = r.replayComments(ctx, postID, *lastSeen, send); err != nil {
+				if ctx.Err() == nil {
+					addSubscriptionError(ctx, err)
+				}
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				addSubscriptionError(ctx, sub.err)
+				return
+			case <-expired:
+				addSubscriptionError(ctx, errSubscriptionExpired)
+				return
+			case c := <-sub.ch:
+				// Комментарий, попавший и в восстановление, и в живые события, отправлен один раз
+				if _, ok := replayed[c.ID]; ok {
+					continue
+				}
+				if !send(c) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (r *subscriptionResolver) CommentsAdded(ctx context.Context, postIds []string) (<-chan *domain.Comment, error) {
+	// Повторы не нужны: подписчик регистрируется под каждым постом один раз
+	seen := make(map[string]struct{}, len(postIds))
+	postIDs := make([]string, 0, len(postIds))
+	for _, id := range postIds {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			postIDs = append(postIDs, id)
+		}
+	}
+	if len(postIDs) == 0 {
+		return nil, &storage.ValidationError{Field: "postIds", Code: storage.ValidationCodeBlank,
+			Err: errors.New("postIds cannot be empty")}
+	}
+	if len(postIDs) > maxCommentsAddedPosts {
+		return nil, &storage.ValidationError{Field: "postIds", Code: storage.ValidationCodeTooLong, Limit: maxCommentsAddedPosts,
+			Err: fmt.Errorf("postIds must contain at most %d posts", maxCommentsAddedPosts)}
+	}
+	for _, id := range postIDs {
+		exists, err := r.postExists(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, id)
+		}
+	}
+
+	sub := r.Observer.subscribeMany(postIDs)
+	out := make(chan *domain.Comment, 1)
+
+	// Горутина пересылает события всех постов в один канал клиента и при завершении
+	// снимает подписчика со всех постов сразу
+	go func() {
+		defer close(out)
+		defer r.Observer.unsubscribeMany(postIDs, sub.id)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				addSubscriptionError(ctx, sub.err)
+				return
+			case c := <-sub.ch:
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (r *subscriptionResolver) PostAdded(ctx context.Context) (<-chan *domain.Post, error) {
+	id, ch := r.PostObserver.subscribe()
+
+	// Горутина для очистки при отключении клиента
+	go func() {
+		<-ctx.Done()
+		r.PostObserver.unsubscribe(id)
+	}()
 
-	ch := make(chan *domain.Comment, 1)
-	subID := uuid.NewString()
+	return ch, nil
+}
 
-	r.Observer.mu.Lock()
-	if r.Observer.subs[postID] == nil {
-		r.Observer.subs[postID] = make(map[string]chan *domain.Comment)
+func (r *subscriptionResolver) CommentMentioned(ctx context.Context, username string) (<-chan *domain.Comment, error) {
+	// Подписаться можно только на свой handle, модератор - на любой
+	user := auth.ForContext(ctx)
+	if user == nil || (user.ID != username && user.Role != auth.RoleModerator) {
+		return nil, auth.ErrForbidden
 	}
-	r.Observer.subs[postID][subID] = ch
-	r.Observer.mu.Unlock()
+
+	id, ch := r.MentionObserver.subscribe(username)
 
 	// Горутина для очистки при отключении клиента
 	go func() {
 		<-ctx.Done()
-		r.Observer.mu.Lock()
-		if postSubs, ok := r.Observer.subs[postID]; ok {
-			delete(postSubs, subID)
-			if len(postSubs) == 0 {
-				delete(r.Observer.subs, postID)
-			}
-		}
-		r.Observer.mu.Unlock()
+		r.MentionObserver.unsubscribe(username, id)
 	}()
 
 	return ch, nil
@@ -217,6 +1277,11 @@ func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string)
 // Comment returns generated.CommentResolver implementation.
 func (r *Resolver) Comment() generated.CommentResolver { return &commentResolver{r} }
 
+// CreateCommentPayload returns generated.CreateCommentPayloadResolver implementation.
+func (r *Resolver) CreateCommentPayload() generated.CreateCommentPayloadResolver {
+	return &createCommentPayloadResolver{r}
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
@@ -230,6 +1295,7 @@ func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
 
 type commentResolver struct{ *Resolver }
+type createCommentPayloadResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
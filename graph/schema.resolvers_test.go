@@ -0,0 +1,2072 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/config"
+	"github.com/UkralStul/graphql-comments-service/internal/dataloader"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/mention"
+	"github.com/UkralStul/graphql-comments-service/internal/schemaversion"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestResolver создает резолвер поверх in-memory хранилища с тестовыми лимитами
+func newTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	mentions, err := mention.NewParser(mention.DefaultPattern, nil)
+	require.NoError(t, err)
+	return &Resolver{
+		Storage:         inmemory.New(),
+		Observer:        NewCommentObserver(),
+		PostObserver:    NewPostObserver(),
+		MentionObserver: NewMentionObserver(),
+		Mentions:        mentions,
+		Config: &config.Config{
+			MaxPageSize: 3,
+			MaxOffset:   10,
+		},
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+// withLoaders возвращает контекст с лоадерами, как после dataloader.Middleware
+func withLoaders(t *testing.T, store storage.Storage) context.Context {
+	t.Helper()
+	var ctx context.Context
+	handler := dataloader.Middleware(store, 10, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx = req.Context()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotNil(t, ctx)
+	return ctx
+}
+
+func TestQueryResolver_Posts_ClampsLimit(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+	}
+
+	posts, err := r.Query().Posts(ctx, intPtr(100000), nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, posts, 3)
+
+	// Лимит в пределах максимума не меняется
+	posts, err = r.Query().Posts(ctx, intPtr(2), nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+}
+
+func TestQueryResolver_Posts_RejectsNegativeArgs(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	_, err := r.Query().Posts(ctx, intPtr(-1), nil, nil, nil)
+	assert.Error(t, err)
+
+	_, err = r.Query().Posts(ctx, nil, intPtr(-1), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestQueryResolver_Posts_RejectsDeepOffset(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	_, err := r.Query().Posts(ctx, nil, intPtr(10), nil, nil)
+	require.NoError(t, err)
+
+	_, err = r.Query().Posts(ctx, nil, intPtr(11), nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cursor pagination")
+}
+
+func TestSubscriptionResolver_CommentAdded_EndsOnShutdown(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var channels []<-chan *domain.Comment
+	var posts []*domain.Post
+	for i := 0; i < 2; i++ {
+		post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		ch, err := r.Subscription().CommentAdded(ctx, post.ID, nil)
+		require.NoError(t, err)
+		posts = append(posts, post)
+		channels = append(channels, ch)
+	}
+
+	r.Observer.CloseAll()
+	for _, ch := range channels {
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok, "subscription channel must be closed")
+		case <-time.After(time.Second):
+			t.Fatal("subscription was not terminated")
+		}
+	}
+
+	// Повторное завершение, отключение клиентов и публикация после остановки не паникуют
+	r.Observer.CloseAll()
+	r.Observer.closePost(posts[0].ID, errPostDeleted)
+	cancel()
+	r.Observer.publish(&domain.Comment{ID: "c-1", PostID: posts[1].ID})
+	assert.Empty(t, r.Observer.subs)
+}
+
+func TestSubscriptionResolver_CommentAdded_DeliversInOrder(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	ch, err := r.Subscription().CommentAdded(ctx, post.ID, nil)
+	require.NoError(t, err)
+
+	const n = 10
+	var created []string
+	for i := 0; i < n; i++ {
+		payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: model.CommentContent(fmt.Sprintf("comment %d", i))})
+		require.NoError(t, err)
+		created = append(created, payload.Comment.ID)
+	}
+
+	var delivered []string
+	for len(delivered) < n {
+		select {
+		case c := <-ch:
+			delivered = append(delivered, c.ID)
+		case <-time.After(time.Second):
+			t.Fatalf("delivered %d of %d comments", len(delivered), n)
+		}
+	}
+	assert.Equal(t, created, delivered)
+}
+
+func TestSubscriptionResolver_CommentAdded_ResumesFromLastSeen(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	var created []*domain.Comment
+	for i := 0; i < 6; i++ {
+		c, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+		created = append(created, c)
+	}
+	// Ответы тоже восстанавливаются - живая подписка их доставляет
+	reply, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &created[0].ID, AuthorID: "user-3", Content: "reply"})
+	require.NoError(t, err)
+
+	receive := func(ch <-chan *domain.Comment) string {
+		t.Helper()
+		select {
+		case c := <-ch:
+			return c.ID
+		case <-time.After(time.Second):
+			t.Fatal("comment was not delivered")
+			return ""
+		}
+	}
+
+	// Пропущенные комментарии приходят в порядке создания, на нескольких страницах (MaxPageSize 3)
+	cursor := storage.EncodeCursor(created[1])
+	ch, err := r.Subscription().CommentAdded(ctx, post.ID, &cursor)
+	require.NoError(t, err)
+	var got []string
+	for i := 0; i < 5; i++ {
+		got = append(got, receive(ch))
+	}
+	assert.Equal(t, []string{created[2].ID, created[3].ID, created[4].ID, created[5].ID, reply.ID}, got)
+
+	// Живое событие об уже восстановленном комментарии не приходит второй раз
+	r.Observer.publish(created[5])
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "live"})
+	require.NoError(t, err)
+	assert.Equal(t, payload.Comment.ID, receive(ch))
+
+	// ID комментария тоже подходит как курсор
+	ch, err = r.Subscription().CommentAdded(ctx, post.ID, &reply.ID)
+	require.NoError(t, err)
+	assert.Equal(t, payload.Comment.ID, receive(ch))
+
+	other, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Other", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = r.Subscription().CommentAdded(ctx, other.ID, &reply.ID)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	missing := "missing"
+	_, err = r.Subscription().CommentAdded(ctx, post.ID, &missing)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestSubscriptionResolver_CommentAdded_ResumesWithEqualTimestamps(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Импорт с исходным временем дает комментарии поста с одинаковым временем создания
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	var comments []*domain.Comment
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		comments = append(comments, &domain.Comment{ID: id, PostID: post.ID, AuthorID: "user-2", Content: id, CreatedAt: createdAt})
+	}
+	require.NoError(t, r.Storage.ImportThread(ctx, post, comments))
+
+	// Восстановление идет страницами по MaxPageSize (3): граница страницы внутри
+	// одинакового времени не теряет комментарии
+	ch, err := r.Subscription().CommentAdded(ctx, post.ID, &comments[0].ID)
+	require.NoError(t, err)
+	var got []string
+	for i := 0; i < 4; i++ {
+		select {
+		case c := <-ch:
+			got = append(got, c.ID)
+		case <-time.After(time.Second):
+			t.Fatal("comment was not delivered")
+		}
+	}
+	assert.Equal(t, []string{"b", "c", "d", "e"}, got)
+}
+
+func TestMutationResolver_MergePosts(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+
+	source, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Source", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	target, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Target", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: source.ID, AuthorID: "user-2", Content: "Hello"})
+	require.NoError(t, err)
+	ch, err := r.Subscription().CommentAdded(ctx, source.ID, nil)
+	require.NoError(t, err)
+
+	_, err = r.Mutation().MergePosts(auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser}), source.ID, target.ID)
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Mutation().MergePosts(modCtx, target.ID, target.ID)
+	assert.ErrorIs(t, err, storage.ErrMergeSamePost)
+
+	merged, err := r.Mutation().MergePosts(modCtx, source.ID, target.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target.ID, merged.ID)
+	moved, err := r.Storage.GetCommentByID(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target.ID, moved.PostID)
+
+	// Подписка на исходный пост завершается
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "subscription channel must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not terminated")
+	}
+}
+
+func TestSubscriptionResolver_CommentAdded_MaxLifetime(t *testing.T) {
+	r := newTestResolver(t)
+	r.Config.SubscriptionMaxLifetime = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	ch, err := r.Subscription().CommentAdded(ctx, post.ID, nil)
+	require.NoError(t, err)
+
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "before"})
+	require.NoError(t, err)
+	var last *domain.Comment
+	select {
+	case last = <-ch:
+		assert.Equal(t, payload.Comment.ID, last.ID)
+	case <-time.After(time.Second):
+		t.Fatal("comment was not delivered")
+	}
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "subscription channel must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not terminated")
+	}
+	require.Eventually(t, func() bool {
+		r.Observer.mu.RLock()
+		defer r.Observer.mu.RUnlock()
+		return len(r.Observer.subs) == 0
+	}, time.Second, time.Millisecond)
+
+	// Комментарий, созданный до переподключения, приходит через восстановление
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "between"})
+	require.NoError(t, err)
+	cursor := storage.EncodeCursor(last)
+	ch, err = r.Subscription().CommentAdded(ctx, post.ID, &cursor)
+	require.NoError(t, err)
+	select {
+	case c := <-ch:
+		assert.Equal(t, payload.Comment.ID, c.ID)
+	case <-time.After(time.Second):
+		t.Fatal("missed comment was not replayed")
+	}
+}
+
+func TestSubscriptionResolver_CommentAdded_EndsOnPostDelete(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	ch, err := r.Subscription().CommentAdded(ctx, post.ID, nil)
+	require.NoError(t, err)
+
+	_, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "Hello"})
+	require.NoError(t, err)
+	select {
+	case c := <-ch:
+		assert.Equal(t, "Hello", c.Content)
+	case <-time.After(time.Second):
+		t.Fatal("comment was not delivered")
+	}
+
+	// Удалять пост может только автор или модератор
+	_, err = r.Mutation().DeletePost(ctx, post.ID)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Mutation().DeletePost(ctx, "missing")
+	require.ErrorIs(t, err, storage.ErrPostNotFound)
+
+	deleted, err := r.Mutation().DeletePost(auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser}), post.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "subscription channel must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not terminated")
+	}
+}
+
+func TestSubscriptionResolver_CommentsAdded(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var posts []*domain.Post
+	for i := 0; i < 3; i++ {
+		post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		posts = append(posts, post)
+	}
+
+	_, err := r.Subscription().CommentsAdded(ctx, nil)
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "postIds", validationErr.Field)
+	_, err = r.Subscription().CommentsAdded(ctx, []string{posts[0].ID, "missing"})
+	require.ErrorIs(t, err, storage.ErrPostNotFound)
+	assert.Empty(t, r.Observer.subs)
+
+	// Повтор ID не дублирует события
+	ch, err := r.Subscription().CommentsAdded(ctx, []string{posts[0].ID, posts[1].ID, posts[0].ID})
+	require.NoError(t, err)
+
+	_, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: posts[2].ID, AuthorID: "user-2", Content: "Other"})
+	require.NoError(t, err)
+	for _, post := range posts[:2] {
+		_, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "Hello"})
+		require.NoError(t, err)
+	}
+
+	got := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		select {
+		case c := <-ch:
+			assert.Equal(t, "Hello", c.Content)
+			got[c.PostID]++
+		case <-time.After(time.Second):
+			t.Fatal("comment was not delivered")
+		}
+	}
+	assert.Equal(t, map[string]int{posts[0].ID: 1, posts[1].ID: 1}, got)
+
+	// Удаление одного из постов завершает всю подписку и снимает ее со всех постов
+	r.Observer.closePost(posts[1].ID, errPostDeleted)
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "subscription channel must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not terminated")
+	}
+	assert.Eventually(t, func() bool {
+		r.Observer.mu.RLock()
+		defer r.Observer.mu.RUnlock()
+		return len(r.Observer.subs) == 0
+	}, time.Second, time.Millisecond)
+
+	// Повторное завершение подписчика, уже закрытого через другой пост, не паникует
+	sub := r.Observer.subscribeMany([]string{posts[0].ID, posts[2].ID})
+	r.Observer.closePost(posts[0].ID, errPostDeleted)
+	r.Observer.CloseAll()
+	assert.ErrorIs(t, sub.err, errPostDeleted)
+}
+
+func TestMutationResolver_CreateComment_UserErrors(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: false})
+	require.NoError(t, err)
+
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "Hello"})
+	require.NoError(t, err)
+	assert.Nil(t, payload.Comment)
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, model.UserErrorCodeCommentsDisabled, payload.UserErrors[0].Code)
+
+	_, err = r.Mutation().ToggleComments(auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser}), post.ID, true)
+	require.NoError(t, err)
+
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "   "})
+	require.NoError(t, err)
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, "content", *payload.UserErrors[0].Field)
+	assert.Equal(t, model.UserErrorCodeBlank, payload.UserErrors[0].Code)
+
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "Hello"})
+	require.NoError(t, err)
+	assert.Empty(t, payload.UserErrors)
+	require.NotNil(t, payload.Comment)
+	assert.Equal(t, "Hello", payload.Comment.Content)
+}
+
+func TestMutationResolver_CreateComment_Attachments(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	var resp struct {
+		CreateComment struct {
+			Comment *struct {
+				Attachments []struct {
+					URL   string
+					Type  string
+					Title *string
+				}
+			}
+			UserErrors []struct {
+				Field *string
+				Code  string
+			}
+		}
+	}
+	const mutation = `mutation($postId: ID!, $attachments: [AttachmentInput!]) {
+		createComment(input: {postId: $postId, authorId: "user-2", content: "look", attachments: $attachments}) {
+			comment { attachments { url type title } } userErrors { field code }
+		}
+	}`
+
+	// Без вложений клиент получает пустой список
+	c.MustPost(mutation, &resp, client.Var("postId", post.ID))
+	require.NotNil(t, resp.CreateComment.Comment)
+	assert.Empty(t, resp.CreateComment.Comment.Attachments)
+
+	attachments := []map[string]interface{}{
+		{"url": "https://example.com/cat.png", "type": "IMAGE"},
+		{"url": "https://example.com/article", "type": "LINK", "title": "Article"},
+	}
+	c.MustPost(mutation, &resp, client.Var("postId", post.ID), client.Var("attachments", attachments))
+	require.NotNil(t, resp.CreateComment.Comment)
+	got := resp.CreateComment.Comment.Attachments
+	require.Len(t, got, 2)
+	assert.Equal(t, "https://example.com/cat.png", got[0].URL)
+	assert.Equal(t, "IMAGE", got[0].Type)
+	assert.Nil(t, got[0].Title)
+	require.NotNil(t, got[1].Title)
+	assert.Equal(t, "Article", *got[1].Title)
+
+	// Ссылка не на http(s) отклоняется как ошибка пользователя
+	resp.CreateComment.Comment = nil
+	c.MustPost(mutation, &resp, client.Var("postId", post.ID),
+		client.Var("attachments", []map[string]interface{}{{"url": "javascript:alert(1)", "type": "LINK"}}))
+	assert.Nil(t, resp.CreateComment.Comment)
+	require.Len(t, resp.CreateComment.UserErrors, 1)
+	assert.Equal(t, "attachments[0].url", *resp.CreateComment.UserErrors[0].Field)
+	assert.Equal(t, string(model.UserErrorCodeInvalid), resp.CreateComment.UserErrors[0].Code)
+}
+
+func TestMutationResolver_RejectsControlChars(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "user-2"})
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "pasted\x00\x01binary"})
+	require.NoError(t, err)
+	assert.Nil(t, payload.Comment)
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, "content", *payload.UserErrors[0].Field)
+	assert.Equal(t, model.UserErrorCodeInvalid, payload.UserErrors[0].Code)
+
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "two\n\tlines"})
+	require.NoError(t, err)
+	require.NotNil(t, payload.Comment)
+
+	_, err = r.Mutation().UpdateComment(ctx, payload.Comment.ID, "edited\x1b")
+	assert.ErrorIs(t, err, storage.ErrContentControl)
+}
+
+func TestMutationResolver_CreateComment_Placement(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	reply, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-1", Content: "reply"})
+	require.NoError(t, err)
+
+	var resp struct {
+		CreateComment struct {
+			Comment   struct{ ID string }
+			Cursor    string
+			Depth     *int
+			Ancestors []struct{ ID string }
+		}
+	}
+	c.MustPost(`mutation($postId: ID!, $parentId: ID) {
+		createComment(input: {postId: $postId, parentId: $parentId, authorId: "user-2", content: "nested"}) {
+			comment { id } cursor depth ancestors { id }
+		}
+	}`, &resp, client.Var("postId", post.ID), client.Var("parentId", reply.ID))
+
+	payload := resp.CreateComment
+	require.NotNil(t, payload.Depth)
+	assert.Equal(t, 2, *payload.Depth)
+	require.Len(t, payload.Ancestors, 2)
+	assert.Equal(t, reply.ID, payload.Ancestors[0].ID)
+	assert.Equal(t, root.ID, payload.Ancestors[1].ID)
+
+	// Курсор продолжает список ответов родителя сразу после нового комментария
+	children, err := r.Storage.GetCommentsByParentID(ctx, reply.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, children, 1)
+	assert.Equal(t, storage.EncodeCursor(children[0]), payload.Cursor)
+	assert.Equal(t, children[0].ID, payload.Comment.ID)
+
+	// При ошибке валидации поля размещения пустые
+	failed, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "   "})
+	require.NoError(t, err)
+	assert.Nil(t, failed.Cursor)
+	depth, err := r.CreateCommentPayload().Depth(ctx, failed)
+	require.NoError(t, err)
+	assert.Nil(t, depth)
+}
+
+func TestCommentResolver_Siblings_PageBoundary(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	comments := make([]*domain.Comment, 5)
+	for i := range comments {
+		comments[i], err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+		require.NoError(t, err)
+	}
+
+	// Сам комментарий находится внутри первой страницы - страница все равно полная
+	self := comments[1]
+	page, err := r.Comment().Siblings(ctx, self, intPtr(2), nil)
+	require.NoError(t, err)
+	require.Len(t, page.Edges, 2)
+	assert.Equal(t, comments[0].ID, page.Edges[0].Node.ID)
+	assert.Equal(t, comments[2].ID, page.Edges[1].Node.ID)
+	assert.True(t, page.PageInfo.HasNextPage)
+
+	page, err = r.Comment().Siblings(ctx, self, intPtr(2), page.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, page.Edges, 2)
+	assert.Equal(t, comments[3].ID, page.Edges[0].Node.ID)
+	assert.Equal(t, comments[4].ID, page.Edges[1].Node.ID)
+	assert.False(t, page.PageInfo.HasNextPage)
+
+	// Сам комментарий - первый элемент следующей страницы
+	self = comments[2]
+	page, err = r.Comment().Siblings(ctx, self, intPtr(2), nil)
+	require.NoError(t, err)
+	require.Len(t, page.Edges, 2)
+	assert.True(t, page.PageInfo.HasNextPage)
+
+	page, err = r.Comment().Siblings(ctx, self, intPtr(2), page.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, page.Edges, 2)
+	assert.Equal(t, comments[3].ID, page.Edges[0].Node.ID)
+	assert.False(t, page.PageInfo.HasNextPage)
+}
+
+func TestCommentResolver_Siblings_RejectsNegativeLimit(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+	require.NoError(t, err)
+
+	for _, limit := range []int{-1, -2} {
+		_, err = r.Comment().Siblings(ctx, comment, intPtr(limit), nil)
+		require.EqualError(t, err, "limit must not be negative")
+	}
+}
+
+func TestResolvers_RejectNegativeConnectionLimit(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+	require.NoError(t, err)
+
+	_, err = r.Comment().Children(ctx, comment, intPtr(-1), nil)
+	require.EqualError(t, err, "limit must not be negative")
+	// Первая страница ответов через Dataloader проверяется так же
+	loaderCtx := withLoaders(t, r.Storage)
+	_, err = r.Comment().Children(loaderCtx, comment, intPtr(-1), nil)
+	require.EqualError(t, err, "limit must not be negative")
+
+	_, err = r.Post().Comments(ctx, post, intPtr(-1), nil, nil, nil, nil)
+	require.EqualError(t, err, "limit must not be negative")
+}
+
+func TestSubscriptionResolver_PostAdded(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := r.Subscription().PostAdded(ctx)
+	require.NoError(t, err)
+
+	post, err := r.Mutation().CreatePost(context.Background(), model.NewPost{Title: "New", Content: "Content", AuthorID: "user-1"})
+	require.NoError(t, err)
+
+	select {
+	case p := <-ch:
+		assert.Equal(t, post.ID, p.ID)
+	case <-time.After(time.Second):
+		t.Fatal("post was not delivered")
+	}
+
+	// После отключения клиента подписчик удаляется
+	cancel()
+	assert.Eventually(t, func() bool {
+		r.PostObserver.mu.RLock()
+		defer r.PostObserver.mu.RUnlock()
+		return len(r.PostObserver.subs) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMutationResolver_PublishPost(t *testing.T) {
+	r := newTestResolver(t)
+	r.Storage = inmemory.New(storage.WithDraftPosts(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authorCtx := auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser})
+	otherCtx := auth.WithUser(ctx, &auth.User{ID: "user-2", Role: auth.RoleUser})
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+
+	ch, err := r.Subscription().PostAdded(ctx)
+	require.NoError(t, err)
+
+	post, err := r.Mutation().CreatePost(authorCtx, model.NewPost{Title: "Draft", Content: "Content", AuthorID: "user-1"})
+	require.NoError(t, err)
+	assert.False(t, post.Published())
+
+	// Черновик видят только автор и модераторы
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		visible bool
+	}{
+		{"anonymous", ctx, false},
+		{"other user", otherCtx, false},
+		{"author", authorCtx, true},
+		{"moderator", modCtx, true},
+	} {
+		posts, err := r.Query().Posts(tc.ctx, nil, nil, nil, nil)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.visible, len(posts) == 1, tc.name)
+
+		conn, err := r.Query().PostsConnection(tc.ctx, nil, nil, nil, nil)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.visible, len(conn.Edges) == 1, tc.name)
+	}
+
+	_, err = r.Mutation().PublishPost(otherCtx, post.ID)
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+
+	// Подписчики ленты узнают о посте только при публикации
+	select {
+	case p := <-ch:
+		t.Fatalf("draft %s was delivered to postAdded", p.ID)
+	default:
+	}
+	published, err := r.Mutation().PublishPost(authorCtx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, published.Published())
+	select {
+	case p := <-ch:
+		assert.Equal(t, post.ID, p.ID)
+	case <-time.After(time.Second):
+		t.Fatal("published post was not delivered")
+	}
+
+	posts, err := r.Query().Posts(ctx, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+
+	// Повторная публикация ничего не меняет и не рассылает пост снова
+	_, err = r.Mutation().PublishPost(modCtx, post.ID)
+	require.NoError(t, err)
+	select {
+	case p := <-ch:
+		t.Fatalf("post %s was delivered twice", p.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err = r.Mutation().PublishPost(modCtx, "missing")
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestMutationResolver_CreateComment_Mentions(t *testing.T) {
+	r := newTestResolver(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	// Анонимно и на чужой handle подписаться нельзя
+	_, err = r.Subscription().CommentMentioned(ctx, "alice")
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Subscription().CommentMentioned(auth.WithUser(ctx, &auth.User{ID: "bob", Role: auth.RoleUser}), "alice")
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Subscription().CommentMentioned(auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator}), "alice")
+	require.NoError(t, err)
+
+	ch, err := r.Subscription().CommentMentioned(auth.WithUser(ctx, &auth.User{ID: "alice", Role: auth.RoleUser}), "alice")
+	require.NoError(t, err)
+
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "@alice и @bob, посмотрите"})
+	require.NoError(t, err)
+	require.NotNil(t, payload.Comment)
+	assert.Equal(t, []string{"alice", "bob"}, payload.Comment.Mentions)
+
+	select {
+	case c := <-ch:
+		assert.Equal(t, payload.Comment.ID, c.ID)
+	case <-time.After(time.Second):
+		t.Fatal("mention was not delivered")
+	}
+}
+
+func TestMutationResolver_ToggleCommentsBulk(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := graphql.WithResponseContext(context.Background(), ErrorPresenter, graphql.DefaultRecover)
+
+	p1, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post 1", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	p2, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post 2", Content: "Content", AuthorID: "user-2", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	_, err = r.Mutation().ToggleCommentsBulk(ctx, []string{p1.ID}, false)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+	posts, err := r.Mutation().ToggleCommentsBulk(modCtx, []string{p1.ID, "missing", p2.ID}, false)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.False(t, posts[0].CommentsEnabled)
+	assert.False(t, posts[1].CommentsEnabled)
+
+	// Пропущенный ID сообщается ошибкой NOT_FOUND, не отменяя батч
+	errs := graphql.GetErrors(modCtx)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "missing")
+	assert.Equal(t, codeNotFound, errs[0].Extensions["code"])
+}
+
+func TestCommentResolver_WithoutLoaders(t *testing.T) {
+	r := newTestResolver(t)
+	// Контекст без dataloader.Middleware: резолверы обращаются к хранилищу напрямую
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "text"})
+	require.NoError(t, err)
+	require.NoError(t, r.Storage.AddReaction(ctx, comment.ID, "user-2", domain.ReactionLike))
+
+	loaded, err := r.Comment().Post(ctx, comment)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, loaded.ID)
+
+	reactions, err := r.Comment().Reactions(ctx, comment)
+	require.NoError(t, err)
+	require.Len(t, reactions, len(domain.ReactionTypes))
+	assert.Equal(t, 1, reactions[0].Count)
+
+	reply, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &comment.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+
+	parent, err := r.Comment().Parent(ctx, reply)
+	require.NoError(t, err)
+	assert.Equal(t, comment.ID, parent.ID)
+
+	children, err := r.Comment().Children(ctx, comment, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, children.Edges, 1)
+	assert.Equal(t, reply.ID, children.Edges[0].Node.ID)
+}
+
+func TestCommentResolver_Children_LoaderPageContinuesWithCursor(t *testing.T) {
+	r := newTestResolver(t)
+	// Страница больше ChildrenLimit лоадера не должна упираться в MaxPageSize
+	r.Config.MaxPageSize = 20
+	ctx := withLoaders(t, r.Storage)
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	var replies []string
+	for i := 0; i < 5; i++ {
+		c, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: fmt.Sprintf("reply %d", i)})
+		require.NoError(t, err)
+		replies = append(replies, c.ID)
+	}
+
+	// Первая страница приходит из Dataloader'а, вторая - из хранилища по курсору
+	first, err := r.Comment().Children(ctx, root, intPtr(3), nil)
+	require.NoError(t, err)
+	require.Len(t, first.Edges, 3)
+	assert.True(t, first.PageInfo.HasNextPage)
+
+	second, err := r.Comment().Children(ctx, root, intPtr(3), first.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, second.Edges, 2)
+	assert.False(t, second.PageInfo.HasNextPage)
+
+	var got []string
+	for _, e := range append(first.Edges, second.Edges...) {
+		got = append(got, e.Node.ID)
+	}
+	assert.Equal(t, replies, got)
+
+	// Страница больше ChildrenLimit лоадера (10) читается из хранилища целиком
+	for i := 0; i < 7; i++ {
+		_, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-3", Content: fmt.Sprintf("late reply %d", i)})
+		require.NoError(t, err)
+	}
+	large, err := r.Comment().Children(ctx, root, intPtr(20), nil)
+	require.NoError(t, err)
+	assert.Len(t, large.Edges, 12)
+}
+
+func TestMutationResolver_CreatePost_CommentsEnabled(t *testing.T) {
+	r := newTestResolver(t)
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+
+	create := func(commentsEnabled string) bool {
+		var resp struct {
+			CreatePost struct{ CommentsEnabled bool }
+		}
+		c.MustPost(`mutation {
+			createPost(input: {title: "Post", content: "Content", authorId: "user-1"`+commentsEnabled+`}) { commentsEnabled }
+		}`, &resp)
+		return resp.CreatePost.CommentsEnabled
+	}
+
+	assert.True(t, create(""))
+	assert.True(t, create(", commentsEnabled: null"))
+	assert.False(t, create(", commentsEnabled: false"))
+}
+
+func TestMutationResolver_CreatePostWithComments(t *testing.T) {
+	r := newTestResolver(t)
+	srv := handler.NewDefaultServer(NewSchema(r))
+	srv.SetErrorPresenter(ErrorPresenter)
+	c := client.New(srv)
+	ctx := context.Background()
+
+	const mutation = `mutation($comments: [NewComment!]!, $enabled: Boolean) {
+		createPostWithComments(
+			post: {title: "Post", content: "Content", authorId: "user-1", commentsEnabled: $enabled},
+			comments: $comments
+		) { id }
+	}`
+	comment := func(authorID, content string) map[string]interface{} {
+		// postId игнорируется, поэтому подходит любое значение
+		return map[string]interface{}{"postId": "", "authorId": authorID, "content": content}
+	}
+	var resp struct {
+		CreatePostWithComments *struct{ ID string }
+	}
+
+	err := c.Post(mutation, &resp, client.Var("comments", []interface{}{comment("user-1", "first"), comment("user-2", "second")}))
+	require.NoError(t, err)
+	require.NotNil(t, resp.CreatePostWithComments)
+	comments, err := r.Storage.GetCommentsByPostID(ctx, resp.CreatePostWithComments.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "first", comments[0].Content)
+	assert.Equal(t, "user-2", comments[1].AuthorID)
+
+	// Ошибка валидации указывает на комментарий, и ничего не сохраняется
+	err = c.Post(mutation, &resp, client.Var("comments", []interface{}{comment("user-1", "first"), comment(" ", "second")}))
+	assert.Equal(t, storage.ValidationCodeBlank, errorCode(t, err))
+	assert.Contains(t, err.Error(), `"field":"comments[1].authorId"`)
+
+	withParent := comment("user-1", "reply")
+	withParent["parentId"] = "2b1f6c8e-4c61-4f4a-9a57-2f7f3f2f0c11"
+	err = c.Post(mutation, &resp, client.Var("comments", []interface{}{withParent}))
+	assert.Equal(t, storage.ValidationCodeInvalid, errorCode(t, err))
+
+	// Ошибка хранилища на комментарии откатывает уже созданный пост
+	err = c.Post(mutation, &resp, client.Var("comments", []interface{}{comment("user-1", "first")}), client.Var("enabled", false))
+	require.Error(t, err)
+
+	posts, err := r.Storage.GetPosts(ctx, 10, 0, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+func TestMutationResolver_UpdatePost(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	content, disabled := "Edited", false
+	input := model.UpdatePostInput{Content: &content, CommentsEnabled: &disabled}
+
+	otherCtx := auth.WithUser(ctx, &auth.User{ID: "user-2", Role: auth.RoleUser})
+	_, err = r.Mutation().UpdatePost(otherCtx, post.ID, input)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	authorCtx := auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser})
+	updated, err := r.Mutation().UpdatePost(authorCtx, post.ID, input)
+	require.NoError(t, err)
+	assert.Equal(t, "Post", updated.Title)
+	assert.Equal(t, "Edited", updated.Content)
+	assert.False(t, updated.CommentsEnabled)
+
+	// toggleComments - обертка над тем же обновлением с теми же правами
+	_, err = r.Mutation().ToggleComments(ctx, post.ID, true)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Mutation().ToggleComments(otherCtx, post.ID, true)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Mutation().ToggleComments(authorCtx, "missing", true)
+	require.ErrorIs(t, err, storage.ErrPostNotFound)
+	updated, err = r.Mutation().ToggleComments(authorCtx, post.ID, true)
+	require.NoError(t, err)
+	assert.True(t, updated.CommentsEnabled)
+	assert.Equal(t, "Edited", updated.Content)
+}
+
+func TestMutationResolver_FollowPost(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	_, err = r.Mutation().FollowPost(ctx, post.ID, nil)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	userCtx := auth.WithUser(ctx, &auth.User{ID: "user-2", Role: auth.RoleUser})
+	sub, err := r.Mutation().FollowPost(userCtx, post.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", sub.UserID)
+	assert.Equal(t, domain.NotificationEmail, sub.Channel)
+
+	removed, err := r.Mutation().UnfollowPost(userCtx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, removed)
+}
+
+func TestMutationResolver_ReportComment(t *testing.T) {
+	r := newTestResolver(t)
+	r.Storage = inmemory.New(storage.WithReportHideThreshold(1))
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "spam"})
+	require.NoError(t, err)
+
+	_, err = r.Mutation().ReportComment(ctx, comment.ID, nil)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	for _, reporter := range []string{"user-2", "user-3"} {
+		userCtx := auth.WithUser(ctx, &auth.User{ID: reporter, Role: auth.RoleUser})
+		_, err = r.Mutation().ReportComment(userCtx, comment.ID, nil)
+		require.NoError(t, err)
+	}
+
+	// Скрытый комментарий не виден пользователю, но виден модератору
+	userCtx := auth.WithUser(ctx, &auth.User{ID: "user-2", Role: auth.RoleUser})
+	conn, err := r.Post().Comments(userCtx, post, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, conn.Edges)
+
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+	conn, err = r.Post().Comments(modCtx, post, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.True(t, conn.Edges[0].Node.Hidden)
+
+	// По ID скрытый комментарий тоже отдается только модератору
+	_, err = r.Query().Comment(userCtx, comment.ID)
+	require.ErrorIs(t, err, storage.ErrCommentNotFound)
+	got, err := r.Query().Comment(modCtx, comment.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Hidden)
+
+	_, err = r.Mutation().SetCommentHidden(userCtx, comment.ID, false)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	restored, err := r.Mutation().SetCommentHidden(modCtx, comment.ID, false)
+	require.NoError(t, err)
+	assert.False(t, restored.Hidden)
+	_, err = r.Query().Comment(userCtx, comment.ID)
+	require.NoError(t, err)
+}
+
+func TestMutationResolver_ApproveComment(t *testing.T) {
+	r := newTestResolver(t)
+	r.Storage = inmemory.New(storage.WithPremoderation(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	ch, err := r.Subscription().CommentAdded(ctx, post.ID, nil)
+	require.NoError(t, err)
+
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-2", Content: "Hello"})
+	require.NoError(t, err)
+	require.NotNil(t, payload.Comment)
+	assert.Equal(t, domain.CommentPending, payload.Comment.Status)
+
+	// До одобрения подписчики комментарий не получают
+	select {
+	case c := <-ch:
+		t.Fatalf("pending comment %s was delivered", c.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	userCtx := auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser})
+	_, err = r.Mutation().ApproveComment(userCtx, payload.Comment.ID)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+	approved, err := r.Mutation().ApproveComment(modCtx, payload.Comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentApproved, approved.Status)
+	select {
+	case c := <-ch:
+		assert.Equal(t, payload.Comment.ID, c.ID)
+	case <-time.After(time.Second):
+		t.Fatal("approved comment was not delivered")
+	}
+
+	conn, err := r.Post().Comments(userCtx, post, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, conn.Edges, 1)
+
+	rejected, err := r.Mutation().RejectComment(modCtx, payload.Comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentRejected, rejected.Status)
+	conn, err = r.Post().Comments(userCtx, post, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, conn.Edges)
+}
+
+func TestQueryResolver_PendingComments(t *testing.T) {
+	r := newTestResolver(t)
+	r.Storage = inmemory.New(storage.WithPremoderation(true))
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+
+	_, err = r.Query().PendingComments(auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser}), nil, nil)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+	conn, err := r.Query().PendingComments(modCtx, intPtr(1), nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.Equal(t, "comment 0", conn.Edges[0].Node.Content)
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	// Одобренный комментарий уходит из очереди
+	_, err = r.Mutation().ApproveComment(modCtx, conn.Edges[0].Node.ID)
+	require.NoError(t, err)
+	conn, err = r.Query().PendingComments(modCtx, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.Equal(t, "comment 1", conn.Edges[0].Node.Content)
+}
+
+func TestQueryResolver_AdminPosts(t *testing.T) {
+	r := newTestResolver(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.Storage = inmemory.New(
+		storage.WithPremoderation(true),
+		storage.WithClock(storage.ClockFunc(func() time.Time { return now })),
+	)
+	ctx := context.Background()
+
+	active, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Active", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	draft, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Draft", Content: "Content", AuthorID: "user-1", CommentsEnabled: true, Draft: true})
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	var comments []*domain.Comment
+	for i := 0; i < 2; i++ {
+		c, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: active.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+		comments = append(comments, c)
+	}
+	_, err = r.Storage.SetCommentStatus(ctx, comments[0].ID, domain.CommentApproved)
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = r.Storage.ReportComment(ctx, comments[0].ID, "user-3", "spam")
+	require.NoError(t, err)
+
+	_, err = r.Query().AdminPosts(auth.WithUser(ctx, &auth.User{ID: "user-1", Role: auth.RoleUser}), nil, nil)
+	require.ErrorIs(t, err, auth.ErrForbidden)
+
+	// Черновик тоже попадает в список, посты идут от новых к старым
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+	conn, err := r.Query().AdminPosts(modCtx, intPtr(1), nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.Equal(t, draft.ID, conn.Edges[0].Node.ID)
+	assert.Equal(t, storage.PostStats{LastActivityAt: draft.CreatedAt}, *conn.Edges[0].Stats)
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Query().AdminPosts(modCtx, intPtr(1), conn.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.Equal(t, active.ID, conn.Edges[0].Node.ID)
+	assert.Equal(t, storage.PostStats{CommentCount: 2, PendingCount: 1, ReportCount: 1, LastActivityAt: now}, *conn.Edges[0].Stats)
+	assert.False(t, conn.PageInfo.HasNextPage)
+}
+
+func TestQueryResolver_UnansweredComments(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "owner", CommentsEnabled: true})
+	require.NoError(t, err)
+	var roots []*domain.Comment
+	for i := 0; i < 4; i++ {
+		c, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("question %d", i)})
+		require.NoError(t, err)
+		roots = append(roots, c)
+	}
+	_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &roots[1].ID, AuthorID: "owner", Content: "answer"})
+	require.NoError(t, err)
+
+	conn, err := r.Query().UnansweredComments(ctx, post.ID, "owner", intPtr(2), nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 2)
+	assert.Equal(t, "question 0", conn.Edges[0].Node.Content)
+	assert.Equal(t, "question 2", conn.Edges[1].Node.Content)
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Query().UnansweredComments(ctx, post.ID, "owner", intPtr(2), conn.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.Equal(t, "question 3", conn.Edges[0].Node.Content)
+	assert.False(t, conn.PageInfo.HasNextPage)
+}
+
+func TestQueryResolver_RecentComments(t *testing.T) {
+	r := newTestResolver(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.Storage = inmemory.New(storage.WithClock(storage.ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	var postIDs []string
+	for i := 0; i < 2; i++ {
+		post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		postIDs = append(postIDs, post.ID)
+	}
+	// Комментарии чередуются между постами, время растет
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Minute)
+		_, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: postIDs[i%2], AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+
+	// Лимит ограничен MaxPageSize
+	comments, err := r.Query().RecentComments(ctx, postIDs, intPtr(10))
+	require.NoError(t, err)
+	require.Len(t, comments, 3)
+	assert.Equal(t, []string{"comment 3", "comment 2", "comment 1"},
+		[]string{comments[0].Content, comments[1].Content, comments[2].Content})
+
+	comments, err = r.Query().RecentComments(ctx, postIDs[:1], nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "comment 2", comments[0].Content)
+
+	_, err = r.Query().RecentComments(ctx, postIDs, intPtr(-1))
+	require.Error(t, err)
+
+	// Постов в запросе не больше MaxPageSize (3)
+	_, err = r.Query().RecentComments(ctx, []string{postIDs[0], postIDs[1], "a", "b"}, nil)
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, storage.ValidationCodeTooLong, validationErr.Code)
+}
+
+func TestQueryResolver_Comment_HidesRejected(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod", Role: auth.RoleModerator})
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "root"})
+	require.NoError(t, err)
+	reply, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-3", Content: "reply"})
+	require.NoError(t, err)
+	_, err = r.Storage.SetCommentStatus(ctx, root.ID, domain.CommentRejected)
+	require.NoError(t, err)
+
+	// Отклоненный комментарий по ID для не модератора не существует
+	_, err = r.Query().Comment(ctx, root.ID)
+	require.ErrorIs(t, err, storage.ErrCommentNotFound)
+	got, err := r.Query().Comment(modCtx, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentRejected, got.Status)
+
+	// Видимый ответ доступен, но его отклоненный родитель скрыт
+	got, err = r.Query().Comment(ctx, reply.ID)
+	require.NoError(t, err)
+	parent, err := r.Comment().Parent(ctx, got)
+	require.NoError(t, err)
+	assert.Nil(t, parent)
+	ancestors, err := r.Comment().Ancestors(ctx, got)
+	require.NoError(t, err)
+	assert.Empty(t, ancestors)
+
+	parent, err = r.Comment().Parent(modCtx, got)
+	require.NoError(t, err)
+	require.NotNil(t, parent)
+	assert.Equal(t, root.ID, parent.ID)
+	ancestors, err = r.Comment().Ancestors(modCtx, got)
+	require.NoError(t, err)
+	assert.Len(t, ancestors, 1)
+}
+
+func TestQueryResolver_CommentsSince(t *testing.T) {
+	r := newTestResolver(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.Storage = inmemory.New(storage.WithClock(storage.ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	start := now
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Minute)
+		_, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+
+	// Ответ ограничен MaxPageSize, следующий опрос продолжает с ID последнего комментария
+	comments, err := r.Query().CommentsSince(ctx, post.ID, &start, nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 3)
+	assert.Equal(t, "comment 2", comments[2].Content)
+
+	comments, err = r.Query().CommentsSince(ctx, post.ID, nil, &comments[2].ID)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, []string{"comment 3", "comment 4"}, []string{comments[0].Content, comments[1].Content})
+
+	// Комментарий с тем же временем создания, что и последний полученный, не теряется
+	other := &domain.Post{ID: "other", Title: "Other", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	require.NoError(t, r.Storage.ImportThread(ctx, other, []*domain.Comment{
+		{ID: "first", PostID: other.ID, AuthorID: "user-2", Content: "first", CreatedAt: start},
+		{ID: "second", PostID: other.ID, AuthorID: "user-2", Content: "second", CreatedAt: start},
+	}))
+	cursor := storage.EncodeCursor(&domain.Comment{ID: "first", CreatedAt: start})
+	comments, err = r.Query().CommentsSince(ctx, other.ID, nil, &cursor)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "second", comments[0].ID)
+
+	_, err = r.Query().CommentsSince(ctx, post.ID, nil, nil)
+	assert.ErrorIs(t, err, errCommentsSinceBounds)
+	_, err = r.Query().CommentsSince(ctx, post.ID, &start, &comments[0].ID)
+	assert.ErrorIs(t, err, errCommentsSinceBounds)
+	_, err = r.Query().CommentsSince(ctx, "missing", &start, nil)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestQueryResolver_ChildrenOf(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	first, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "first"})
+	require.NoError(t, err)
+	second, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "second"})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &first.ID, AuthorID: "author", Content: fmt.Sprintf("reply %d", i)})
+		require.NoError(t, err)
+	}
+	_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &second.ID, AuthorID: "author", Content: "only reply"})
+	require.NoError(t, err)
+
+	// Порядок - как в запросе, повторы схлопываются, у неизвестного ID пустой список
+	replies, err := r.Query().ChildrenOf(ctx, []string{second.ID, first.ID, second.ID, "unknown"}, intPtr(2))
+	require.NoError(t, err)
+	require.Len(t, replies, 3)
+
+	assert.Equal(t, second.ID, replies[0].ParentID)
+	require.Len(t, replies[0].Comments, 1)
+	assert.Equal(t, "only reply", replies[0].Comments[0].Content)
+	assert.False(t, replies[0].HasMore)
+
+	assert.Equal(t, first.ID, replies[1].ParentID)
+	require.Len(t, replies[1].Comments, 2)
+	assert.Equal(t, "reply 0", replies[1].Comments[0].Content)
+	assert.True(t, replies[1].HasMore)
+
+	assert.Equal(t, "unknown", replies[2].ParentID)
+	assert.NotNil(t, replies[2].Comments)
+	assert.Empty(t, replies[2].Comments)
+
+	_, err = r.Query().ChildrenOf(ctx, []string{first.ID}, intPtr(-1))
+	assert.Error(t, err)
+}
+
+// parentBatchStore считает батчевые загрузки ответов.
+type parentBatchStore struct {
+	storage.Storage
+	calls int
+}
+
+func (s *parentBatchStore) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, perParent int, includeHidden bool) (map[string][]*domain.Comment, error) {
+	s.calls++
+	return s.Storage.GetCommentsByParentIDs(ctx, parentIDs, perParent, includeHidden)
+}
+
+func TestQueryResolver_CommentsWithPreview(t *testing.T) {
+	r := newTestResolver(t)
+	store := &parentBatchStore{Storage: r.Storage}
+	r.Storage = store
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	var roots []*domain.Comment
+	for i := 0; i < 3; i++ {
+		root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: fmt.Sprintf("root %d", i)})
+		require.NoError(t, err)
+		roots = append(roots, root)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &roots[0].ID, AuthorID: "author", Content: fmt.Sprintf("reply %d", i)})
+		require.NoError(t, err)
+	}
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &roots[1].ID, AuthorID: "author", Content: "only reply"})
+	require.NoError(t, err)
+
+	conn, err := r.Query().CommentsWithPreview(ctx, post.ID, intPtr(2), nil, intPtr(2))
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 2)
+	assert.True(t, conn.PageInfo.HasNextPage)
+	assert.Equal(t, 1, store.calls)
+
+	first := conn.Edges[0].Replies
+	require.NotNil(t, first)
+	assert.Equal(t, roots[0].ID, first.ParentID)
+	require.Len(t, first.Comments, 2)
+	assert.Equal(t, "reply 0", first.Comments[0].Content)
+	assert.True(t, first.HasMore)
+
+	second := conn.Edges[1].Replies
+	require.Len(t, second.Comments, 1)
+	assert.False(t, second.HasMore)
+
+	// Последняя страница: у комментария без ответов пустой список
+	conn, err = r.Query().CommentsWithPreview(ctx, post.ID, intPtr(2), conn.PageInfo.EndCursor, nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.NotNil(t, conn.Edges[0].Replies.Comments)
+	assert.Empty(t, conn.Edges[0].Replies.Comments)
+
+	_, err = r.Query().CommentsWithPreview(ctx, post.ID, nil, nil, intPtr(-1))
+	assert.Error(t, err)
+	_, err = r.Query().CommentsWithPreview(ctx, "missing", nil, nil, nil)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestQueryResolver_FlatComments(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "root"})
+	require.NoError(t, err)
+	_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "second root"})
+	require.NoError(t, err)
+	reply, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "author", Content: "reply"})
+	require.NoError(t, err)
+	_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &reply.ID, AuthorID: "author", Content: "nested"})
+	require.NoError(t, err)
+
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+	var resp struct {
+		FlatComments struct {
+			Edges []struct {
+				Cursor string
+				Node   struct {
+					Content  string
+					ParentID *string
+				}
+			}
+			PageInfo struct{ HasNextPage bool }
+		}
+	}
+	// MaxPageSize в тестах равен 3
+	c.MustPost(`query($postId: ID!) {
+		flatComments(postId: $postId) {
+			edges { cursor node { content parentId } }
+			pageInfo { hasNextPage }
+		}
+	}`, &resp, client.Var("postId", post.ID))
+
+	edges := resp.FlatComments.Edges
+	require.Len(t, edges, 3)
+	assert.Equal(t, "root", edges[0].Node.Content)
+	assert.Nil(t, edges[0].Node.ParentID)
+	assert.Equal(t, "reply", edges[1].Node.Content)
+	assert.Equal(t, root.ID, *edges[1].Node.ParentID)
+	assert.Equal(t, "nested", edges[2].Node.Content)
+	assert.Equal(t, reply.ID, *edges[2].Node.ParentID)
+	assert.True(t, resp.FlatComments.PageInfo.HasNextPage)
+
+	conn, err := r.Query().FlatComments(ctx, post.ID, nil, &edges[2].Cursor)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.Equal(t, "second root", conn.Edges[0].Node.Content)
+	assert.False(t, conn.PageInfo.HasNextPage)
+}
+
+func TestQueryResolver_AuthorActivity(t *testing.T) {
+	r := newTestResolver(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.Storage = inmemory.New(storage.WithClock(storage.ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "comment"})
+	require.NoError(t, err)
+
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+	var resp struct {
+		AuthorActivity struct {
+			Edges []struct {
+				Node struct {
+					Typename string `json:"__typename"`
+					Title    string
+					Content  string
+				}
+			}
+			PageInfo struct{ HasNextPage bool }
+		}
+	}
+	c.MustPost(`query {
+		authorActivity(authorId: "author") {
+			edges { node {
+				__typename
+				... on Post { title }
+				... on Comment { content }
+			} }
+			pageInfo { hasNextPage }
+		}
+	}`, &resp)
+
+	edges := resp.AuthorActivity.Edges
+	require.Len(t, edges, 2)
+	assert.Equal(t, "Comment", edges[0].Node.Typename)
+	assert.Equal(t, "comment", edges[0].Node.Content)
+	assert.Equal(t, "Post", edges[1].Node.Typename)
+	assert.Equal(t, "Post", edges[1].Node.Title)
+	assert.False(t, resp.AuthorActivity.PageInfo.HasNextPage)
+
+	conn, err := r.Query().AuthorActivity(ctx, "author", intPtr(1), nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	// Черновик автора не виден анонимному пользователю, но виден самому автору
+	draft := true
+	_, err = r.Storage.UpdatePost(ctx, post.ID, storage.PostUpdate{Draft: &draft})
+	require.NoError(t, err)
+	conn, err = r.Query().AuthorActivity(ctx, "author", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	assert.IsType(t, &domain.Comment{}, conn.Edges[0].Node)
+
+	authorCtx := auth.WithUser(ctx, &auth.User{ID: "author", Role: auth.RoleUser})
+	conn, err = r.Query().AuthorActivity(authorCtx, "author", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, conn.Edges, 2)
+}
+
+func TestQueryResolver_PostsConnection(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	created := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		p, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		created[p.ID] = true
+	}
+
+	// Проходим все страницы по курсору: каждый пост встречается ровно один раз
+	seen := make(map[string]bool)
+	var cursor *string
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "pagination must terminate")
+		conn, err := r.Query().PostsConnection(ctx, intPtr(2), cursor, nil, nil)
+		require.NoError(t, err)
+		for _, e := range conn.Edges {
+			assert.False(t, seen[e.Node.ID], "post %s returned twice", e.Node.ID)
+			seen[e.Node.ID] = true
+		}
+		if !conn.PageInfo.HasNextPage {
+			break
+		}
+		cursor = conn.PageInfo.EndCursor
+	}
+	assert.Equal(t, created, seen)
+
+	bad := "not-a-cursor"
+	_, err := r.Query().PostsConnection(ctx, nil, &bad, nil, nil)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestPostResolver_Comments_PaginationDirection(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+
+	type page struct {
+		Post struct {
+			Comments struct {
+				Edges []struct {
+					Cursor string
+					Node   struct{ Content string }
+				}
+				PageInfo struct {
+					HasPreviousPage bool
+					StartCursor     *string
+				}
+			}
+		}
+	}
+	query := func(args string) (page, error) {
+		var resp page
+		err := c.Post(fmt.Sprintf(`{ post(id: %q) { comments(%s) {
+			edges { cursor node { content } } pageInfo { hasPreviousPage startCursor }
+		} } }`, post.ID, args), &resp)
+		return resp, err
+	}
+
+	t.Run("backward", func(t *testing.T) {
+		resp, err := query("last: 2")
+		require.NoError(t, err)
+		conn := resp.Post.Comments
+		require.Len(t, conn.Edges, 2)
+		assert.Equal(t, "comment 1", conn.Edges[0].Node.Content)
+		assert.Equal(t, "comment 2", conn.Edges[1].Node.Content)
+		assert.True(t, conn.PageInfo.HasPreviousPage)
+		require.NotNil(t, conn.PageInfo.StartCursor)
+
+		resp, err = query(fmt.Sprintf("last: 2, before: %q", *conn.PageInfo.StartCursor))
+		require.NoError(t, err)
+		conn = resp.Post.Comments
+		require.Len(t, conn.Edges, 1)
+		assert.Equal(t, "comment 0", conn.Edges[0].Node.Content)
+		assert.False(t, conn.PageInfo.HasPreviousPage)
+	})
+
+	// default-значение limit не мешает пагинации назад, а явный limit - конфликт
+	for _, args := range []string{
+		"limit: 2, last: 2",
+		"limit: 2, before: \"x\"",
+		"cursor: \"x\", last: 2",
+		"cursor: \"x\", before: \"y\"",
+	} {
+		t.Run("conflict "+args, func(t *testing.T) {
+			_, err := query(args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), errMixedPaginationDirection.Error())
+		})
+	}
+}
+
+func TestPostResolver_Comments_DefaultOrder(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "user-1", Role: auth.RoleUser})
+	post, err := r.Mutation().CreatePost(ctx, model.NewPost{Title: "Post", Content: "Content", AuthorID: "user-1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentOrderOldest, post.DefaultCommentOrder)
+	for i := 0; i < 3; i++ {
+		_, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+	contents := func(conn *model.CommentConnection) []string {
+		got := make([]string, len(conn.Edges))
+		for i, e := range conn.Edges {
+			got[i] = e.Node.Content
+		}
+		return got
+	}
+
+	_, err = r.Mutation().SetDefaultCommentOrder(auth.WithUser(ctx, &auth.User{ID: "user-2"}), post.ID, domain.CommentOrderNewest)
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+	_, err = r.Mutation().SetDefaultCommentOrder(ctx, "missing", domain.CommentOrderNewest)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+
+	post, err = r.Mutation().SetDefaultCommentOrder(ctx, post.ID, domain.CommentOrderNewest)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentOrderNewest, post.DefaultCommentOrder)
+
+	// Без orderBy действует порядок поста, явный orderBy его переопределяет
+	conn, err := r.Post().Comments(ctx, post, intPtr(2), nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"comment 2", "comment 1"}, contents(conn))
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Post().Comments(ctx, post, intPtr(2), conn.PageInfo.EndCursor, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"comment 0"}, contents(conn))
+
+	oldest := domain.CommentOrderOldest
+	conn, err = r.Post().Comments(ctx, post, intPtr(2), nil, nil, nil, &oldest)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"comment 0", "comment 1"}, contents(conn))
+}
+
+func TestPostResolver_Comments_Top(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "user-1", Role: auth.RoleUser})
+	post, err := r.Mutation().CreatePost(ctx, model.NewPost{Title: "Post", Content: "Content", AuthorID: "user-1"})
+	require.NoError(t, err)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		c, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+		ids = append(ids, c.ID)
+	}
+	require.NoError(t, r.Storage.AddReaction(ctx, ids[2], "user-3", domain.ReactionLike))
+	require.NoError(t, r.Storage.AddReaction(ctx, ids[0], "user-3", domain.ReactionDislike))
+	contents := func(conn *model.CommentConnection) []string {
+		got := make([]string, len(conn.Edges))
+		for i, e := range conn.Edges {
+			got[i] = e.Node.Content
+		}
+		return got
+	}
+
+	top := domain.CommentOrderTop
+	conn, err := r.Post().Comments(ctx, post, intPtr(2), nil, nil, nil, &top)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"comment 2", "comment 1"}, contents(conn))
+	assert.True(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Post().Comments(ctx, post, intPtr(2), conn.PageInfo.EndCursor, nil, nil, &top)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"comment 0"}, contents(conn))
+	assert.False(t, conn.PageInfo.HasNextPage)
+
+	conn, err = r.Post().Comments(ctx, post, nil, nil, intPtr(1), conn.PageInfo.EndCursor, &top)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"comment 1"}, contents(conn))
+}
+
+func TestQueryResolver_SearchComments(t *testing.T) {
+	r := newTestResolver(t)
+	r.SearchLimiter = NewRateLimiter(60, 2)
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "user-1", Role: auth.RoleUser})
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	visible, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "about <go>"})
+	require.NoError(t, err)
+	hidden, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "go"})
+	require.NoError(t, err)
+	_, err = r.Storage.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	// Неверный запрос отклоняется до лимита и до хранилища
+	_, err = r.Query().SearchComments(ctx, "   ", nil, nil)
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, storage.ValidationCodeBlank, validationErr.Code)
+	_, err = r.Query().SearchComments(ctx, strings.Repeat("a", maxSearchQueryLength+1), nil, nil)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, maxSearchQueryLength, validationErr.Limit)
+
+	res, err := r.Query().SearchComments(ctx, "GO", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, res.Edges, 1)
+	assert.Equal(t, visible.ID, res.Edges[0].Node.ID)
+	assert.Equal(t, "about &lt;<mark>go</mark>&gt;", res.Edges[0].Highlight)
+	assert.Positive(t, res.Edges[0].Score)
+
+	res, err = r.Query().SearchComments(ctx, "go", &post.ID, nil)
+	require.NoError(t, err)
+	assert.Len(t, res.Edges, 1)
+
+	// Запас поисков пользователя исчерпан, у модератора свой лимит, и он видит скрытые
+	_, err = r.Query().SearchComments(ctx, "go", nil, nil)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	modCtx := auth.WithUser(context.Background(), &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+	res, err = r.Query().SearchComments(modCtx, "go", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, res.Edges, 2)
+}
+
+func TestQueryResolver_ServerInfo(t *testing.T) {
+	r := newTestResolver(t)
+
+	r.Config.MaxCommentLength = 500
+	r.Config.MaxCommentDepth = 7
+	r.Config.MaxAttachments = 3
+
+	info, err := r.Query().ServerInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, schemaversion.Version, info.Version)
+	assert.Equal(t, 500, info.MaxCommentLength)
+	assert.Equal(t, 7, info.MaxCommentDepth)
+	assert.Equal(t, 3, info.MaxAttachments)
+	assert.Equal(t, r.Config.MaxPageSize, info.MaxPageSize)
+	assert.Equal(t, r.Config.MaxOffset, info.MaxOffset)
+	assert.Equal(t, []model.SubscriptionTransport{model.SubscriptionTransportWebsocket}, info.SubscriptionTransports)
+}
+
+// countStore считает обращения к хранилищу за числом комментариев.
+type countStore struct {
+	storage.Storage
+	batchCalls  int
+	singleCalls int
+}
+
+func (s *countStore) CountCommentsByPostIDs(ctx context.Context, postIDs []string, includeHidden bool) (map[string]int, error) {
+	s.batchCalls++
+	return s.Storage.CountCommentsByPostIDs(ctx, postIDs, includeHidden)
+}
+
+func (s *countStore) GetPostWithCommentCount(ctx context.Context, id string, includeHidden bool) (*domain.Post, int, error) {
+	s.singleCalls++
+	return s.Storage.GetPostWithCommentCount(ctx, id, includeHidden)
+}
+
+// pageTotalStore считает обращения к хранилищу за страницей комментариев с общим числом.
+type pageTotalStore struct {
+	storage.Storage
+	calls int
+}
+
+func (s *pageTotalStore) GetCommentsPageByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, int, error) {
+	s.calls++
+	return s.Storage.GetCommentsPageByPostID(ctx, postID, args)
+}
+
+func TestPostResolver_Comments_TotalCount(t *testing.T) {
+	r := newTestResolver(t)
+	store := &pageTotalStore{Storage: r.Storage}
+	r.Storage = store
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+
+	var resp struct {
+		Post struct {
+			Comments struct {
+				Edges      []struct{ Cursor string }
+				TotalCount *int
+			}
+		}
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { comments(limit: 2) { edges { cursor } totalCount } } }`, &resp, client.Var("id", post.ID))
+	assert.Len(t, resp.Post.Comments.Edges, 2)
+	require.NotNil(t, resp.Post.Comments.TotalCount)
+	assert.Equal(t, 5, *resp.Post.Comments.TotalCount)
+	assert.Equal(t, 1, store.calls)
+
+	c.MustPost(`query($id: ID!) { post(id: $id) { comments(last: 2) { totalCount } } }`, &resp, client.Var("id", post.ID))
+	assert.Equal(t, 5, *resp.Post.Comments.TotalCount)
+
+	// Без totalCount число не считается
+	c.MustPost(`query($id: ID!) { post(id: $id) { comments(limit: 2) { edges { cursor } } } }`, &resp, client.Var("id", post.ID))
+	assert.Equal(t, 2, store.calls)
+}
+
+func TestQueryResolver_Post_CommentCount(t *testing.T) {
+	r := newTestResolver(t)
+	store := &countStore{Storage: r.Storage}
+	r.Storage = store
+	c := client.New(dataloader.Middleware(store, 10, handler.NewDefaultServer(NewSchema(r))))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, &domain.Post{Title: "Empty", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+
+	// post(id:) получает счетчик вместе с постом, без отдельного батча
+	var single struct {
+		Post struct{ CommentCount int }
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { commentCount } }`, &single, client.Var("id", post.ID))
+	assert.Equal(t, 2, single.Post.CommentCount)
+	assert.Equal(t, 1, store.singleCalls)
+	assert.Equal(t, 0, store.batchCalls)
+
+	// Без commentCount в выборке пост читается обычным запросом
+	var plain struct {
+		Post struct{ Title string }
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { title } }`, &plain, client.Var("id", post.ID))
+	assert.Equal(t, 1, store.singleCalls)
+
+	// В списке счетчики всех постов загружаются одним батчем
+	var list struct {
+		Posts []struct {
+			Title        string
+			CommentCount int
+		}
+	}
+	c.MustPost(`{ posts { title commentCount } }`, &list)
+	counts := map[string]int{}
+	for _, p := range list.Posts {
+		counts[p.Title] = p.CommentCount
+	}
+	assert.Equal(t, map[string]int{"Post": 2, "Empty": 0}, counts)
+	assert.Equal(t, 1, store.batchCalls)
+}
+
+func TestPostResolver_DepthHistogram(t *testing.T) {
+	r := newTestResolver(t)
+	c := client.New(handler.NewDefaultServer(NewSchema(r)))
+	ctx := context.Background()
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	parentID := ""
+	for i := 0; i < 3; i++ {
+		comment := &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: fmt.Sprintf("comment %d", i)}
+		if parentID != "" {
+			comment.ParentID = &parentID
+		}
+		created, err := r.Storage.CreateComment(ctx, comment)
+		require.NoError(t, err)
+		parentID = created.ID
+	}
+	_, err = r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "second root"})
+	require.NoError(t, err)
+
+	var resp struct {
+		Post struct {
+			DepthHistogram []struct{ Depth, Count int }
+		}
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { depthHistogram { depth count } } }`, &resp, client.Var("id", post.ID))
+	assert.Equal(t, []struct{ Depth, Count int }{{0, 2}, {1, 1}, {2, 1}}, resp.Post.DepthHistogram)
+}
+
+func TestMutationResolver_LockThread(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	reply, err := r.Storage.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+
+	_, err = r.Mutation().LockThread(ctx, root.ID, true)
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+
+	locked, err := r.Mutation().LockThread(modCtx, root.ID, true)
+	require.NoError(t, err)
+	assert.True(t, locked.Locked)
+
+	// Ответ на вложенный комментарий закрытой ветки тоже отклоняется
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, ParentID: &reply.ID, AuthorID: "user-3", Content: "Hello"})
+	require.NoError(t, err)
+	assert.Nil(t, payload.Comment)
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, model.UserErrorCodeThreadLocked, payload.UserErrors[0].Code)
+	assert.Equal(t, "parentId", *payload.UserErrors[0].Field)
+
+	// Остальной пост открыт
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "user-3", Content: "Hello"})
+	require.NoError(t, err)
+	require.NotNil(t, payload.Comment)
+
+	_, err = r.Mutation().LockThread(modCtx, root.ID, false)
+	require.NoError(t, err)
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, ParentID: &reply.ID, AuthorID: "user-3", Content: "Hello"})
+	require.NoError(t, err)
+	assert.Empty(t, payload.UserErrors)
+}
+
+func TestMutationResolver_BlockAuthorOnPost(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	ownerCtx := auth.WithUser(ctx, &auth.User{ID: "owner"})
+	modCtx := auth.WithUser(ctx, &auth.User{ID: "mod-1", Role: auth.RoleModerator})
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "owner", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	_, err = r.Mutation().BlockAuthorOnPost(auth.WithUser(ctx, &auth.User{ID: "stranger"}), post.ID, "spammer", nil)
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+
+	_, err = r.Mutation().BlockAuthorOnPost(ownerCtx, post.ID, "spammer", nil)
+	require.NoError(t, err)
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "spammer", Content: "spam"})
+	require.NoError(t, err)
+	assert.Nil(t, payload.Comment)
+	require.Len(t, payload.UserErrors, 1)
+	assert.Equal(t, model.UserErrorCodeAuthorBlocked, payload.UserErrors[0].Code)
+	assert.Equal(t, storage.ErrAuthorBlocked.Error(), payload.UserErrors[0].Message)
+
+	unblock := false
+	_, err = r.Mutation().BlockAuthorOnPost(modCtx, post.ID, "spammer", &unblock)
+	require.NoError(t, err)
+	payload, err = r.Mutation().CreateComment(ctx, model.NewComment{PostID: post.ID, AuthorID: "spammer", Content: "sorry"})
+	require.NoError(t, err)
+	assert.Empty(t, payload.UserErrors)
+
+	_, err = r.Mutation().BlockAuthorOnPost(modCtx, "missing", "spammer", nil)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestPostResolver_CanComment(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+	userCtx := auth.WithUser(ctx, &auth.User{ID: "user-2"})
+
+	post, err := r.Storage.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	can, err := r.Post().CanComment(userCtx, post)
+	require.NoError(t, err)
+	assert.True(t, can)
+
+	// Блокировка действует только на заблокированного пользователя
+	require.NoError(t, r.Storage.BlockAuthor(ctx, post.ID, "user-2"))
+	can, err = r.Post().CanComment(userCtx, post)
+	require.NoError(t, err)
+	assert.False(t, can)
+	can, err = r.Post().CanComment(auth.WithUser(ctx, &auth.User{ID: "user-3"}), post)
+	require.NoError(t, err)
+	assert.True(t, can)
+	require.NoError(t, r.Storage.UnblockAuthor(ctx, post.ID, "user-2"))
+
+	r.ReadOnly = NewReadOnlyMode(true, []string{"createPost"})
+	can, err = r.Post().CanComment(userCtx, post)
+	require.NoError(t, err)
+	assert.True(t, can, "createComment is not blocked by read-only mode")
+	r.ReadOnly = NewReadOnlyMode(true, nil)
+	can, err = r.Post().CanComment(userCtx, post)
+	require.NoError(t, err)
+	assert.False(t, can)
+	r.ReadOnly.SetEnabled(false)
+
+	post.CommentsEnabled = false
+	can, err = r.Post().CanComment(userCtx, post)
+	require.NoError(t, err)
+	assert.False(t, can)
+}
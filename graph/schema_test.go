@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/graph/generated"
+)
+
+// TestSchemaDefaultLimitsMatchResolvers проверяет, что default-значения limit в схеме
+// совпадают с константами резолверов, чтобы они не разошлись незаметно.
+func TestSchemaDefaultLimitsMatchResolvers(t *testing.T) {
+	schema := generated.NewExecutableSchema(generated.Config{Resolvers: &Resolver{}}).Schema()
+
+	cases := []struct {
+		typeName, field, arg string
+		want                 int
+	}{
+		{"Query", "posts", "limit", defaultPostsLimit},
+		{"Query", "postsConnection", "limit", defaultPostsLimit},
+		{"Post", "comments", "limit", defaultCommentsLimit},
+		{"Comment", "children", "limit", defaultChildrenLimit},
+		{"Comment", "siblings", "limit", defaultSiblingsLimit},
+		{"Query", "pendingComments", "limit", defaultPendingLimit},
+		{"Query", "unansweredComments", "limit", defaultCommentsLimit},
+		{"Query", "adminPosts", "limit", defaultPostsLimit},
+		{"Query", "recentComments", "limit", defaultRecentLimit},
+		{"Query", "authorActivity", "limit", defaultActivityLimit},
+		{"Query", "childrenOf", "limitPerParent", defaultChildrenLimit},
+		{"Query", "flatComments", "limit", defaultFlatLimit},
+		{"Query", "commentsWithPreview", "limit", defaultCommentsLimit},
+		{"Query", "commentsWithPreview", "repliesPerComment", defaultPreviewLimit},
+		{"Query", "searchComments", "limit", defaultSearchLimit},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.typeName+"."+tc.field, func(t *testing.T) {
+			def := schema.Types[tc.typeName]
+			require.NotNil(t, def)
+			field := def.Fields.ForName(tc.field)
+			require.NotNil(t, field)
+			arg := field.Arguments.ForName(tc.arg)
+			require.NotNil(t, arg)
+			require.NotNil(t, arg.DefaultValue, "%s must have a default value in the schema", tc.arg)
+
+			got, err := strconv.Atoi(arg.DefaultValue.Raw)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// TestSchema_PostsDeprecatedInIntrospection проверяет, что клиенты видят устаревание
+// offset-пагинации постов через интроспекцию.
+func TestSchema_PostsDeprecatedInIntrospection(t *testing.T) {
+	srv := handler.NewDefaultServer(NewSchema(newTestResolver(t)))
+	c := client.New(srv)
+
+	var resp struct {
+		Type struct {
+			Fields []struct {
+				Name              string
+				IsDeprecated      bool
+				DeprecationReason *string
+			}
+		} `json:"__type"`
+	}
+	c.MustPost(`{ __type(name: "Query") { fields(includeDeprecated: true) { name isDeprecated deprecationReason } } }`, &resp)
+
+	fields := make(map[string]bool)
+	for _, f := range resp.Type.Fields {
+		fields[f.Name] = f.IsDeprecated
+		if f.Name == "posts" {
+			require.NotNil(t, f.DeprecationReason)
+			assert.Contains(t, *f.DeprecationReason, "postsConnection")
+		}
+	}
+	assert.True(t, fields["posts"])
+	assert.False(t, fields["postsConnection"])
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// inputValidator проверяет входные структуры по тегам validate, которые задаются
+// в схеме директивой @goTag. Хранилище по-прежнему проверяет данные само.
+var inputValidator = newInputValidator()
+
+func newInputValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// В ошибках поле называется так же, как в схеме: по тегу json
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		return name
+	})
+	if err := v.RegisterValidation("notblank", validators.NotBlank); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// validateInput проверяет входную структуру и возвращает по ошибке на каждое неверное поле.
+func validateInput(input interface{}) []*storage.ValidationError {
+	err := inputValidator.Struct(input)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil
+	}
+
+	errs := make([]*storage.ValidationError, len(fieldErrs))
+	for i, fe := range fieldErrs {
+		errs[i] = fieldError(fe)
+	}
+	return errs
+}
+
+// fieldError переводит ошибку validator в ошибку валидации с кодом из UserErrorCode.
+func fieldError(fe validator.FieldError) *storage.ValidationError {
+	field := fe.Field()
+	switch fe.Tag() {
+	case "required", "notblank":
+		return &storage.ValidationError{Field: field, Code: storage.ValidationCodeBlank,
+			Err: fmt.Errorf("%s cannot be empty", field)}
+	case "max":
+		limit, _ := strconv.Atoi(fe.Param())
+		return &storage.ValidationError{Field: field, Code: storage.ValidationCodeTooLong, Limit: limit,
+			Err: fmt.Errorf("%s must be at most %d characters", field, limit)}
+	case "uuid":
+		return &storage.ValidationError{Field: field, Code: storage.ValidationCodeInvalid,
+			Err: fmt.Errorf("%s must be a valid UUID", field)}
+	default:
+		return &storage.ValidationError{Field: field, Code: storage.ValidationCodeInvalid,
+			Err: fmt.Errorf("%s is invalid", field)}
+	}
+}
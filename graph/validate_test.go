@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/graph/model"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+func TestMutationResolver_CreateComment_InputValidation(t *testing.T) {
+	r := newTestResolver(t)
+	ctx := context.Background()
+
+	parentID := "not-a-uuid"
+	payload, err := r.Mutation().CreateComment(ctx, model.NewComment{PostID: "42", ParentID: &parentID, AuthorID: " ", Content: "Hello"})
+	require.NoError(t, err)
+	assert.Nil(t, payload.Comment)
+
+	// Ошибка на каждое неверное поле, с именами полей из схемы
+	codes := make(map[string]model.UserErrorCode)
+	for _, e := range payload.UserErrors {
+		require.NotNil(t, e.Field)
+		codes[*e.Field] = e.Code
+	}
+	assert.Equal(t, map[string]model.UserErrorCode{
+		"postId":   model.UserErrorCodeInvalid,
+		"parentId": model.UserErrorCodeInvalid,
+		"authorId": model.UserErrorCodeBlank,
+	}, codes)
+}
+
+func TestMutationResolver_CreatePost_InputValidation(t *testing.T) {
+	r := newTestResolver(t)
+
+	// title и content проверяет директива @constraint, остальные поля - теги validate
+	_, err := r.Mutation().CreatePost(context.Background(), model.NewPost{Title: "Post", Content: "Content", AuthorID: strings.Repeat("a", 256)})
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "authorId", validationErr.Field)
+	assert.Equal(t, storage.ValidationCodeTooLong, validationErr.Code)
+	assert.Equal(t, 255, validationErr.Limit)
+}
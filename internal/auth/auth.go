@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Role - роль пользователя в системе.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+)
+
+// Заголовки, которые проставляет API-шлюз после аутентификации.
+// Сервис не проверяет учетные данные сам и доверяет шлюзу.
+const (
+	userIDHeader = "X-User-ID"
+	roleHeader   = "X-User-Role"
+)
+
+// ErrForbidden возвращается, когда у пользователя недостаточно прав.
+var ErrForbidden = errors.New("forbidden: insufficient permissions")
+
+// User - аутентифицированный пользователь текущего запроса.
+type User struct {
+	ID   string
+	Role Role
+}
+
+type contextKey string
+
+const userKey = contextKey("user")
+
+// Middleware извлекает пользователя из заголовков запроса и кладет его в контекст.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(userIDHeader)
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		role := Role(r.Header.Get(roleHeader))
+		if role != RoleModerator {
+			role = RoleUser
+		}
+
+		ctx := WithUser(r.Context(), &User{ID: id, Role: role})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithUser возвращает контекст с указанным пользователем.
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// ForContext извлекает пользователя из контекста. Возвращает nil для анонимных запросов.
+func ForContext(ctx context.Context) *User {
+	user, _ := ctx.Value(userKey).(*User)
+	return user
+}
+
+// IsModerator сообщает, является ли текущий пользователь модератором.
+func IsModerator(ctx context.Context) bool {
+	user := ForContext(ctx)
+	return user != nil && user.Role == RoleModerator
+}
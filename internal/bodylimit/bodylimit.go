@@ -0,0 +1,46 @@
+// Package bodylimit ограничивает размер тела HTTP-запроса.
+package bodylimit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Middleware отклоняет запросы с телом больше maxBytes ответом 413 Request Entity Too Large.
+// Тело читается не больше чем на maxBytes, поэтому огромный запрос не буферизуется целиком.
+// Проверка нужна до разбора GraphQL: транспорт gqlgen иначе вернул бы на обрезанное тело
+// невнятную ошибку декодирования JSON.
+func Middleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Если клиент заранее сообщил размер, не читаем тело вовсе
+			if r.ContentLength > maxBytes {
+				tooLarge(w)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					tooLarge(w)
+					return
+				}
+				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func tooLarge(w http.ResponseWriter) {
+	http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,41 @@
+package bodylimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var received string
+	handler := Middleware(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Тело в пределах лимита доходит до обработчика целиком
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"query":"{}"}`))))
+	assert.Equal(t, `{"query":"{}"}`, received)
+
+	// Слишком большое тело с известным Content-Length
+	assert.Equal(t, http.StatusRequestEntityTooLarge,
+		serve(httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(strings.Repeat("x", 1024)))))
+
+	// Слишком большое тело без Content-Length (chunked)
+	req := httptest.NewRequest(http.MethodPost, "/query", io.MultiReader(strings.NewReader(strings.Repeat("x", 1024))))
+	req.ContentLength = -1
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(req))
+
+	// Запросы без тела (например, websocket-апгрейд) пропускаются
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodGet, "/query", nil)))
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"flag"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UkralStul/graphql-comments-service/internal/mention"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+const (
+	defaultPort           = "8080"
+	defaultPlaygroundPath = "/"
+	defaultMaxPageSize    = 100
+	defaultMaxOffset      = 10000
+	// defaultMaxRequestBodyBytes с запасом вмещает запрос с комментарием максимальной длины
+	defaultMaxRequestBodyBytes = 1 << 20
+	// defaultMaxImportBodyBytes вмещает выгрузку треда из нескольких тысяч комментариев
+	defaultMaxImportBodyBytes  = 32 << 20
+	defaultReportHideThreshold = 5
+	defaultSlowQueryThreshold  = time.Second
+	// defaultQueryComplexityLimit пропускает страницу поста с двумя уровнями ответов
+	// при limit по умолчанию и отклоняет вложенные children с большими limit
+	defaultQueryComplexityLimit = 5000
+	defaultReadHeaderTimeout    = 5 * time.Second
+	defaultReadTimeout          = 30 * time.Second
+	defaultWriteTimeout         = 30 * time.Second
+	defaultIdleTimeout          = 2 * time.Minute
+	// defaultSubscriptionFanOutLimit совпадает с graph.DefaultFanOutLimit
+	defaultSubscriptionFanOutLimit = 64
+	// defaultSubscriptionPostCacheTTL - сколько помнится проверка поста при подписке
+	defaultSubscriptionPostCacheTTL = 5 * time.Second
+	// defaultLoaderChildrenLimit покрывает первую страницу children с максимальным limit
+	defaultLoaderChildrenLimit = defaultMaxPageSize + 1
+	// defaultSearchRateLimit и defaultSearchRateBurst - поиск по запросу раз в пару секунд
+	// с запасом на несколько быстрых уточнений подряд
+	defaultSearchRateLimit = 30
+	defaultSearchRateBurst = 5
+)
+
+// defaultErrorExtensions включает все расширения ошибок, см. graph.DefaultErrorFormat.
+var defaultErrorExtensions = []string{"field", "code", "limit"}
+
+// Config содержит настройки приложения, собранные из флагов и переменных окружения.
+type Config struct {
+	Port        string
+	StorageType string
+	DatabaseURL string
+
+	// Production включает режим продакшена: интроспекция и playground отключаются.
+	Production           bool
+	IntrospectionEnabled bool
+	PlaygroundEnabled    bool
+	// PlaygroundPath - путь, по которому отдается playground.
+	PlaygroundPath string
+	// PlaygroundUser и PlaygroundPassword включают basic auth для playground, если заданы оба.
+	PlaygroundUser     string
+	PlaygroundPassword string
+
+	// ReadOnly запускает сервис в режиме обслуживания: мутации отклоняются, чтение работает.
+	ReadOnly bool
+	// ReadOnlyMutations - мутации, запрещенные в режиме ReadOnly. Пустой список запрещает все.
+	ReadOnlyMutations []string
+	// ErrorExtensions - расширения типизированных ошибок GraphQL (field, code, limit); "none" - только сообщение.
+	ErrorExtensions []string
+
+	// CORSAllowedOrigins - список origin'ов, которым разрешено обращаться к API из браузера.
+	// Пустой список означает политику same-origin. "*" не допускается: CORS отдается
+	// с учетными данными, и любой сайт смог бы обращаться к API от имени пользователя.
+	CORSAllowedOrigins []string
+
+	// MaxRequestBodyBytes - максимальный размер тела запроса к /query в байтах.
+	MaxRequestBodyBytes int64
+	// MaxImportBodyBytes - максимальный размер выгрузки, принимаемой POST /posts/import, в байтах.
+	MaxImportBodyBytes int64
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout и IdleTimeout - таймауты http.Server, 0 - выключено.
+	// Потоковые запросы подписок освобождаются от ReadTimeout и WriteTimeout.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// DBLogLevel - уровень журнала SQL-запросов postgres. На уровне info пишется каждый запрос
+	// с параметрами, включая тексты комментариев, поэтому в продакшене по умолчанию warn.
+	DBLogLevel storage.LogLevel
+	// DBSlowQueryThreshold - SQL-запросы дольше порога пишутся в лог с GraphQL-операцией,
+	// если DBLogLevel не ниже warn. 0 - выключено.
+	DBSlowQueryThreshold time.Duration
+
+	// SlowQueryThreshold - операции дольше этого порога пишутся в лог со стоимостью, 0 - выключено.
+	SlowQueryThreshold time.Duration
+	// QueryComplexityLimit - максимальная стоимость операции, 0 - выключено.
+	// Стоимость children и comments умножается на limit, см. graph/complexity.go.
+	QueryComplexityLimit int
+
+	// MaxPageSize - максимальный размер страницы; большие limit обрезаются до него.
+	MaxPageSize int
+	// MaxOffset - максимальный offset для offset-пагинации постов.
+	// Глубокие offset'ы стоят O(n), для них следует использовать курсоры.
+	MaxOffset int
+	// LoaderChildrenLimit - сколько ответов на комментарий загружает Dataloader за раз.
+	// Более длинные списки ответов доступны только через пагинацию children.
+	LoaderChildrenLimit int
+	// MaxCommentDepth - максимальная глубина вложенности комментариев.
+	MaxCommentDepth int
+	// MaxCommentLength - максимальная длина комментария в символах.
+	MaxCommentLength int
+	// MaxAttachments - сколько вложений может быть у комментария, 0 запрещает вложения.
+	MaxAttachments int
+	// CommentAllowedControlChars - управляющие символы, допустимые в тексте комментария.
+	CommentAllowedControlChars string
+	// NormalizeComments включает нормализацию пробелов и пустых строк в комментариях.
+	NormalizeComments bool
+	// DuplicateCommentWindow - окно антиспам-проверки повторных комментариев, 0 - выключено.
+	DuplicateCommentWindow time.Duration
+	// UniqueComments запрещает автору повторять текст комментария в посте, не только в окне.
+	UniqueComments bool
+	// MentionPattern - регулярное выражение для поиска упоминаний @handle.
+	MentionPattern string
+	// MentionUserServiceURL - адрес сервиса пользователей для проверки упоминаний,
+	// пустое значение отключает проверку.
+	MentionUserServiceURL string
+	// ReportHideThreshold - число жалоб от разных пользователей, после превышения
+	// которого комментарий скрывается до проверки модератором. 0 - выключено.
+	ReportHideThreshold int
+	// Premoderation - новые комментарии скрыты до одобрения модератором.
+	Premoderation bool
+	// DraftPosts - новые посты создаются черновиками и попадают в списки после publishPost.
+	DraftPosts bool
+	// CascadeDeletes - внешние ключи комментариев в postgres удаляют комментарии вместе
+	// с постом или родителем (CASCADE), а не запрещают удаление.
+	CascadeDeletes bool
+	// SubscriptionFanOutLimit - сколько рассылок событий подписчикам идет одновременно.
+	SubscriptionFanOutLimit int
+	// SubscriptionPostCacheTTL - время жизни кеша существования постов для подписок, 0 - выключено.
+	SubscriptionPostCacheTTL time.Duration
+	// SubscriptionMaxLifetime - сколько живет подписка commentAdded до завершения сервером, 0 - без ограничения.
+	SubscriptionMaxLifetime time.Duration
+	// CommentPostCacheTTL - время жизни кеша состояния постов для проверки перед созданием
+	// комментария, 0 - строгий режим без кеша.
+	CommentPostCacheTTL time.Duration
+	// SearchRateLimit - сколько поисков комментариев в минуту разрешено пользователю, 0 - без ограничения.
+	SearchRateLimit int
+	// SearchRateBurst - сколько поисков подряд пользователь может сделать до ограничения частоты.
+	SearchRateBurst int
+	// StorageMetrics включает подсчет вызовов и длительности методов хранилища,
+	// статистика отдается модераторам на /metrics/storage.
+	StorageMetrics bool
+	// TimeOrderedIDs включает UUIDv7 вместо случайных UUID для новых постов и комментариев.
+	TimeOrderedIDs bool
+}
+
+// Load разбирает флаги командной строки и переменные окружения.
+func Load() *Config {
+	cfg := &Config{}
+
+	flag.StringVar(&cfg.StorageType, "storage", "in-memory", "Storage type (in-memory, postgres or hybrid)")
+	flag.BoolVar(&cfg.Production, "production", false, "Run in production mode (disables introspection and playground)")
+	flag.BoolVar(&cfg.ReadOnly, "read-only", envBool("READ_ONLY", false), "Reject mutations while serving queries and subscriptions")
+	flag.Parse()
+
+	cfg.Port = os.Getenv("PORT")
+	if cfg.Port == "" {
+		cfg.Port = defaultPort
+	}
+	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
+	cfg.CORSAllowedOrigins = splitList(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	if slices.Contains(cfg.CORSAllowedOrigins, "*") {
+		log.Fatalf("config: CORS_ALLOWED_ORIGINS must list origins explicitly, \"*\" is not allowed with credentials")
+	}
+	cfg.ReadOnlyMutations = splitList(os.Getenv("READ_ONLY_MUTATIONS"))
+	cfg.ErrorExtensions = splitList(os.Getenv("ERROR_EXTENSIONS"))
+	if len(cfg.ErrorExtensions) == 0 {
+		cfg.ErrorExtensions = defaultErrorExtensions
+	}
+	cfg.MaxRequestBodyBytes = int64(envInt("MAX_REQUEST_BODY_BYTES", defaultMaxRequestBodyBytes))
+	cfg.MaxImportBodyBytes = int64(envInt("MAX_IMPORT_BODY_BYTES", defaultMaxImportBodyBytes))
+	cfg.ReadHeaderTimeout = envDuration("READ_HEADER_TIMEOUT", defaultReadHeaderTimeout)
+	cfg.ReadTimeout = envDuration("READ_TIMEOUT", defaultReadTimeout)
+	cfg.WriteTimeout = envDuration("WRITE_TIMEOUT", defaultWriteTimeout)
+	cfg.IdleTimeout = envDuration("IDLE_TIMEOUT", defaultIdleTimeout)
+	cfg.SlowQueryThreshold = envDuration("SLOW_QUERY_THRESHOLD", defaultSlowQueryThreshold)
+	cfg.QueryComplexityLimit = envInt("QUERY_COMPLEXITY_LIMIT", defaultQueryComplexityLimit)
+	cfg.MaxPageSize = envInt("MAX_PAGE_SIZE", defaultMaxPageSize)
+	cfg.MaxOffset = envInt("MAX_OFFSET", defaultMaxOffset)
+	cfg.LoaderChildrenLimit = envInt("LOADER_CHILDREN_LIMIT", defaultLoaderChildrenLimit)
+	cfg.MaxCommentDepth = envInt("MAX_COMMENT_DEPTH", storage.DefaultMaxDepth)
+	cfg.MaxCommentLength = envInt("MAX_COMMENT_LENGTH", storage.DefaultMaxCommentLength)
+	cfg.MaxAttachments = envInt("MAX_ATTACHMENTS", storage.DefaultMaxAttachments)
+	cfg.CommentAllowedControlChars = envEscaped("COMMENT_ALLOWED_CONTROL_CHARS", storage.DefaultAllowedControlChars)
+	cfg.NormalizeComments = envBool("NORMALIZE_COMMENTS", true)
+	cfg.DuplicateCommentWindow = envDuration("DUPLICATE_COMMENT_WINDOW", 0)
+	cfg.UniqueComments = envBool("UNIQUE_COMMENTS", false)
+	cfg.MentionPattern = os.Getenv("MENTION_PATTERN")
+	if cfg.MentionPattern == "" {
+		cfg.MentionPattern = mention.DefaultPattern
+	}
+	cfg.MentionUserServiceURL = os.Getenv("MENTION_USER_SERVICE_URL")
+	cfg.ReportHideThreshold = envInt("REPORT_HIDE_THRESHOLD", defaultReportHideThreshold)
+	cfg.Premoderation = envBool("PREMODERATION", false)
+	cfg.DraftPosts = envBool("DRAFT_POSTS", false)
+	cfg.SubscriptionFanOutLimit = envInt("SUBSCRIPTION_FANOUT_LIMIT", defaultSubscriptionFanOutLimit)
+	cfg.SubscriptionPostCacheTTL = envDuration("SUBSCRIPTION_POST_CACHE_TTL", defaultSubscriptionPostCacheTTL)
+	cfg.SubscriptionMaxLifetime = envDuration("SUBSCRIPTION_MAX_LIFETIME", 0)
+	cfg.CommentPostCacheTTL = envDuration("COMMENT_POST_CACHE_TTL", 0)
+	cfg.SearchRateLimit = envInt("SEARCH_RATE_LIMIT", defaultSearchRateLimit)
+	cfg.SearchRateBurst = envInt("SEARCH_RATE_BURST", defaultSearchRateBurst)
+	cfg.StorageMetrics = envBool("STORAGE_METRICS", false)
+	cfg.TimeOrderedIDs = envBool("TIME_ORDERED_IDS", false)
+	cfg.CascadeDeletes = envBool("CASCADE_DELETES", false)
+
+	cfg.DBSlowQueryThreshold = envDuration("DB_SLOW_QUERY_THRESHOLD", storage.DefaultSlowQueryThreshold)
+	cfg.DBLogLevel = storage.LogInfo
+	if cfg.Production {
+		cfg.DBLogLevel = storage.LogWarn
+	}
+	if raw := os.Getenv("DB_LOG_LEVEL"); raw != "" {
+		level, err := storage.ParseLogLevel(raw)
+		if err != nil {
+			log.Fatalf("config: DB_LOG_LEVEL: %v", err)
+		}
+		cfg.DBLogLevel = level
+	}
+
+	// В dev-режиме интроспекция и playground нужны для удобной разработки,
+	// в продакшене они раскрывают всю схему, поэтому выключаем их.
+	cfg.IntrospectionEnabled = !cfg.Production
+	cfg.PlaygroundEnabled = envBool("PLAYGROUND_ENABLED", !cfg.Production)
+	cfg.PlaygroundPath = os.Getenv("PLAYGROUND_PATH")
+	if cfg.PlaygroundPath == "" {
+		cfg.PlaygroundPath = defaultPlaygroundPath
+	}
+	if !strings.HasPrefix(cfg.PlaygroundPath, "/") {
+		log.Fatalf("config: PLAYGROUND_PATH must start with /: %q", cfg.PlaygroundPath)
+	}
+	cfg.PlaygroundUser = os.Getenv("PLAYGROUND_USER")
+	cfg.PlaygroundPassword = os.Getenv("PLAYGROUND_PASSWORD")
+	if (cfg.PlaygroundUser == "") != (cfg.PlaygroundPassword == "") {
+		log.Fatalf("config: PLAYGROUND_USER and PLAYGROUND_PASSWORD must be set together")
+	}
+
+	return cfg
+}
+
+// splitList разбирает список значений, разделенных запятыми, отбрасывая пустые элементы.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envInt читает целое число из переменной окружения, возвращая def, если она не задана.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatalf("config: invalid integer in %s: %v", name, err)
+	}
+	return v
+}
+
+// envBool читает булево значение из переменной окружения, возвращая def, если она не задана.
+func envBool(name string, def bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Fatalf("config: invalid boolean in %s: %v", name, err)
+	}
+	return v
+}
+
+// envDuration читает длительность (например, "30s") из переменной окружения.
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("config: invalid duration in %s: %v", name, err)
+	}
+	return v
+}
+
+// envEscaped читает строку с escape-последовательностями Go (например, `\t\n`) из переменной
+// окружения: управляющие символы неудобно задавать в окружении как есть.
+func envEscaped(name, def string) string {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Unquote(`"` + raw + `"`)
+	if err != nil {
+		log.Fatalf("config: invalid escaped string in %s: %q", name, raw)
+	}
+	return v
+}
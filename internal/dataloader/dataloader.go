@@ -2,8 +2,12 @@ package dataloader
 
 import (
 	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
 	"github.com/UkralStul/graphql-comments-service/internal/storage"
 	"github.com/graph-gophers/dataloader"
+	"github.com/vektah/gqlparser/v2/ast"
 	"net/http"
 	"time"
 )
@@ -14,52 +18,237 @@ const key = contextKey("dataloaders")
 
 // Loaders содержит все дата-лоадеры приложения.
 type Loaders struct {
-	ChildrenByCommentID *dataloader.Loader
+	ChildrenByCommentID  *dataloader.Loader
+	CommentByID          *dataloader.Loader
+	PostByID             *dataloader.Loader
+	ReactionsByCommentID *dataloader.Loader
+	CommentCountByPostID *dataloader.Loader
+
+	// ChildrenLimit - сколько первых ответов на комментарий загружает ChildrenByCommentID.
+	// Полный список ответов доступен только через пагинацию поля children.
+	ChildrenLimit int
+}
+
+// newLoaders создает новый набор лоадеров с пустым кешем.
+func newLoaders(store storage.Storage, childrenLimit int) *Loaders {
+	return &Loaders{
+		ChildrenLimit:        childrenLimit,
+		ChildrenByCommentID:  dataloader.NewBatchedLoader(childrenBatchFn(store, childrenLimit), dataloader.WithWait(time.Millisecond*1)),
+		CommentByID:          dataloader.NewBatchedLoader(commentBatchFn(store), dataloader.WithWait(time.Millisecond*1)),
+		PostByID:             dataloader.NewBatchedLoader(postBatchFn(store), dataloader.WithWait(time.Millisecond*1)),
+		ReactionsByCommentID: dataloader.NewBatchedLoader(reactionsBatchFn(store), dataloader.WithWait(time.Millisecond*1)),
+		CommentCountByPostID: dataloader.NewBatchedLoader(commentCountBatchFn(store), dataloader.WithWait(time.Millisecond*1)),
+	}
 }
 
 // Middleware для внедрения лоадеров в контекст запроса.
-func Middleware(store storage.Storage, next http.Handler) http.Handler {
+// childrenLimit ограничивает число ответов, загружаемых на один комментарий.
+func Middleware(store storage.Storage, childrenLimit int, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Создаем батч-функцию для лоадера
-		batchFn := func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
-			// Преобразуем ключи в []string
-			parentIDs := make([]string, len(keys))
-			for i, key := range keys {
-				parentIDs[i] = key.String()
-			}
+		// Создаем лоадеры и помещаем их в контекст
+		ctx := context.WithValue(r.Context(), key, newLoaders(store, childrenLimit))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SubscriptionMiddleware выдает подпискам свежие лоадеры на каждое событие.
+// Middleware срабатывает один раз на websocket-соединение, поэтому без этого все события
+// подписки делили бы лоадеры соединения и получали из их кеша устаревшие данные.
+// Подключается через handler.Server.AroundResponses; остальные операции не затрагивает.
+func SubscriptionMiddleware(store storage.Storage, childrenLimit int) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		if isSubscription(ctx) {
+			ctx = context.WithValue(ctx, key, newLoaders(store, childrenLimit))
+		}
+		return next(ctx)
+	}
+}
+
+func isSubscription(ctx context.Context) bool {
+	if !graphql.HasOperationContext(ctx) {
+		return false
+	}
+	op := graphql.GetOperationContext(ctx).Operation
+	return op != nil && op.Operation == ast.Subscription
+}
+
+// For извлекает лоадеры из контекста. ok == false, если Middleware не применялся
+// (например, в unit-тестах) - тогда вызывающий код должен обращаться к хранилищу напрямую.
+func For(ctx context.Context) (*Loaders, bool) {
+	loaders, ok := ctx.Value(key).(*Loaders)
+	return loaders, ok && loaders != nil
+}
 
-			// Вызываем метод хранилища, который делает ОДИН запрос к БД
-			commentsMap, err := store.GetCommentsByParentIDs(ctx, parentIDs)
-			if err != nil {
-				// В случае ошибки, возвращаем ее для всех ключей
-				results := make([]*dataloader.Result, len(keys))
-				for i := range results {
-					results[i] = &dataloader.Result{Error: err}
-				}
-				return results
+// LoadPost загружает пост через батчинг. Запросы из разных резолверов
+// в пределах одного окна объединяются в один вызов хранилища.
+func (l *Loaders) LoadPost(ctx context.Context, id string) (*domain.Post, error) {
+	res, err := l.PostByID.Load(ctx, dataloader.StringKey(id))()
+	if err != nil {
+		return nil, err
+	}
+	return res.(*domain.Post), nil
+}
+
+// LoadChildren загружает первые ChildrenLimit ответов на комментарий через батчинг.
+func (l *Loaders) LoadChildren(ctx context.Context, parentID string) ([]*domain.Comment, error) {
+	res, err := l.ChildrenByCommentID.Load(ctx, dataloader.StringKey(parentID))()
+	if err != nil {
+		return nil, err
+	}
+	children, _ := res.([]*domain.Comment)
+	return children, nil
+}
+
+// LoadComment загружает комментарий по ID через батчинг.
+func (l *Loaders) LoadComment(ctx context.Context, id string) (*domain.Comment, error) {
+	res, err := l.CommentByID.Load(ctx, dataloader.StringKey(id))()
+	if err != nil {
+		return nil, err
+	}
+	return res.(*domain.Comment), nil
+}
+
+// LoadReactionCounts загружает счетчики реакций комментария через батчинг.
+func (l *Loaders) LoadReactionCounts(ctx context.Context, commentID string) (map[domain.ReactionType]int, error) {
+	res, err := l.ReactionsByCommentID.Load(ctx, dataloader.StringKey(commentID))()
+	if err != nil {
+		return nil, err
+	}
+	return res.(map[domain.ReactionType]int), nil
+}
+
+// LoadCommentCount загружает число комментариев поста через батчинг.
+func (l *Loaders) LoadCommentCount(ctx context.Context, postID string) (int, error) {
+	res, err := l.CommentCountByPostID.Load(ctx, dataloader.StringKey(postID))()
+	if err != nil {
+		return 0, err
+	}
+	return res.(int), nil
+}
+
+// PrimeCommentCount кладет в кеш уже известное число комментариев поста,
+// например полученное вместе с постом, чтобы LoadCommentCount не ходил в хранилище.
+func (l *Loaders) PrimeCommentCount(ctx context.Context, postID string, count int) {
+	l.CommentCountByPostID.Prime(ctx, dataloader.StringKey(postID), count)
+}
+
+// childrenBatchFn создает батч-функцию для загрузки первых limit дочерних комментариев.
+func childrenBatchFn(store storage.Storage, limit int) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		// Преобразуем ключи в []string
+		parentIDs := keys.Keys()
+
+		// Вызываем метод хранилища, который делает ОДИН запрос к БД.
+		// Скрытые по жалобам комментарии видят только модераторы.
+		commentsMap, err := store.GetCommentsByParentIDs(ctx, parentIDs, limit, auth.IsModerator(ctx))
+		if err != nil {
+			return errorResults(len(keys), err)
+		}
+
+		// Формируем результат в том же порядке, что и ключи
+		results := make([]*dataloader.Result, len(keys))
+		for i, parentID := range parentIDs {
+			results[i] = &dataloader.Result{Data: commentsMap[parentID]}
+		}
+
+		return results
+	}
+}
+
+// commentBatchFn создает батч-функцию для загрузки комментариев по ID.
+func commentBatchFn(store storage.Storage) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := keys.Keys()
+
+		commentsMap, err := store.GetCommentsByIDs(ctx, ids)
+		if err != nil {
+			return errorResults(len(keys), err)
+		}
+
+		results := make([]*dataloader.Result, len(keys))
+		for i, id := range ids {
+			if comment, ok := commentsMap[id]; ok {
+				results[i] = &dataloader.Result{Data: comment}
+			} else {
+				results[i] = &dataloader.Result{Error: storage.ErrCommentNotFound}
 			}
+		}
+
+		return results
+	}
+}
+
+// postBatchFn создает батч-функцию для загрузки постов по ID.
+func postBatchFn(store storage.Storage) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := keys.Keys()
+
+		postsMap, err := store.GetPostsByIDs(ctx, ids)
+		if err != nil {
+			return errorResults(len(keys), err)
+		}
 
-			// Формируем результат в том же порядке, что и ключи
-			results := make([]*dataloader.Result, len(keys))
-			for i, parentID := range parentIDs {
-				results[i] = &dataloader.Result{Data: commentsMap[parentID]}
+		results := make([]*dataloader.Result, len(keys))
+		for i, id := range ids {
+			if post, ok := postsMap[id]; ok {
+				results[i] = &dataloader.Result{Data: post}
+			} else {
+				results[i] = &dataloader.Result{Error: storage.ErrPostNotFound}
 			}
+		}
+
+		return results
+	}
+}
+
+// reactionsBatchFn создает батч-функцию для загрузки счетчиков реакций.
+func reactionsBatchFn(store storage.Storage) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := keys.Keys()
 
-			return results
+		countsMap, err := store.GetReactionCountsByCommentIDs(ctx, ids)
+		if err != nil {
+			return errorResults(len(keys), err)
 		}
 
-		// Создаем лоадеры
-		loaders := Loaders{
-			ChildrenByCommentID: dataloader.NewBatchedLoader(batchFn, dataloader.WithWait(time.Millisecond*1)),
+		results := make([]*dataloader.Result, len(keys))
+		for i, id := range ids {
+			counts := countsMap[id]
+			if counts == nil {
+				// Комментарий без реакций
+				counts = map[domain.ReactionType]int{}
+			}
+			results[i] = &dataloader.Result{Data: counts}
 		}
 
-		// Помещаем их в контекст
-		ctx := context.WithValue(r.Context(), key, &loaders)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+		return results
+	}
+}
+
+// commentCountBatchFn создает батч-функцию для подсчета комментариев постов.
+func commentCountBatchFn(store storage.Storage) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		postIDs := keys.Keys()
+
+		// Скрытые комментарии учитываются только для модераторов - как в списках
+		counts, err := store.CountCommentsByPostIDs(ctx, postIDs, auth.IsModerator(ctx))
+		if err != nil {
+			return errorResults(len(keys), err)
+		}
+
+		results := make([]*dataloader.Result, len(keys))
+		for i, id := range postIDs {
+			results[i] = &dataloader.Result{Data: counts[id]}
+		}
+		return results
+	}
 }
 
-// For извлекает лоадеры из контекста.
-func For(ctx context.Context) *Loaders {
-	return ctx.Value(key).(*Loaders)
+// errorResults возвращает одну и ту же ошибку для всех ключей батча.
+func errorResults(n int, err error) []*dataloader.Result {
+	results := make([]*dataloader.Result, n)
+	for i := range results {
+		results[i] = &dataloader.Result{Error: err}
+	}
+	return results
 }
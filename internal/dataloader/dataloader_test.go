@@ -0,0 +1,125 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/graph-gophers/dataloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+// countingStore считает обращения к батч-методам хранилища.
+type countingStore struct {
+	storage.Storage
+	reactionCalls atomic.Int32
+}
+
+func (s *countingStore) GetReactionCountsByCommentIDs(ctx context.Context, ids []string) (map[string]map[domain.ReactionType]int, error) {
+	s.reactionCalls.Add(1)
+	return s.Storage.GetReactionCountsByCommentIDs(ctx, ids)
+}
+
+// loadersFor возвращает лоадеры, которые Middleware кладет в контекст запроса.
+func loadersFor(t *testing.T, store storage.Storage) *Loaders {
+	t.Helper()
+	var loaders *Loaders
+	handler := Middleware(store, 10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loaders, _ = For(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotNil(t, loaders)
+	return loaders
+}
+
+func TestLoadReactionCounts_SingleBatch(t *testing.T) {
+	ctx := context.Background()
+	mem := inmemory.New()
+	post, err := mem.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	ids := make([]string, 50)
+	for i := range ids {
+		c, err := mem.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+		ids[i] = c.ID
+		if i%2 == 0 {
+			require.NoError(t, mem.AddReaction(ctx, c.ID, "user-2", domain.ReactionLike))
+		}
+	}
+
+	store := &countingStore{Storage: mem}
+	loaders := loadersFor(t, store)
+
+	// Как резолверы списка: сначала запрашиваем все ключи, затем ждем результаты
+	thunks := make([]dataloader.Thunk, len(ids))
+	for i, id := range ids {
+		thunks[i] = loaders.ReactionsByCommentID.Load(ctx, dataloader.StringKey(id))
+	}
+	for i, thunk := range thunks {
+		res, err := thunk()
+		require.NoError(t, err)
+		counts := res.(map[domain.ReactionType]int)
+		if i%2 == 0 {
+			assert.Equal(t, 1, counts[domain.ReactionLike])
+		} else {
+			assert.Empty(t, counts)
+		}
+	}
+
+	assert.Equal(t, int32(1), store.reactionCalls.Load())
+}
+
+func TestSubscriptionMiddleware_FreshLoadersPerEvent(t *testing.T) {
+	ctx := context.Background()
+	store := inmemory.New()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "text"})
+	require.NoError(t, err)
+
+	middleware := SubscriptionMiddleware(store, 10)
+	// Контекст подписки без лоадеров от HTTP-middleware
+	subCtx := graphql.WithOperationContext(ctx, &graphql.OperationContext{
+		Operation: &ast.OperationDefinition{Operation: ast.Subscription},
+	})
+
+	var seen []*Loaders
+	for i := 0; i < 2; i++ {
+		middleware(subCtx, func(ctx context.Context) *graphql.Response {
+			loaders, ok := For(ctx)
+			require.True(t, ok)
+			loaded, err := loaders.LoadComment(ctx, comment.ID)
+			require.NoError(t, err)
+			assert.Equal(t, comment.ID, loaded.ID)
+
+			_, err = loaders.LoadComment(ctx, "missing")
+			assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+
+			seen = append(seen, loaders)
+			return &graphql.Response{}
+		})
+	}
+	require.Len(t, seen, 2)
+	assert.NotSame(t, seen[0], seen[1])
+
+	// Запросы и мутации продолжают использовать лоадеры из Middleware
+	queryCtx := graphql.WithOperationContext(ctx, &graphql.OperationContext{
+		Operation: &ast.OperationDefinition{Operation: ast.Query},
+	})
+	middleware(queryCtx, func(ctx context.Context) *graphql.Response {
+		_, ok := For(ctx)
+		assert.False(t, ok)
+		return &graphql.Response{}
+	})
+}
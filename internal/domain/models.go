@@ -8,18 +8,156 @@ type Post struct {
 	Title           string     `json:"title" gorm:"type:varchar(255);not null"`
 	Content         string     `json:"content" gorm:"type:text;not null"`
 	AuthorID        string     `json:"authorId" gorm:"type:varchar(255);not null"`
-	CommentsEnabled bool       `json:"commentsEnabled" gorm:"not null;default:true"`
+	CommentsEnabled bool       `json:"commentsEnabled" gorm:"not null"` // без default: gorm подставил бы его вместо false
 	CreatedAt       time.Time  `json:"createdAt" gorm:"not null;default:now()"`
+	LastCommentAt   *time.Time `json:"lastCommentAt,omitempty"`    // время последнего комментария, nil - комментариев нет
 	Comments        []*Comment `json:"-" gorm:"foreignKey:PostID"` // gorm only
+
+	// Draft - пост еще не опубликован: в списках постов его видят только автор и модераторы.
+	// Хранится признак черновика, а не публикации, чтобы существующие посты остались опубликованными.
+	Draft bool `json:"draft" gorm:"not null;default:false"`
+	// DefaultCommentOrder - порядок комментариев верхнего уровня, выбранный автором поста.
+	// Действует, когда клиент не указал порядок явно.
+	DefaultCommentOrder CommentOrder `json:"defaultCommentOrder" gorm:"type:varchar(16);not null;default:'OLDEST_FIRST'"`
+}
+
+// CommentOrder - порядок комментариев верхнего уровня поста.
+type CommentOrder string
+
+const (
+	// CommentOrderOldest - сначала старые, в порядке создания (по умолчанию).
+	CommentOrderOldest CommentOrder = "OLDEST_FIRST"
+	// CommentOrderNewest - сначала новые.
+	CommentOrderNewest CommentOrder = "NEWEST_FIRST"
+	// CommentOrderTop - сначала комментарии с наибольшим рейтингом по реакциям (см. ReactionType.Weight),
+	// при равном рейтинге - в порядке создания.
+	CommentOrderTop CommentOrder = "TOP"
+)
+
+// Published сообщает, что пост опубликован.
+func (p *Post) Published() bool {
+	return !p.Draft
 }
 
 // Comment представляет комментарий к посту.
 type Comment struct {
 	ID        string     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	PostID    string     `json:"postId" gorm:"type:uuid;not null;index"`
-	ParentID  *string    `json:"parentId,omitempty" gorm:"type:uuid;index"`
+	ParentID  *string    `json:"parentId,omitempty" gorm:"type:uuid;index;check:chk_comments_parent_not_self,parent_id <> id"`
 	AuthorID  string     `json:"authorId" gorm:"type:varchar(255);not null"`
 	Content   string     `json:"content" gorm:"type:varchar(2000);not null"`
 	CreatedAt time.Time  `json:"createdAt" gorm:"not null;default:now()"`
-	Children  []*Comment `json:"-" gorm:"foreignKey:ParentID"` // gorm only
+	Mentions  []string   `json:"mentions" gorm:"serializer:json;type:jsonb;not null;default:'[]'"` // handle'ы из @упоминаний
+	Hidden    bool       `json:"hidden" gorm:"not null;default:false;index"`                       // скрыт по жалобам до проверки модератором
+	Locked    bool       `json:"locked" gorm:"not null;default:false"`                             // ветка закрыта для новых ответов модератором
+	Children  []*Comment `json:"-" gorm:"foreignKey:ParentID"`                                     // gorm only
+
+	// Attachments - необязательные вложения: изображения и ссылки с превью.
+	Attachments []Attachment `json:"attachments,omitempty" gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+
+	// Status - статус премодерации. Существующие комментарии считаются одобренными.
+	Status CommentStatus `json:"status" gorm:"type:varchar(16);not null;default:'APPROVED';index"`
+
+	// Score - рейтинг комментария по реакциям на момент выборки. Заполняется только
+	// в выборках в порядке CommentOrderTop и попадает в курсор комментария.
+	Score *int `json:"-" gorm:"-"`
+}
+
+// Attachment - вложение комментария: изображение или ссылка, для которой клиент показывает превью.
+type Attachment struct {
+	URL   string         `json:"url"`
+	Type  AttachmentType `json:"type"`
+	Title *string        `json:"title,omitempty"`
+}
+
+// AttachmentType - вид вложения комментария.
+type AttachmentType string
+
+const (
+	AttachmentImage AttachmentType = "IMAGE"
+	AttachmentLink  AttachmentType = "LINK"
+)
+
+// CommentStatus - статус комментария в премодерации.
+type CommentStatus string
+
+const (
+	// CommentPending - комментарий ждет проверки модератором.
+	CommentPending CommentStatus = "PENDING"
+	// CommentApproved - комментарий виден всем. Без премодерации комментарии сразу одобрены.
+	CommentApproved CommentStatus = "APPROVED"
+	// CommentRejected - комментарий отклонен модератором.
+	CommentRejected CommentStatus = "REJECTED"
+)
+
+// Visible сообщает, виден ли комментарий в списках не модераторам:
+// он одобрен и не скрыт по жалобам.
+func (c *Comment) Visible() bool {
+	return !c.Hidden && c.Status == CommentApproved
+}
+
+// ReactionType - тип реакции на комментарий.
+type ReactionType string
+
+const (
+	ReactionLike    ReactionType = "LIKE"
+	ReactionDislike ReactionType = "DISLIKE"
+)
+
+// ReactionTypes - все типы реакций в порядке вывода.
+var ReactionTypes = []ReactionType{ReactionLike, ReactionDislike}
+
+// Weight - вклад реакции в рейтинг комментария: лайк +1, дизлайк -1.
+func (t ReactionType) Weight() int {
+	switch t {
+	case ReactionLike:
+		return 1
+	case ReactionDislike:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// Reaction - реакция пользователя на комментарий. У пользователя не больше одной
+// реакции на комментарий, повторная реакция заменяет предыдущую.
+type Reaction struct {
+	CommentID string       `json:"commentId" gorm:"type:uuid;primaryKey"`
+	UserID    string       `json:"userId" gorm:"type:varchar(255);primaryKey"`
+	Type      ReactionType `json:"type" gorm:"type:varchar(16);not null;index"`
+	CreatedAt time.Time    `json:"createdAt" gorm:"not null;default:now()"`
+}
+
+// Report - жалоба пользователя на комментарий. От одного пользователя учитывается
+// не больше одной жалобы на комментарий.
+type Report struct {
+	CommentID  string    `json:"commentId" gorm:"type:uuid;primaryKey"`
+	ReporterID string    `json:"reporterId" gorm:"type:varchar(255);primaryKey"`
+	Reason     string    `json:"reason" gorm:"type:varchar(500);not null;default:''"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"not null;default:now()"`
+}
+
+// NotificationChannel - канал доставки уведомлений подписчику поста.
+type NotificationChannel string
+
+const (
+	NotificationEmail NotificationChannel = "EMAIL"
+	NotificationPush  NotificationChannel = "PUSH"
+)
+
+// PostBlock - запрет автору комментировать пост, выставленный автором поста или модератором.
+type PostBlock struct {
+	PostID    string    `json:"postId" gorm:"type:uuid;primaryKey"`
+	AuthorID  string    `json:"authorId" gorm:"type:varchar(255);primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;default:now()"`
+}
+
+// PostSubscription - постоянная подписка пользователя на новые комментарии поста.
+// В отличие от GraphQL-подписок, работает и когда пользователь не в сети:
+// уведомления доставляются по Channel. У пользователя одна подписка на пост.
+type PostSubscription struct {
+	PostID    string              `json:"postId" gorm:"type:uuid;primaryKey"`
+	UserID    string              `json:"userId" gorm:"type:varchar(255);primaryKey"`
+	Channel   NotificationChannel `json:"channel" gorm:"type:varchar(16);not null"`
+	CreatedAt time.Time           `json:"createdAt" gorm:"not null;default:now()"`
 }
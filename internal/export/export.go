@@ -0,0 +1,136 @@
+// Package export отдает пост вместе со всем деревом комментариев в виде JSON
+// для резервного копирования и переноса данных. Работает в обход GraphQL.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// Форматы выгрузки комментариев.
+const (
+	FormatNested = "nested"
+	FormatFlat   = "flat"
+)
+
+// Comment - комментарий в выгрузке. В формате nested ответы вложены в Children,
+// в формате flat Children пуст, а связь задается через ParentID.
+type Comment struct {
+	*domain.Comment
+	Children []*Comment `json:"children,omitempty"`
+}
+
+// Thread - пост с его комментариями.
+type Thread struct {
+	Post     *domain.Post `json:"post"`
+	Format   string       `json:"format"`
+	Comments []*Comment   `json:"comments"`
+}
+
+// Handler обрабатывает GET /posts/{id}/export?format=nested|flat (по умолчанию nested).
+func Handler(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatNested
+		}
+		if format != FormatNested && format != FormatFlat {
+			http.Error(w, "unknown format: expected nested or flat", http.StatusBadRequest)
+			return
+		}
+
+		postID := chi.URLParam(r, "id")
+		post, err := store.GetPostByID(r.Context(), postID)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		comments, err := store.GetCommentTree(r.Context(), postID)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		if !auth.IsModerator(r.Context()) {
+			comments = visibleOnly(comments)
+		}
+
+		thread := &Thread{Post: post, Format: format}
+		if format == FormatFlat {
+			thread.Comments = flatten(comments)
+		} else {
+			thread.Comments = BuildTree(comments)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(thread); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// BuildTree собирает плоский список комментариев в дерево. Порядок комментариев
+// одного уровня сохраняется. Комментарии, чей родитель отсутствует в списке,
+// становятся корнями, чтобы не потерять их при выгрузке.
+func BuildTree(comments []*domain.Comment) []*Comment {
+	nodes := make(map[string]*Comment, len(comments))
+	for _, c := range comments {
+		nodes[c.ID] = &Comment{Comment: c}
+	}
+
+	roots := []*Comment{}
+	for _, c := range comments {
+		node := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// visibleOnly убирает скрытые и не одобренные комментарии вместе с ответами на них.
+// Список идет в порядке обхода дерева, поэтому родитель всегда встречается раньше ответов.
+func visibleOnly(comments []*domain.Comment) []*domain.Comment {
+	dropped := make(map[string]struct{})
+	visible := make([]*domain.Comment, 0, len(comments))
+	for _, c := range comments {
+		if c.ParentID != nil {
+			if _, ok := dropped[*c.ParentID]; ok {
+				dropped[c.ID] = struct{}{}
+				continue
+			}
+		}
+		if !c.Visible() {
+			dropped[c.ID] = struct{}{}
+			continue
+		}
+		visible = append(visible, c)
+	}
+	return visible
+}
+
+func flatten(comments []*domain.Comment) []*Comment {
+	flat := make([]*Comment, len(comments))
+	for i, c := range comments {
+		flat[i] = &Comment{Comment: c}
+	}
+	return flat
+}
+
+func writeStorageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrPostNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	store := inmemory.New()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-3", Content: "second root"})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/posts/{id}/export", Handler(store))
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		rec := get("/posts/" + post.ID + "/export")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var thread Thread
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &thread))
+		assert.Equal(t, post.ID, thread.Post.ID)
+		require.Len(t, thread.Comments, 2)
+		assert.Equal(t, "root", thread.Comments[0].Content)
+		require.Len(t, thread.Comments[0].Children, 1)
+		assert.Equal(t, "reply", thread.Comments[0].Children[0].Content)
+		assert.Equal(t, "second root", thread.Comments[1].Content)
+	})
+
+	t.Run("flat", func(t *testing.T) {
+		rec := get("/posts/" + post.ID + "/export?format=flat")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var thread Thread
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &thread))
+		require.Len(t, thread.Comments, 3)
+		assert.Equal(t, []string{"root", "reply", "second root"}, []string{
+			thread.Comments[0].Content, thread.Comments[1].Content, thread.Comments[2].Content,
+		})
+		assert.Equal(t, root.ID, *thread.Comments[1].ParentID)
+		assert.Empty(t, thread.Comments[1].Children)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/posts/missing/export").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/posts/"+post.ID+"/export?format=xml").Code)
+	})
+
+	t.Run("hidden", func(t *testing.T) {
+		_, err := store.SetCommentHidden(ctx, root.ID, true)
+		require.NoError(t, err)
+
+		var thread Thread
+		rec := get("/posts/" + post.ID + "/export?format=flat")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &thread))
+		// Скрытый комментарий пропадает вместе с ответом на него
+		require.Len(t, thread.Comments, 1)
+		assert.Equal(t, "second root", thread.Comments[0].Content)
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/"+post.ID+"/export?format=flat", nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &auth.User{ID: "mod", Role: auth.RoleModerator}))
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &thread))
+		assert.Len(t, thread.Comments, 3)
+	})
+}
@@ -0,0 +1,196 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// ErrInvalidThread - выгрузка не прошла проверку структуры дерева.
+var ErrInvalidThread = errors.New("invalid thread")
+
+// ImportResult - ответ на успешный импорт.
+type ImportResult struct {
+	PostID   string `json:"postId"`
+	Comments int    `json:"comments"`
+}
+
+// ImportHandler обрабатывает POST /posts/import. Принимает выгрузку в формате
+// nested или flat и воссоздает пост с новыми ID. С ?preserveTimestamps=true
+// сохраняется исходное время создания. Доступен только модераторам,
+// так как позволяет писать от имени любых авторов. Размер тела ограничивает
+// bodylimit.Middleware на маршруте.
+func ImportHandler(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.IsModerator(r.Context()) {
+			http.Error(w, auth.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+
+		preserve := false
+		if raw := r.URL.Query().Get("preserveTimestamps"); raw != "" {
+			v, err := strconv.ParseBool(raw)
+			if err != nil {
+				http.Error(w, "invalid preserveTimestamps value", http.StatusBadRequest)
+				return
+			}
+			preserve = v
+		}
+
+		var thread Thread
+		if err := json.NewDecoder(r.Body).Decode(&thread); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		post, comments, err := PrepareImport(&thread, preserve, time.Now().UTC())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := store.ImportThread(r.Context(), post, comments); err != nil {
+			var vErr *storage.ValidationError
+			if errors.As(err, &vErr) || errors.Is(err, storage.ErrParentNotFound) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// Текст ошибки хранилища может раскрыть детали базы, клиенту он не нужен
+			log.Printf("import thread: %v", err)
+			http.Error(w, "failed to import thread", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&ImportResult{PostID: post.ID, Comments: len(comments)})
+	}
+}
+
+// PrepareImport проверяет дерево выгрузки и строит пост и комментарии с новыми ID.
+// Комментарии возвращаются в порядке обхода в глубину, родитель раньше ответов.
+// Если preserve == false, время создания заменяется на now с сохранением исходного порядка.
+func PrepareImport(thread *Thread, preserve bool, now time.Time) (*domain.Post, []*domain.Comment, error) {
+	if thread.Post == nil {
+		return nil, nil, fmt.Errorf("%w: post is required", ErrInvalidThread)
+	}
+
+	roots := thread.Comments
+	if thread.Format == FormatFlat {
+		var err error
+		if roots, err = treeFromFlat(thread.Comments); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	src := thread.Post
+	post := &domain.Post{
+		ID:              uuid.NewString(),
+		Title:           src.Title,
+		Content:         src.Content,
+		AuthorID:        src.AuthorID,
+		CommentsEnabled: src.CommentsEnabled,
+		CreatedAt:       now,
+	}
+	if preserve && !src.CreatedAt.IsZero() {
+		post.CreatedAt = src.CreatedAt
+	}
+
+	var comments []*domain.Comment
+	seen := make(map[*domain.Comment]struct{})
+	var walk func(nodes []*Comment, parentID *string) error
+	walk = func(nodes []*Comment, parentID *string) error {
+		for _, node := range nodes {
+			if node == nil || node.Comment == nil {
+				return fmt.Errorf("%w: empty comment", ErrInvalidThread)
+			}
+			// Один и тот же узел в двух местах дерева означал бы цикл или дубликат
+			if _, ok := seen[node.Comment]; ok {
+				return fmt.Errorf("%w: comment %s appears twice", ErrInvalidThread, node.ID)
+			}
+			seen[node.Comment] = struct{}{}
+
+			c := &domain.Comment{
+				ID:          uuid.NewString(),
+				PostID:      post.ID,
+				ParentID:    parentID,
+				AuthorID:    node.AuthorID,
+				Content:     node.Content,
+				Mentions:    node.Mentions,
+				Attachments: node.Attachments,
+				CreatedAt:   now.Add(time.Duration(len(comments)) * time.Microsecond),
+			}
+			if preserve && !node.CreatedAt.IsZero() {
+				c.CreatedAt = node.CreatedAt
+			}
+			comments = append(comments, c)
+
+			if err := walk(node.Children, &c.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(roots, nil); err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range comments {
+		if post.LastCommentAt == nil || c.CreatedAt.After(*post.LastCommentAt) {
+			createdAt := c.CreatedAt
+			post.LastCommentAt = &createdAt
+		}
+	}
+
+	return post, comments, nil
+}
+
+// treeFromFlat собирает дерево из плоской выгрузки, проверяя уникальность ID,
+// существование родителей и отсутствие циклов.
+func treeFromFlat(flat []*Comment) ([]*Comment, error) {
+	byID := make(map[string]*domain.Comment, len(flat))
+	for _, c := range flat {
+		if c == nil || c.Comment == nil || c.ID == "" {
+			return nil, fmt.Errorf("%w: flat comments must have an id", ErrInvalidThread)
+		}
+		if _, ok := byID[c.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate comment id %s", ErrInvalidThread, c.ID)
+		}
+		byID[c.ID] = c.Comment
+	}
+
+	comments := make([]*domain.Comment, 0, len(flat))
+	for _, c := range flat {
+		if c.ParentID != nil {
+			if _, ok := byID[*c.ParentID]; !ok {
+				return nil, fmt.Errorf("%w: comment %s references unknown parent %s", ErrInvalidThread, c.ID, *c.ParentID)
+			}
+		}
+		comments = append(comments, c.Comment)
+	}
+
+	// Комментарии, входящие в цикл, недостижимы из корней
+	roots := BuildTree(comments)
+	if reachable := countNodes(roots); reachable != len(comments) {
+		return nil, fmt.Errorf("%w: %d comments form a cycle", ErrInvalidThread, len(comments)-reachable)
+	}
+	return roots, nil
+}
+
+func countNodes(nodes []*Comment) int {
+	n := len(nodes)
+	for _, node := range nodes {
+		n += countNodes(node.Children)
+	}
+	return n
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+func TestImportHandler_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := inmemory.New()
+
+	post, err := src.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := src.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	title := "Screenshot"
+	attachments := []domain.Attachment{{URL: "https://example.com/a.png", Type: domain.AttachmentImage, Title: &title}}
+	reply, err := src.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply", Attachments: attachments})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(auth.Middleware)
+	router.Get("/posts/{id}/export", Handler(src))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/"+post.ID+"/export", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	exported := rec.Body.Bytes()
+
+	dst := inmemory.New()
+	router.Post("/posts/import", ImportHandler(dst))
+
+	importReq := func(body []byte, url string, role auth.Role) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if role != "" {
+			req.Header.Set("X-User-ID", "mod-1")
+			req.Header.Set("X-User-Role", string(role))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, importReq(exported, "/posts/import", auth.RoleUser).Code)
+
+	rec = importReq(exported, "/posts/import?preserveTimestamps=true", auth.RoleModerator)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var res ImportResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.NotEqual(t, post.ID, res.PostID)
+	assert.Equal(t, 2, res.Comments)
+
+	tree, err := dst.GetCommentTree(ctx, res.PostID)
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+	assert.Equal(t, "root", tree[0].Content)
+	assert.Equal(t, "reply", tree[1].Content)
+	assert.Equal(t, tree[0].ID, *tree[1].ParentID)
+	assert.Empty(t, tree[0].Attachments)
+	assert.Equal(t, attachments, tree[1].Attachments)
+	assert.NotEqual(t, reply.ID, tree[1].ID)
+	assert.True(t, reply.CreatedAt.Equal(tree[1].CreatedAt))
+}
+
+// failingImportStore отклоняет импорт ошибкой с деталями, которые не должны попасть клиенту.
+type failingImportStore struct {
+	storage.Storage
+}
+
+func (failingImportStore) ImportThread(ctx context.Context, post *domain.Post, comments []*domain.Comment) error {
+	return errors.New("pq: connection to 10.0.0.5 refused")
+}
+
+func TestImportHandler_HidesStorageErrors(t *testing.T) {
+	body := `{"post": {"title": "Post", "content": "Content", "authorId": "user-1"}}`
+	req := httptest.NewRequest(http.MethodPost, "/posts/import", strings.NewReader(body))
+	req = req.WithContext(auth.WithUser(req.Context(), &auth.User{ID: "mod-1", Role: auth.RoleModerator}))
+	rec := httptest.NewRecorder()
+	ImportHandler(failingImportStore{}).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "failed to import thread\n", rec.Body.String())
+}
+
+func TestPrepareImport_Validation(t *testing.T) {
+	now := time.Now().UTC()
+	post := &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1"}
+	strPtr := func(s string) *string { return &s }
+
+	_, _, err := PrepareImport(&Thread{Comments: []*Comment{}}, false, now)
+	assert.ErrorIs(t, err, ErrInvalidThread)
+
+	// Ссылка на несуществующего родителя
+	_, _, err = PrepareImport(&Thread{Post: post, Format: FormatFlat, Comments: []*Comment{
+		{Comment: &domain.Comment{ID: "a", ParentID: strPtr("missing"), Content: "a"}},
+	}}, false, now)
+	assert.ErrorIs(t, err, ErrInvalidThread)
+
+	// Цикл a -> b -> a
+	_, _, err = PrepareImport(&Thread{Post: post, Format: FormatFlat, Comments: []*Comment{
+		{Comment: &domain.Comment{ID: "root", Content: "root"}},
+		{Comment: &domain.Comment{ID: "a", ParentID: strPtr("b"), Content: "a"}},
+		{Comment: &domain.Comment{ID: "b", ParentID: strPtr("a"), Content: "b"}},
+	}}, false, now)
+	assert.ErrorIs(t, err, ErrInvalidThread)
+
+	// Корректное плоское дерево без сохранения времени: порядок сохраняется
+	newPost, comments, err := PrepareImport(&Thread{Post: post, Format: FormatFlat, Comments: []*Comment{
+		{Comment: &domain.Comment{ID: "root", Content: "root"}},
+		{Comment: &domain.Comment{ID: "child", ParentID: strPtr("root"), Content: "child"}},
+	}}, false, now)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, newPost.ID, comments[1].PostID)
+	assert.Equal(t, comments[0].ID, *comments[1].ParentID)
+	assert.True(t, comments[0].CreatedAt.Before(comments[1].CreatedAt))
+}
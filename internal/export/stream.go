@@ -0,0 +1,83 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+const (
+	// streamPageSize - сколько комментариев читается из хранилища за раз.
+	streamPageSize = 500
+	// streamPageTimeout - за сколько клиент должен принять очередную страницу.
+	// Выгрузка большого обсуждения может идти дольше WriteTimeout сервера, поэтому
+	// дедлайн записи продлевается на каждую страницу: медленный клиент не обрывается,
+	// пока читает, а зависший отключается.
+	streamPageTimeout = 30 * time.Second
+)
+
+// StreamHandler обрабатывает GET /posts/{id}/comments.ndjson: отдает все комментарии поста
+// по одному JSON-объекту в строке в порядке обхода дерева. Комментарии читаются из хранилища
+// страницами по курсору и сразу пишутся в ответ, поэтому обсуждение любого размера
+// не загружается в память целиком. При отключении клиента выгрузка прекращается.
+func StreamHandler(store storage.Storage) http.HandlerFunc {
+	return newStreamHandler(store, streamPageSize)
+}
+
+func newStreamHandler(store storage.Storage, pageSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		postID := chi.URLParam(r, "id")
+		// Пока ничего не записано, об отсутствии поста можно сообщить статусом
+		if _, err := store.GetPostByID(ctx, postID); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="post-`+postID+`-comments.ndjson"`)
+
+		rc := http.NewResponseController(w)
+		enc := json.NewEncoder(w)
+		args := storage.PaginationArgs{Limit: pageSize, IncludeHidden: auth.IsModerator(ctx)}
+		for {
+			// Контекст запроса отменяется, когда клиент отключается
+			if ctx.Err() != nil {
+				return
+			}
+			page, err := store.GetCommentTreePage(ctx, postID, args)
+			if err != nil {
+				// Статус уже отправлен: обрываем ответ, клиент получит неполный файл
+				log.Printf("stream comments of post %s: %v", postID, err)
+				return
+			}
+
+			if err := rc.SetWriteDeadline(time.Now().Add(streamPageTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return
+			}
+			for _, c := range page {
+				if err := enc.Encode(c); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return
+			}
+
+			// Хранилище может вернуть меньше pageSize (см. storage.WithMaxPageSize),
+			// поэтому выгрузка заканчивается только на пустой странице
+			if len(page) == 0 {
+				return
+			}
+			cursor := storage.EncodeCursor(page[len(page)-1])
+			args.Cursor = &cursor
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/auth"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+// pageStore считает страницы, прочитанные выгрузкой, и вызывает onPage после каждой.
+type pageStore struct {
+	storage.Storage
+	pages  int
+	onPage func()
+}
+
+func (s *pageStore) GetCommentTreePage(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	s.pages++
+	if s.onPage != nil {
+		s.onPage()
+	}
+	return s.Storage.GetCommentTreePage(ctx, postID, args)
+}
+
+func TestStreamHandler(t *testing.T) {
+	ctx := context.Background()
+	mem := inmemory.New()
+
+	post, err := mem.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	var want, roots []string
+	for i := 0; i < 3; i++ {
+		root, err := mem.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: fmt.Sprintf("root %d", i)})
+		require.NoError(t, err)
+		reply, err := mem.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: fmt.Sprintf("reply %d", i)})
+		require.NoError(t, err)
+		want = append(want, root.Content, reply.Content)
+		roots = append(roots, root.ID)
+	}
+
+	store := &pageStore{Storage: mem}
+	router := chi.NewRouter()
+	router.Get("/posts/{id}/comments.ndjson", newStreamHandler(store, 4))
+
+	get := func(ctx context.Context, url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil).WithContext(ctx))
+		return rec
+	}
+	lines := func(rec *httptest.ResponseRecorder) []*domain.Comment {
+		var comments []*domain.Comment
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var c domain.Comment
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &c))
+			comments = append(comments, &c)
+		}
+		return comments
+	}
+	contents := func(comments []*domain.Comment) []string {
+		got := make([]string, len(comments))
+		for i, c := range comments {
+			got[i] = c.Content
+		}
+		return got
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		store.pages = 0
+		rec := get(ctx, "/posts/"+post.ID+"/comments.ndjson")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+		comments := lines(rec)
+		assert.Equal(t, want, contents(comments))
+		assert.Equal(t, *comments[1].ParentID, comments[0].ID)
+		assert.Equal(t, 3, store.pages)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(ctx, "/posts/missing/comments.ndjson").Code)
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		// Клиент отключается, пока выгрузка читает первую страницу
+		reqCtx, cancel := context.WithCancel(ctx)
+		store.pages = 0
+		store.onPage = cancel
+		defer func() { store.onPage = nil }()
+
+		rec := get(reqCtx, "/posts/"+post.ID+"/comments.ndjson")
+		assert.Len(t, lines(rec), 4)
+		assert.Equal(t, 1, store.pages)
+	})
+
+	t.Run("hidden", func(t *testing.T) {
+		_, err := mem.SetCommentHidden(ctx, roots[0], true)
+		require.NoError(t, err)
+
+		// Скрытый комментарий пропадает вместе с ответом на него
+		assert.Equal(t, want[2:], contents(lines(get(ctx, "/posts/"+post.ID+"/comments.ndjson"))))
+
+		modCtx := auth.WithUser(ctx, &auth.User{ID: "mod", Role: auth.RoleModerator})
+		assert.Equal(t, want, contents(lines(get(modCtx, "/posts/"+post.ID+"/comments.ndjson"))))
+	})
+}
@@ -0,0 +1,36 @@
+// Package httptimeout снимает серверные таймауты с долгоживущих соединений.
+package httptimeout
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Middleware снимает ReadTimeout и WriteTimeout http.Server для потоковых запросов:
+// websocket-апгрейда и SSE (Accept: text/event-stream). Дедлайны сервера рассчитаны
+// на обычные запросы и оборвали бы подписку через WriteTimeout после ее начала.
+// Остальные запросы проходят с дедлайнами сервера без изменений.
+//
+// После апгрейда net/http сам сбрасывает дедлайны перехваченного соединения,
+// но явный сброс не зависит от этого и нужен SSE, который соединение не перехватывает.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsStreaming(r) {
+			rc := http.NewResponseController(w)
+			// ErrNotSupported означает, что обертка ResponseWriter не дает доступа к соединению;
+			// тогда запрос остается с дедлайнами сервера
+			_ = rc.SetReadDeadline(time.Time{})
+			_ = rc.SetWriteDeadline(time.Time{})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsStreaming сообщает, открывает ли запрос долгоживущее соединение подписки.
+func IsStreaming(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
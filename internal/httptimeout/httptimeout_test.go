@@ -0,0 +1,57 @@
+package httptimeout
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	ts := httptest.NewUnstartedServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ответ позже WriteTimeout сервера
+		time.Sleep(100 * time.Millisecond)
+		_, _ = io.WriteString(w, "event")
+	})))
+	ts.Config.WriteTimeout = 20 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	get := func(accept string) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// Обычный запрос обрывается по WriteTimeout
+	_, err := get("application/json")
+	assert.Error(t, err)
+
+	// Для потокового запроса дедлайн снят
+	body, err := get("text/event-stream")
+	require.NoError(t, err)
+	assert.Equal(t, "event", body)
+}
+
+func TestIsStreaming(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/query", nil)
+	assert.False(t, IsStreaming(req))
+
+	req.Header.Set("Upgrade", "WebSocket")
+	assert.True(t, IsStreaming(req))
+
+	req = httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	assert.True(t, IsStreaming(req))
+}
@@ -0,0 +1,106 @@
+// Package mention извлекает упоминания пользователей (@handle) из текста комментариев.
+package mention
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultPattern - регулярное выражение упоминания по умолчанию.
+// Первая группа захвата - сам handle без символа @.
+const DefaultPattern = `(?:^|[^\w@])@([A-Za-z0-9_]{1,32})\b`
+
+// Validator проверяет, что упомянутый пользователь существует.
+type Validator interface {
+	Exists(ctx context.Context, handle string) (bool, error)
+}
+
+// Parser извлекает упоминания по настраиваемому регулярному выражению
+// и, если задан Validator, оставляет только существующих пользователей.
+type Parser struct {
+	re        *regexp.Regexp
+	validator Validator
+}
+
+// NewParser компилирует pattern. Если в выражении есть группа захвата,
+// handle берется из первой группы, иначе - из всего совпадения без @.
+// validator может быть nil - тогда handle'ы не проверяются.
+func NewParser(pattern string, validator Validator) (*Parser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mention pattern: %w", err)
+	}
+	return &Parser{re: re, validator: validator}, nil
+}
+
+// Extract возвращает уникальные упоминания в порядке появления в тексте.
+func (p *Parser) Extract(ctx context.Context, content string) ([]string, error) {
+	seen := make(map[string]struct{})
+	handles := []string{}
+	for _, m := range p.re.FindAllStringSubmatch(content, -1) {
+		handle := strings.TrimPrefix(m[0], "@")
+		if len(m) > 1 {
+			handle = m[1]
+		}
+		if handle == "" {
+			continue
+		}
+		if _, ok := seen[handle]; ok {
+			continue
+		}
+		seen[handle] = struct{}{}
+
+		if p.validator != nil {
+			ok, err := p.validator.Exists(ctx, handle)
+			if err != nil {
+				return nil, fmt.Errorf("validate mention %q: %w", handle, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		handles = append(handles, handle)
+	}
+	return handles, nil
+}
+
+// HTTPValidator проверяет handle запросом GET {BaseURL}/users/{handle} к сервису пользователей:
+// 200 - пользователь существует, 404 - нет, остальные статусы считаются ошибкой.
+type HTTPValidator struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewHTTPValidator создает валидатор с таймаутом запроса по умолчанию.
+func NewHTTPValidator(baseURL string) *HTTPValidator {
+	return &HTTPValidator{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (v *HTTPValidator) Exists(ctx context.Context, handle string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.BaseURL+"/users/"+url.PathEscape(handle), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("user service returned %s", resp.Status)
+	}
+}
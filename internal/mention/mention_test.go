@@ -0,0 +1,61 @@
+package mention
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_Extract(t *testing.T) {
+	p, err := NewParser(DefaultPattern, nil)
+	require.NoError(t, err)
+
+	handles, err := p.Extract(context.Background(), "@alice привет, @bob! Почта bob@example.com, снова @alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, handles)
+
+	handles, err = p.Extract(context.Background(), "без упоминаний")
+	require.NoError(t, err)
+	assert.Empty(t, handles)
+}
+
+func TestParser_CustomPattern(t *testing.T) {
+	// Выражение без группы захвата - handle берется из всего совпадения
+	p, err := NewParser(`@[a-z]+`, nil)
+	require.NoError(t, err)
+
+	handles, err := p.Extract(context.Background(), "@alice и @Bob")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, handles)
+
+	_, err = NewParser(`(`, nil)
+	assert.Error(t, err)
+}
+
+func TestParser_HTTPValidator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/alice":
+			w.WriteHeader(http.StatusOK)
+		case "/users/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewParser(DefaultPattern, NewHTTPValidator(srv.URL))
+	require.NoError(t, err)
+
+	handles, err := p.Extract(context.Background(), "@alice @ghost")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, handles)
+
+	_, err = p.Extract(context.Background(), "@broken")
+	assert.Error(t, err)
+}
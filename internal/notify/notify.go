@@ -0,0 +1,98 @@
+// Package notify доставляет подписчикам поста уведомления о новых комментариях
+// по email или push. В отличие от GraphQL-подписок, получатель может быть не в сети.
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+// DefaultQueueSize - сколько новых комментариев может ждать рассылки.
+const DefaultQueueSize = 1000
+
+// Notification - уведомление одному подписчику о новом комментарии.
+type Notification struct {
+	UserID  string
+	Channel domain.NotificationChannel
+	Comment *domain.Comment
+}
+
+// Sender доставляет уведомление по его каналу.
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// Followers - источник подписчиков поста. Реализуется storage.Storage.
+type Followers interface {
+	GetFollowers(ctx context.Context, postID string) ([]*domain.PostSubscription, error)
+}
+
+// LogSender пишет уведомления в лог. Используется, пока не подключена
+// настоящая доставка по email и push.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, n Notification) error {
+	log.Printf("notify: %s to %s about comment %s in post %s", n.Channel, n.UserID, n.Comment.ID, n.Comment.PostID)
+	return nil
+}
+
+// Dispatcher ставит новые комментарии в очередь и в фоне рассылает уведомления
+// подписчикам поста. Мутация не ждет ни чтения подписчиков, ни доставки.
+type Dispatcher struct {
+	followers Followers
+	sender    Sender
+	queue     chan *domain.Comment
+}
+
+// NewDispatcher создает рассыльщик с очередью на queueSize комментариев.
+// Рассылка начинается после запуска Run.
+func NewDispatcher(followers Followers, sender Sender, queueSize int) *Dispatcher {
+	return &Dispatcher{
+		followers: followers,
+		sender:    sender,
+		queue:     make(chan *domain.Comment, queueSize),
+	}
+}
+
+// CommentAdded ставит комментарий в очередь на рассылку. Не блокируется:
+// при переполненной очереди комментарий пропускается с записью в лог.
+func (d *Dispatcher) CommentAdded(c *domain.Comment) {
+	select {
+	case d.queue <- c:
+	default:
+		log.Printf("notify: queue is full, dropping notifications for comment %s", c.ID)
+	}
+}
+
+// Run обрабатывает очередь до отмены ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case c := <-d.queue:
+			d.dispatch(ctx, c)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch отправляет уведомления всем подписчикам поста, кроме автора комментария.
+// Ошибка доставки одному подписчику не мешает остальным.
+func (d *Dispatcher) dispatch(ctx context.Context, c *domain.Comment) {
+	subs, err := d.followers.GetFollowers(ctx, c.PostID)
+	if err != nil {
+		log.Printf("notify: failed to get followers of post %s: %v", c.PostID, err)
+		return
+	}
+	for _, sub := range subs {
+		if sub.UserID == c.AuthorID {
+			continue
+		}
+		n := Notification{UserID: sub.UserID, Channel: sub.Channel, Comment: c}
+		if err := d.sender.Send(ctx, n); err != nil {
+			log.Printf("notify: failed to send %s to %s: %v", sub.Channel, sub.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+// recordingSender запоминает отправленные уведомления.
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (s *recordingSender) Send(ctx context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func (s *recordingSender) notifications() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification(nil), s.sent...)
+}
+
+func TestDispatcher_NotifiesFollowersExceptAuthor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := inmemory.New()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = store.FollowPost(ctx, post.ID, "user-1", domain.NotificationEmail)
+	require.NoError(t, err)
+	_, err = store.FollowPost(ctx, post.ID, "user-2", domain.NotificationPush)
+	require.NoError(t, err)
+
+	sender := &recordingSender{}
+	d := NewDispatcher(store, sender, DefaultQueueSize)
+	go d.Run(ctx)
+
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "text"})
+	require.NoError(t, err)
+	d.CommentAdded(comment)
+
+	require.Eventually(t, func() bool { return len(sender.notifications()) == 1 }, time.Second, 5*time.Millisecond)
+	n := sender.notifications()[0]
+	assert.Equal(t, "user-2", n.UserID)
+	assert.Equal(t, domain.NotificationPush, n.Channel)
+	assert.Equal(t, comment.ID, n.Comment.ID)
+}
+
+func TestDispatcher_DropsWhenQueueIsFull(t *testing.T) {
+	d := NewDispatcher(inmemory.New(), &recordingSender{}, 1)
+
+	// Run не запущен: первый комментарий занимает очередь, второй отбрасывается без блокировки
+	d.CommentAdded(&domain.Comment{ID: "c1"})
+	d.CommentAdded(&domain.Comment{ID: "c2"})
+	assert.Len(t, d.queue, 1)
+}
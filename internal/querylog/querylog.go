@@ -0,0 +1,128 @@
+// Package querylog пишет в лог медленные GraphQL-операции вместе с их стоимостью,
+// чтобы находить дорогие клиентские запросы и подбирать лимиты сложности.
+package querylog
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type contextKey string
+
+const resolversKey = contextKey("resolvers")
+
+// SlowQueryLogger - расширение gqlgen, которое логирует запросы и мутации дольше Threshold:
+// имя операции, сложность, число вызванных резолверов, длительность и выбранные поля.
+// Подписки не логируются - они живут долго по определению.
+type SlowQueryLogger struct {
+	Threshold time.Duration
+	// Logf по умолчанию - log.Printf.
+	Logf func(format string, args ...interface{})
+
+	schema graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = &SlowQueryLogger{}
+
+// New создает логгер медленных операций с указанным порогом.
+func New(threshold time.Duration) *SlowQueryLogger {
+	return &SlowQueryLogger{Threshold: threshold}
+}
+
+func (l *SlowQueryLogger) ExtensionName() string {
+	return "SlowQueryLogger"
+}
+
+// Validate запоминает схему - она нужна для подсчета сложности.
+func (l *SlowQueryLogger) Validate(schema graphql.ExecutableSchema) error {
+	l.schema = schema
+	return nil
+}
+
+func (l *SlowQueryLogger) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil || oc.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+
+	resolvers := new(int64)
+	resp := next(context.WithValue(ctx, resolversKey, resolvers))
+
+	start := oc.Stats.OperationStart
+	if start.IsZero() {
+		return resp
+	}
+	if duration := time.Since(start); duration >= l.Threshold {
+		l.logf("slow operation: name=%q type=%s complexity=%d resolvers=%d duration=%s fields=%s",
+			oc.Operation.Name, oc.Operation.Operation, complexity.Calculate(l.schema, oc.Operation, oc.Variables),
+			atomic.LoadInt64(resolvers), duration, strings.Join(SelectedFields(oc.Operation.SelectionSet), ","))
+	}
+	return resp
+}
+
+// InterceptField считает вызовы резолверов, написанных вручную. Поля структур не считаются.
+func (l *SlowQueryLogger) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	if fc := graphql.GetFieldContext(ctx); fc != nil && fc.IsResolver {
+		if resolvers, ok := ctx.Value(resolversKey).(*int64); ok {
+			atomic.AddInt64(resolvers, 1)
+		}
+	}
+	return next(ctx)
+}
+
+func (l *SlowQueryLogger) logf(format string, args ...interface{}) {
+	if l.Logf != nil {
+		l.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// SelectedFields возвращает отсортированные пути всех выбранных полей, например
+// "posts.comments.edges.node.content". Фрагменты раскрываются на месте.
+func SelectedFields(set ast.SelectionSet) []string {
+	seen := make(map[string]struct{})
+	collectFields(set, "", seen)
+
+	fields := make([]string, 0, len(seen))
+	for f := range seen {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func collectFields(set ast.SelectionSet, prefix string, seen map[string]struct{}) {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *ast.Field:
+			path := prefix + s.Name
+			if len(s.SelectionSet) == 0 {
+				seen[path] = struct{}{}
+				continue
+			}
+			collectFields(s.SelectionSet, path+".", seen)
+		case *ast.InlineFragment:
+			collectFields(s.SelectionSet, prefix, seen)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				collectFields(s.Definition.SelectionSet, prefix, seen)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package querylog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/graph"
+	"github.com/UkralStul/graphql-comments-service/internal/config"
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+const feedQuery = `query Feed {
+	postsConnection(limit: 5) {
+		edges { node { id ...PostComments } }
+	}
+}
+
+fragment PostComments on Post {
+	comments(limit: 3) { edges { node { content } } }
+}`
+
+// newClient поднимает сервер с логгером медленных операций и одним постом с комментарием.
+func newClient(t *testing.T, threshold time.Duration) (*client.Client, *[]string) {
+	t.Helper()
+	ctx := context.Background()
+	store := inmemory.New()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "text"})
+	require.NoError(t, err)
+
+	resolver := &graph.Resolver{Storage: store, Config: &config.Config{MaxPageSize: 10}}
+	srv := handler.NewDefaultServer(graph.NewSchema(resolver))
+
+	var lines []string
+	logger := New(threshold)
+	logger.Logf = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	srv.Use(logger)
+	return client.New(srv), &lines
+}
+
+func TestSlowQueryLogger_LogsOperationsOverThreshold(t *testing.T) {
+	c, lines := newClient(t, 0)
+
+	var resp map[string]interface{}
+	require.NoError(t, c.Post(feedQuery, &resp))
+
+	require.Len(t, *lines, 1)
+	line := (*lines)[0]
+	assert.Contains(t, line, `name="Feed"`)
+	assert.Contains(t, line, "type=query")
+	assert.NotContains(t, line, "complexity=0 ")
+	// postsConnection и comments - резолверы, написанные вручную
+	assert.Contains(t, line, "resolvers=2 ")
+	assert.Contains(t, line, "fields=postsConnection.edges.node.comments.edges.node.content,postsConnection.edges.node.id")
+}
+
+func TestSlowQueryLogger_SkipsFastOperations(t *testing.T) {
+	c, lines := newClient(t, time.Hour)
+
+	var resp map[string]interface{}
+	require.NoError(t, c.Post(feedQuery, &resp))
+	assert.Empty(t, *lines)
+}
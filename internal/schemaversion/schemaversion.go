@@ -0,0 +1,7 @@
+// Package schemaversion хранит версию GraphQL-схемы, по которой клиенты
+// определяют доступные возможности API.
+package schemaversion
+
+// Version - версия схемы в формате semver. Минорная версия растет при добавлении
+// полей и помечании старых как @deprecated, мажорная - при удалении устаревших полей.
+const Version = "1.2.0"
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Clock - источник текущего времени для хранилищ. В тестах его подменяют,
+// чтобы задавать время создания записей явно, в том числе одинаковое.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc позволяет использовать обычную функцию как Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+// SystemClock - реальное время, источник по умолчанию.
+var SystemClock Clock = ClockFunc(time.Now)
+
+type preserveCreatedAtKey struct{}
+
+// PreserveCreatedAt помечает контекст наполнения или импорта данных: CreatePost и CreateComment
+// сохраняют заданное CreatedAt, а не ставят текущее время. API такой контекст не создает,
+// поэтому клиенты не могут задним числом подделать время своих записей.
+func PreserveCreatedAt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, preserveCreatedAtKey{}, true)
+}
+
+// CreatedAtPreserved сообщает, помечен ли контекст PreserveCreatedAt.
+func CreatedAtPreserved(ctx context.Context) bool {
+	preserve, _ := ctx.Value(preserveCreatedAtKey{}).(bool)
+	return preserve
+}
+
+// CreationTime возвращает время создания новой записи с точностью step: заданное createdAt,
+// если контекст помечен PreserveCreatedAt и время задано, иначе now.
+func CreationTime(ctx context.Context, createdAt, now time.Time, step time.Duration) time.Time {
+	if CreatedAtPreserved(ctx) && !createdAt.IsZero() {
+		return createdAt.UTC().Truncate(step)
+	}
+	return now.UTC().Truncate(step)
+}
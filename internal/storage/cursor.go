@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+// Cursor - позиция записи в списке, отсортированном по (время, ID). Для комментариев
+// время - CreatedAt, для постов - ключ выбранного порядка сортировки.
+// Курсор сам несет ключ сортировки, поэтому хранилищу не нужно искать
+// запись-курсор, чтобы продолжить выборку.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+	// Score - рейтинг комментария на момент выдачи курсора, только у курсоров порядка
+	// domain.CommentOrderTop. Хронологические порядки его не учитывают.
+	Score *int
+}
+
+// Encode кодирует курсор в непрозрачную для клиента строку.
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	if c.Score != nil {
+		raw += "|" + strconv.Itoa(*c.Score)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// EncodeCursor кодирует позицию комментария в непрозрачную для клиента строку.
+// Курсор комментария из выборки в порядке TOP несет и его рейтинг.
+func EncodeCursor(c *domain.Comment) string {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID, Score: c.Score}.Encode()
+}
+
+// PostCursor возвращает курсор поста для указанного порядка сортировки.
+func PostCursor(p *domain.Post, order PostOrder) Cursor {
+	return Cursor{CreatedAt: PostSortKey(p, order), ID: p.ID}
+}
+
+// PostSortKey возвращает время, по которому пост сортируется в указанном порядке:
+// время создания или, для PostOrderLastActivityDesc, время последней активности.
+func PostSortKey(p *domain.Post, order PostOrder) time.Time {
+	if order == PostOrderLastActivityDesc && p.LastCommentAt != nil {
+		return *p.LastCommentAt
+	}
+	return p.CreatedAt
+}
+
+// DecodeCursor разбирает курсор, выданный EncodeCursor. ok == false означает, что строка
+// не является курсором этого формата - например, это ID комментария от старого клиента.
+func DecodeCursor(cursor string) (c Cursor, ok bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, false
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) < 2 || parts[1] == "" {
+		return Cursor{}, false
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return Cursor{}, false
+	}
+	c = Cursor{CreatedAt: createdAt, ID: parts[1]}
+	if len(parts) == 3 {
+		score, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return Cursor{}, false
+		}
+		c.Score = &score
+	}
+	return c, true
+}
+
+// DecodeTopCursor разбирает курсор порядка domain.CommentOrderTop. Курсоры без рейтинга
+// (хронологических порядков или ID комментария) в этом порядке не имеют позиции
+// и отклоняются с ErrInvalidCursor.
+func DecodeTopCursor(cursor string) (Cursor, error) {
+	c, ok := DecodeCursor(cursor)
+	if !ok || c.Score == nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// TopLess сообщает, идет ли комментарий a в порядке domain.CommentOrderTop раньше b:
+// рейтинг по убыванию, при равном рейтинге - (CreatedAt, ID) по возрастанию.
+// Рейтинг берется из Score, поэтому у обоих комментариев он должен быть заполнен.
+func TopLess(a, b *domain.Comment) bool {
+	if *a.Score != *b.Score {
+		return *a.Score > *b.Score
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// Comment возвращает комментарий-заглушку с ключом сортировки курсора - для сравнения
+// с комментариями через TopLess.
+func (c Cursor) Comment() *domain.Comment {
+	return &domain.Comment{CreatedAt: c.CreatedAt, ID: c.ID, Score: c.Score}
+}
+
+// After сообщает, идет ли комментарий в порядке сортировки строго после курсора.
+func (c Cursor) After(comment *domain.Comment) bool {
+	if !comment.CreatedAt.Equal(c.CreatedAt) {
+		return comment.CreatedAt.After(c.CreatedAt)
+	}
+	return comment.ID > c.ID
+}
+
+// Before сообщает, идет ли комментарий в порядке сортировки строго перед курсором.
+func (c Cursor) Before(comment *domain.Comment) bool {
+	if !comment.CreatedAt.Equal(c.CreatedAt) {
+		return comment.CreatedAt.Before(c.CreatedAt)
+	}
+	return comment.ID < c.ID
+}
+
+// Precedes сообщает, идет ли запись с ключом (t, id) строго после курсора
+// в порядке убывания (время DESC, ID DESC), в котором отдаются посты.
+func (c Cursor) Precedes(t time.Time, id string) bool {
+	if !t.Equal(c.CreatedAt) {
+		return t.Before(c.CreatedAt)
+	}
+	return id < c.ID
+}
+
+// NextCommentTime возвращает время создания нового комментария поста с точностью step:
+// now, но строго позже последнего комментария поста lastCommentAt. Так новый комментарий
+// всегда встает в конец списка (в том числе при совпадении времени до точности хранилища
+// или отставании часов), и листание вперед по выданным курсорам его не пропускает.
+func NextCommentTime(now time.Time, lastCommentAt *time.Time, step time.Duration) time.Time {
+	now = now.UTC().Truncate(step)
+	if lastCommentAt != nil && !now.After(*lastCommentAt) {
+		return lastCommentAt.UTC().Truncate(step).Add(step)
+	}
+	return now
+}
+
+// CommentCreationTime возвращает время создания нового комментария: заданное comment.CreatedAt
+// в контексте PreserveCreatedAt, иначе NextCommentTime. Сохраненное время может быть раньше
+// последнего комментария поста - наполнение и импорт идут до того, как посты листают клиенты.
+func CommentCreationTime(ctx context.Context, comment *domain.Comment, now time.Time, lastCommentAt *time.Time, step time.Duration) time.Time {
+	if CreatedAtPreserved(ctx) && !comment.CreatedAt.IsZero() {
+		return comment.CreatedAt.UTC().Truncate(step)
+	}
+	return NextCommentTime(now, lastCommentAt, step)
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.FixedZone("MSK", 3*60*60))
+	encoded := EncodeCursor(&domain.Comment{ID: "2b1f6c8e-4c61-4f4a-9a57-2f7f3f2f0c11", CreatedAt: createdAt})
+
+	cursor, ok := DecodeCursor(encoded)
+	assert.True(t, ok)
+	assert.Equal(t, "2b1f6c8e-4c61-4f4a-9a57-2f7f3f2f0c11", cursor.ID)
+	assert.True(t, cursor.CreatedAt.Equal(createdAt))
+}
+
+func TestDecodeCursor_LegacyID(t *testing.T) {
+	// ID комментария из старых клиентов не должен приниматься за курсор нового формата
+	for _, legacy := range []string{"2b1f6c8e-4c61-4f4a-9a57-2f7f3f2f0c11", "", "not a cursor"} {
+		_, ok := DecodeCursor(legacy)
+		assert.False(t, ok, legacy)
+	}
+}
+
+func TestNextCommentTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC)
+	assert.Equal(t, now.Truncate(time.Microsecond), NextCommentTime(now, nil, time.Microsecond))
+
+	// Совпадение до точности хранилища и отставшие часы сдвигают время за последний комментарий
+	last := now.Truncate(time.Microsecond)
+	assert.Equal(t, last.Add(time.Microsecond), NextCommentTime(now, &last, time.Microsecond))
+	future := now.Add(time.Hour)
+	assert.Equal(t, future.Truncate(time.Microsecond).Add(time.Microsecond), NextCommentTime(now, &future, time.Microsecond))
+
+	earlier := now.Add(-time.Second)
+	assert.Equal(t, now, NextCommentTime(now, &earlier, time.Nanosecond))
+}
+
+func TestPaginationArgs_Normalize(t *testing.T) {
+	args := PaginationArgs{Limit: 500}
+	assert.NoError(t, args.Normalize(100))
+	assert.Equal(t, 100, args.Limit)
+
+	// 0 - без ограничения сверху
+	args = PaginationArgs{Limit: 500}
+	assert.NoError(t, args.Normalize(0))
+	assert.Equal(t, 500, args.Limit)
+
+	args = PaginationArgs{Limit: 0}
+	assert.NoError(t, args.Normalize(100))
+	assert.Equal(t, 0, args.Limit)
+
+	args = PaginationArgs{Limit: -5}
+	assert.NoError(t, args.Normalize(100))
+	assert.Equal(t, 0, args.Limit)
+
+	empty := ""
+	args = PaginationArgs{Limit: 10, Cursor: &empty}
+	assert.ErrorIs(t, args.Normalize(100), ErrInvalidCursor)
+
+	// ID комментария от старого клиента проверяет уже хранилище
+	legacy := "2b1f6c8e-4c61-4f4a-9a57-2f7f3f2f0c11"
+	args = PaginationArgs{Limit: 10, Cursor: &legacy}
+	assert.NoError(t, args.Normalize(100))
+}
+
+func TestCursor_Top(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	score := -3
+	encoded := EncodeCursor(&domain.Comment{ID: "c1", CreatedAt: createdAt, Score: &score})
+
+	cursor, err := DecodeTopCursor(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "c1", cursor.ID)
+	assert.Equal(t, -3, *cursor.Score)
+
+	// Курсор с рейтингом годится и для хронологического порядка, обратное - нет
+	chronological, ok := DecodeCursor(encoded)
+	assert.True(t, ok)
+	assert.True(t, chronological.CreatedAt.Equal(createdAt))
+	_, err = DecodeTopCursor(EncodeCursor(&domain.Comment{ID: "c1", CreatedAt: createdAt}))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
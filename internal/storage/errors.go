@@ -0,0 +1,48 @@
+package storage
+
+import "errors"
+
+// Ошибки, общие для всех реализаций хранилища.
+// Резолверы различают их, чтобы отдать клиенту понятную ошибку вместо системной.
+var (
+	ErrPostNotFound       = errors.New("post not found")
+	ErrCommentNotFound    = errors.New("comment not found")
+	ErrParentNotFound     = errors.New("parent comment not found")
+	ErrParentOtherPost    = errors.New("parent comment belongs to another post")
+	ErrCommentsDisabled   = errors.New("comments are disabled for this post")
+	ErrThreadLocked       = errors.New("thread is locked")
+	ErrAuthorBlocked      = errors.New("you are blocked from commenting on this post")
+	ErrContentTooLong     = errors.New("comment content is too long")
+	ErrContentEmpty       = errors.New("comment content cannot be empty")
+	ErrContentControl     = errors.New("comment content contains disallowed control characters")
+	ErrTooManyAttachments = errors.New("comment has too many attachments")
+	ErrAttachmentInvalid  = errors.New("invalid attachment")
+	ErrDuplicateComment   = errors.New("duplicate comment")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrMergeSamePost      = errors.New("cannot merge a post into itself")
+)
+
+// Коды ошибок валидации. Совпадают со значениями UserErrorCode в GraphQL-схеме.
+const (
+	ValidationCodeTooLong = "TOO_LONG"
+	ValidationCodeBlank   = "BLANK"
+	ValidationCodeInvalid = "INVALID"
+)
+
+// ValidationError - ошибка валидации входных данных с указанием поля и нарушенного ограничения.
+// Оборачивает одну из sentinel-ошибок, поэтому errors.Is(err, ErrContentTooLong) продолжает работать.
+type ValidationError struct {
+	Field string
+	Code  string
+	// Limit - значение ограничения (например, максимальная длина), 0 если неприменимо.
+	Limit int
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
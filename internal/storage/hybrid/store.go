@@ -0,0 +1,390 @@
+// Package hybrid реализует write-through кеш над постоянным хранилищем:
+// запись идет в основное хранилище (postgres), чтение - из копии в памяти.
+//
+// Компромиссы:
+//   - вся база постов и комментариев держится в памяти процесса, поэтому режим подходит
+//     только для наборов данных, которые заведомо помещаются в RAM;
+//   - копия заполняется целиком при старте, время запуска растет с объемом данных;
+//   - кеш не знает об изменениях, сделанных в обход процесса, поэтому запускать
+//     можно только один экземпляр сервиса;
+//   - записи сериализуются, чтобы порядок изменений в копии совпадал с основным хранилищем;
+//   - реакции и жалобы не кешируются и всегда читаются из основного хранилища.
+package hybrid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+// hydrateBatchSize - размер страницы постов при заполнении кеша.
+const hydrateBatchSize = 500
+
+// Store реализует интерфейс Storage: пишет в primary и зеркалирует изменения в cache.
+type Store struct {
+	primary storage.Storage
+	cache   *inmemory.Store
+
+	// writeMu сериализует записи, чтобы изменения попадали в кеш в том же порядке
+	writeMu sync.Mutex
+}
+
+// New создает гибридное хранилище и заполняет кеш всеми постами и комментариями из primary.
+func New(ctx context.Context, primary storage.Storage, opts ...storage.Option) (*Store, error) {
+	s := &Store{
+		primary: primary,
+		cache:   inmemory.New(opts...),
+	}
+	if err := s.hydrate(ctx); err != nil {
+		return nil, fmt.Errorf("failed to hydrate cache: %w", err)
+	}
+	return s, nil
+}
+
+// hydrate постранично копирует посты и деревья их комментариев в кеш.
+func (s *Store) hydrate(ctx context.Context) error {
+	for offset := 0; ; offset += hydrateBatchSize {
+		posts, err := s.primary.GetPosts(ctx, hydrateBatchSize, offset, storage.PostFilter{IncludeDrafts: true})
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			tree, err := s.primary.GetCommentTree(ctx, post.ID)
+			if err != nil {
+				return err
+			}
+			s.cache.PutPost(post)
+			// Дерево приходит в порядке обхода в глубину: родитель раньше ответов
+			for _, c := range tree {
+				s.cache.PutComment(c)
+			}
+		}
+		if len(posts) < hydrateBatchSize {
+			return nil
+		}
+	}
+}
+
+// === Write Methods ===
+
+// WithTransaction открывает транзакцию в primary и в кеше: fn получает хранилище, которое
+// пишет в обе и читает из копии кеша. Если fn или фиксация в primary завершились ошибкой,
+// откатываются обе транзакции, и кеш остается согласованным с primary.
+func (s *Store) WithTransaction(ctx context.Context, fn func(tx storage.Storage) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.cache.WithTransaction(ctx, func(cacheTx storage.Storage) error {
+		return s.primary.WithTransaction(ctx, func(primaryTx storage.Storage) error {
+			return fn(&Store{primary: primaryTx, cache: cacheTx.(*inmemory.Store)})
+		})
+	})
+}
+
+func (s *Store) CreatePost(ctx context.Context, post *domain.Post) (*domain.Post, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	created, err := s.primary.CreatePost(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutPost(created)
+	return created, nil
+}
+
+func (s *Store) DeletePost(ctx context.Context, id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.primary.DeletePost(ctx, id); err != nil {
+		return err
+	}
+	return s.cache.DeletePost(ctx, id)
+}
+
+func (s *Store) MergePosts(ctx context.Context, sourceID, targetID string) (*domain.Post, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	post, err := s.primary.MergePosts(ctx, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.cache.MergePosts(ctx, sourceID, targetID); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *Store) UpdatePost(ctx context.Context, postID string, update storage.PostUpdate) (*domain.Post, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	post, err := s.primary.UpdatePost(ctx, postID, update)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutPost(post)
+	return post, nil
+}
+
+func (s *Store) ToggleCommentsForAuthor(ctx context.Context, authorID string, enable bool) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	affected, err := s.primary.ToggleCommentsForAuthor(ctx, authorID, enable)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.cache.ToggleCommentsForAuthor(ctx, authorID, enable); err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func (s *Store) ToggleCommentsBulk(ctx context.Context, postIDs []string, enable bool) ([]*domain.Post, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	posts, err := s.primary.ToggleCommentsBulk(ctx, postIDs, enable)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range posts {
+		s.cache.PutPost(p)
+	}
+	return posts, nil
+}
+
+func (s *Store) ImportThread(ctx context.Context, post *domain.Post, comments []*domain.Comment) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.primary.ImportThread(ctx, post, comments); err != nil {
+		return err
+	}
+	s.cache.PutPost(post)
+	for _, c := range comments {
+		s.cache.PutComment(c)
+	}
+	return nil
+}
+
+func (s *Store) CreateComment(ctx context.Context, comment *domain.Comment) (*domain.Comment, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	created, err := s.primary.CreateComment(ctx, comment)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutComment(created)
+	return created, nil
+}
+
+func (s *Store) UpdateComment(ctx context.Context, id string, content string) (*domain.Comment, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	updated, err := s.primary.UpdateComment(ctx, id, content)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutComment(updated)
+	return updated, nil
+}
+
+// ReportComment выполняется в primary: порог считается там же, где хранятся жалобы.
+// В кеш попадает только итоговый флаг Hidden.
+func (s *Store) ReportComment(ctx context.Context, commentID, reporterID, reason string) (*domain.Comment, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	reported, err := s.primary.ReportComment(ctx, commentID, reporterID, reason)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutComment(reported)
+	return reported, nil
+}
+
+func (s *Store) SetCommentHidden(ctx context.Context, commentID string, hidden bool) (*domain.Comment, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	updated, err := s.primary.SetCommentHidden(ctx, commentID, hidden)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutComment(updated)
+	return updated, nil
+}
+
+func (s *Store) SetCommentLocked(ctx context.Context, commentID string, locked bool) (*domain.Comment, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	updated, err := s.primary.SetCommentLocked(ctx, commentID, locked)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutComment(updated)
+	return updated, nil
+}
+
+func (s *Store) SetCommentStatus(ctx context.Context, commentID string, status domain.CommentStatus) (*domain.Comment, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	updated, err := s.primary.SetCommentStatus(ctx, commentID, status)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.PutComment(updated)
+	return updated, nil
+}
+
+// Подписки на посты, как и реакции, не кешируются и читаются из primary.
+
+// Запреты комментировать проверяет primary при создании комментария, поэтому они не кешируются.
+
+func (s *Store) BlockAuthor(ctx context.Context, postID, authorID string) error {
+	return s.primary.BlockAuthor(ctx, postID, authorID)
+}
+
+func (s *Store) UnblockAuthor(ctx context.Context, postID, authorID string) error {
+	return s.primary.UnblockAuthor(ctx, postID, authorID)
+}
+
+func (s *Store) IsAuthorBlocked(ctx context.Context, postID, authorID string) (bool, error) {
+	return s.primary.IsAuthorBlocked(ctx, postID, authorID)
+}
+
+func (s *Store) FollowPost(ctx context.Context, postID, userID string, channel domain.NotificationChannel) (*domain.PostSubscription, error) {
+	return s.primary.FollowPost(ctx, postID, userID, channel)
+}
+
+func (s *Store) UnfollowPost(ctx context.Context, postID, userID string) (bool, error) {
+	return s.primary.UnfollowPost(ctx, postID, userID)
+}
+
+func (s *Store) GetFollowers(ctx context.Context, postID string) ([]*domain.PostSubscription, error) {
+	return s.primary.GetFollowers(ctx, postID)
+}
+
+func (s *Store) AddReaction(ctx context.Context, commentID, userID string, reactionType domain.ReactionType) error {
+	return s.primary.AddReaction(ctx, commentID, userID, reactionType)
+}
+
+// === Read Methods ===
+
+func (s *Store) GetPosts(ctx context.Context, limit, offset int, filter storage.PostFilter) ([]*domain.Post, error) {
+	return s.cache.GetPosts(ctx, limit, offset, filter)
+}
+
+func (s *Store) GetPostsAfter(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) ([]*domain.Post, error) {
+	return s.cache.GetPostsAfter(ctx, limit, cursor, filter)
+}
+
+func (s *Store) GetPostByID(ctx context.Context, id string) (*domain.Post, error) {
+	return s.cache.GetPostByID(ctx, id)
+}
+
+func (s *Store) GetCommentByID(ctx context.Context, id string) (*domain.Comment, error) {
+	return s.cache.GetCommentByID(ctx, id)
+}
+
+func (s *Store) GetCommentAncestors(ctx context.Context, id string) ([]*domain.Comment, error) {
+	return s.cache.GetCommentAncestors(ctx, id)
+}
+
+func (s *Store) GetCommentTree(ctx context.Context, postID string) ([]*domain.Comment, error) {
+	return s.cache.GetCommentTree(ctx, postID)
+}
+
+func (s *Store) GetCommentTreePage(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	return s.cache.GetCommentTreePage(ctx, postID, args)
+}
+
+// GetCommentsByPostID в порядке TOP читает из primary: рейтинг считается по реакциям,
+// а они не кешируются.
+func (s *Store) GetCommentsByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if args.Order == domain.CommentOrderTop {
+		return s.primary.GetCommentsByPostID(ctx, postID, args)
+	}
+	return s.cache.GetCommentsByPostID(ctx, postID, args)
+}
+
+func (s *Store) GetCommentsByParentID(ctx context.Context, parentID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	return s.cache.GetCommentsByParentID(ctx, parentID, args)
+}
+
+func (s *Store) GetCommentsPageByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, int, error) {
+	if args.Order == domain.CommentOrderTop {
+		return s.primary.GetCommentsPageByPostID(ctx, postID, args)
+	}
+	return s.cache.GetCommentsPageByPostID(ctx, postID, args)
+}
+
+func (s *Store) GetUnansweredComments(ctx context.Context, postID, authorID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	return s.cache.GetUnansweredComments(ctx, postID, authorID, args)
+}
+
+func (s *Store) GetPendingComments(ctx context.Context, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	return s.cache.GetPendingComments(ctx, args)
+}
+
+func (s *Store) GetRecentCommentsByPostIDs(ctx context.Context, postIDs []string, limit int) ([]*domain.Comment, error) {
+	return s.cache.GetRecentCommentsByPostIDs(ctx, postIDs, limit)
+}
+
+func (s *Store) GetCommentsSince(ctx context.Context, postID string, after storage.Cursor, limit int, includeHidden bool) ([]*domain.Comment, error) {
+	return s.cache.GetCommentsSince(ctx, postID, after, limit, includeHidden)
+}
+
+func (s *Store) GetAuthorActivity(ctx context.Context, authorID string, args storage.PaginationArgs, filter storage.PostFilter) ([]storage.ActivityItem, error) {
+	return s.cache.GetAuthorActivity(ctx, authorID, args, filter)
+}
+
+func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, perParent int, includeHidden bool) (map[string][]*domain.Comment, error) {
+	return s.cache.GetCommentsByParentIDs(ctx, parentIDs, perParent, includeHidden)
+}
+
+func (s *Store) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*domain.Post, error) {
+	return s.cache.GetPostsByIDs(ctx, ids)
+}
+
+func (s *Store) CountCommentsByPostIDs(ctx context.Context, postIDs []string, includeHidden bool) (map[string]int, error) {
+	return s.cache.CountCommentsByPostIDs(ctx, postIDs, includeHidden)
+}
+
+func (s *Store) GetDepthHistogram(ctx context.Context, postID string, includeHidden bool) (map[int]int, error) {
+	return s.cache.GetDepthHistogram(ctx, postID, includeHidden)
+}
+
+func (s *Store) GetPostStats(ctx context.Context, postIDs []string) (map[string]storage.PostStats, error) {
+	return s.primary.GetPostStats(ctx, postIDs)
+}
+
+// SearchComments читает из primary: поиск в памяти сравнивает подстроки, а не слова,
+// и находил бы не то же, что postgres, а полнотекстовый индекс есть только в базе
+func (s *Store) SearchComments(ctx context.Context, query string, args storage.SearchArgs) ([]storage.SearchHit, error) {
+	return s.primary.SearchComments(ctx, query, args)
+}
+
+func (s *Store) GetPostWithCommentCount(ctx context.Context, id string, includeHidden bool) (*domain.Post, int, error) {
+	return s.cache.GetPostWithCommentCount(ctx, id, includeHidden)
+}
+
+func (s *Store) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*domain.Comment, error) {
+	return s.cache.GetCommentsByIDs(ctx, ids)
+}
+
+func (s *Store) GetReactionCountsByCommentIDs(ctx context.Context, ids []string) (map[string]map[domain.ReactionType]int, error) {
+	return s.primary.GetReactionCountsByCommentIDs(ctx, ids)
+}
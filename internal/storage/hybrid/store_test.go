@@ -0,0 +1,123 @@
+package hybrid
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+func TestStore_HydratesFromPrimary(t *testing.T) {
+	ctx := context.Background()
+	primary := inmemory.New()
+
+	post, err := primary.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := primary.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	reply, err := primary.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+
+	store, err := New(ctx, primary)
+	require.NoError(t, err)
+
+	got, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.Title, got.Title)
+
+	children, err := store.GetCommentsByParentID(ctx, root.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, children, 1)
+	assert.Equal(t, reply.ID, children[0].ID)
+}
+
+func TestStore_WritesThrough(t *testing.T) {
+	ctx := context.Background()
+	primary := inmemory.New()
+	store, err := New(ctx, primary)
+	require.NoError(t, err)
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "hello"})
+	require.NoError(t, err)
+
+	// Запись дошла до основного хранилища с теми же ID
+	_, err = primary.GetCommentByID(ctx, comment.ID)
+	require.NoError(t, err)
+
+	// И видна при чтении из кеша
+	_, err = store.UpdateComment(ctx, comment.ID, "edited")
+	require.NoError(t, err)
+	cached, err := store.cache.GetCommentByID(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", cached.Content)
+
+	disabled := false
+	_, err = store.UpdatePost(ctx, post.ID, storage.PostUpdate{CommentsEnabled: &disabled})
+	require.NoError(t, err)
+	cachedPost, err := store.cache.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.False(t, cachedPost.CommentsEnabled)
+
+	// Ошибка основного хранилища не меняет кеш
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "blocked"})
+	assert.ErrorIs(t, err, storage.ErrCommentsDisabled)
+	top, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, top, 1)
+
+	require.NoError(t, store.DeletePost(ctx, post.ID))
+	_, err = store.GetPostByID(ctx, post.ID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_WithTransaction(t *testing.T) {
+	ctx := context.Background()
+	primary := inmemory.New()
+	store, err := New(ctx, primary)
+	require.NoError(t, err)
+	errAbort := errors.New("abort")
+
+	// Откат затрагивает и primary, и кеш
+	var postID string
+	err = store.WithTransaction(ctx, func(tx storage.Storage) error {
+		post, err := tx.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		postID = post.ID
+		_, err = tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "first"})
+		require.NoError(t, err)
+
+		// Внутри транзакции ее записи видны при чтении
+		_, err = tx.GetPostByID(ctx, post.ID)
+		require.NoError(t, err)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	_, err = primary.GetPostByID(ctx, postID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	_, err = store.GetPostByID(ctx, postID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+
+	err = store.WithTransaction(ctx, func(tx storage.Storage) error {
+		post, err := tx.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		if err != nil {
+			return err
+		}
+		postID = post.ID
+		_, err = tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "first"})
+		return err
+	})
+	require.NoError(t, err)
+	_, err = primary.GetPostByID(ctx, postID)
+	require.NoError(t, err)
+	comments, err := store.GetCommentsByPostID(ctx, postID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+}
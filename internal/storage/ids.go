@@ -0,0 +1,23 @@
+package storage
+
+import "github.com/google/uuid"
+
+// IDGenerator выдает ID новых постов и комментариев. ID должны быть уникальными;
+// для postgres и для проверки входных данных (тег validate uuid) - еще и в формате UUID.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc позволяет использовать обычную функцию как IDGenerator.
+type IDGeneratorFunc func() string
+
+func (f IDGeneratorFunc) NewID() string { return f() }
+
+var (
+	// UUIDv4 - случайные UUID, генератор по умолчанию.
+	UUIDv4 IDGenerator = IDGeneratorFunc(uuid.NewString)
+	// UUIDv7 - UUID, упорядоченные по времени создания, как ULID, но в формате UUID,
+	// который принимают колонки uuid в postgres. При одинаковом времени создания
+	// комментарии упорядочиваются по ID, и с UUIDv7 этот порядок совпадает с порядком создания.
+	UUIDv7 IDGenerator = IDGeneratorFunc(func() string { return uuid.Must(uuid.NewV7()).String() })
+)
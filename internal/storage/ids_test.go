@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUIDv7_TimeOrdered(t *testing.T) {
+	prev := UUIDv7.NewID()
+	for i := 0; i < 100; i++ {
+		id := UUIDv7.NewID()
+		parsed, err := uuid.Parse(id)
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Version(7), parsed.Version())
+		// ID, выданные позже, сортируются строкой после предыдущих
+		assert.Greater(t, id, prev)
+		prev = id
+	}
+}
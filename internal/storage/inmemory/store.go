@@ -2,34 +2,53 @@ package inmemory
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
 	"github.com/UkralStul/graphql-comments-service/internal/storage"
-	"github.com/google/uuid"
 )
 
 // Store реализует интерфейс Storage в памяти.
 type Store struct {
+	opts storage.Options
+
 	mu               sync.RWMutex
 	posts            map[string]*domain.Post
 	comments         map[string]*domain.Comment
 	commentsByPost   map[string][]string // map[postID][]commentID (только корневые)
 	commentsByParent map[string][]string // map[parentID][]commentID
+	commentsByAuthor map[string][]string // map[authorID][]commentID в порядке создания
+
+	// map[commentID]map[userID]type
+	reactions map[string]map[string]domain.ReactionType
+	// map[commentID]map[reporterID]report
+	reports map[string]map[string]*domain.Report
+	// map[postID]map[userID]subscription
+	followers map[string]map[string]*domain.PostSubscription
+	// map[postID]map[authorID]bool - авторы, которым запрещено комментировать пост
+	blocks map[string]map[string]bool
 }
 
 // New создает новый экземпляр in-memory хранилища.
-func New() *Store {
+func New(opts ...storage.Option) *Store {
 	return &Store{
+		opts:             storage.NewOptions(opts...),
 		posts:            make(map[string]*domain.Post),
 		comments:         make(map[string]*domain.Comment),
 		commentsByPost:   make(map[string][]string),
 		commentsByParent: make(map[string][]string),
+		commentsByAuthor: make(map[string][]string),
+		reactions:        make(map[string]map[string]domain.ReactionType),
+		reports:          make(map[string]map[string]*domain.Report),
+		followers:        make(map[string]map[string]*domain.PostSubscription),
+		blocks:           make(map[string]map[string]bool),
 	}
 }
 
@@ -39,59 +58,573 @@ func (s *Store) CreatePost(ctx context.Context, post *domain.Post) (*domain.Post
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	post.ID = uuid.NewString()
-	post.CreatedAt = time.Now().UTC()
+	post.ID = s.opts.IDs.NewID()
+	post.CreatedAt = storage.CreationTime(ctx, post.CreatedAt, s.opts.Clock.Now(), time.Nanosecond)
+	post.Draft = s.opts.DraftPosts
+	if post.DefaultCommentOrder == "" {
+		post.DefaultCommentOrder = domain.CommentOrderOldest
+	}
 	s.posts[post.ID] = post
 	return post, nil
 }
 
+func (s *Store) DeletePost(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return fmt.Errorf("%w: %s", storage.ErrPostNotFound, id)
+	}
+	delete(s.posts, id)
+	delete(s.commentsByPost, id)
+	delete(s.followers, id)
+	delete(s.blocks, id)
+
+	// Удаляем все комментарии поста вместе с индексами их дочерних элементов
+	authors := make(map[string]struct{})
+	for cID, c := range s.comments {
+		if c.PostID == id {
+			delete(s.comments, cID)
+			delete(s.commentsByParent, cID)
+			delete(s.reactions, cID)
+			delete(s.reports, cID)
+			authors[c.AuthorID] = struct{}{}
+		}
+	}
+	// Из индекса авторов убираем ссылки на удаленные комментарии
+	for authorID := range authors {
+		ids := s.commentsByAuthor[authorID][:0]
+		for _, cID := range s.commentsByAuthor[authorID] {
+			if _, ok := s.comments[cID]; ok {
+				ids = append(ids, cID)
+			}
+		}
+		if len(ids) == 0 {
+			delete(s.commentsByAuthor, authorID)
+		} else {
+			s.commentsByAuthor[authorID] = ids
+		}
+	}
+	return nil
+}
+
 func (s *Store) GetPostByID(ctx context.Context, id string) (*domain.Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	post, ok := s.posts[id]
 	if !ok {
-		return nil, fmt.Errorf("post with id %s not found", id)
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, id)
 	}
 	return post, nil
 }
 
-func (s *Store) GetPosts(ctx context.Context, limit, offset int) ([]*domain.Post, error) {
+func (s *Store) GetPostWithCommentCount(ctx context.Context, id string, includeHidden bool) (*domain.Post, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", storage.ErrPostNotFound, id)
+	}
+	return post, s.countComments(id, includeHidden), nil
+}
+
+func (s *Store) CountCommentsByPostIDs(ctx context.Context, postIDs []string, includeHidden bool) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int, len(postIDs))
+	for _, id := range postIDs {
+		if n := s.countComments(id, includeHidden); n > 0 {
+			counts[id] = n
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) GetDepthHistogram(ctx context.Context, postID string, includeHidden bool) (map[int]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histogram := make(map[int]int)
+	var walk func(ids []string, depth int)
+	walk = func(ids []string, depth int) {
+		for _, id := range ids {
+			c, ok := s.comments[id]
+			if !ok {
+				continue
+			}
+			if includeHidden || c.Visible() {
+				histogram[depth]++
+			}
+			walk(s.commentsByParent[id], depth+1)
+		}
+	}
+	walk(s.commentsByPost[postID], 0)
+	return histogram, nil
+}
+
+// countComments считает комментарии поста вместе с ответами. Скрытый комментарий
+// не учитывается, но его видимые ответы учитываются - так же считает postgres.
+// Вызывается под блокировкой.
+func (s *Store) countComments(postID string, includeHidden bool) int {
+	count := 0
+	var walk func(ids []string)
+	walk = func(ids []string) {
+		for _, id := range ids {
+			c, ok := s.comments[id]
+			if !ok {
+				continue
+			}
+			if includeHidden || c.Visible() {
+				count++
+			}
+			walk(s.commentsByParent[id])
+		}
+	}
+	walk(s.commentsByPost[postID])
+	return count
+}
+
+// GetPostStats собирает статистику постов одним обходом дерева каждого поста.
+// Счетчики включают скрытые и не одобренные комментарии.
+func (s *Store) GetPostStats(ctx context.Context, postIDs []string) (map[string]storage.PostStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]storage.PostStats, len(postIDs))
+	for _, id := range postIDs {
+		post, ok := s.posts[id]
+		if !ok {
+			continue
+		}
+		stats := storage.PostStats{LastActivityAt: post.CreatedAt}
+		if post.LastCommentAt != nil && post.LastCommentAt.After(stats.LastActivityAt) {
+			stats.LastActivityAt = *post.LastCommentAt
+		}
+		// Один обход дерева поста собирает все счетчики
+		var walk func(ids []string)
+		walk = func(ids []string) {
+			for _, cid := range ids {
+				c, ok := s.comments[cid]
+				if !ok {
+					continue
+				}
+				stats.CommentCount++
+				if c.Status == domain.CommentPending {
+					stats.PendingCount++
+				}
+				for _, r := range s.reports[cid] {
+					stats.ReportCount++
+					if r.CreatedAt.After(stats.LastActivityAt) {
+						stats.LastActivityAt = r.CreatedAt
+					}
+				}
+				walk(s.commentsByParent[cid])
+			}
+		}
+		walk(s.commentsByPost[id])
+		result[id] = stats
+	}
+	return result, nil
+}
+
+func (s *Store) SearchComments(ctx context.Context, query string, args storage.SearchArgs) ([]storage.SearchHit, error) {
+	terms := storage.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hits []storage.SearchHit
+	for _, c := range s.comments {
+		if args.PostID != nil && c.PostID != *args.PostID {
+			continue
+		}
+		if !args.IncludeHidden && !c.Visible() {
+			continue
+		}
+		highlight, score, ok := highlightTerms(storage.StripHighlightMarkers(c.Content), terms)
+		if !ok {
+			continue
+		}
+		hits = append(hits, storage.SearchHit{Comment: c, Highlight: storage.RenderHighlight(highlight), Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return storage.Cursor{CreatedAt: hits[j].Comment.CreatedAt, ID: hits[j].Comment.ID}.Before(hits[i].Comment)
+	})
+	if args.Limit < len(hits) {
+		hits = hits[:args.Limit]
+	}
+	return hits, nil
+}
+
+// highlightTerms отмечает маркерами все вхождения слов запроса в текст без учета регистра.
+// ok - в тексте есть каждое слово, score - число вхождений, отнесенное к длине текста в
+// символах, чтобы короткий комментарий о предмете поиска был выше длинного с тем же словом.
+func highlightTerms(content string, terms []string) (highlight string, score float64, ok bool) {
+	text := []rune(content)
+	// Нижний регистр по символам сохраняет позиции: strings.ToLower может изменить длину
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(text))
+	matches := 0
+	for _, term := range terms {
+		t := []rune(term)
+		found := false
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(t)], t) {
+				found = true
+				matches++
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+			}
+		}
+		if !found {
+			return "", 0, false
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range text {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(storage.HighlightStartMarker)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(text)-1 || !marked[i+1]) {
+			b.WriteString(storage.HighlightStopMarker)
+		}
+	}
+	return b.String(), float64(matches) / float64(len(text)), true
+}
+
+func (s *Store) GetPosts(ctx context.Context, limit, offset int, filter storage.PostFilter) ([]*domain.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return pagePosts(s.sortedPosts(filter), offset, limit), nil
+}
+
+func (s *Store) GetPostsAfter(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) ([]*domain.Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	allPosts := s.sortedPosts(filter)
+	start := 0
+	if cursor != nil {
+		c, ok := storage.DecodeCursor(*cursor)
+		if !ok {
+			return nil, storage.ErrInvalidCursor
+		}
+		start = sort.Search(len(allPosts), func(i int) bool {
+			p := allPosts[i]
+			return c.Precedes(storage.PostSortKey(p, filter.Order), p.ID)
+		})
+	}
+	return pagePosts(allPosts, start, limit), nil
+}
+
+// sortedPosts возвращает посты, прошедшие фильтр, в порядке filter.Order
+// с ID в качестве второго ключа. Вызывается под блокировкой.
+func (s *Store) sortedPosts(filter storage.PostFilter) []*domain.Post {
+	// Фильтруем до сортировки и нарезки, чтобы limit/offset применялись к отфильтрованному списку
 	allPosts := make([]*domain.Post, 0, len(s.posts))
 	for _, p := range s.posts {
+		if !filter.Includes(p) {
+			continue
+		}
 		allPosts = append(allPosts, p)
 	}
 
 	sort.Slice(allPosts, func(i, j int) bool {
-		return allPosts[i].CreatedAt.After(allPosts[j].CreatedAt)
+		ki, kj := storage.PostSortKey(allPosts[i], filter.Order), storage.PostSortKey(allPosts[j], filter.Order)
+		if !ki.Equal(kj) {
+			return ki.After(kj)
+		}
+		return allPosts[i].ID > allPosts[j].ID
 	})
+	return allPosts
+}
 
-	start := offset
-	if start >= len(allPosts) {
-		return []*domain.Post{}, nil
+// pagePosts вырезает страницу [start, start+limit) из отсортированного списка.
+func pagePosts(posts []*domain.Post, start, limit int) []*domain.Post {
+	if start >= len(posts) {
+		return []*domain.Post{}
 	}
 	end := start + limit
-	if end > len(allPosts) {
-		end = len(allPosts)
+	if end > len(posts) {
+		end = len(posts)
 	}
-	return allPosts[start:end], nil
+	return posts[start:end]
 }
 
-func (s *Store) ToggleComments(ctx context.Context, postID string, enable bool) (*domain.Post, error) {
+func (s *Store) UpdatePost(ctx context.Context, postID string, update storage.PostUpdate) (*domain.Post, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	post, ok := s.posts[postID]
 	if !ok {
-		return nil, fmt.Errorf("post with id %s not found", postID)
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+	if update.Title != nil {
+		post.Title = *update.Title
+	}
+	if update.Content != nil {
+		post.Content = *update.Content
+	}
+	if update.CommentsEnabled != nil {
+		post.CommentsEnabled = *update.CommentsEnabled
+	}
+	if update.Draft != nil {
+		post.Draft = *update.Draft
+	}
+	if update.DefaultCommentOrder != nil {
+		post.DefaultCommentOrder = *update.DefaultCommentOrder
 	}
-	post.CommentsEnabled = enable
 	return post, nil
 }
 
+func (s *Store) ToggleCommentsForAuthor(ctx context.Context, authorID string, enable bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	affected := 0
+	for _, post := range s.posts {
+		if post.AuthorID == authorID {
+			post.CommentsEnabled = enable
+			affected++
+		}
+	}
+	return affected, nil
+}
+
+func (s *Store) ToggleCommentsBulk(ctx context.Context, postIDs []string, enable bool) ([]*domain.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := make([]*domain.Post, 0, len(postIDs))
+	seen := make(map[string]struct{}, len(postIDs))
+	for _, id := range postIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		post, ok := s.posts[id]
+		if !ok {
+			continue
+		}
+		post.CommentsEnabled = enable
+		updated = append(updated, post)
+	}
+	return updated, nil
+}
+
+func (s *Store) ImportThread(ctx context.Context, post *domain.Post, comments []*domain.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[post.ID]; ok {
+		return fmt.Errorf("post %s already exists", post.ID)
+	}
+
+	// Сначала проверяем все комментарии, чтобы при ошибке ничего не сохранить
+	known := make(map[string]struct{}, len(comments))
+	for _, c := range comments {
+		content, err := s.opts.PrepareCommentContent(c.Content)
+		if err != nil {
+			return err
+		}
+		c.Content = content
+		if err := storage.ValidateAttachments(c.Attachments, s.opts.MaxAttachments); err != nil {
+			return err
+		}
+		if c.Status == "" {
+			c.Status = domain.CommentApproved
+		}
+		if c.ParentID != nil {
+			if _, ok := known[*c.ParentID]; !ok {
+				return storage.ErrParentNotFound
+			}
+		}
+		known[c.ID] = struct{}{}
+	}
+
+	if post.DefaultCommentOrder == "" {
+		post.DefaultCommentOrder = domain.CommentOrderOldest
+	}
+	s.posts[post.ID] = post
+	for _, c := range comments {
+		s.addComment(c)
+	}
+	return nil
+}
+
+func (s *Store) MergePosts(ctx context.Context, sourceID, targetID string) (*domain.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sourceID == targetID {
+		return nil, storage.ErrMergeSamePost
+	}
+	source, ok := s.posts[sourceID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, sourceID)
+	}
+	target, ok := s.posts[targetID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, targetID)
+	}
+
+	var moved []*domain.Comment
+	for _, c := range s.comments {
+		if c.PostID == sourceID {
+			moved = append(moved, c)
+		}
+	}
+	// Проверяем уникальность до изменений, чтобы при ошибке ничего не перенести
+	if s.opts.UniqueComments {
+		for _, c := range moved {
+			if s.hasSameContent(targetID, c.AuthorID, c.Content, "") {
+				return nil, storage.ErrDuplicateComment
+			}
+		}
+	}
+
+	for _, c := range moved {
+		c.PostID = targetID
+	}
+	for _, id := range s.commentsByPost[sourceID] {
+		s.commentsByPost[targetID] = s.insertSorted(s.commentsByPost[targetID], s.comments[id])
+	}
+	if source.LastCommentAt != nil && (target.LastCommentAt == nil || source.LastCommentAt.After(*target.LastCommentAt)) {
+		lastCommentAt := *source.LastCommentAt
+		target.LastCommentAt = &lastCommentAt
+	}
+	for userID, sub := range s.followers[sourceID] {
+		if _, ok := s.followers[targetID][userID]; ok {
+			continue
+		}
+		if s.followers[targetID] == nil {
+			s.followers[targetID] = make(map[string]*domain.PostSubscription)
+		}
+		sub.PostID = targetID
+		s.followers[targetID][userID] = sub
+	}
+
+	delete(s.posts, sourceID)
+	delete(s.commentsByPost, sourceID)
+	delete(s.followers, sourceID)
+	delete(s.blocks, sourceID)
+	return target, nil
+}
+
+// === Transactions ===
+
+// WithTransaction выполняет fn над копией данных, удерживая блокировку записи: остальные
+// вызовы хранилища ждут конца транзакции. Если fn завершилась успешно, копия заменяет данные
+// хранилища, иначе просто отбрасывается. Копирование занимает время, пропорциональное объему
+// данных, что приемлемо для хранилища разработки и тестов.
+func (s *Store) WithTransaction(ctx context.Context, fn func(tx storage.Storage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.clone()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.posts, s.comments = tx.posts, tx.comments
+	s.commentsByPost, s.commentsByParent, s.commentsByAuthor = tx.commentsByPost, tx.commentsByParent, tx.commentsByAuthor
+	s.reactions, s.reports, s.followers, s.blocks = tx.reactions, tx.reports, tx.followers, tx.blocks
+	return nil
+}
+
+// clone возвращает независимую копию данных с теми же настройками. Методы изменяют посты
+// и комментарии на месте, поэтому они копируются по значению, а не по указателю.
+// Вызывается под s.mu.
+func (s *Store) clone() *Store {
+	c := &Store{
+		opts:             s.opts,
+		posts:            cloneValues(s.posts),
+		comments:         cloneValues(s.comments),
+		commentsByPost:   cloneIndex(s.commentsByPost),
+		commentsByParent: cloneIndex(s.commentsByParent),
+		commentsByAuthor: cloneIndex(s.commentsByAuthor),
+		reactions:        make(map[string]map[string]domain.ReactionType, len(s.reactions)),
+		reports:          make(map[string]map[string]*domain.Report, len(s.reports)),
+		followers:        make(map[string]map[string]*domain.PostSubscription, len(s.followers)),
+		blocks:           make(map[string]map[string]bool, len(s.blocks)),
+	}
+	for id, m := range s.reactions {
+		c.reactions[id] = maps.Clone(m)
+	}
+	for id, m := range s.reports {
+		c.reports[id] = cloneValues(m)
+	}
+	for id, m := range s.followers {
+		c.followers[id] = cloneValues(m)
+	}
+	for id, m := range s.blocks {
+		c.blocks[id] = maps.Clone(m)
+	}
+	return c
+}
+
+// cloneValues копирует map вместе со значениями, на которые указывают ее элементы.
+func cloneValues[T any](m map[string]*T) map[string]*T {
+	c := make(map[string]*T, len(m))
+	for k, v := range m {
+		copied := *v
+		c[k] = &copied
+	}
+	return c
+}
+
+// cloneIndex копирует индекс вместе со списками ID.
+func cloneIndex(m map[string][]string) map[string][]string {
+	c := make(map[string][]string, len(m))
+	for k, ids := range m {
+		c[k] = slices.Clone(ids)
+	}
+	return c
+}
+
+// === Mirroring Methods ===
+
+// PutPost сохраняет пост с уже назначенными ID и временем создания, заменяя
+// существующую запись. Используется для зеркалирования другого хранилища.
+func (s *Store) PutPost(post *domain.Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.posts[post.ID] = post
+}
+
+// PutComment сохраняет комментарий с уже назначенными ID и временем создания без
+// валидации, заменяя существующую запись. Используется для зеркалирования другого хранилища.
+func (s *Store) PutComment(comment *domain.Comment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.comments[comment.ID]; ok {
+		// Связи комментария не меняются, поэтому индексы обновлять не нужно
+		s.comments[comment.ID] = comment
+		return
+	}
+	s.addComment(comment)
+}
+
 // === Comment Methods ===
 
 func (s *Store) CreateComment(ctx context.Context, comment *domain.Comment) (*domain.Comment, error) {
@@ -101,40 +634,159 @@ func (s *Store) CreateComment(ctx context.Context, comment *domain.Comment) (*do
 	// Проверка поста
 	post, ok := s.posts[comment.PostID]
 	if !ok {
-		return nil, errors.New("post not found")
+		return nil, storage.ErrPostNotFound
 	}
 	if !post.CommentsEnabled {
-		return nil, errors.New("comments are disabled for this post")
+		return nil, storage.ErrCommentsDisabled
+	}
+	if s.blocks[comment.PostID][comment.AuthorID] {
+		return nil, storage.ErrAuthorBlocked
 	}
 
-	// Проверка длины комментария
-	if len(comment.Content) > 2000 {
-		return nil, errors.New("comment content is too long")
+	// Нормализация и проверка текста комментария
+	content, err := s.opts.PrepareCommentContent(comment.Content)
+	if err != nil {
+		return nil, err
 	}
-	if strings.TrimSpace(comment.Content) == "" {
-		return nil, errors.New("comment content cannot be empty")
+	comment.Content = content
+	if err := storage.ValidateAttachments(comment.Attachments, s.opts.MaxAttachments); err != nil {
+		return nil, err
 	}
 
 	// Проверка родительского комментария
 	if comment.ParentID != nil {
-		if _, ok := s.comments[*comment.ParentID]; !ok {
-			return nil, errors.New("parent comment not found")
+		parent, ok := s.comments[*comment.ParentID]
+		if !ok {
+			return nil, storage.ErrParentNotFound
 		}
+		if err := storage.ValidateParentPost(parent.PostID, comment.PostID); err != nil {
+			return nil, err
+		}
+		if s.threadLocked(parent) {
+			return nil, storage.ErrThreadLocked
+		}
+	}
+
+	now := s.opts.Clock.Now().UTC()
+	if s.isDuplicate(comment, now) {
+		return nil, storage.ErrDuplicateComment
+	}
+
+	comment.ID = s.opts.IDs.NewID()
+	comment.CreatedAt = storage.CommentCreationTime(ctx, comment, now, post.LastCommentAt, time.Nanosecond)
+	comment.Status = s.opts.InitialStatus()
+	s.addComment(comment)
+
+	return comment, nil
+}
+
+// threadLocked сообщает, закрыт ли для ответов комментарий c или любой из его предков.
+// Вызывается под блокировкой.
+func (s *Store) threadLocked(c *domain.Comment) bool {
+	for depth := 0; c != nil && depth < s.opts.MaxDepth; depth++ {
+		if c.Locked {
+			return true
+		}
+		if c.ParentID == nil {
+			return false
+		}
+		c = s.comments[*c.ParentID]
 	}
+	return false
+}
 
-	comment.ID = uuid.NewString()
-	comment.CreatedAt = time.Now().UTC()
+// addComment сохраняет комментарий и обновляет индексы. Вызывается под блокировкой.
+func (s *Store) addComment(comment *domain.Comment) {
 	s.comments[comment.ID] = comment
+	if post, ok := s.posts[comment.PostID]; ok {
+		if post.LastCommentAt == nil || comment.CreatedAt.After(*post.LastCommentAt) {
+			createdAt := comment.CreatedAt
+			post.LastCommentAt = &createdAt
+		}
+	}
+	s.commentsByAuthor[comment.AuthorID] = append(s.commentsByAuthor[comment.AuthorID], comment.ID)
 
 	// Обновление индексов для иерархии
 	if comment.ParentID == nil {
 		// Корневой комментарий
-		s.commentsByPost[comment.PostID] = append(s.commentsByPost[comment.PostID], comment.ID)
+		s.commentsByPost[comment.PostID] = s.insertSorted(s.commentsByPost[comment.PostID], comment)
 	} else {
 		// Дочерний комментарий
-		s.commentsByParent[*comment.ParentID] = append(s.commentsByParent[*comment.ParentID], comment.ID)
+		s.commentsByParent[*comment.ParentID] = s.insertSorted(s.commentsByParent[*comment.ParentID], comment)
+	}
+}
+
+// insertSorted вставляет ID комментария в индекс, сохраняя порядок (CreatedAt, ID).
+// Новые комментарии почти всегда попадают в конец, поэтому вставка обычно O(1);
+// сдвиг нужен только для импорта со старыми датами. Вызывается под блокировкой.
+func (s *Store) insertSorted(ids []string, comment *domain.Comment) []string {
+	pos := s.searchAfter(ids, storage.Cursor{CreatedAt: comment.CreatedAt, ID: comment.ID})
+	ids = append(ids, "")
+	copy(ids[pos+1:], ids[pos:])
+	ids[pos] = comment.ID
+	return ids
+}
+
+// searchAfter возвращает индекс первого комментария после курсора.
+// ids должны быть отсортированы по (CreatedAt, ID). Вызывается под блокировкой.
+func (s *Store) searchAfter(ids []string, cursor storage.Cursor) int {
+	return sort.Search(len(ids), func(i int) bool {
+		return cursor.After(s.comments[ids[i]])
+	})
+}
+
+// isDuplicate сообщает, совпадает ли комментарий с последним комментарием того же автора
+// в том же посте, оставленным в пределах DuplicateWindow, или - при UniqueComments -
+// с любым комментарием автора в посте. Вызывается под блокировкой.
+func (s *Store) isDuplicate(comment *domain.Comment, now time.Time) bool {
+	if s.opts.UniqueComments && s.hasSameContent(comment.PostID, comment.AuthorID, comment.Content, "") {
+		return true
+	}
+	if s.opts.DuplicateWindow <= 0 {
+		return false
+	}
+
+	// Идем по индексу автора с конца, пока не найдем его последний комментарий в этом посте
+	ids := s.commentsByAuthor[comment.AuthorID]
+	for i := len(ids) - 1; i >= 0; i-- {
+		last, ok := s.comments[ids[i]]
+		if !ok || last.PostID != comment.PostID {
+			continue
+		}
+		return last.Content == comment.Content && now.Sub(last.CreatedAt) < s.opts.DuplicateWindow
+	}
+	return false
+}
+
+// hasSameContent сообщает, есть ли у автора в посте комментарий с таким текстом,
+// не считая комментария exceptID. Вызывается под блокировкой.
+func (s *Store) hasSameContent(postID, authorID, content, exceptID string) bool {
+	for _, id := range s.commentsByAuthor[authorID] {
+		c, ok := s.comments[id]
+		if ok && id != exceptID && c.PostID == postID && c.Content == content {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) UpdateComment(ctx context.Context, id string, content string) (*domain.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, storage.ErrCommentNotFound
+	}
+	content, err := s.opts.PrepareCommentContent(content)
+	if err != nil {
+		return nil, err
+	}
+	if s.opts.UniqueComments && s.hasSameContent(comment.PostID, comment.AuthorID, content, comment.ID) {
+		return nil, storage.ErrDuplicateComment
 	}
 
+	comment.Content = content
 	return comment, nil
 }
 
@@ -143,75 +795,574 @@ func (s *Store) GetCommentByID(ctx context.Context, id string) (*domain.Comment,
 	defer s.mu.RUnlock()
 	comment, ok := s.comments[id]
 	if !ok {
-		return nil, errors.New("comment not found")
+		return nil, storage.ErrCommentNotFound
 	}
 	return comment, nil
 }
 
-// === Pagination Methods ===
-
-func (s *Store) GetCommentsByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+func (s *Store) GetCommentAncestors(ctx context.Context, id string) ([]*domain.Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	commentIDs, ok := s.commentsByPost[postID]
+	comment, ok := s.comments[id]
 	if !ok {
-		return []*domain.Comment{}, nil
+		return nil, storage.ErrCommentNotFound
 	}
 
-	return s.paginateComments(commentIDs, args), nil
+	// Поднимаемся по ParentID, но не глубже MaxDepth - защита от слишком длинных цепочек
+	ancestors := make([]*domain.Comment, 0)
+	for parentID := comment.ParentID; parentID != nil && len(ancestors) < s.opts.MaxDepth; {
+		parent, ok := s.comments[*parentID]
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, parent)
+		parentID = parent.ParentID
+	}
+	return ancestors, nil
 }
 
-func (s *Store) GetCommentsByParentID(ctx context.Context, parentID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *Store) ReportComment(ctx context.Context, commentID, reporterID, reason string) (*domain.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	commentIDs, ok := s.commentsByParent[parentID]
+	comment, ok := s.comments[commentID]
 	if !ok {
-		return []*domain.Comment{}, nil
+		return nil, fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
 	}
-
-	return s.paginateComments(commentIDs, args), nil
+	if s.reports[commentID] == nil {
+		s.reports[commentID] = make(map[string]*domain.Report)
+	}
+	// Повторная жалоба того же пользователя только обновляет причину
+	s.reports[commentID][reporterID] = &domain.Report{
+		CommentID:  commentID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		CreatedAt:  s.opts.Clock.Now().UTC(),
+	}
+	if s.opts.ShouldHide(len(s.reports[commentID])) {
+		comment.Hidden = true
+	}
+	return comment, nil
 }
 
-// paginateComments - вспомогательная функция для пагинации
-func (s *Store) paginateComments(ids []string, args storage.PaginationArgs) []*domain.Comment {
-	allComments := make([]*domain.Comment, 0, len(ids))
-	for _, id := range ids {
-		if c, ok := s.comments[id]; ok {
-			allComments = append(allComments, c)
+func (s *Store) SetCommentHidden(ctx context.Context, commentID string, hidden bool) (*domain.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[commentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+	}
+	comment.Hidden = hidden
+	if !hidden {
+		delete(s.reports, commentID)
+	}
+	return comment, nil
+}
+
+func (s *Store) SetCommentLocked(ctx context.Context, commentID string, locked bool) (*domain.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[commentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+	}
+	comment.Locked = locked
+	return comment, nil
+}
+
+func (s *Store) SetCommentStatus(ctx context.Context, commentID string, status domain.CommentStatus) (*domain.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[commentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+	}
+	comment.Status = status
+	return comment, nil
+}
+
+// === Pagination Methods ===
+
+func (s *Store) GetCommentTree(ctx context.Context, postID string) ([]*domain.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+
+	// Индексы хранят ID в порядке создания, поэтому сортировать не нужно
+	tree := []*domain.Comment{}
+	var walk func(ids []string)
+	walk = func(ids []string) {
+		for _, id := range ids {
+			c, ok := s.comments[id]
+			if !ok {
+				continue
+			}
+			tree = append(tree, c)
+			walk(s.commentsByParent[id])
+		}
+	}
+	walk(s.commentsByPost[postID])
+	return tree, nil
+}
+
+// GetCommentTreePage продолжает обход дерева в глубину с позиции курсора по индексам ответов,
+// не собирая все дерево: страница стоит пропорционально своему размеру и глубине курсора.
+func (s *Store) GetCommentTreePage(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+
+	// treeFrame - продолжение обхода на одном уровне: ID братьев и позиция следующего
+	type treeFrame struct {
+		ids  []string
+		next int
+	}
+	var stack []treeFrame
+	if args.Cursor == nil {
+		stack = append(stack, treeFrame{ids: s.commentsByPost[postID]})
+	} else {
+		c, ok := storage.DecodeCursor(*args.Cursor)
+		if !ok {
+			return nil, storage.ErrInvalidCursor
+		}
+		// Комментарии не удаляются по одному, поэтому комментарий-курсор всегда есть в индексах,
+		// даже если с тех пор его скрыли
+		cursor, ok := s.comments[c.ID]
+		if !ok || cursor.PostID != postID {
+			return nil, storage.ErrInvalidCursor
+		}
+		chain := []*domain.Comment{cursor}
+		for parentID := cursor.ParentID; parentID != nil; {
+			parent, ok := s.comments[*parentID]
+			if !ok {
+				break
+			}
+			chain = append(chain, parent)
+			parentID = parent.ParentID
+		}
+		slices.Reverse(chain)
+
+		// Снизу стека - корневые комментарии после корня ветки, сверху - ответы на курсор.
+		// Невидимость наследуется ответами, поэтому уровни внутри невидимой ветки пропускаются.
+		visible := true
+		for _, node := range chain {
+			if !visible && !args.IncludeHidden {
+				break
+			}
+			siblings := s.commentsByPost[postID]
+			if node.ParentID != nil {
+				siblings = s.commentsByParent[*node.ParentID]
+			}
+			after := storage.Cursor{CreatedAt: node.CreatedAt, ID: node.ID}
+			stack = append(stack, treeFrame{ids: siblings, next: s.searchAfter(siblings, after)})
+			visible = visible && node.Visible()
+		}
+		if visible || args.IncludeHidden {
+			stack = append(stack, treeFrame{ids: s.commentsByParent[cursor.ID]})
+		}
+	}
+
+	page := []*domain.Comment{}
+	for len(stack) > 0 && len(page) < args.Limit {
+		top := &stack[len(stack)-1]
+		if top.next >= len(top.ids) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		c, ok := s.comments[top.ids[top.next]]
+		top.next++
+		// Ветка невидимого комментария пропускается целиком
+		if !ok || (!args.IncludeHidden && !c.Visible()) {
+			continue
+		}
+		page = append(page, c)
+		if replies := s.commentsByParent[c.ID]; len(replies) > 0 {
+			stack = append(stack, treeFrame{ids: replies})
+		}
+	}
+	return page, nil
+}
+
+func (s *Store) GetCommentsByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	commentIDs, ok := s.commentsByPost[postID]
+	if !ok {
+		return []*domain.Comment{}, nil
+	}
+
+	if args.Order == domain.CommentOrderTop {
+		return s.topComments(commentIDs, args)
+	}
+	return s.paginateComments(commentIDs, args), nil
+}
+
+func (s *Store) GetCommentsPageByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, int, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	commentIDs := s.commentsByPost[postID]
+	total := len(commentIDs)
+	if !args.IncludeHidden {
+		total = 0
+		for _, id := range commentIDs {
+			if s.comments[id].Visible() {
+				total++
+			}
 		}
 	}
-	// Сортируем по времени создания, чтобы пагинация была консистентной
-	sort.Slice(allComments, func(i, j int) bool {
-		return allComments[i].CreatedAt.Before(allComments[j].CreatedAt)
+	if args.Order == domain.CommentOrderTop {
+		page, err := s.topComments(commentIDs, args)
+		return page, total, err
+	}
+	return s.paginateComments(commentIDs, args), total, nil
+}
+
+func (s *Store) GetCommentsByParentID(ctx context.Context, parentID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	commentIDs, ok := s.commentsByParent[parentID]
+	if !ok {
+		return []*domain.Comment{}, nil
+	}
+
+	return s.paginateComments(commentIDs, args), nil
+}
+
+// GetPendingComments перебирает все комментарии: индекса по статусу нет,
+// а очередь премодерации обычно короткая.
+func (s *Store) GetPendingComments(ctx context.Context, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, c := range s.comments {
+		if c.Status == domain.CommentPending {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		ca, cb := s.comments[a], s.comments[b]
+		if c := ca.CreatedAt.Compare(cb.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(ca.ID, cb.ID)
 	})
 
-	startIndex := 0
+	args.IncludeHidden = true
+	return s.paginateComments(ids, args), nil
+}
+
+func (s *Store) GetUnansweredComments(ctx context.Context, postID, authorID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Отбор сохраняет порядок commentsByPost, поэтому пагинация работает как в GetCommentsByPostID
+	var ids []string
+	for _, id := range s.commentsByPost[postID] {
+		if s.comments[id].AuthorID == authorID {
+			continue
+		}
+		answered := slices.ContainsFunc(s.commentsByParent[id], func(childID string) bool {
+			return s.comments[childID].AuthorID == authorID
+		})
+		if !answered {
+			ids = append(ids, id)
+		}
+	}
+	return s.paginateComments(ids, args), nil
+}
+
+func (s *Store) GetRecentCommentsByPostIDs(ctx context.Context, postIDs []string, limit int) ([]*domain.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	recent := []*domain.Comment{}
+	if limit <= 0 {
+		return recent, nil
+	}
+
+	// Собираем видимые комментарии каждого поста из индексов и сливаем их по времени
+	var walk func(ids []string)
+	walk = func(ids []string) {
+		for _, id := range ids {
+			c, ok := s.comments[id]
+			if !ok {
+				continue
+			}
+			// Ответы на скрытый комментарий остаются видимыми, как и в списках ответов
+			if c.Visible() {
+				recent = append(recent, c)
+			}
+			walk(s.commentsByParent[id])
+		}
+	}
+	for _, postID := range slices.Compact(slices.Sorted(slices.Values(postIDs))) {
+		walk(s.commentsByPost[postID])
+	}
+
+	slices.SortFunc(recent, func(a, b *domain.Comment) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent, nil
+}
+
+func (s *Store) GetCommentsSince(ctx context.Context, postID string, after storage.Cursor, limit int, includeHidden bool) ([]*domain.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+
+	comments := []*domain.Comment{}
+	if limit <= 0 {
+		return comments, nil
+	}
+	// Курсор без ID продолжает выборку строго после времени создания
+	follows := after.After
+	if after.ID == "" {
+		follows = func(c *domain.Comment) bool { return c.CreatedAt.After(after.CreatedAt) }
+	}
+	var walk func(ids []string)
+	walk = func(ids []string) {
+		for _, id := range ids {
+			c, ok := s.comments[id]
+			if !ok {
+				continue
+			}
+			if follows(c) && (includeHidden || c.Visible()) {
+				comments = append(comments, c)
+			}
+			walk(s.commentsByParent[id])
+		}
+	}
+	walk(s.commentsByPost[postID])
+
+	slices.SortFunc(comments, func(a, b *domain.Comment) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(comments) > limit {
+		comments = comments[:limit]
+	}
+	return comments, nil
+}
+
+func (s *Store) GetAuthorActivity(ctx context.Context, authorID string, args storage.PaginationArgs, filter storage.PostFilter) ([]storage.ActivityItem, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	var cursor *storage.Cursor
 	if args.Cursor != nil {
-		for i, c := range allComments {
-			if c.ID == *args.Cursor {
-				startIndex = i + 1
-				break
+		c, ok := storage.DecodeCursor(*args.Cursor)
+		if !ok {
+			return nil, storage.ErrInvalidCursor
+		}
+		cursor = &c
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []storage.ActivityItem
+	add := func(item storage.ActivityItem) {
+		if c := item.Cursor(); cursor == nil || cursor.Precedes(c.CreatedAt, c.ID) {
+			items = append(items, item)
+		}
+	}
+	for _, p := range s.posts {
+		if p.AuthorID == authorID && filter.Includes(p) {
+			add(storage.ActivityItem{Post: p})
+		}
+	}
+	for _, id := range s.commentsByAuthor[authorID] {
+		if c := s.comments[id]; args.IncludeHidden || c.Visible() {
+			add(storage.ActivityItem{Comment: c})
+		}
+	}
+
+	slices.SortFunc(items, func(a, b storage.ActivityItem) int {
+		ca, cb := a.Cursor(), b.Cursor()
+		if c := cb.CreatedAt.Compare(ca.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(cb.ID, ca.ID)
+	})
+	if len(items) > args.Limit {
+		items = items[:args.Limit]
+	}
+	return items, nil
+}
+
+// paginateComments - вспомогательная функция для пагинации.
+// Индексы уже отсортированы по (CreatedAt, ID), поэтому граница страницы
+// находится бинарным поиском по ключу из курсора, без сортировки и перебора.
+func (s *Store) paginateComments(ids []string, args storage.PaginationArgs) []*domain.Comment {
+	if args.Order == domain.CommentOrderNewest {
+		// Страница от новых к старым - это соседняя страница в порядке создания в обратном
+		// направлении, развернутая: курсор и видимость обрабатываются тем же кодом
+		chronological := args
+		chronological.Order = domain.CommentOrderOldest
+		chronological.Backward = !args.Backward
+		page := s.paginateComments(ids, chronological)
+		slices.Reverse(page)
+		return page
+	}
+
+	var cursor storage.Cursor
+	hasCursor := false
+	if args.Cursor != nil {
+		cursor, hasCursor = storage.DecodeCursor(*args.Cursor)
+		if !hasCursor {
+			// Старый формат курсора - ID комментария
+			if c, found := s.comments[*args.Cursor]; found {
+				cursor, hasCursor = storage.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}, true
 			}
 		}
 	}
+	if args.Backward {
+		endIndex := len(ids)
+		if hasCursor {
+			endIndex = sort.Search(len(ids), func(i int) bool {
+				return !cursor.Before(s.comments[ids[i]])
+			})
+		}
+		return s.paginateCommentsBackward(ids[:endIndex], args)
+	}
+
+	startIndex := 0
+	if hasCursor {
+		startIndex = s.searchAfter(ids, cursor)
+	}
+
+	page := make([]*domain.Comment, 0, min(args.Limit, max(len(ids)-startIndex, 0)))
+	for i := startIndex; i < len(ids) && len(page) < args.Limit; i++ {
+		c := s.comments[ids[i]]
+		// Скрытые и не одобренные комментарии пропускаем, не уменьшая размер страницы
+		if !args.IncludeHidden && !c.Visible() {
+			continue
+		}
+		page = append(page, c)
+	}
+	return page
+}
+
+// paginateCommentsBackward возвращает последние args.Limit комментариев из ids
+// в прямом порядке. Вызывается под блокировкой.
+func (s *Store) paginateCommentsBackward(ids []string, args storage.PaginationArgs) []*domain.Comment {
+	page := make([]*domain.Comment, 0, min(args.Limit, len(ids)))
+	for i := len(ids) - 1; i >= 0 && len(page) < args.Limit; i-- {
+		c := s.comments[ids[i]]
+		if !args.IncludeHidden && !c.Visible() {
+			continue
+		}
+		page = append(page, c)
+	}
+	slices.Reverse(page)
+	return page
+}
+
+// topComments возвращает страницу комментариев из ids в порядке domain.CommentOrderTop.
+// Рейтинги считаются заново при каждом вызове, а комментарии страницы - копии с заполненным
+// Score: рейтинг относится к этой выборке, а не к сохраненному комментарию.
+// Вызывается под блокировкой.
+func (s *Store) topComments(ids []string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	var cursor *domain.Comment
+	if args.Cursor != nil {
+		c, err := storage.DecodeTopCursor(*args.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c.Comment()
+	}
 
-	if startIndex >= len(allComments) {
-		return []*domain.Comment{}
+	scored := make([]*domain.Comment, 0, len(ids))
+	for _, id := range ids {
+		c := s.comments[id]
+		if !args.IncludeHidden && !c.Visible() {
+			continue
+		}
+		scoredComment := *c
+		score := s.reactionScore(id)
+		scoredComment.Score = &score
+		scored = append(scored, &scoredComment)
 	}
+	sort.Slice(scored, func(i, j int) bool {
+		return storage.TopLess(scored[i], scored[j])
+	})
 
-	endIndex := startIndex + args.Limit
-	if endIndex > len(allComments) {
-		endIndex = len(allComments)
+	if args.Backward {
+		end := len(scored)
+		if cursor != nil {
+			end = sort.Search(len(scored), func(i int) bool { return !storage.TopLess(scored[i], cursor) })
+		}
+		return scored[max(end-args.Limit, 0):end], nil
+	}
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(scored), func(i int) bool { return storage.TopLess(cursor, scored[i]) })
 	}
+	return scored[start:min(start+args.Limit, len(scored))], nil
+}
 
-	return allComments[startIndex:endIndex]
+// reactionScore считает рейтинг комментария по реакциям. Вызывается под блокировкой.
+func (s *Store) reactionScore(commentID string) int {
+	score := 0
+	for _, t := range s.reactions[commentID] {
+		score += t.Weight()
+	}
+	return score
 }
 
 // === Dataloader Methods ===
 
-func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string) (map[string][]*domain.Comment, error) {
+func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, perParent int, includeHidden bool) (map[string][]*domain.Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -219,18 +1370,167 @@ func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string)
 
 	for _, pID := range parentIDs {
 		childIDs := s.commentsByParent[pID]
-		children := make([]*domain.Comment, 0, len(childIDs))
+		capacity := len(childIDs)
+		if perParent > 0 && perParent < capacity {
+			capacity = perParent
+		}
+		children := make([]*domain.Comment, 0, capacity)
 		for _, cID := range childIDs {
-			if c, ok := s.comments[cID]; ok {
+			if perParent > 0 && len(children) == perParent {
+				break
+			}
+			if c, ok := s.comments[cID]; ok && (includeHidden || c.Visible()) {
 				children = append(children, c)
 			}
 		}
-		// Важно: Dataloader'у нужны отсортированные данные для консистентности
-		sort.Slice(children, func(i, j int) bool {
-			return children[i].CreatedAt.Before(children[j].CreatedAt)
-		})
+		// Индекс уже отсортирован по (CreatedAt, ID) - в том же порядке, что и у курсорной
+		// пагинации, поэтому первую страницу children можно отдавать из Dataloader'а
 		results[pID] = children
 	}
 
 	return results, nil
 }
+
+func (s *Store) BlockAuthor(ctx context.Context, postID, authorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+	if s.blocks[postID] == nil {
+		s.blocks[postID] = make(map[string]bool)
+	}
+	s.blocks[postID][authorID] = true
+	return nil
+}
+
+func (s *Store) UnblockAuthor(ctx context.Context, postID, authorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+	delete(s.blocks[postID], authorID)
+	if len(s.blocks[postID]) == 0 {
+		delete(s.blocks, postID)
+	}
+	return nil
+}
+
+func (s *Store) IsAuthorBlocked(ctx context.Context, postID, authorID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.blocks[postID][authorID], nil
+}
+
+func (s *Store) FollowPost(ctx context.Context, postID, userID string, channel domain.NotificationChannel) (*domain.PostSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+	}
+	if s.followers[postID] == nil {
+		s.followers[postID] = make(map[string]*domain.PostSubscription)
+	}
+	if sub, ok := s.followers[postID][userID]; ok {
+		sub.Channel = channel
+		return sub, nil
+	}
+	sub := &domain.PostSubscription{PostID: postID, UserID: userID, Channel: channel, CreatedAt: s.opts.Clock.Now().UTC()}
+	s.followers[postID][userID] = sub
+	return sub, nil
+}
+
+func (s *Store) UnfollowPost(ctx context.Context, postID, userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.followers[postID][userID]; !ok {
+		return false, nil
+	}
+	delete(s.followers[postID], userID)
+	if len(s.followers[postID]) == 0 {
+		delete(s.followers, postID)
+	}
+	return true, nil
+}
+
+func (s *Store) GetFollowers(ctx context.Context, postID string) ([]*domain.PostSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]*domain.PostSubscription, 0, len(s.followers[postID]))
+	for _, sub := range s.followers[postID] {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if !subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+		}
+		return subs[i].UserID < subs[j].UserID
+	})
+	return subs, nil
+}
+
+func (s *Store) AddReaction(ctx context.Context, commentID, userID string, reactionType domain.ReactionType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.comments[commentID]; !ok {
+		return fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+	}
+	if s.reactions[commentID] == nil {
+		s.reactions[commentID] = make(map[string]domain.ReactionType)
+	}
+	s.reactions[commentID][userID] = reactionType
+	return nil
+}
+
+func (s *Store) GetReactionCountsByCommentIDs(ctx context.Context, ids []string) (map[string]map[domain.ReactionType]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]map[domain.ReactionType]int, len(ids))
+	for _, id := range ids {
+		byUser := s.reactions[id]
+		if len(byUser) == 0 {
+			continue
+		}
+		counts := make(map[domain.ReactionType]int)
+		for _, t := range byUser {
+			counts[t]++
+		}
+		result[id] = counts
+	}
+	return result, nil
+}
+
+func (s *Store) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*domain.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make(map[string]*domain.Post, len(ids))
+	for _, id := range ids {
+		if p, ok := s.posts[id]; ok {
+			results[id] = p
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*domain.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make(map[string]*domain.Comment, len(ids))
+	for _, id := range ids {
+		if c, ok := s.comments[id]; ok {
+			results[id] = c
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,85 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// newBenchStore создает пост с n корневыми комментариями и возвращает ID комментариев.
+func newBenchStore(b *testing.B, n int) (*Store, *domain.Post, []string) {
+	b.Helper()
+	store := New()
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Bench", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ids := make([]string, n)
+	for i := 0; i < n; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids[i] = c.ID
+	}
+	return store, post, ids
+}
+
+func BenchmarkStore_PaginateComments(b *testing.B) {
+	for _, n := range []int{100, 10000, 100000} {
+		store, _, ids := newBenchStore(b, n)
+		// Курсор в середине списка - типичная "глубокая" страница.
+		// Старый курсор (ID) требует поиска комментария, новый несет ключ сортировки сам.
+		legacyCursor := ids[n/2]
+		cursor := storage.EncodeCursor(store.comments[legacyCursor])
+
+		b.Run(fmt.Sprintf("comments=%d/first", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				store.paginateComments(ids, storage.PaginationArgs{Limit: 10})
+			}
+		})
+		b.Run(fmt.Sprintf("comments=%d/middle", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				store.paginateComments(ids, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+			}
+		})
+		b.Run(fmt.Sprintf("comments=%d/middle-legacy-id", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				store.paginateComments(ids, storage.PaginationArgs{Limit: 10, Cursor: &legacyCursor})
+			}
+		})
+	}
+}
+
+func BenchmarkStore_GetCommentsByParentIDs(b *testing.B) {
+	const parents, childrenPerParent = 50, 20
+
+	store, post, ids := newBenchStore(b, parents)
+	ctx := context.Background()
+	for _, parentID := range ids {
+		parentID := parentID
+		for j := 0; j < childrenPerParent; j++ {
+			_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &parentID, AuthorID: "user-2", Content: "reply"})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	// Один батч соответствует одному вызову дата-лоадера для страницы комментариев
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetCommentsByParentIDs(ctx, ids, 0, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,8 +4,11 @@ package inmemory
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	// ЗАМЕНИТЕ НА ВАШ ПУТЬ
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
@@ -60,7 +63,8 @@ func TestStore_CreateComment_CommentsDisabled(t *testing.T) {
 	ctx := context.Background()
 
 	// Отключаем комментарии
-	_, err := store.ToggleComments(ctx, post.ID, false)
+	disabled := false
+	_, err := store.UpdatePost(ctx, post.ID, storage.PostUpdate{CommentsEnabled: &disabled})
 	require.NoError(t, err)
 
 	// Пытаемся создать комментарий
@@ -136,3 +140,1683 @@ func TestStore_Pagination(t *testing.T) {
 	assert.NotEqual(t, firstPage[0].ID, secondPage[0].ID)
 	assert.NotEqual(t, firstPage[1].ID, secondPage[0].ID)
 }
+
+func TestStore_ToggleCommentsForAuthor(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	other, err := store.CreatePost(ctx, &domain.Post{Title: "Other", Content: "Content", AuthorID: "user-2", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, &domain.Post{Title: "Second", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	affected, err := store.ToggleCommentsForAuthor(ctx, "user-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, affected)
+
+	retrieved, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.False(t, retrieved.CommentsEnabled)
+
+	// Посты других авторов не затронуты
+	retrieved, err = store.GetPostByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.True(t, retrieved.CommentsEnabled)
+}
+
+func TestStore_GetCommentAncestors(t *testing.T) {
+	store := New(storage.WithMaxDepth(2))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	// Цепочка root -> c1 -> c2 -> c3
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	c1, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "c1"})
+	require.NoError(t, err)
+	c2, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &c1.ID, AuthorID: "user-3", Content: "c2"})
+	require.NoError(t, err)
+
+	ancestors, err := store.GetCommentAncestors(ctx, c2.ID)
+	require.NoError(t, err)
+	require.Len(t, ancestors, 2)
+	assert.Equal(t, c1.ID, ancestors[0].ID, "nearest parent goes first")
+	assert.Equal(t, root.ID, ancestors[1].ID)
+
+	ancestors, err = store.GetCommentAncestors(ctx, root.ID)
+	require.NoError(t, err)
+	assert.Empty(t, ancestors)
+
+	// Обход ограничен MaxDepth
+	c3, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &c2.ID, AuthorID: "user-1", Content: "c3"})
+	require.NoError(t, err)
+	ancestors, err = store.GetCommentAncestors(ctx, c3.ID)
+	require.NoError(t, err)
+	assert.Len(t, ancestors, 2)
+
+	_, err = store.GetCommentAncestors(ctx, "non-existent-id")
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_CreateComment_ValidationErrorDetails(t *testing.T) {
+	store := New(storage.WithMaxCommentLength(10))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: strings.Repeat("a", 11)})
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "content", validationErr.Field)
+	assert.Equal(t, storage.ValidationCodeTooLong, validationErr.Code)
+	assert.Equal(t, 10, validationErr.Limit)
+	assert.ErrorIs(t, err, storage.ErrContentTooLong)
+
+	// Длина считается в символах: 10 кириллических букв занимают 20 байт, но проходят проверку
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: strings.Repeat("я", 10)})
+	require.NoError(t, err)
+
+	// Та же валидация применяется при редактировании
+	_, err = store.UpdateComment(ctx, comment.ID, " ")
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "content", validationErr.Field)
+	assert.Equal(t, storage.ValidationCodeBlank, validationErr.Code)
+
+	updated, err := store.UpdateComment(ctx, comment.ID, "edited")
+	require.NoError(t, err)
+	assert.Equal(t, "edited", updated.Content)
+}
+
+func TestStore_CreateComment_Attachments(t *testing.T) {
+	store := New(storage.WithMaxAttachments(2))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	image := domain.Attachment{URL: "https://example.com/cat.png", Type: domain.AttachmentImage}
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "three",
+		Attachments: []domain.Attachment{image, image, image}})
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "attachments", validationErr.Field)
+	assert.Equal(t, storage.ValidationCodeTooLong, validationErr.Code)
+	assert.Equal(t, 2, validationErr.Limit)
+	assert.ErrorIs(t, err, storage.ErrTooManyAttachments)
+
+	for _, bad := range []domain.Attachment{
+		{URL: "/relative/path.png", Type: domain.AttachmentImage},
+		{URL: "ftp://example.com/file", Type: domain.AttachmentLink},
+		{URL: "https://example.com/" + strings.Repeat("a", storage.MaxAttachmentURLLength), Type: domain.AttachmentLink},
+		{URL: "https://example.com", Type: "VIDEO"},
+	} {
+		_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "bad",
+			Attachments: []domain.Attachment{bad}})
+		require.ErrorAs(t, err, &validationErr, bad.URL)
+		assert.True(t, strings.HasPrefix(validationErr.Field, "attachments[0]."), validationErr.Field)
+		assert.ErrorIs(t, err, storage.ErrAttachmentInvalid, bad.URL)
+	}
+
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "ok",
+		Attachments: []domain.Attachment{image}})
+	require.NoError(t, err)
+	got, err := store.GetCommentByID(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Attachment{image}, got.Attachments)
+}
+
+func TestStore_CreateComment_RejectsControlChars(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	for _, content := range []string{"nul\x00byte", "escape \x1b[31mred", "bell\a", "del\x7f", "c1 \u0085 next line"} {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: content})
+		var validationErr *storage.ValidationError
+		require.ErrorAs(t, err, &validationErr, "%q", content)
+		assert.Equal(t, "content", validationErr.Field)
+		assert.Equal(t, storage.ValidationCodeInvalid, validationErr.Code)
+		assert.ErrorIs(t, err, storage.ErrContentControl)
+	}
+
+	// Переводы строк и табуляция допустимы
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "line\r\n\tindented"})
+	require.NoError(t, err)
+	assert.Equal(t, "line\n\tindented", comment.Content)
+
+	_, err = store.UpdateComment(ctx, comment.ID, "edited\x00")
+	assert.ErrorIs(t, err, storage.ErrContentControl)
+
+	// Набор допустимых символов настраивается
+	strict := New(storage.WithAllowedControlChars("\n"))
+	post, err = strict.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = strict.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "a\tb"})
+	assert.EqualError(t, err, "comment content contains disallowed control characters: U+0009")
+	_, err = strict.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "a\nb"})
+	assert.NoError(t, err)
+}
+
+func TestStore_MaxPageSize(t *testing.T) {
+	store := New(storage.WithMaxPageSize(3))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+	}
+
+	// Лимит больше допустимого урезается хранилищем, а не отклоняется
+	comments, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, comments, 3)
+
+	comments, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: -1})
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+
+	empty := ""
+	_, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: &empty})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestStore_CreateComment_NormalizesContent(t *testing.T) {
+	store := New(storage.WithMaxCommentLength(11))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name, input, want string
+	}{
+		{"outer whitespace", "  \n\t hello \n ", "hello"},
+		{"blank line runs collapse to two", "a\n\n\n\n\nb", "a\n\n\nb"},
+		{"whitespace-only lines count as blank", "a\n \n\t\n  \n \nb", "a\n\n\nb"},
+		{"crlf", "a\r\n\r\n\r\n\r\nb", "a\n\n\nb"},
+		{"two blank lines kept", "a\n\n\nb", "a\n\n\nb"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: tc.input})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, comment.Content)
+		})
+	}
+
+	// Длина проверяется после нормализации: 11 значащих символов в окружении пробелов проходят
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "   hello world   \n\n\n\n"})
+	require.NoError(t, err)
+
+	// Проверка на пустоту выполняется после обрезки
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "\n\n \t \n"})
+	assert.ErrorIs(t, err, storage.ErrContentEmpty)
+}
+
+func TestStore_CreateComment_NormalizationDisabled(t *testing.T) {
+	store := New(storage.WithNormalization(false, 0))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "  a\n\n\n\nb  "})
+	require.NoError(t, err)
+	assert.Equal(t, "  a\n\n\n\nb  ", comment.Content)
+}
+
+func TestStore_CreateComment_DuplicateDetection(t *testing.T) {
+	store := New(storage.WithDuplicateWindow(time.Minute))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	require.NoError(t, err)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+
+	// Тот же текст от другого автора допустим
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-3", Content: "Buy now!"})
+	require.NoError(t, err)
+
+	// Повтор допустим, если между ними был другой комментарий автора
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Something else"})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	require.NoError(t, err)
+}
+
+func TestStore_CreateComment_DuplicateDetectionDisabledByDefault(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Same text"})
+		require.NoError(t, err)
+	}
+}
+
+func TestStore_UniqueComments(t *testing.T) {
+	store := New(storage.WithUniqueComments(true))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Test Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	require.NoError(t, err)
+	other, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Something else"})
+	require.NoError(t, err)
+
+	// В отличие от окна дубликатов, повтор запрещен и после других комментариев автора
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+	_, err = store.UpdateComment(ctx, other.ID, "Buy now!")
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+
+	// Сохранение комментария без изменений - не повтор
+	_, err = store.UpdateComment(ctx, other.ID, "Something else")
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-3", Content: "Buy now!"})
+	require.NoError(t, err)
+}
+
+func TestStore_GetPosts_FilterByCommentsEnabled(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := store.CreatePost(ctx, &domain.Post{Title: "Locked", Content: "Content", AuthorID: "user-1", CommentsEnabled: false})
+		require.NoError(t, err)
+	}
+
+	disabled := false
+	posts, err := store.GetPosts(ctx, 10, 0, storage.PostFilter{CommentsEnabled: &disabled})
+	require.NoError(t, err)
+	assert.Len(t, posts, 3)
+	for _, p := range posts {
+		assert.False(t, p.CommentsEnabled)
+	}
+
+	// Фильтр сочетается с limit/offset
+	posts, err = store.GetPosts(ctx, 2, 2, storage.PostFilter{CommentsEnabled: &disabled})
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+
+	enabled := true
+	posts, err = store.GetPosts(ctx, 10, 0, storage.PostFilter{CommentsEnabled: &enabled})
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+
+	posts, err = store.GetPosts(ctx, 10, 0, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Len(t, posts, 4)
+}
+
+func TestStore_DraftPosts(t *testing.T) {
+	store := New(storage.WithDraftPosts(true))
+	ctx := context.Background()
+
+	draft, err := store.CreatePost(ctx, &domain.Post{Title: "Draft", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	assert.True(t, draft.Draft)
+	other, err := store.CreatePost(ctx, &domain.Post{Title: "Other draft", Content: "Content", AuthorID: "user-2", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	// Без явного разрешения черновики не попадают в списки
+	posts, err := store.GetPosts(ctx, 10, 0, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+
+	posts, err = store.GetPostsAfter(ctx, 10, nil, storage.PostFilter{DraftsAuthorID: "user-1"})
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, draft.ID, posts[0].ID)
+
+	posts, err = store.GetPosts(ctx, 10, 0, storage.PostFilter{IncludeDrafts: true})
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+
+	published := false
+	_, err = store.UpdatePost(ctx, other.ID, storage.PostUpdate{Draft: &published})
+	require.NoError(t, err)
+	posts, err = store.GetPosts(ctx, 10, 0, storage.PostFilter{})
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, other.ID, posts[0].ID)
+
+	// Без DraftPosts посты публикуются сразу
+	post, err := New().CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	assert.True(t, post.Published())
+}
+
+func TestStore_Reactions(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	c1, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "first"})
+	require.NoError(t, err)
+	c2, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "second"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddReaction(ctx, c1.ID, "user-2", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, c1.ID, "user-3", domain.ReactionLike))
+	// Повторная реакция пользователя заменяет предыдущую
+	require.NoError(t, store.AddReaction(ctx, c1.ID, "user-3", domain.ReactionDislike))
+
+	err = store.AddReaction(ctx, "missing", "user-2", domain.ReactionLike)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+
+	counts, err := store.GetReactionCountsByCommentIDs(ctx, []string{c1.ID, c2.ID})
+	require.NoError(t, err)
+	assert.Equal(t, map[domain.ReactionType]int{domain.ReactionLike: 1, domain.ReactionDislike: 1}, counts[c1.ID])
+	assert.NotContains(t, counts, c2.ID)
+}
+
+func TestStore_UpdatePost_OnlyProvidedFields(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	title, disabled := "New title", false
+	updated, err := store.UpdatePost(ctx, post.ID, storage.PostUpdate{Title: &title, CommentsEnabled: &disabled})
+	require.NoError(t, err)
+	assert.Equal(t, "New title", updated.Title)
+	assert.Equal(t, "Content", updated.Content)
+	assert.False(t, updated.CommentsEnabled)
+
+	_, err = store.UpdatePost(ctx, "missing", storage.PostUpdate{Title: &title})
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_ToggleCommentsBulk(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	other, err := store.CreatePost(ctx, &domain.Post{Title: "Other", Content: "Content", AuthorID: "user-2", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	updated, err := store.ToggleCommentsBulk(ctx, []string{post.ID, "missing", other.ID, post.ID}, false)
+	require.NoError(t, err)
+	require.Len(t, updated, 2)
+	assert.Equal(t, post.ID, updated[0].ID)
+	assert.Equal(t, other.ID, updated[1].ID)
+	assert.False(t, updated[0].CommentsEnabled)
+	assert.False(t, updated[1].CommentsEnabled)
+}
+
+func TestStore_GetPosts_OrderByLastActivity(t *testing.T) {
+	store, quiet := newTestStore(t)
+	ctx := context.Background()
+
+	active, err := store.CreatePost(ctx, &domain.Post{Title: "Active", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	newest, err := store.CreatePost(ctx, &domain.Post{Title: "Newest", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	assert.Nil(t, quiet.LastCommentAt)
+
+	// Комментарий в первом посте поднимает его выше постов, созданных позже
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: quiet.ID, AuthorID: "user-2", Content: "hello"})
+	require.NoError(t, err)
+	require.NotNil(t, quiet.LastCommentAt)
+
+	posts, err := store.GetPosts(ctx, 10, 0, storage.PostFilter{Order: storage.PostOrderLastActivityDesc})
+	require.NoError(t, err)
+	require.Len(t, posts, 3)
+	assert.Equal(t, []string{quiet.ID, newest.ID, active.ID}, []string{posts[0].ID, posts[1].ID, posts[2].ID})
+
+	// Порядок по умолчанию - по времени создания
+	posts, err = store.GetPosts(ctx, 10, 0, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, newest.ID, posts[0].ID)
+}
+
+func TestStore_Pagination_OpaqueCursor(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	// Комментарии с одинаковым временем создания упорядочиваются по ID
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	comments := []*domain.Comment{
+		{ID: "c", PostID: post.ID, AuthorID: "user-1", Content: "c", CreatedAt: createdAt},
+		{ID: "a", PostID: post.ID, AuthorID: "user-1", Content: "a", CreatedAt: createdAt},
+		{ID: "b", PostID: post.ID, AuthorID: "user-1", Content: "b", CreatedAt: createdAt},
+		{ID: "early", PostID: post.ID, AuthorID: "user-1", Content: "early", CreatedAt: createdAt.Add(-time.Hour)},
+	}
+	require.NoError(t, store.ImportThread(ctx, post, comments))
+
+	var got []string
+	var cursor *string
+	for {
+		page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, c := range page {
+			got = append(got, c.ID)
+		}
+		next := storage.EncodeCursor(page[len(page)-1])
+		cursor = &next
+	}
+	assert.Equal(t, []string{"early", "a", "b", "c"}, got)
+}
+
+func TestStore_Pagination_Backward(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	comments := []*domain.Comment{
+		{ID: "c", PostID: post.ID, AuthorID: "user-1", Content: "c", CreatedAt: createdAt},
+		{ID: "a", PostID: post.ID, AuthorID: "user-1", Content: "a", CreatedAt: createdAt},
+		{ID: "b", PostID: post.ID, AuthorID: "user-1", Content: "b", CreatedAt: createdAt, Hidden: true},
+		{ID: "early", PostID: post.ID, AuthorID: "user-1", Content: "early", CreatedAt: createdAt.Add(-time.Hour)},
+	}
+	require.NoError(t, store.ImportThread(ctx, post, comments))
+
+	ids := func(page []*domain.Comment) []string {
+		got := make([]string, len(page))
+		for i, c := range page {
+			got[i] = c.ID
+		}
+		return got
+	}
+
+	// Без курсора страница берется с конца списка, порядок внутри страницы прямой;
+	// скрытый комментарий пропускается без уменьшения страницы
+	page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, ids(page))
+
+	cursor := storage.EncodeCursor(page[0])
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: &cursor, Backward: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"early"}, ids(page))
+
+	// Старый формат курсора тоже поддерживается
+	legacy := "c"
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 5, Cursor: &legacy, Backward: true, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"early", "a", "b"}, ids(page))
+}
+
+func TestStore_Pagination_NewestFirst(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	comments := []*domain.Comment{
+		{ID: "a", PostID: post.ID, AuthorID: "user-1", Content: "a", CreatedAt: createdAt},
+		{ID: "b", PostID: post.ID, AuthorID: "user-1", Content: "b", CreatedAt: createdAt.Add(time.Minute), Hidden: true},
+		{ID: "c", PostID: post.ID, AuthorID: "user-1", Content: "c", CreatedAt: createdAt.Add(2 * time.Minute)},
+		{ID: "d", PostID: post.ID, AuthorID: "user-1", Content: "d", CreatedAt: createdAt.Add(3 * time.Minute)},
+	}
+	require.NoError(t, store.ImportThread(ctx, post, comments))
+	assert.Equal(t, domain.CommentOrderOldest, post.DefaultCommentOrder)
+
+	ids := func(page []*domain.Comment) []string {
+		got := make([]string, len(page))
+		for i, c := range page {
+			got[i] = c.ID
+		}
+		return got
+	}
+	newest := storage.PaginationArgs{Limit: 2, Order: domain.CommentOrderNewest}
+
+	// Вперед - от новых к старым, скрытый комментарий пропускается
+	page, err := store.GetCommentsByPostID(ctx, post.ID, newest)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "c"}, ids(page))
+
+	cursor := storage.EncodeCursor(page[1])
+	args := newest
+	args.Cursor = &cursor
+	page, err = store.GetCommentsByPostID(ctx, post.ID, args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(page))
+
+	// Назад от курсора - более новые комментарии, в том же порядке от новых к старым
+	cursor = storage.EncodeCursor(page[0])
+	args.Backward = true
+	args.IncludeHidden = true
+	page, err = store.GetCommentsByPostID(ctx, post.ID, args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, ids(page))
+
+	page, total, err := store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true, Order: domain.CommentOrderNewest})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, ids(page))
+	assert.Equal(t, 3, total)
+
+	order := domain.CommentOrderNewest
+	updated, err := store.UpdatePost(ctx, post.ID, storage.PostUpdate{DefaultCommentOrder: &order})
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentOrderNewest, updated.DefaultCommentOrder)
+}
+
+func TestStore_Pagination_Top(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	comments := []*domain.Comment{
+		{ID: "a", PostID: post.ID, AuthorID: "user-1", Content: "a", CreatedAt: createdAt},
+		{ID: "b", PostID: post.ID, AuthorID: "user-1", Content: "b", CreatedAt: createdAt.Add(time.Minute), Hidden: true},
+		{ID: "c", PostID: post.ID, AuthorID: "user-1", Content: "c", CreatedAt: createdAt.Add(2 * time.Minute)},
+		{ID: "d", PostID: post.ID, AuthorID: "user-1", Content: "d", CreatedAt: createdAt.Add(3 * time.Minute)},
+		{ID: "e", PostID: post.ID, AuthorID: "user-1", Content: "e", CreatedAt: createdAt.Add(4 * time.Minute)},
+	}
+	require.NoError(t, store.ImportThread(ctx, post, comments))
+	require.NoError(t, store.AddReaction(ctx, "a", "user-2", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, "b", "user-2", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, "b", "user-3", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, "c", "user-2", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, "c", "user-3", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, "d", "user-2", domain.ReactionDislike))
+	require.NoError(t, store.AddReaction(ctx, "e", "user-2", domain.ReactionLike))
+
+	ids := func(page []*domain.Comment) []string {
+		got := make([]string, len(page))
+		for i, c := range page {
+			got[i] = c.ID
+		}
+		return got
+	}
+	top := storage.PaginationArgs{Limit: 2, Order: domain.CommentOrderTop}
+
+	// Рейтинг по убыванию, при равенстве - в порядке создания; скрытый комментарий пропускается
+	page, total, err := store.GetCommentsPageByPostID(ctx, post.ID, top)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, ids(page))
+	assert.Equal(t, 4, total)
+	require.NotNil(t, page[0].Score)
+	assert.Equal(t, 2, *page[0].Score)
+	stored, err := store.GetCommentByID(ctx, "c")
+	require.NoError(t, err)
+	assert.Nil(t, stored.Score)
+
+	// Назад от последнего комментария
+	last, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 1, Backward: true, Order: domain.CommentOrderTop})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d"}, ids(last))
+	cursor := storage.EncodeCursor(last[0])
+	args := top
+	args.Cursor = &cursor
+	args.Backward = true
+	page, err = store.GetCommentsByPostID(ctx, post.ID, args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "e"}, ids(page))
+
+	// Рейтинг "c" падает после первой страницы: курсор хранит рейтинг "a" на момент выдачи,
+	// поэтому обход продолжается после "a", а "c" попадает на следующую страницу повторно
+	first, err := store.GetCommentsByPostID(ctx, post.ID, top)
+	require.NoError(t, err)
+	cursor = storage.EncodeCursor(first[1])
+	require.NoError(t, store.AddReaction(ctx, "c", "user-2", domain.ReactionDislike))
+	require.NoError(t, store.AddReaction(ctx, "c", "user-3", domain.ReactionDislike))
+	args = top
+	args.Cursor = &cursor
+	args.Limit = 3
+	page, err = store.GetCommentsByPostID(ctx, post.ID, args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e", "d", "c"}, ids(page))
+
+	// Курсор хронологического порядка не содержит рейтинга
+	chronological := storage.EncodeCursor(comments[0])
+	args.Cursor = &chronological
+	_, err = store.GetCommentsByPostID(ctx, post.ID, args)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestStore_Pagination_InsertBetweenPages(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	var want []string
+	create := func(content string) {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: content})
+		require.NoError(t, err)
+		want = append(want, c.ID)
+	}
+	for _, content := range []string{"1", "2", "3", "4"} {
+		create(content)
+	}
+
+	// Новый комментарий встает в конец списка: курсор первой страницы не сдвигается,
+	// вторая страница продолжает выборку без повторов и пропусков и доходит до нового
+	page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	got := []string{page[0].ID, page[1].ID}
+	create("5")
+
+	cursor := storage.EncodeCursor(page[1])
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	for _, c := range page {
+		got = append(got, c.ID)
+	}
+	assert.Equal(t, want, got)
+
+	// При листании с конца новый комментарий не попадает на более ранние страницы
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true})
+	require.NoError(t, err)
+	assert.Equal(t, want[3:], []string{page[0].ID, page[1].ID})
+	create("6")
+
+	cursor = storage.EncodeCursor(page[0])
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor, Backward: true})
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+	assert.Equal(t, want[:3], []string{page[0].ID, page[1].ID, page[2].ID})
+
+	// Даже если последний комментарий поста "из будущего" (импорт, отставшие часы),
+	// новый встает после него, а не перед выданным курсором
+	imported := &domain.Post{ID: "post-imported", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	future := &domain.Comment{ID: "future", PostID: imported.ID, AuthorID: "user-1", Content: "future", CreatedAt: time.Now().Add(time.Hour)}
+	require.NoError(t, store.ImportThread(ctx, imported, []*domain.Comment{future}))
+	cursor = storage.EncodeCursor(future)
+	created, err := store.CreateComment(ctx, &domain.Comment{PostID: imported.ID, AuthorID: "user-1", Content: "new"})
+	require.NoError(t, err)
+	page, err = store.GetCommentsByPostID(ctx, imported.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, created.ID, page[0].ID)
+}
+
+func TestStore_IDGenerator(t *testing.T) {
+	var n int
+	ids := storage.IDGeneratorFunc(func() string {
+		n++
+		return fmt.Sprintf("id-%d", n)
+	})
+	store := New(storage.WithIDGenerator(ids))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, "id-1", post.ID)
+
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	assert.Equal(t, "id-2", comment.ID)
+	reply, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &comment.ID, AuthorID: "user-1", Content: "reply"})
+	require.NoError(t, err)
+	assert.Equal(t, "id-3", reply.ID)
+
+	got, err := store.GetCommentByID(ctx, "id-3")
+	require.NoError(t, err)
+	assert.Equal(t, "reply", got.Content)
+}
+
+func TestStore_Clock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := storage.ClockFunc(func() time.Time { return now })
+	store := New(storage.WithClock(clock), storage.WithDuplicateWindow(time.Minute))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, now, post.CreatedAt)
+
+	// Комментарии, созданные в один и тот же момент, получают возрастающее время
+	// и листаются в порядке создания независимо от случайных ID
+	var want []string
+	for i := 0; i < 5; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(time.Duration(i)), c.CreatedAt)
+		want = append(want, c.ID)
+	}
+	var got []string
+	var cursor *string
+	for {
+		page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, c := range page {
+			got = append(got, c.ID)
+		}
+		next := storage.EncodeCursor(page[len(page)-1])
+		cursor = &next
+	}
+	assert.Equal(t, want, got)
+
+	// Окно антиспама отсчитывается по тем же часам
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment 4"})
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+	now = now.Add(2 * time.Minute)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment 4"})
+	require.NoError(t, err)
+}
+
+func TestStore_PreserveCreatedAt(t *testing.T) {
+	store := New()
+	ctx := storage.PreserveCreatedAt(context.Background())
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true, CreatedAt: start})
+	require.NoError(t, err)
+	assert.True(t, post.CreatedAt.Equal(start))
+
+	// Комментарии наполняются не по порядку, но список упорядочен по сохраненному времени
+	late, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "late", CreatedAt: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	early, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "early", CreatedAt: start.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.True(t, early.CreatedAt.Equal(start.Add(time.Hour)))
+
+	comments, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, []string{early.ID, late.ID}, []string{comments[0].ID, comments[1].ID})
+
+	stored, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastCommentAt)
+	assert.True(t, stored.LastCommentAt.Equal(late.CreatedAt))
+
+	// Без пометки контекста время ставит хранилище, даже если клиент его передал
+	plain, err := store.CreateComment(context.Background(), &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "plain", CreatedAt: start})
+	require.NoError(t, err)
+	assert.True(t, plain.CreatedAt.After(late.CreatedAt))
+}
+
+func TestStore_GetRecentCommentsByPostIDs(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return createdAt.Add(time.Duration(minutes) * time.Minute) }
+	first := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	root := "root-1"
+	require.NoError(t, store.ImportThread(ctx, first, []*domain.Comment{
+		{ID: root, PostID: first.ID, AuthorID: "user-1", Content: "root", CreatedAt: at(0), Hidden: true},
+		{ID: "reply-1", PostID: first.ID, ParentID: &root, AuthorID: "user-1", Content: "reply", CreatedAt: at(3)},
+	}))
+	second := &domain.Post{ID: "post-2", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	require.NoError(t, store.ImportThread(ctx, second, []*domain.Comment{
+		{ID: "b", PostID: second.ID, AuthorID: "user-1", Content: "b", CreatedAt: at(1)},
+		{ID: "c", PostID: second.ID, AuthorID: "user-1", Content: "c", CreatedAt: at(2)},
+	}))
+	other := &domain.Post{ID: "post-3", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	require.NoError(t, store.ImportThread(ctx, other, []*domain.Comment{
+		{ID: "other", PostID: other.ID, AuthorID: "user-1", Content: "other", CreatedAt: at(10)},
+	}))
+
+	ids := func(comments []*domain.Comment) []string {
+		got := make([]string, len(comments))
+		for i, c := range comments {
+			got[i] = c.ID
+		}
+		return got
+	}
+
+	// Ответы входят в выборку, скрытые комментарии и посты вне набора - нет;
+	// повтор и несуществующий ID поста не влияют на результат
+	recent, err := store.GetRecentCommentsByPostIDs(ctx, []string{"post-2", "post-1", "post-2", "missing"}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply-1", "c", "b"}, ids(recent))
+
+	recent, err = store.GetRecentCommentsByPostIDs(ctx, []string{"post-1", "post-2"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply-1", "c"}, ids(recent))
+
+	recent, err = store.GetRecentCommentsByPostIDs(ctx, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, recent)
+}
+
+func TestStore_GetCommentsSince(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return createdAt.Add(time.Duration(minutes) * time.Minute) }
+	post := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	root := "root"
+	require.NoError(t, store.ImportThread(ctx, post, []*domain.Comment{
+		{ID: root, PostID: post.ID, AuthorID: "user-1", Content: "root", CreatedAt: at(0)},
+		{ID: "second", PostID: post.ID, AuthorID: "user-1", Content: "second", CreatedAt: at(4)},
+		{ID: "reply", PostID: post.ID, ParentID: &root, AuthorID: "user-2", Content: "reply", CreatedAt: at(2)},
+		{ID: "twin", PostID: post.ID, AuthorID: "user-1", Content: "twin", CreatedAt: at(2)},
+		{ID: "hidden", PostID: post.ID, ParentID: &root, AuthorID: "user-2", Content: "hidden", CreatedAt: at(3), Hidden: true},
+	}))
+
+	ids := func(comments []*domain.Comment) []string {
+		got := make([]string, len(comments))
+		for i, c := range comments {
+			got[i] = c.ID
+		}
+		return got
+	}
+
+	// Ответы любой глубины идут вперемешку с корневыми по времени; граница since не включается
+	since, err := store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: at(0)}, 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply", "twin", "second"}, ids(since))
+
+	since, err = store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: at(0)}, 10, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply", "twin", "hidden", "second"}, ids(since))
+
+	// Курсор с ID продолжает выборку с комментария с тем же временем создания
+	since, err = store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: at(2), ID: "reply"}, 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"twin", "second"}, ids(since))
+
+	since, err = store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: at(-1)}, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root", "reply"}, ids(since))
+
+	since, err = store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: at(4)}, 10, false)
+	require.NoError(t, err)
+	assert.Empty(t, since)
+
+	_, err = store.GetCommentsSince(ctx, "missing", storage.Cursor{CreatedAt: at(0)}, 10, false)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_GetCommentsPageByPostID(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: fmt.Sprintf("comment %d", i)})
+		require.NoError(t, err)
+		ids = append(ids, c.ID)
+	}
+	_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &ids[0], AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, ids[3], true)
+	require.NoError(t, err)
+
+	// Ответы и скрытые комментарии не входят в число, курсор и лимит на него не влияют
+	page, total, err := store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, 3, total)
+
+	cursor := storage.EncodeCursor(page[1])
+	page, total, err = store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, ids[2], page[0].ID)
+	assert.Equal(t, 3, total)
+
+	page, total, err = store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[2], ids[3]}, []string{page[0].ID, page[1].ID})
+	assert.Equal(t, 4, total)
+
+	page, total, err = store.GetCommentsPageByPostID(ctx, "missing", storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	assert.Zero(t, total)
+}
+
+func TestStore_CreateComment_ParentInOtherPost(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	var posts []*domain.Post
+	for i := 0; i < 2; i++ {
+		post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		posts = append(posts, post)
+	}
+	parent, err := store.CreateComment(ctx, &domain.Comment{PostID: posts[0].ID, AuthorID: "user-1", Content: "parent"})
+	require.NoError(t, err)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: posts[1].ID, ParentID: &parent.ID, AuthorID: "user-1", Content: "reply"})
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "parentId", validationErr.Field)
+	assert.ErrorIs(t, err, storage.ErrParentOtherPost)
+
+	// Ни в одном из постов ответ не появился
+	children, err := store.GetCommentsByParentID(ctx, parent.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, children)
+	tree, err := store.GetCommentTree(ctx, posts[1].ID)
+	require.NoError(t, err)
+	assert.Empty(t, tree)
+}
+
+func TestStore_GetAuthorActivity(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := New(storage.WithClock(storage.ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+	tick := func() { now = now.Add(time.Minute) }
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	tick()
+	other, err := store.CreatePost(ctx, &domain.Post{Title: "Other", Content: "Content", AuthorID: "someone", CommentsEnabled: true})
+	require.NoError(t, err)
+	tick()
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: other.ID, AuthorID: "author", Content: "comment"})
+	require.NoError(t, err)
+	tick()
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "hidden"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+	tick()
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "someone", Content: "not author's"})
+	require.NoError(t, err)
+	tick()
+	latest, err := store.CreatePost(ctx, &domain.Post{Title: "Latest", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	ids := func(items []storage.ActivityItem) []string {
+		got := make([]string, len(items))
+		for i, item := range items {
+			got[i] = item.Cursor().ID
+		}
+		return got
+	}
+
+	// Посты и комментарии автора идут одной лентой от новых к старым, курсор продолжает ее
+	items, err := store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 2}, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{latest.ID, comment.ID}, ids(items))
+	assert.NotNil(t, items[0].Post)
+	assert.NotNil(t, items[1].Comment)
+
+	cursor := items[1].Cursor().Encode()
+	items, err = store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 2, Cursor: &cursor}, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{post.ID}, ids(items))
+
+	// Скрытые комментарии видны только с IncludeHidden
+	items, err = store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 10, IncludeHidden: true}, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{latest.ID, hidden.ID, comment.ID, post.ID}, ids(items))
+
+	// Черновик виден только автору и модераторам
+	draft := true
+	_, err = store.UpdatePost(ctx, latest.ID, storage.PostUpdate{Draft: &draft})
+	require.NoError(t, err)
+	items, err = store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 10}, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{comment.ID, post.ID}, ids(items))
+	items, err = store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 10}, storage.PostFilter{DraftsAuthorID: "author"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{latest.ID, comment.ID, post.ID}, ids(items))
+	items, err = store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 10}, storage.PostFilter{IncludeDrafts: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{latest.ID, comment.ID, post.ID}, ids(items))
+
+	invalid := "not a cursor"
+	_, err = store.GetAuthorActivity(ctx, "author", storage.PaginationArgs{Limit: 2, Cursor: &invalid}, storage.PostFilter{})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestStore_GetCommentTreePage(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	create := func(content string, parent *domain.Comment) *domain.Comment {
+		c := &domain.Comment{PostID: post.ID, AuthorID: "author", Content: content}
+		if parent != nil {
+			c.ParentID = &parent.ID
+		}
+		created, err := store.CreateComment(ctx, c)
+		require.NoError(t, err)
+		return created
+	}
+	first := create("first", nil)
+	second := create("second", nil)
+	create("first.1", first)
+	hidden := create("second.1", second)
+	create("second.1.1", hidden)
+	create("first.2", first)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	contents := func(comments []*domain.Comment) []string {
+		got := make([]string, len(comments))
+		for i, c := range comments {
+			got[i] = c.Content
+		}
+		return got
+	}
+
+	// Порядок обхода дерева, ветка скрытого комментария пропущена целиком
+	page, err := store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "first.1"}, contents(page))
+
+	cursor := storage.EncodeCursor(page[1])
+	page, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first.2", "second"}, contents(page))
+
+	page, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "first.1", "first.2", "second", "second.1", "second.1.1"}, contents(page))
+
+	// Курсор на скрытом комментарии продолжает обход после его ветки
+	cursor = storage.EncodeCursor(hidden)
+	page, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	// Постраничный обход с любым размером страницы совпадает с полным деревом
+	tree, err := store.GetCommentTree(ctx, post.ID)
+	require.NoError(t, err)
+	for limit := 1; limit <= 3; limit++ {
+		var all []*domain.Comment
+		args := storage.PaginationArgs{Limit: limit, IncludeHidden: true}
+		for {
+			page, err := store.GetCommentTreePage(ctx, post.ID, args)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			all = append(all, page...)
+			cursor := storage.EncodeCursor(page[len(page)-1])
+			args.Cursor = &cursor
+		}
+		assert.Equal(t, contents(tree), contents(all), "limit %d", limit)
+	}
+
+	invalid := storage.EncodeCursor(&domain.Comment{ID: "unknown"})
+	_, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &invalid})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, err = store.GetCommentTreePage(ctx, "unknown", storage.PaginationArgs{Limit: 10})
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_Premoderation(t *testing.T) {
+	store := New(storage.WithPremoderation(true))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentPending, root.Status)
+	reply, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+
+	// Не одобренные комментарии видны только с IncludeHidden - и в списках, и в лоадере
+	page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+	children, err := store.GetCommentsByParentIDs(ctx, []string{root.ID}, 0, false)
+	require.NoError(t, err)
+	assert.Empty(t, children[root.ID])
+
+	_, err = store.SetCommentStatus(ctx, root.ID, domain.CommentApproved)
+	require.NoError(t, err)
+	_, err = store.SetCommentStatus(ctx, reply.ID, domain.CommentRejected)
+	require.NoError(t, err)
+
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, root.ID, page[0].ID)
+	children, err = store.GetCommentsByParentIDs(ctx, []string{root.ID}, 0, false)
+	require.NoError(t, err)
+	assert.Empty(t, children[root.ID])
+
+	_, err = store.SetCommentStatus(ctx, "missing", domain.CommentApproved)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_GetPendingComments(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := &domain.Post{ID: "post-1", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	require.NoError(t, store.ImportThread(ctx, first, []*domain.Comment{
+		{ID: "b", PostID: first.ID, AuthorID: "user-1", Content: "b", CreatedAt: createdAt, Status: domain.CommentPending},
+		{ID: "approved", PostID: first.ID, AuthorID: "user-1", Content: "approved", CreatedAt: createdAt.Add(-2 * time.Hour)},
+	}))
+	second := &domain.Post{ID: "post-2", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	require.NoError(t, store.ImportThread(ctx, second, []*domain.Comment{
+		{ID: "a", PostID: second.ID, AuthorID: "user-1", Content: "a", CreatedAt: createdAt, Status: domain.CommentPending, Hidden: true},
+		{ID: "early", PostID: second.ID, AuthorID: "user-1", Content: "early", CreatedAt: createdAt.Add(-time.Hour), Status: domain.CommentPending},
+	}))
+
+	// Очередь общая для всех постов, сначала самые старые; скрытые по жалобам тоже в ней
+	page, err := store.GetPendingComments(ctx, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "early", page[0].ID)
+	assert.Equal(t, "a", page[1].ID)
+
+	cursor := storage.EncodeCursor(page[1])
+	page, err = store.GetPendingComments(ctx, storage.PaginationArgs{Limit: 2, Cursor: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "b", page[0].ID)
+}
+
+func TestStore_ReportComment_HidesPastThreshold(t *testing.T) {
+	store := New(storage.WithReportHideThreshold(2))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	reported, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "spam"})
+	require.NoError(t, err)
+	visible, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "ok"})
+	require.NoError(t, err)
+
+	for _, reporter := range []string{"user-2", "user-3", "user-3"} {
+		c, err := store.ReportComment(ctx, reported.ID, reporter, "spam")
+		require.NoError(t, err)
+		// Повторная жалоба user-3 не считается: жалоб от разных пользователей только 2
+		assert.False(t, c.Hidden)
+	}
+	c, err := store.ReportComment(ctx, reported.ID, "user-4", "")
+	require.NoError(t, err)
+	assert.True(t, c.Hidden)
+
+	page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, visible.ID, page[0].ID)
+
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+
+	// Модератор возвращает комментарий, жалобы сбрасываются
+	c, err = store.SetCommentHidden(ctx, reported.ID, false)
+	require.NoError(t, err)
+	assert.False(t, c.Hidden)
+	c, err = store.ReportComment(ctx, reported.ID, "user-5", "")
+	require.NoError(t, err)
+	assert.False(t, c.Hidden)
+
+	_, err = store.ReportComment(ctx, "missing", "user-2", "")
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_ReportComment_ThresholdDisabled(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "text"})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		comment, err = store.ReportComment(ctx, comment.ID, "user-"+strings.Repeat("x", i+1), "")
+		require.NoError(t, err)
+	}
+	assert.False(t, comment.Hidden)
+}
+
+func TestStore_GetCommentsByParentIDs_PerParentLimit(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	var replies []string
+	for i := 0; i < 5; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply " + strings.Repeat("!", i)})
+		require.NoError(t, err)
+		replies = append(replies, c.ID)
+	}
+
+	bounded, err := store.GetCommentsByParentIDs(ctx, []string{root.ID}, 3, false)
+	require.NoError(t, err)
+	require.Len(t, bounded[root.ID], 3)
+	for i, c := range bounded[root.ID] {
+		assert.Equal(t, replies[i], c.ID)
+	}
+
+	all, err := store.GetCommentsByParentIDs(ctx, []string{root.ID}, 0, false)
+	require.NoError(t, err)
+	assert.Len(t, all[root.ID], 5)
+}
+
+func TestStore_GetCommentsByParentIDs_GroupsAndOrders(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := &domain.Post{ID: "post", Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true, CreatedAt: base}
+	comment := func(id, parentID string, offset time.Duration) *domain.Comment {
+		c := &domain.Comment{ID: id, PostID: post.ID, AuthorID: "user-1", Content: id, CreatedAt: base.Add(offset)}
+		if parentID != "" {
+			c.ParentID = &parentID
+		}
+		return c
+	}
+
+	// Ответы двух родителей перемешаны и идут не по времени; a2-x и a2-y созданы одновременно
+	require.NoError(t, store.ImportThread(ctx, post, []*domain.Comment{
+		comment("a", "", 0),
+		comment("b", "", time.Millisecond),
+		comment("b2", "b", 5*time.Second),
+		comment("a3", "a", 6*time.Second),
+		comment("a2-y", "a", 3*time.Second),
+		comment("b1", "b", 2*time.Second),
+		comment("a1", "a", time.Second),
+		comment("a2-x", "a", 3*time.Second),
+	}))
+
+	byParent, err := store.GetCommentsByParentIDs(ctx, []string{"b", "a", "missing"}, 0, false)
+	require.NoError(t, err)
+
+	ids := func(comments []*domain.Comment) []string {
+		out := make([]string, len(comments))
+		for i, c := range comments {
+			out[i] = c.ID
+		}
+		return out
+	}
+	assert.Equal(t, []string{"a1", "a2-x", "a2-y", "a3"}, ids(byParent["a"]))
+	assert.Equal(t, []string{"b1", "b2"}, ids(byParent["b"]))
+	assert.Empty(t, byParent["missing"])
+}
+
+func TestStore_FollowPost(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := store.FollowPost(ctx, post.ID, "user-2", domain.NotificationEmail)
+	require.NoError(t, err)
+	_, err = store.FollowPost(ctx, post.ID, "user-3", domain.NotificationEmail)
+	require.NoError(t, err)
+	// Повторная подписка меняет канал, а не добавляет запись
+	sub, err := store.FollowPost(ctx, post.ID, "user-2", domain.NotificationPush)
+	require.NoError(t, err)
+	assert.Equal(t, domain.NotificationPush, sub.Channel)
+
+	followers, err := store.GetFollowers(ctx, post.ID)
+	require.NoError(t, err)
+	require.Len(t, followers, 2)
+	assert.Equal(t, "user-2", followers[0].UserID)
+	assert.Equal(t, "user-3", followers[1].UserID)
+
+	removed, err := store.UnfollowPost(ctx, post.ID, "user-3")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = store.UnfollowPost(ctx, post.ID, "user-3")
+	require.NoError(t, err)
+	assert.False(t, removed)
+
+	_, err = store.FollowPost(ctx, "missing", "user-2", domain.NotificationEmail)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_MergePosts(t *testing.T) {
+	store := New(storage.WithUniqueComments(true))
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	target := &domain.Post{ID: "target", Title: "Target", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	require.NoError(t, store.ImportThread(ctx, target, []*domain.Comment{
+		{ID: "t1", PostID: target.ID, AuthorID: "user-2", Content: "t1", CreatedAt: createdAt},
+		{ID: "t2", PostID: target.ID, AuthorID: "user-2", Content: "t2", CreatedAt: createdAt.Add(2 * time.Minute)},
+	}))
+	source := &domain.Post{ID: "source", Title: "Source", Content: "Content", AuthorID: "user-1", CommentsEnabled: true}
+	sourceRoot := "s1"
+	require.NoError(t, store.ImportThread(ctx, source, []*domain.Comment{
+		{ID: "s1", PostID: source.ID, AuthorID: "user-3", Content: "s1", CreatedAt: createdAt.Add(time.Minute)},
+		{ID: "s2", PostID: source.ID, ParentID: &sourceRoot, AuthorID: "user-2", Content: "s2", CreatedAt: createdAt.Add(3 * time.Minute)},
+	}))
+	for _, post := range []string{source.ID, target.ID} {
+		_, err := store.FollowPost(ctx, post, "user-2", domain.NotificationEmail)
+		require.NoError(t, err)
+	}
+	_, err := store.FollowPost(ctx, source.ID, "user-3", domain.NotificationEmail)
+	require.NoError(t, err)
+
+	_, err = store.MergePosts(ctx, source.ID, source.ID)
+	assert.ErrorIs(t, err, storage.ErrMergeSamePost)
+	_, err = store.MergePosts(ctx, source.ID, "missing")
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+
+	// При UniqueComments совпадение с комментарием целевого поста отменяет перенос целиком
+	dup, err := store.CreateComment(ctx, &domain.Comment{PostID: target.ID, AuthorID: "user-3", Content: "s1"})
+	require.NoError(t, err)
+	_, err = store.MergePosts(ctx, source.ID, target.ID)
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+	tree, err := store.GetCommentTree(ctx, source.ID)
+	require.NoError(t, err)
+	assert.Len(t, tree, 2)
+	_, err = store.UpdateComment(ctx, dup.ID, "edited")
+	require.NoError(t, err)
+
+	merged, err := store.MergePosts(ctx, source.ID, target.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target.ID, merged.ID)
+	assert.True(t, merged.LastCommentAt.Equal(dup.CreatedAt))
+
+	// Корневые комментарии идут по времени создания, ответ остался в своей ветке
+	tree, err = store.GetCommentTree(ctx, target.ID)
+	require.NoError(t, err)
+	ids := make([]string, len(tree))
+	for i, c := range tree {
+		ids[i] = c.ID
+		assert.Equal(t, target.ID, c.PostID)
+	}
+	assert.Equal(t, []string{"t1", "s1", "s2", "t2", dup.ID}, ids)
+
+	_, err = store.GetPostByID(ctx, source.ID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	followers, err := store.GetFollowers(ctx, target.ID)
+	require.NoError(t, err)
+	assert.Len(t, followers, 2)
+}
+
+func TestStore_GetPostWithCommentCount(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	empty, err := store.CreatePost(ctx, &domain.Post{Title: "Empty", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "hidden"})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &hidden.ID, AuthorID: "user-1", Content: "reply"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	// Видимый ответ на скрытый комментарий учитывается
+	got, count, err := store.GetPostWithCommentCount(ctx, post.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, got.ID)
+	assert.Equal(t, 2, count)
+
+	_, count, err = store.GetPostWithCommentCount(ctx, post.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	counts, err := store.CountCommentsByPostIDs(ctx, []string{post.ID, empty.ID, "missing"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{post.ID: 2}, counts)
+
+	// Скрытый ответ выпадает из своего уровня, но его ответ остается на следующем
+	histogram, err := store.GetDepthHistogram(ctx, post.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 2: 1}, histogram)
+	histogram, err = store.GetDepthHistogram(ctx, post.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 1: 1, 2: 1}, histogram)
+	histogram, err = store.GetDepthHistogram(ctx, empty.ID, true)
+	require.NoError(t, err)
+	assert.Empty(t, histogram)
+
+	_, _, err = store.GetPostWithCommentCount(ctx, "missing", false)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_GetPostStats(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := New(storage.WithPremoderation(true), storage.WithClock(storage.ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	empty, err := store.CreatePost(ctx, &domain.Post{Title: "Empty", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "root"})
+	require.NoError(t, err)
+	_, err = store.SetCommentStatus(ctx, root.ID, domain.CommentApproved)
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, root.ID, true)
+	require.NoError(t, err)
+	reply, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-3", Content: "reply"})
+	require.NoError(t, err)
+
+	// Жалоба позже последнего комментария сдвигает время активности
+	now = now.Add(time.Minute)
+	for _, reporter := range []string{"user-4", "user-5"} {
+		_, err := store.ReportComment(ctx, reply.ID, reporter, "spam")
+		require.NoError(t, err)
+	}
+
+	stats, err := store.GetPostStats(ctx, []string{post.ID, empty.ID, "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]storage.PostStats{
+		post.ID:  {CommentCount: 2, PendingCount: 1, ReportCount: 2, LastActivityAt: now},
+		empty.ID: {LastActivityAt: empty.CreatedAt},
+	}, stats)
+}
+
+func TestStore_GetUnansweredComments(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	create := func(parentID *string, authorID, content string) *domain.Comment {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: parentID, AuthorID: authorID, Content: content})
+		require.NoError(t, err)
+		return c
+	}
+	first := create(nil, "user-2", "first")
+	answered := create(nil, "user-3", "answered")
+	create(nil, "user-1", "own")
+	deep := create(nil, "user-2", "deep")
+	create(&answered.ID, "user-1", "reply")
+	// Ответ автора глубже первого уровня не считается ответом на корневой комментарий
+	other := create(&deep.ID, "user-4", "other reply")
+	create(&other.ID, "user-1", "nested reply")
+
+	page, err := store.GetUnansweredComments(ctx, post.ID, "user-1", storage.PaginationArgs{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, first.ID, page[0].ID)
+
+	cursor := storage.EncodeCursor(page[0])
+	page, err = store.GetUnansweredComments(ctx, post.ID, "user-1", storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, deep.ID, page[0].ID)
+
+	// Для другого автора ответы user-1 не в счет
+	page, err = store.GetUnansweredComments(ctx, post.ID, "user-4", storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page, 3)
+}
+
+func TestStore_SearchComments(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	other, err := store.CreatePost(ctx, &domain.Post{Title: "Other", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	long, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Пишу на Go уже давно, и <Go> мне нравится"})
+	require.NoError(t, err)
+	short, err := store.CreateComment(ctx, &domain.Comment{PostID: other.ID, AuthorID: "user-2", Content: "GO и Rust"})
+	require.NoError(t, err)
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "go hidden"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "ничего общего"})
+	require.NoError(t, err)
+
+	ids := func(hits []storage.SearchHit) []string {
+		result := make([]string, len(hits))
+		for i, h := range hits {
+			result[i] = h.Comment.ID
+		}
+		return result
+	}
+
+	// Короткий комментарий с тем же словом релевантнее, скрытый найден не будет
+	hits, err := store.SearchComments(ctx, "go", storage.SearchArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{short.ID, long.ID}, ids(hits))
+	assert.Equal(t, "<mark>GO</mark> и Rust", hits[0].Highlight)
+	assert.Equal(t, "Пишу на <mark>Go</mark> уже давно, и &lt;<mark>Go</mark>&gt; мне нравится", hits[1].Highlight)
+	assert.Greater(t, hits[0].Score, hits[1].Score)
+
+	hits, err = store.SearchComments(ctx, "go", storage.SearchArgs{Limit: 10, IncludeHidden: true, PostID: &post.ID})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{long.ID, hidden.ID}, ids(hits))
+
+	// Найдутся только комментарии со всеми словами запроса
+	hits, err = store.SearchComments(ctx, "go, rust", storage.SearchArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{short.ID}, ids(hits))
+
+	hits, err = store.SearchComments(ctx, "go", storage.SearchArgs{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{short.ID}, ids(hits))
+
+	hits, err = store.SearchComments(ctx, " !? ", storage.SearchArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+}
+
+func TestStore_SearchComments_MarkersInContent(t *testing.T) {
+	// Оператор разрешил символы маркеров в тексте - они не становятся тегами в highlight
+	store := New(storage.WithAllowedControlChars(storage.HighlightStartMarker + storage.HighlightStopMarker))
+	ctx := context.Background()
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	content := "go " + storage.HighlightStartMarker + "<img>" + storage.HighlightStopMarker
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: content})
+	require.NoError(t, err)
+
+	hits, err := store.SearchComments(ctx, "go", storage.SearchArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "<mark>go</mark> &lt;img&gt;", hits[0].Highlight)
+}
+
+func TestStore_LockedThread(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	middle, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "middle"})
+	require.NoError(t, err)
+	leaf, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &middle.ID, AuthorID: "user-1", Content: "leaf"})
+	require.NoError(t, err)
+	other, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-3", Content: "other"})
+	require.NoError(t, err)
+
+	locked, err := store.SetCommentLocked(ctx, middle.ID, true)
+	require.NoError(t, err)
+	assert.True(t, locked.Locked)
+
+	// Закрыты сам комментарий и все ответы под ним, но не его предки и соседние ветки
+	for _, parent := range []*domain.Comment{middle, leaf} {
+		_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &parent.ID, AuthorID: "user-4", Content: "reply to " + parent.Content})
+		assert.ErrorIs(t, err, storage.ErrThreadLocked, parent.Content)
+	}
+	for _, parent := range []*domain.Comment{root, other} {
+		_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &parent.ID, AuthorID: "user-4", Content: "reply to " + parent.Content})
+		assert.NoError(t, err, parent.Content)
+	}
+
+	_, err = store.SetCommentLocked(ctx, middle.ID, false)
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &leaf.ID, AuthorID: "user-4", Content: "reply to leaf"})
+	assert.NoError(t, err)
+
+	_, err = store.SetCommentLocked(ctx, "missing", true)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_BlockAuthor(t *testing.T) {
+	store, post := newTestStore(t)
+	ctx := context.Background()
+
+	other, err := store.CreatePost(ctx, &domain.Post{Title: "Other", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	require.NoError(t, store.BlockAuthor(ctx, post.ID, "spammer"))
+	require.NoError(t, store.BlockAuthor(ctx, post.ID, "spammer"))
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "spammer", Content: "spam"})
+	assert.ErrorIs(t, err, storage.ErrAuthorBlocked)
+
+	// Запрет действует только в своем посте и только для этого автора
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: other.ID, AuthorID: "spammer", Content: "spam"})
+	assert.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "hello"})
+	assert.NoError(t, err)
+
+	require.NoError(t, store.UnblockAuthor(ctx, post.ID, "spammer"))
+	require.NoError(t, store.UnblockAuthor(ctx, post.ID, "spammer"))
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "spammer", Content: "sorry"})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, store.BlockAuthor(ctx, "missing", "spammer"), storage.ErrPostNotFound)
+}
+
+func TestStore_WithTransaction(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	// Ошибка fn откатывает и пост, и комментарий
+	var postID string
+	err := store.WithTransaction(ctx, func(tx storage.Storage) error {
+		post, err := tx.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		postID = post.ID
+		_, err = tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "first"})
+		require.NoError(t, err)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	_, err = store.GetPostByID(ctx, postID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	assert.Empty(t, store.comments)
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	err = store.WithTransaction(ctx, func(tx storage.Storage) error {
+		disabled := false
+		if _, err := tx.UpdatePost(ctx, post.ID, storage.PostUpdate{CommentsEnabled: &disabled}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	// Изменения на месте тоже откатываются
+	got, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, got.CommentsEnabled)
+
+	// Паника откатывает транзакцию и не оставляет хранилище заблокированным
+	assert.Panics(t, func() {
+		_ = store.WithTransaction(ctx, func(tx storage.Storage) error {
+			_, _ = tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "lost"})
+			panic("boom")
+		})
+	})
+
+	var comment *domain.Comment
+	err = store.WithTransaction(ctx, func(tx storage.Storage) error {
+		comment, err = tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "kept"})
+		return err
+	})
+	require.NoError(t, err)
+	comments, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, comment.ID, comments[0].ID)
+}
@@ -2,29 +2,285 @@ package storage
 
 import (
 	"context"
+	"time"
+
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
 )
 
 // PaginationArgs - аргументы для пагинации.
 type PaginationArgs struct {
-	Limit  int
+	Limit int
+	// Cursor - курсор из EncodeCursor. Для совместимости со старыми клиентами
+	// также принимается ID комментария.
 	Cursor *string
+	// Backward - страница берется перед Cursor (без курсора - с конца списка).
+	// Комментарии и в этом случае возвращаются в порядке (CreatedAt, ID).
+	Backward bool
+	// IncludeHidden включает в выборку скрытые и не одобренные комментарии (для модераторов).
+	IncludeHidden bool
+	// Order - порядок комментариев, пустое значение означает domain.CommentOrderOldest.
+	// Курсор в хронологических порядках одинаковый, меняется только направление обхода;
+	// их учитывают GetCommentsByPostID, GetCommentsPageByPostID и GetCommentsByParentID.
+	//
+	// domain.CommentOrderTop поддерживают только GetCommentsByPostID и GetCommentsPageByPostID.
+	// Его курсор несет рейтинг комментария на момент выдачи, и следующая страница начинается
+	// после этой позиции в текущих рейтингах. Снимка выборки нет: комментарий, рейтинг
+	// которого изменился между запросами страниц, может встретиться повторно или не встретиться
+	// вовсе, но листание всегда продвигается и заканчивается.
+	Order domain.CommentOrder
+}
+
+// Descending сообщает, что записи выбираются по убыванию (CreatedAt, ID): это страница
+// вперед в порядке от новых к старым или страница назад в порядке от старых к новым.
+func (a PaginationArgs) Descending() bool {
+	return a.Backward != (a.Order == domain.CommentOrderNewest)
+}
+
+// Normalize приводит аргументы к допустимым для хранилища: ограничивает Limit сверху
+// значением maxLimit (0 - без ограничения), отрицательный Limit заменяет нулем (пустая страница)
+// и отклоняет пустой курсор с ErrInvalidCursor. Хранилища вызывают его в начале каждого
+// метода пагинации, поэтому лимит действует, даже если вызывающий забыл его применить.
+// Курсор, который не разбирается DecodeCursor, пропускается: это может быть ID комментария
+// от старого клиента, и проверить его может только хранилище.
+func (a *PaginationArgs) Normalize(maxLimit int) error {
+	if a.Cursor != nil && *a.Cursor == "" {
+		return ErrInvalidCursor
+	}
+	if a.Limit < 0 {
+		a.Limit = 0
+	}
+	if maxLimit > 0 && a.Limit > maxLimit {
+		a.Limit = maxLimit
+	}
+	return nil
+}
+
+// ActivityItem - запись ленты активности автора: пост или комментарий (заполнено ровно одно поле).
+type ActivityItem struct {
+	Post    *domain.Post
+	Comment *domain.Comment
+}
+
+// Cursor возвращает позицию записи в ленте. ID постов и комментариев - UUID из одного
+// генератора, поэтому курсор (время, ID) однозначен для обоих типов записей.
+func (a ActivityItem) Cursor() Cursor {
+	if a.Post != nil {
+		return Cursor{CreatedAt: a.Post.CreatedAt, ID: a.Post.ID}
+	}
+	return Cursor{CreatedAt: a.Comment.CreatedAt, ID: a.Comment.ID}
+}
+
+// SearchArgs - параметры поиска комментариев.
+type SearchArgs struct {
+	// PostID ограничивает поиск одним постом, nil - все посты.
+	PostID *string
+	Limit  int
+	// IncludeHidden включает скрытые и не одобренные комментарии (для модераторов).
+	IncludeHidden bool
+}
+
+// SearchHit - комментарий, найденный поиском.
+type SearchHit struct {
+	Comment *domain.Comment
+	// Highlight - текст комментария, экранированный для HTML, с совпадениями в <mark>...</mark>.
+	Highlight string
+	// Score - релевантность: больше - выше в выдаче. Шкала своя у каждого хранилища.
+	Score float64
+}
+
+// PostStats - статистика поста для модераторов.
+type PostStats struct {
+	// CommentCount - все комментарии поста вместе с ответами
+	CommentCount int
+	// PendingCount - комментарии, ждущие премодерации
+	PendingCount int
+	// ReportCount - жалобы на комментарии поста
+	ReportCount int
+	// LastActivityAt - время последнего события: создания поста, комментария или жалобы
+	LastActivityAt time.Time
+}
+
+// PostOrder - порядок сортировки постов.
+type PostOrder string
+
+const (
+	// PostOrderCreatedDesc - сначала новые посты (по умолчанию).
+	PostOrderCreatedDesc PostOrder = "CREATED_DESC"
+	// PostOrderLastActivityDesc - сначала посты с самой свежей активностью:
+	// временем последнего комментария или, если комментариев нет, временем создания.
+	PostOrderLastActivityDesc PostOrder = "LAST_ACTIVITY_DESC"
+)
+
+// PostFilter - необязательные условия отбора и сортировки постов. Пустые поля не фильтруют,
+// кроме черновиков: без IncludeDrafts и DraftsAuthorID выбираются только опубликованные посты.
+type PostFilter struct {
+	CommentsEnabled *bool
+	// Order - порядок сортировки, пустое значение означает PostOrderCreatedDesc.
+	Order PostOrder
+	// IncludeDrafts включает в выборку черновики всех авторов (для модераторов).
+	IncludeDrafts bool
+	// DraftsAuthorID включает в выборку черновики этого автора.
+	DraftsAuthorID string
+}
+
+// Includes сообщает, проходит ли пост условия фильтра. Хранилища, фильтрующие посты
+// в памяти, используют его, чтобы условия не расходились с SQL-реализацией.
+func (f PostFilter) Includes(p *domain.Post) bool {
+	if f.CommentsEnabled != nil && p.CommentsEnabled != *f.CommentsEnabled {
+		return false
+	}
+	if p.Draft && !f.IncludeDrafts && (f.DraftsAuthorID == "" || p.AuthorID != f.DraftsAuthorID) {
+		return false
+	}
+	return true
+}
+
+// PostUpdate - изменения поста. Поля со значением nil не меняются.
+type PostUpdate struct {
+	Title           *string
+	Content         *string
+	CommentsEnabled *bool
+	// Draft снимает пост с публикации (true) или публикует его (false).
+	Draft               *bool
+	DefaultCommentOrder *domain.CommentOrder
 }
 
 // Storage определяет контракт для хранилищ.
 type Storage interface {
-	GetPosts(ctx context.Context, limit, offset int) ([]*domain.Post, error)
+	GetPosts(ctx context.Context, limit, offset int, filter PostFilter) ([]*domain.Post, error)
+	// GetPostsAfter возвращает страницу постов после курсора из PostCursor в порядке filter.Order.
+	// cursor == nil означает первую страницу.
+	GetPostsAfter(ctx context.Context, limit int, cursor *string, filter PostFilter) ([]*domain.Post, error)
 	GetPostByID(ctx context.Context, id string) (*domain.Post, error)
+	// GetPostWithCommentCount возвращает пост вместе с числом его комментариев (включая ответы)
+	// за одно обращение к хранилищу - для страницы поста. Без includeHidden считаются только
+	// видимые всем комментарии.
+	GetPostWithCommentCount(ctx context.Context, id string, includeHidden bool) (*domain.Post, int, error)
 	CreatePost(ctx context.Context, post *domain.Post) (*domain.Post, error)
-	ToggleComments(ctx context.Context, postID string, enable bool) (*domain.Post, error)
+	// DeletePost удаляет пост вместе со всеми его комментариями.
+	DeletePost(ctx context.Context, id string) error
+	// UpdatePost атомарно применяет к посту все заданные в update изменения и возвращает обновленный пост.
+	UpdatePost(ctx context.Context, postID string, update PostUpdate) (*domain.Post, error)
+	// ToggleCommentsForAuthor переключает комментарии на всех постах автора и возвращает число затронутых постов.
+	ToggleCommentsForAuthor(ctx context.Context, authorID string, enable bool) (int, error)
+	// ToggleCommentsBulk атомарно переключает комментарии на наборе постов и возвращает
+	// обновленные посты. Несуществующие ID пропускаются.
+	ToggleCommentsBulk(ctx context.Context, postIDs []string, enable bool) ([]*domain.Post, error)
+	// ImportThread атомарно сохраняет пост и его комментарии с уже назначенными ID и временем создания.
+	// Родительский комментарий должен идти в comments раньше своих ответов.
+	ImportThread(ctx context.Context, post *domain.Post, comments []*domain.Comment) error
+	// MergePosts атомарно переносит все комментарии поста sourceID в пост targetID и удаляет
+	// исходный пост. Комментарии верхнего уровня остаются верхнего уровня, ответы сохраняют
+	// родителей, и в целевом посте комментарии идут по времени создания вперемешку с его собственными.
+	// Подписчики исходного поста переходят к целевому, блокировки авторов исходного поста
+	// удаляются вместе с ним. Возвращает обновленный целевой пост.
+	MergePosts(ctx context.Context, sourceID, targetID string) (*domain.Post, error)
 
 	CreateComment(ctx context.Context, comment *domain.Comment) (*domain.Comment, error)
+	// UpdateComment изменяет текст комментария с той же валидацией, что и при создании.
+	UpdateComment(ctx context.Context, id string, content string) (*domain.Comment, error)
 	GetCommentByID(ctx context.Context, id string) (*domain.Comment, error)
+	// GetCommentAncestors возвращает цепочку предков комментария от ближайшего родителя до корня.
+	GetCommentAncestors(ctx context.Context, id string) ([]*domain.Comment, error)
+	// GetCommentTree возвращает все дерево комментариев поста в порядке обхода в глубину
+	// (родитель перед своими ответами, ответы одного уровня по времени создания).
+	GetCommentTree(ctx context.Context, postID string) ([]*domain.Comment, error)
+	// GetCommentTreePage возвращает страницу дерева поста в порядке GetCommentTree, начиная
+	// после комментария из args.Cursor. Без IncludeHidden невидимые комментарии пропускаются
+	// вместе со всеми ответами на них. args.Backward не поддерживается. Страница читается
+	// от позиции курсора без построения всего дерева, поэтому обход большого обсуждения
+	// страницами стоит линейно от его размера.
+	GetCommentTreePage(ctx context.Context, postID string, args PaginationArgs) ([]*domain.Comment, error)
+
+	// ReportComment регистрирует жалобу пользователя и атомарно скрывает комментарий,
+	// если число жалоб от разных пользователей превысило порог из Options.
+	ReportComment(ctx context.Context, commentID, reporterID, reason string) (*domain.Comment, error)
+	// SetCommentHidden скрывает или возвращает комментарий. При возврате жалобы
+	// сбрасываются: модератор их рассмотрел.
+	SetCommentHidden(ctx context.Context, commentID string, hidden bool) (*domain.Comment, error)
+	// SetCommentLocked закрывает ветку комментария для новых ответов или открывает ее.
+	// CreateComment отклоняет ответ с ErrThreadLocked, если закрыт родитель или любой его предок.
+	SetCommentLocked(ctx context.Context, commentID string, locked bool) (*domain.Comment, error)
+	// SetCommentStatus меняет статус премодерации комментария.
+	SetCommentStatus(ctx context.Context, commentID string, status domain.CommentStatus) (*domain.Comment, error)
+
+	// BlockAuthor запрещает автору комментировать пост: CreateComment вернет ErrAuthorBlocked.
+	// Повторная блокировка ничего не меняет.
+	BlockAuthor(ctx context.Context, postID, authorID string) error
+	// UnblockAuthor снимает запрет. Снятие отсутствующего запрета не считается ошибкой.
+	UnblockAuthor(ctx context.Context, postID, authorID string) error
+	// IsAuthorBlocked сообщает, запрещено ли автору комментировать пост.
+	IsAuthorBlocked(ctx context.Context, postID, authorID string) (bool, error)
+
+	// FollowPost подписывает пользователя на уведомления о новых комментариях поста.
+	// Повторный вызов меняет канал доставки.
+	FollowPost(ctx context.Context, postID, userID string, channel domain.NotificationChannel) (*domain.PostSubscription, error)
+	// UnfollowPost отменяет подписку. Возвращает false, если подписки не было.
+	UnfollowPost(ctx context.Context, postID, userID string) (bool, error)
+	// GetFollowers возвращает подписки на пост в порядке оформления.
+	GetFollowers(ctx context.Context, postID string) ([]*domain.PostSubscription, error)
+
+	// AddReaction ставит реакцию пользователя на комментарий, заменяя предыдущую.
+	AddReaction(ctx context.Context, commentID, userID string, reactionType domain.ReactionType) error
 
 	// Методы для пагинации
 	GetCommentsByPostID(ctx context.Context, postID string, args PaginationArgs) ([]*domain.Comment, error)
 	GetCommentsByParentID(ctx context.Context, parentID string, args PaginationArgs) ([]*domain.Comment, error)
+	// GetCommentsPageByPostID возвращает ту же страницу, что и GetCommentsByPostID, вместе с общим
+	// числом комментариев верхнего уровня поста без учета курсора и лимита, с тем же учетом
+	// скрытых, - за одно обращение к хранилищу вместо двух.
+	GetCommentsPageByPostID(ctx context.Context, postID string, args PaginationArgs) ([]*domain.Comment, int, error)
+	// GetPendingComments возвращает ожидающие премодерации комментарии всех постов,
+	// начиная с самых старых. Скрытые по жалобам тоже возвращаются: список для модераторов.
+	GetPendingComments(ctx context.Context, args PaginationArgs) ([]*domain.Comment, error)
+	// GetUnansweredComments возвращает комментарии верхнего уровня поста, на которые authorID
+	// еще не ответил, в порядке args.Order (кроме TOP). Ответом считается прямой ответ любого
+	// статуса, в том числе скрытый или ждущий премодерации; комментарии самого authorID не возвращаются.
+	GetUnansweredComments(ctx context.Context, postID, authorID string, args PaginationArgs) ([]*domain.Comment, error)
+	// GetRecentCommentsByPostIDs возвращает limit самых новых видимых комментариев (включая ответы)
+	// из всех указанных постов, от новых к старым.
+	GetRecentCommentsByPostIDs(ctx context.Context, postIDs []string, limit int) ([]*domain.Comment, error)
+	// GetCommentsSince возвращает не больше limit комментариев поста любой глубины, идущих
+	// строго после after в порядке (CreatedAt, ID). after без ID означает «созданные строго после
+	// after.CreatedAt»; продолжать выборку нужно курсором последнего комментария с ID, иначе
+	// комментарии с тем же временем создания пропадут. Видимость, как и в GetRecentCommentsByPostIDs,
+	// проверяется у каждого комментария отдельно. Для несуществующего поста - ErrPostNotFound.
+	GetCommentsSince(ctx context.Context, postID string, after Cursor, limit int, includeHidden bool) ([]*domain.Comment, error)
+	// GetAuthorActivity возвращает посты и комментарии автора одной лентой, сначала новые
+	// (время DESC, ID DESC). args.Cursor - курсор ActivityItem.Cursor, args.Backward не поддерживается.
+	// Черновики автора попадают в ленту по правилам filter (см. PostFilter.Includes).
+	GetAuthorActivity(ctx context.Context, authorID string, args PaginationArgs, filter PostFilter) ([]ActivityItem, error)
 
 	// Методы для Dataloader'ов
-	GetCommentsByParentIDs(ctx context.Context, parentIDs []string) (map[string][]*domain.Comment, error)
+	// GetCommentsByParentIDs возвращает первые perParent ответов каждого родителя в порядке (CreatedAt, ID).
+	// perParent <= 0 снимает ограничение - для тредов с тысячами ответов так делать не стоит,
+	// полная пагинация идет через GetCommentsByParentID.
+	GetCommentsByParentIDs(ctx context.Context, parentIDs []string, perParent int, includeHidden bool) (map[string][]*domain.Comment, error)
+	GetPostsByIDs(ctx context.Context, ids []string) (map[string]*domain.Post, error)
+	// GetCommentsByIDs возвращает найденные комментарии одним запросом. Отсутствующие ID пропускаются.
+	GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*domain.Comment, error)
+	// GetReactionCountsByCommentIDs возвращает количество реакций каждого типа для комментариев одним запросом.
+	// Комментарии без реакций в результат не попадают.
+	GetReactionCountsByCommentIDs(ctx context.Context, ids []string) (map[string]map[domain.ReactionType]int, error)
+	// CountCommentsByPostIDs возвращает число комментариев (включая ответы) каждого поста одним
+	// запросом, с тем же учетом скрытых, что и GetPostWithCommentCount. Посты без комментариев
+	// в результат не попадают.
+	CountCommentsByPostIDs(ctx context.Context, postIDs []string, includeHidden bool) (map[string]int, error)
+	// GetDepthHistogram возвращает число комментариев поста на каждом уровне вложенности
+	// (0 - комментарии верхнего уровня) с тем же учетом скрытых, что и CountCommentsByPostIDs:
+	// сумма по уровням равна числу комментариев поста. Уровни без комментариев в результат не попадают.
+	GetDepthHistogram(ctx context.Context, postID string, includeHidden bool) (map[int]int, error)
+	// GetPostStats возвращает статистику постов для модераторов одним запросом. Комментарии
+	// считаются все, включая скрытые и не одобренные. Отсутствующие посты в результат не попадают.
+	GetPostStats(ctx context.Context, postIDs []string) (map[string]PostStats, error)
+	// SearchComments ищет комментарии, содержащие все слова запроса (см. SearchTerms), и
+	// возвращает до args.Limit лучших по убыванию Score, при равенстве в порядке (CreatedAt, ID).
+	// Postgres сравнивает слова целиком по полнотекстовому индексу, хранилище в памяти ищет
+	// подстроки. Запрос без слов ничего не находит.
+	SearchComments(ctx context.Context, query string, args SearchArgs) ([]SearchHit, error)
+
+	// WithTransaction выполняет fn атомарно: изменения, сделанные через tx, сохраняются,
+	// только если fn вернула nil, и откатываются все вместе при ошибке или панике.
+	// Внутри fn нужно обращаться только к tx: вызовы самого хранилища могут ждать конца транзакции.
+	WithTransaction(ctx context.Context, fn func(tx Storage) error) error
 }
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LogLevel - уровень журнала запросов хранилища. Сейчас его учитывает только postgres.
+type LogLevel int
+
+const (
+	// LogSilent выключает журнал запросов.
+	LogSilent LogLevel = iota + 1
+	// LogError пишет только ошибки запросов.
+	LogError
+	// LogWarn добавляет предупреждения, например о медленных запросах.
+	LogWarn
+	// LogInfo пишет каждый SQL-запрос вместе с параметрами - только для разработки.
+	LogInfo
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogSilent: "silent",
+	LogError:  "error",
+	LogWarn:   "warn",
+	LogInfo:   "info",
+}
+
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLogLevel разбирает уровень журнала по имени (silent, error, warn, info) без учета регистра.
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q: want silent, error, warn or info", name)
+}
+
+type operationKey struct{}
+
+// WithOperation сохраняет в контексте GraphQL-операцию, ради которой идут запросы к хранилищу,
+// например "query PostPage". Журнал медленных запросов пишет ее рядом с SQL.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// Operation возвращает операцию, сохраненную WithOperation, или пустую строку.
+func Operation(ctx context.Context) string {
+	operation, _ := ctx.Value(operationKey{}).(string)
+	return operation
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for _, level := range []LogLevel{LogSilent, LogError, LogWarn, LogInfo} {
+		parsed, err := ParseLogLevel(level.String())
+		require.NoError(t, err)
+		assert.Equal(t, level, parsed)
+	}
+
+	parsed, err := ParseLogLevel("WARN")
+	require.NoError(t, err)
+	assert.Equal(t, LogWarn, parsed)
+
+	_, err = ParseLogLevel("debug")
+	assert.Error(t, err)
+	_, err = ParseLogLevel("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MethodStats - накопленная статистика вызовов одного метода хранилища.
+type MethodStats struct {
+	Calls int64
+	// Errors - вызовы, вернувшие ошибку, включая ожидаемые вроде storage.ErrPostNotFound.
+	Errors int64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Avg возвращает среднюю длительность вызова.
+func (m MethodStats) Avg() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+	return m.Total / time.Duration(m.Calls)
+}
+
+// Collector - Recorder, который копит статистику по методам в памяти процесса
+// с момента запуска и отдает ее в JSON как http.Handler.
+type Collector struct {
+	mu      sync.Mutex
+	methods map[string]*MethodStats
+}
+
+var _ Recorder = (*Collector)(nil)
+
+// NewCollector создает пустой сборщик статистики.
+func NewCollector() *Collector {
+	return &Collector{methods: make(map[string]*MethodStats)}
+}
+
+func (c *Collector) Observe(method string, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.methods[method]
+	if !ok {
+		m = &MethodStats{}
+		c.methods[method] = m
+	}
+	m.Calls++
+	if err != nil {
+		m.Errors++
+	}
+	m.Total += d
+	m.Max = max(m.Max, d)
+}
+
+// Snapshot возвращает копию статистики по методам, которые вызывались хотя бы раз.
+func (c *Collector) Snapshot() map[string]MethodStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(map[string]MethodStats, len(c.methods))
+	for method, m := range c.methods {
+		snapshot[method] = *m
+	}
+	return snapshot
+}
+
+// methodStatsJSON - представление MethodStats в ответе, длительности в миллисекундах.
+type methodStatsJSON struct {
+	Calls   int64   `json:"calls"`
+	Errors  int64   `json:"errors"`
+	TotalMs float64 `json:"totalMs"`
+	AvgMs   float64 `json:"avgMs"`
+	MaxMs   float64 `json:"maxMs"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ServeHTTP отдает снимок статистики объектом {"GetPostByID": {"calls": ..., ...}}.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	out := make(map[string]methodStatsJSON)
+	for method, m := range c.Snapshot() {
+		out[method] = methodStatsJSON{
+			Calls:   m.Calls,
+			Errors:  m.Errors,
+			TotalMs: milliseconds(m.Total),
+			AvgMs:   milliseconds(m.Avg()),
+			MaxMs:   milliseconds(m.Max),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
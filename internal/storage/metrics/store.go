@@ -0,0 +1,278 @@
+// Package metrics измеряет время работы хранилища независимо от резолверов: декоратор
+// над любой реализацией storage.Storage передает каждый вызов метода в Recorder.
+// Так можно сравнить inmemory и postgres и найти медленные запросы по курсорам.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// Recorder получает результат каждого вызова хранилища: имя метода интерфейса,
+// длительность и ошибку. Вызывается конкурентно из разных запросов.
+type Recorder interface {
+	Observe(method string, d time.Duration, err error)
+}
+
+// Store реализует интерфейс Storage: вызывает next и сообщает о вызове в Recorder.
+type Store struct {
+	next storage.Storage
+	rec  Recorder
+}
+
+var _ storage.Storage = (*Store)(nil)
+
+// New оборачивает хранилище next, вызовы которого записываются в rec.
+func New(next storage.Storage, rec Recorder) *Store {
+	return &Store{next: next, rec: rec}
+}
+
+// observe записывает вызов method, начатый в start. Вызывается через defer,
+// поэтому err - указатель на именованный результат метода.
+func (s *Store) observe(method string, start time.Time, err *error) {
+	s.rec.Observe(method, time.Since(start), *err)
+}
+
+// WithTransaction записывает транзакцию целиком под своим именем, а вызовы внутри нее -
+// под именами методов, как и вне транзакции.
+func (s *Store) WithTransaction(ctx context.Context, fn func(tx storage.Storage) error) (err error) {
+	defer s.observe("WithTransaction", time.Now(), &err)
+	return s.next.WithTransaction(ctx, func(tx storage.Storage) error {
+		return fn(New(tx, s.rec))
+	})
+}
+
+// === Posts ===
+
+func (s *Store) GetPosts(ctx context.Context, limit, offset int, filter storage.PostFilter) (posts []*domain.Post, err error) {
+	defer s.observe("GetPosts", time.Now(), &err)
+	return s.next.GetPosts(ctx, limit, offset, filter)
+}
+
+func (s *Store) GetPostsAfter(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (posts []*domain.Post, err error) {
+	defer s.observe("GetPostsAfter", time.Now(), &err)
+	return s.next.GetPostsAfter(ctx, limit, cursor, filter)
+}
+
+func (s *Store) GetPostByID(ctx context.Context, id string) (post *domain.Post, err error) {
+	defer s.observe("GetPostByID", time.Now(), &err)
+	return s.next.GetPostByID(ctx, id)
+}
+
+func (s *Store) GetPostWithCommentCount(ctx context.Context, id string, includeHidden bool) (post *domain.Post, count int, err error) {
+	defer s.observe("GetPostWithCommentCount", time.Now(), &err)
+	return s.next.GetPostWithCommentCount(ctx, id, includeHidden)
+}
+
+func (s *Store) CreatePost(ctx context.Context, post *domain.Post) (created *domain.Post, err error) {
+	defer s.observe("CreatePost", time.Now(), &err)
+	return s.next.CreatePost(ctx, post)
+}
+
+func (s *Store) DeletePost(ctx context.Context, id string) (err error) {
+	defer s.observe("DeletePost", time.Now(), &err)
+	return s.next.DeletePost(ctx, id)
+}
+
+func (s *Store) UpdatePost(ctx context.Context, postID string, update storage.PostUpdate) (post *domain.Post, err error) {
+	defer s.observe("UpdatePost", time.Now(), &err)
+	return s.next.UpdatePost(ctx, postID, update)
+}
+
+func (s *Store) ToggleCommentsForAuthor(ctx context.Context, authorID string, enable bool) (count int, err error) {
+	defer s.observe("ToggleCommentsForAuthor", time.Now(), &err)
+	return s.next.ToggleCommentsForAuthor(ctx, authorID, enable)
+}
+
+func (s *Store) ToggleCommentsBulk(ctx context.Context, postIDs []string, enable bool) (posts []*domain.Post, err error) {
+	defer s.observe("ToggleCommentsBulk", time.Now(), &err)
+	return s.next.ToggleCommentsBulk(ctx, postIDs, enable)
+}
+
+func (s *Store) ImportThread(ctx context.Context, post *domain.Post, comments []*domain.Comment) (err error) {
+	defer s.observe("ImportThread", time.Now(), &err)
+	return s.next.ImportThread(ctx, post, comments)
+}
+
+func (s *Store) MergePosts(ctx context.Context, sourceID, targetID string) (post *domain.Post, err error) {
+	defer s.observe("MergePosts", time.Now(), &err)
+	return s.next.MergePosts(ctx, sourceID, targetID)
+}
+
+// === Comments ===
+
+func (s *Store) CreateComment(ctx context.Context, comment *domain.Comment) (created *domain.Comment, err error) {
+	defer s.observe("CreateComment", time.Now(), &err)
+	return s.next.CreateComment(ctx, comment)
+}
+
+func (s *Store) UpdateComment(ctx context.Context, id string, content string) (comment *domain.Comment, err error) {
+	defer s.observe("UpdateComment", time.Now(), &err)
+	return s.next.UpdateComment(ctx, id, content)
+}
+
+func (s *Store) GetCommentByID(ctx context.Context, id string) (comment *domain.Comment, err error) {
+	defer s.observe("GetCommentByID", time.Now(), &err)
+	return s.next.GetCommentByID(ctx, id)
+}
+
+func (s *Store) GetCommentAncestors(ctx context.Context, id string) (ancestors []*domain.Comment, err error) {
+	defer s.observe("GetCommentAncestors", time.Now(), &err)
+	return s.next.GetCommentAncestors(ctx, id)
+}
+
+func (s *Store) GetCommentTree(ctx context.Context, postID string) (tree []*domain.Comment, err error) {
+	defer s.observe("GetCommentTree", time.Now(), &err)
+	return s.next.GetCommentTree(ctx, postID)
+}
+
+func (s *Store) GetCommentTreePage(ctx context.Context, postID string, args storage.PaginationArgs) (tree []*domain.Comment, err error) {
+	defer s.observe("GetCommentTreePage", time.Now(), &err)
+	return s.next.GetCommentTreePage(ctx, postID, args)
+}
+
+// === Moderation ===
+
+func (s *Store) ReportComment(ctx context.Context, commentID, reporterID, reason string) (comment *domain.Comment, err error) {
+	defer s.observe("ReportComment", time.Now(), &err)
+	return s.next.ReportComment(ctx, commentID, reporterID, reason)
+}
+
+func (s *Store) SetCommentHidden(ctx context.Context, commentID string, hidden bool) (comment *domain.Comment, err error) {
+	defer s.observe("SetCommentHidden", time.Now(), &err)
+	return s.next.SetCommentHidden(ctx, commentID, hidden)
+}
+
+func (s *Store) SetCommentLocked(ctx context.Context, commentID string, locked bool) (comment *domain.Comment, err error) {
+	defer s.observe("SetCommentLocked", time.Now(), &err)
+	return s.next.SetCommentLocked(ctx, commentID, locked)
+}
+
+func (s *Store) SetCommentStatus(ctx context.Context, commentID string, status domain.CommentStatus) (comment *domain.Comment, err error) {
+	defer s.observe("SetCommentStatus", time.Now(), &err)
+	return s.next.SetCommentStatus(ctx, commentID, status)
+}
+
+func (s *Store) BlockAuthor(ctx context.Context, postID, authorID string) (err error) {
+	defer s.observe("BlockAuthor", time.Now(), &err)
+	return s.next.BlockAuthor(ctx, postID, authorID)
+}
+
+func (s *Store) UnblockAuthor(ctx context.Context, postID, authorID string) (err error) {
+	defer s.observe("UnblockAuthor", time.Now(), &err)
+	return s.next.UnblockAuthor(ctx, postID, authorID)
+}
+
+func (s *Store) IsAuthorBlocked(ctx context.Context, postID, authorID string) (blocked bool, err error) {
+	defer s.observe("IsAuthorBlocked", time.Now(), &err)
+	return s.next.IsAuthorBlocked(ctx, postID, authorID)
+}
+
+// === Subscriptions and reactions ===
+
+func (s *Store) FollowPost(ctx context.Context, postID, userID string, channel domain.NotificationChannel) (sub *domain.PostSubscription, err error) {
+	defer s.observe("FollowPost", time.Now(), &err)
+	return s.next.FollowPost(ctx, postID, userID, channel)
+}
+
+func (s *Store) UnfollowPost(ctx context.Context, postID, userID string) (removed bool, err error) {
+	defer s.observe("UnfollowPost", time.Now(), &err)
+	return s.next.UnfollowPost(ctx, postID, userID)
+}
+
+func (s *Store) GetFollowers(ctx context.Context, postID string) (subs []*domain.PostSubscription, err error) {
+	defer s.observe("GetFollowers", time.Now(), &err)
+	return s.next.GetFollowers(ctx, postID)
+}
+
+func (s *Store) AddReaction(ctx context.Context, commentID, userID string, reactionType domain.ReactionType) (err error) {
+	defer s.observe("AddReaction", time.Now(), &err)
+	return s.next.AddReaction(ctx, commentID, userID, reactionType)
+}
+
+// === Pages ===
+
+func (s *Store) GetCommentsByPostID(ctx context.Context, postID string, args storage.PaginationArgs) (comments []*domain.Comment, err error) {
+	defer s.observe("GetCommentsByPostID", time.Now(), &err)
+	return s.next.GetCommentsByPostID(ctx, postID, args)
+}
+
+func (s *Store) GetCommentsByParentID(ctx context.Context, parentID string, args storage.PaginationArgs) (comments []*domain.Comment, err error) {
+	defer s.observe("GetCommentsByParentID", time.Now(), &err)
+	return s.next.GetCommentsByParentID(ctx, parentID, args)
+}
+
+func (s *Store) GetCommentsPageByPostID(ctx context.Context, postID string, args storage.PaginationArgs) (comments []*domain.Comment, total int, err error) {
+	defer s.observe("GetCommentsPageByPostID", time.Now(), &err)
+	return s.next.GetCommentsPageByPostID(ctx, postID, args)
+}
+
+func (s *Store) GetPendingComments(ctx context.Context, args storage.PaginationArgs) (comments []*domain.Comment, err error) {
+	defer s.observe("GetPendingComments", time.Now(), &err)
+	return s.next.GetPendingComments(ctx, args)
+}
+
+func (s *Store) GetUnansweredComments(ctx context.Context, postID, authorID string, args storage.PaginationArgs) (comments []*domain.Comment, err error) {
+	defer s.observe("GetUnansweredComments", time.Now(), &err)
+	return s.next.GetUnansweredComments(ctx, postID, authorID, args)
+}
+
+func (s *Store) GetRecentCommentsByPostIDs(ctx context.Context, postIDs []string, limit int) (comments []*domain.Comment, err error) {
+	defer s.observe("GetRecentCommentsByPostIDs", time.Now(), &err)
+	return s.next.GetRecentCommentsByPostIDs(ctx, postIDs, limit)
+}
+
+func (s *Store) GetCommentsSince(ctx context.Context, postID string, after storage.Cursor, limit int, includeHidden bool) (comments []*domain.Comment, err error) {
+	defer s.observe("GetCommentsSince", time.Now(), &err)
+	return s.next.GetCommentsSince(ctx, postID, after, limit, includeHidden)
+}
+
+func (s *Store) GetAuthorActivity(ctx context.Context, authorID string, args storage.PaginationArgs, filter storage.PostFilter) (items []storage.ActivityItem, err error) {
+	defer s.observe("GetAuthorActivity", time.Now(), &err)
+	return s.next.GetAuthorActivity(ctx, authorID, args, filter)
+}
+
+func (s *Store) SearchComments(ctx context.Context, query string, args storage.SearchArgs) (hits []storage.SearchHit, err error) {
+	defer s.observe("SearchComments", time.Now(), &err)
+	return s.next.SearchComments(ctx, query, args)
+}
+
+// === Batches ===
+
+func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, perParent int, includeHidden bool) (comments map[string][]*domain.Comment, err error) {
+	defer s.observe("GetCommentsByParentIDs", time.Now(), &err)
+	return s.next.GetCommentsByParentIDs(ctx, parentIDs, perParent, includeHidden)
+}
+
+func (s *Store) GetPostsByIDs(ctx context.Context, ids []string) (posts map[string]*domain.Post, err error) {
+	defer s.observe("GetPostsByIDs", time.Now(), &err)
+	return s.next.GetPostsByIDs(ctx, ids)
+}
+
+func (s *Store) GetCommentsByIDs(ctx context.Context, ids []string) (comments map[string]*domain.Comment, err error) {
+	defer s.observe("GetCommentsByIDs", time.Now(), &err)
+	return s.next.GetCommentsByIDs(ctx, ids)
+}
+
+func (s *Store) GetReactionCountsByCommentIDs(ctx context.Context, ids []string) (counts map[string]map[domain.ReactionType]int, err error) {
+	defer s.observe("GetReactionCountsByCommentIDs", time.Now(), &err)
+	return s.next.GetReactionCountsByCommentIDs(ctx, ids)
+}
+
+func (s *Store) CountCommentsByPostIDs(ctx context.Context, postIDs []string, includeHidden bool) (counts map[string]int, err error) {
+	defer s.observe("CountCommentsByPostIDs", time.Now(), &err)
+	return s.next.CountCommentsByPostIDs(ctx, postIDs, includeHidden)
+}
+
+func (s *Store) GetDepthHistogram(ctx context.Context, postID string, includeHidden bool) (histogram map[int]int, err error) {
+	defer s.observe("GetDepthHistogram", time.Now(), &err)
+	return s.next.GetDepthHistogram(ctx, postID, includeHidden)
+}
+
+func (s *Store) GetPostStats(ctx context.Context, postIDs []string) (stats map[string]storage.PostStats, err error) {
+	defer s.observe("GetPostStats", time.Now(), &err)
+	return s.next.GetPostStats(ctx, postIDs)
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/UkralStul/graphql-comments-service/internal/storage/inmemory"
+)
+
+func TestStore_RecordsCalls(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	store := New(inmemory.New(), collector)
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	_, err = store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	_, err = store.GetPostByID(ctx, "missing")
+	require.ErrorIs(t, err, storage.ErrPostNotFound)
+
+	stats := collector.Snapshot()
+	assert.Equal(t, int64(1), stats["CreatePost"].Calls)
+	assert.Equal(t, int64(2), stats["GetPostByID"].Calls)
+	assert.Equal(t, int64(1), stats["GetPostByID"].Errors)
+	assert.GreaterOrEqual(t, stats["GetPostByID"].Total, stats["GetPostByID"].Max)
+	assert.NotContains(t, stats, "DeletePost")
+}
+
+func TestStore_WithTransactionRecordsInnerCalls(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	store := New(inmemory.New(), collector)
+
+	errRollback := errors.New("rollback")
+	err := store.WithTransaction(ctx, func(tx storage.Storage) error {
+		_, err := tx.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1"})
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	stats := collector.Snapshot()
+	assert.Equal(t, int64(1), stats["WithTransaction"].Calls)
+	assert.Equal(t, int64(1), stats["WithTransaction"].Errors)
+	assert.Equal(t, int64(1), stats["CreatePost"].Calls)
+	assert.Equal(t, int64(0), stats["CreatePost"].Errors)
+}
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	collector := NewCollector()
+	collector.Observe("GetPostByID", 10*time.Millisecond, nil)
+	collector.Observe("GetPostByID", 30*time.Millisecond, storage.ErrPostNotFound)
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/storage", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body map[string]methodStatsJSON
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, methodStatsJSON{Calls: 2, Errors: 1, TotalMs: 40, AvgMs: 20, MaxMs: 30}, body["GetPostByID"])
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+const (
+	// DefaultMaxDepth - глубина вложенности комментариев по умолчанию.
+	DefaultMaxDepth = 100
+	// DefaultMaxCommentLength - максимальная длина комментария по умолчанию.
+	// Колонка content в postgres имеет тип varchar(2000), поэтому больший лимит там не поместится.
+	DefaultMaxCommentLength = 2000
+	// DefaultMaxAttachments - сколько вложений может быть у комментария по умолчанию.
+	DefaultMaxAttachments = 5
+	// DefaultMaxBlankLines - сколько пустых строк подряд оставляет нормализация.
+	DefaultMaxBlankLines = 2
+	// DefaultSlowQueryThreshold - порог медленного запроса к базе по умолчанию,
+	// тот же, что у logger.Default в GORM.
+	DefaultSlowQueryThreshold = 200 * time.Millisecond
+)
+
+// Options - настройки поведения хранилищ, общие для всех реализаций.
+type Options struct {
+	// MaxDepth ограничивает обход цепочки предков комментария.
+	MaxDepth int
+	// MaxCommentLength - максимальная длина текста комментария в символах.
+	MaxCommentLength int
+	// MaxPageSize - сколько записей может вернуть один вызов метода пагинации, 0 - без ограничения.
+	MaxPageSize int
+	// MaxAttachments - сколько вложений может быть у комментария, 0 запрещает вложения.
+	MaxAttachments int
+	// AllowedControlChars - управляющие символы, которые допускаются в тексте комментария.
+	AllowedControlChars string
+	// NormalizeContent включает нормализацию текста комментария перед сохранением.
+	NormalizeContent bool
+	// MaxBlankLines - максимальное число пустых строк подряд после нормализации.
+	MaxBlankLines int
+	// DuplicateWindow - окно, в течение которого повтор того же текста тем же автором
+	// в том же посте отклоняется как дубликат. 0 отключает проверку.
+	DuplicateWindow time.Duration
+	// UniqueComments запрещает автору повторять текст комментария в том же посте
+	// без ограничения по времени. В postgres это обеспечивает уникальный индекс.
+	UniqueComments bool
+	// ReportHideThreshold - комментарий скрывается, когда число жалоб от разных
+	// пользователей превышает это значение. 0 отключает автоскрытие.
+	ReportHideThreshold int
+	// Premoderation - новые комментарии ждут одобрения модератором (статус PENDING).
+	Premoderation bool
+	// DraftPosts - новые посты создаются черновиками и появляются в списках после публикации.
+	DraftPosts bool
+	// CascadeDeletes - при удалении поста или комментария в обход хранилища postgres
+	// удаляет и зависящие от них комментарии (ON DELETE CASCADE). Иначе такое удаление запрещено.
+	CascadeDeletes bool
+	// LogLevel - уровень журнала SQL-запросов postgres.
+	LogLevel LogLevel
+	// SlowQueryThreshold - запросы дольше порога пишутся в журнал на уровне warn, 0 - выключено.
+	SlowQueryThreshold time.Duration
+	// IDs выдает ID новых постов и комментариев.
+	IDs IDGenerator
+	// Clock задает время создания записей.
+	Clock Clock
+}
+
+// Option изменяет настройки хранилища.
+type Option func(*Options)
+
+// WithMaxDepth задает максимальную глубину вложенности комментариев.
+func WithMaxDepth(depth int) Option {
+	return func(o *Options) {
+		o.MaxDepth = depth
+	}
+}
+
+// WithMaxCommentLength задает максимальную длину текста комментария.
+func WithMaxCommentLength(length int) Option {
+	return func(o *Options) {
+		o.MaxCommentLength = length
+	}
+}
+
+// WithMaxAttachments задает, сколько вложений может быть у комментария.
+func WithMaxAttachments(n int) Option {
+	return func(o *Options) {
+		o.MaxAttachments = n
+	}
+}
+
+// WithAllowedControlChars задает управляющие символы, допустимые в тексте комментария.
+func WithAllowedControlChars(chars string) Option {
+	return func(o *Options) {
+		o.AllowedControlChars = chars
+	}
+}
+
+// WithMaxPageSize ограничивает число записей, которое возвращает один вызов метода пагинации.
+func WithMaxPageSize(size int) Option {
+	return func(o *Options) {
+		o.MaxPageSize = size
+	}
+}
+
+// WithNormalization включает или выключает нормализацию текста комментариев.
+func WithNormalization(enabled bool, maxBlankLines int) Option {
+	return func(o *Options) {
+		o.NormalizeContent = enabled
+		o.MaxBlankLines = maxBlankLines
+	}
+}
+
+// WithDuplicateWindow включает отклонение повторяющихся подряд комментариев.
+func WithDuplicateWindow(window time.Duration) Option {
+	return func(o *Options) {
+		o.DuplicateWindow = window
+	}
+}
+
+// WithUniqueComments включает запрет повторного текста комментария автора в посте.
+func WithUniqueComments(enabled bool) Option {
+	return func(o *Options) {
+		o.UniqueComments = enabled
+	}
+}
+
+// WithReportHideThreshold задает число жалоб, после превышения которого комментарий скрывается.
+func WithReportHideThreshold(threshold int) Option {
+	return func(o *Options) {
+		o.ReportHideThreshold = threshold
+	}
+}
+
+// WithPremoderation включает премодерацию новых комментариев.
+func WithPremoderation(enabled bool) Option {
+	return func(o *Options) {
+		o.Premoderation = enabled
+	}
+}
+
+// WithDraftPosts включает создание новых постов черновиками.
+func WithDraftPosts(enabled bool) Option {
+	return func(o *Options) {
+		o.DraftPosts = enabled
+	}
+}
+
+// WithCascadeDeletes выбирает поведение внешних ключей комментариев при удалении поста или родителя.
+func WithCascadeDeletes(enabled bool) Option {
+	return func(o *Options) {
+		o.CascadeDeletes = enabled
+	}
+}
+
+// WithLogLevel задает уровень журнала запросов хранилища.
+func WithLogLevel(level LogLevel) Option {
+	return func(o *Options) {
+		o.LogLevel = level
+	}
+}
+
+// WithSlowQueryThreshold задает порог, после которого запрос к базе считается медленным.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(o *Options) {
+		o.SlowQueryThreshold = threshold
+	}
+}
+
+// WithIDGenerator задает генератор ID новых постов и комментариев.
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(o *Options) {
+		o.IDs = ids
+	}
+}
+
+// WithClock задает источник времени для записей хранилища.
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// InitialStatus возвращает статус нового комментария.
+func (o Options) InitialStatus() domain.CommentStatus {
+	if o.Premoderation {
+		return domain.CommentPending
+	}
+	return domain.CommentApproved
+}
+
+// ShouldHide сообщает, нужно ли скрыть комментарий с указанным числом жалоб.
+func (o Options) ShouldHide(reports int) bool {
+	return o.ReportHideThreshold > 0 && reports > o.ReportHideThreshold
+}
+
+// NewOptions возвращает настройки по умолчанию с примененными опциями.
+func NewOptions(opts ...Option) Options {
+	o := Options{
+		MaxDepth:            DefaultMaxDepth,
+		MaxCommentLength:    DefaultMaxCommentLength,
+		MaxAttachments:      DefaultMaxAttachments,
+		AllowedControlChars: DefaultAllowedControlChars,
+		NormalizeContent:    true,
+		MaxBlankLines:       DefaultMaxBlankLines,
+		LogLevel:            LogInfo,
+		SlowQueryThreshold:  DefaultSlowQueryThreshold,
+		IDs:                 UUIDv4,
+		Clock:               SystemClock,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// PrepareCommentContent нормализует (если включено) и проверяет текст комментария.
+// Проверки выполняются уже над нормализованным текстом.
+func (o Options) PrepareCommentContent(content string) (string, error) {
+	if o.NormalizeContent {
+		content = NormalizeCommentContent(content, o.MaxBlankLines)
+	}
+	if err := ValidateCommentContent(content, o.MaxCommentLength); err != nil {
+		return "", err
+	}
+	if err := ValidateControlChars(content, o.AllowedControlChars); err != nil {
+		return "", err
+	}
+	return content, nil
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// slowQueryLogger - логгер GORM, который пишет медленные запросы одной строкой ключ=значение
+// вместе с GraphQL-операцией из контекста (storage.WithOperation), как querylog пишет
+// медленные операции. Остальные записи уходят в стандартный логгер GORM.
+type slowQueryLogger struct {
+	logger.Interface
+	level     logger.LogLevel
+	threshold time.Duration
+	// logf по умолчанию - log.Printf.
+	logf func(format string, args ...interface{})
+}
+
+// newLogger создает логгер GORM с уровнем level и порогом медленных запросов threshold (0 - выключено).
+func newLogger(level logger.LogLevel, threshold time.Duration) *slowQueryLogger {
+	// Медленные запросы пишет slowQueryLogger, поэтому у стандартного логгера порог выключен
+	base := logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		LogLevel: level,
+		Colorful: true,
+	})
+	return &slowQueryLogger{Interface: base, level: level, threshold: threshold, logf: log.Printf}
+}
+
+func (l *slowQueryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.Interface = l.Interface.LogMode(level)
+	copied.level = level
+	return &copied
+}
+
+// Trace пишет медленный запрос, если он завершился без ошибки. Ошибки и обычные запросы
+// на уровне info пишет стандартный логгер.
+func (l *slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	if err == nil && l.threshold > 0 && elapsed >= l.threshold && l.level >= logger.Warn {
+		sql, rows := fc()
+		l.logf("slow sql: operation=%q duration=%s rows=%d sql=%q", storage.Operation(ctx), elapsed, rows, sql)
+		return
+	}
+	l.Interface.Trace(ctx, begin, fc, err)
+}
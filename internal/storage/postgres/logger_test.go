@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+func TestSlowQueryLogger(t *testing.T) {
+	var lines []string
+	l := newLogger(logger.Warn, 100*time.Millisecond)
+	l.logf = func(format string, args ...interface{}) { lines = append(lines, fmt.Sprintf(format, args...)) }
+	l.Interface = logger.Discard
+
+	ctx := storage.WithOperation(context.Background(), "query PostPage")
+	query := func() (string, int64) { return `SELECT * FROM "comments" WHERE id > '1'`, 3 }
+
+	l.Trace(ctx, time.Now(), query, nil)
+	assert.Empty(t, lines)
+
+	l.Trace(ctx, time.Now().Add(-time.Second), query, nil)
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `operation="query PostPage"`)
+	assert.Contains(t, lines[0], "rows=3")
+	assert.Contains(t, lines[0], `sql="SELECT * FROM \"comments\" WHERE id > '1'"`)
+
+	// Ошибки пишет стандартный логгер, даже если запрос был медленным
+	l.Trace(ctx, time.Now().Add(-time.Second), query, errors.New("boom"))
+	assert.Len(t, lines, 1)
+
+	// Уровень ниже warn выключает и журнал медленных запросов
+	silent := l.LogMode(logger.Error).(*slowQueryLogger)
+	silent.Trace(ctx, time.Now().Add(-time.Second), query, nil)
+	assert.Len(t, lines, 1)
+}
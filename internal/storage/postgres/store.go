@@ -4,204 +4,1610 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/UkralStul/graphql-comments-service/internal/domain"
 	"github.com/UkralStul/graphql-comments-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 // Store реализует интерфейс Storage с использованием PostgreSQL.
 type Store struct {
-	db *gorm.DB
+	db   *gorm.DB
+	opts storage.Options
 }
 
 // New создает новый экземпляр хранилища PostgreSQL.
-func New(dsn string) (*Store, error) {
+func New(dsn string, opts ...storage.Option) (*Store, error) {
+	o := storage.NewOptions(opts...)
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info), // Включаем логирование для отладки
+		Logger: newLogger(gormLogLevel(o.LogLevel), o.SlowQueryThreshold),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Выполняем миграцию схемы
-	if err := db.AutoMigrate(&domain.Post{}, &domain.Comment{}); err != nil {
+	if err := db.AutoMigrate(&domain.Post{}, &domain.Comment{}, &domain.Reaction{}, &domain.Report{}, &domain.PostSubscription{}, &domain.PostBlock{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	if err := migrateUniqueComments(db, o.UniqueComments); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := migrateForeignKeys(db, o.CascadeDeletes); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := migrateListingIndexes(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return &Store{db: db, opts: o}, nil
+}
+
+// WithTransaction выполняет fn в транзакции GORM над копией хранилища, привязанной к ней.
+// Транзакции внутри методов tx становятся точками сохранения внешней.
+func (s *Store) WithTransaction(ctx context.Context, fn func(tx storage.Storage) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Store{db: tx, opts: s.opts})
+	})
+}
+
+// gormLogLevel переводит уровень журнала хранилища в уровень логгера GORM.
+func gormLogLevel(level storage.LogLevel) logger.LogLevel {
+	switch level {
+	case storage.LogSilent:
+		return logger.Silent
+	case storage.LogError:
+		return logger.Error
+	case storage.LogWarn:
+		return logger.Warn
+	default:
+		return logger.Info
+	}
+}
+
+// uniqueCommentsIndex - уникальный индекс, запрещающий автору повторять текст в посте.
+const uniqueCommentsIndex = "idx_comments_unique_content"
+
+// migrateUniqueComments создает или удаляет уникальный индекс по (post_id, author_id, content_hash).
+// Индекс строится по хешу, а не по тексту: текст до 2000 символов слишком велик для btree.
+// Индекс необязателен - на базе, где уже есть повторы, его создание завершится ошибкой,
+// поэтому он создается только при включенной опции UniqueComments.
+func migrateUniqueComments(db *gorm.DB, enabled bool) error {
+	if !enabled {
+		return db.Exec("DROP INDEX IF EXISTS " + uniqueCommentsIndex).Error
+	}
+	if err := db.Exec("ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_hash text GENERATED ALWAYS AS (md5(content)) STORED").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " + uniqueCommentsIndex + " ON comments (post_id, author_id, content_hash)").Error
+}
+
+// Индексы списков комментариев. Страницы выбираются с условием на родителя и курсором
+// (created_at, id) > (?, ?) в порядке (created_at, id), поэтому индекс с этими колонками
+// после условий на равенство отдает страницу чтением диапазона, без сортировки всех комментариев:
+//   - postListingIndex - комментарии верхнего уровня поста: post_id = ? AND parent_id IS NULL;
+//   - childrenListingIndex - ответы: parent_id = ? и parent_id IN (...) у Dataloader.
+//     Эти запросы не фильтруют по post_id, поэтому первый индекс им не подходит;
+//   - postTimelineIndex - комментарии поста любой глубины для GetCommentsSince:
+//     post_id = ? AND (created_at, id) > (?, ?). Условия на parent_id нет, поэтому первый индекс не подходит;
+//   - searchIndex - GIN-индекс по словам текста для SearchComments. Выражение должно совпадать
+//     с запросом дословно, иначе планировщик индекс не использует.
+//
+// Условие на видимость (status, hidden) проверяется по строкам индекса, что дешево,
+// пока скрытых комментариев немного. TestListingIndexes проверяет планы через EXPLAIN.
+const (
+	postListingIndex     = "idx_comments_post_listing"
+	childrenListingIndex = "idx_comments_children_listing"
+	postTimelineIndex    = "idx_comments_post_timeline"
+	searchIndex          = "idx_comments_search"
+)
+
+// migrateListingIndexes создает индексы списков комментариев.
+func migrateListingIndexes(db *gorm.DB) error {
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS " + postListingIndex + " ON comments (post_id, parent_id, created_at, id)").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS " + childrenListingIndex + " ON comments (parent_id, created_at, id)").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS " + postTimelineIndex + " ON comments (post_id, created_at, id)").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS " + searchIndex + " ON comments USING GIN (to_tsvector('simple', content))").Error
+}
+
+// Внешние ключи комментариев. Имена совпадают с теми, которые GORM дает связям
+// Post.Comments и Comment.Children, поэтому AutoMigrate не создает вторые ключи.
+// Ключ на родителя вместе с проверкой parent_id <> id (тег check в domain.Comment)
+// исключает циклы: parent_id не меняется после создания, а родитель всегда создан раньше ответа.
+const (
+	postForeignKey   = "fk_posts_comments"    // comments.post_id -> posts.id
+	parentForeignKey = "fk_comments_children" // comments.parent_id -> comments.id
+)
+
+// onDeleteRules - значения pg_constraint.confdeltype для действий ON DELETE.
+var onDeleteRules = map[string]string{"NO ACTION": "a", "RESTRICT": "r", "CASCADE": "c"}
+
+// migrateForeignKeys задает действия внешних ключей комментариев при удалении поста
+// или родительского комментария. Без каскада ключ на родителя - NO ACTION, а не RESTRICT:
+// RESTRICT проверяется по каждой строке сразу, и удаление всех комментариев поста
+// одним запросом (DeletePost, каскад от поста) падало бы на ответах, удаленных позже родителя.
+func migrateForeignKeys(db *gorm.DB, cascade bool) error {
+	postAction, parentAction := "RESTRICT", "NO ACTION"
+	if cascade {
+		postAction, parentAction = "CASCADE", "CASCADE"
+	}
+	if err := migrateForeignKey(db, postForeignKey, "post_id", "posts", postAction); err != nil {
+		return err
+	}
+	return migrateForeignKey(db, parentForeignKey, "parent_id", "comments", parentAction)
+}
+
+// migrateForeignKey создает внешний ключ name на колонке comments.column с действием action.
+// Ключ пересоздается только при смене действия - его создание проверяет все комментарии
+// и завершится ошибкой, если в базе есть комментарии без поста или родителя.
+func migrateForeignKey(db *gorm.DB, name, column, refTable, action string) error {
+	var current string
+	if err := db.Raw("SELECT confdeltype::text FROM pg_constraint WHERE conname = ? AND conrelid = 'comments'::regclass",
+		name).Scan(&current).Error; err != nil {
+		return err
+	}
+	if current == onDeleteRules[action] {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE comments DROP CONSTRAINT IF EXISTS " + name).Error; err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE comments ADD CONSTRAINT " + name +
+			" FOREIGN KEY (" + column + ") REFERENCES " + refTable + " (id) ON DELETE " + action).Error
+	})
+}
+
+// violatesForeignKey сообщает, что запись нарушила внешний ключ name: связанной записи нет.
+func violatesForeignKey(err error, name string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == name
+}
+
+// isDuplicateComment сообщает, что запись нарушила уникальный индекс uniqueCommentsIndex.
+func isDuplicateComment(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == uniqueCommentsIndex
 }
 
 // === Post Methods ===
 
 func (s *Store) CreatePost(ctx context.Context, post *domain.Post) (*domain.Post, error) {
+	post.ID = s.opts.IDs.NewID()
+	// Время округляется до точности Postgres, чтобы курсор возвращенного поста совпадал с сохраненным
+	post.CreatedAt = storage.CreationTime(ctx, post.CreatedAt, s.opts.Clock.Now(), time.Microsecond)
+	post.Draft = s.opts.DraftPosts
+	if post.DefaultCommentOrder == "" {
+		post.DefaultCommentOrder = domain.CommentOrderOldest
+	}
 	if err := s.db.WithContext(ctx).Create(post).Error; err != nil {
 		return nil, err
 	}
-	// GORM автоматически заполнит ID и CreatedAt после создания
 	return post, nil
 }
 
+func (s *Store) DeletePost(ctx context.Context, id string) error {
+	// Некорректный UUID уронил бы запрос, а такого поста заведомо нет
+	if _, err := uuid.Parse(id); err != nil {
+		return storage.ErrPostNotFound
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		postComments := tx.Model(&domain.Comment{}).Select("id").Where("post_id = ?", id)
+		if err := tx.Where("comment_id IN (?)", postComments).Delete(&domain.Reaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id IN (?)", postComments).Delete(&domain.Report{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", id).Delete(&domain.PostSubscription{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", id).Delete(&domain.PostBlock{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", id).Delete(&domain.Comment{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&domain.Post{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return storage.ErrPostNotFound
+		}
+		return nil
+	})
+}
+
 func (s *Store) GetPostByID(ctx context.Context, id string) (*domain.Post, error) {
+	// Некорректный UUID уронил бы запрос, а такого поста заведомо нет
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, storage.ErrPostNotFound
+	}
 	var post domain.Post
 	if err := s.db.WithContext(ctx).First(&post, "id = ?", id).Error; err != nil {
 		// GORM возвращает gorm.ErrRecordNotFound, если запись не найдена
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, storage.ErrPostNotFound
+		}
 		return nil, err
 	}
 	return &post, nil
 }
 
+func (s *Store) GetPostWithCommentCount(ctx context.Context, id string, includeHidden bool) (*domain.Post, int, error) {
+	// Счетчик - подзапрос по индексу comments.post_id в том же запросе, что и пост
+	count := applyHidden(s.db.Model(&domain.Comment{}).Select("COUNT(*)").Where("comments.post_id = posts.id"), includeHidden)
+	var row struct {
+		domain.Post
+		CommentCount int
+	}
+	res := s.db.WithContext(ctx).Model(&domain.Post{}).
+		Select("posts.*, (?) AS comment_count", count).
+		Where("posts.id = ?", id).
+		Limit(1).
+		Scan(&row)
+	if res.Error != nil {
+		return nil, 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, 0, storage.ErrPostNotFound
+	}
+	return &row.Post, row.CommentCount, nil
+}
+
 func (s *Store) GetCommentByID(ctx context.Context, id string) (*domain.Comment, error) {
+	// Некорректный UUID уронил бы запрос, а такого комментария заведомо нет
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, storage.ErrCommentNotFound
+	}
 	var comment domain.Comment
 	if err := s.db.WithContext(ctx).First(&comment, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, storage.ErrCommentNotFound
+		}
 		return nil, err
 	}
 	return &comment, nil
 }
 
-func (s *Store) GetPosts(ctx context.Context, limit, offset int) ([]*domain.Post, error) {
-	var posts []*domain.Post
-	err := s.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Offset(offset).Find(&posts).Error
-	return posts, err
+func (s *Store) GetCommentAncestors(ctx context.Context, id string) ([]*domain.Comment, error) {
+	if _, err := s.GetCommentByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	// Рекурсивно поднимаемся по parent_id от ближайшего родителя к корню,
+	// ограничивая глубину обхода MaxDepth
+	var ancestors []*domain.Comment
+	err := s.db.WithContext(ctx).Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT c.*, 1 AS depth
+			FROM comments c
+			WHERE c.id = (SELECT parent_id FROM comments WHERE id = ?)
+			UNION ALL
+			SELECT c.*, a.depth + 1
+			FROM comments c
+			JOIN ancestors a ON c.id = a.parent_id
+			WHERE a.depth < ?
+		)
+		SELECT * FROM ancestors ORDER BY depth`, id, s.opts.MaxDepth).
+		Scan(&ancestors).Error
+	if err != nil {
+		return nil, err
+	}
+	if ancestors == nil {
+		ancestors = []*domain.Comment{}
+	}
+	return ancestors, nil
 }
 
-func (s *Store) ToggleComments(ctx context.Context, postID string, enable bool) (*domain.Post, error) {
-	var post domain.Post
-	// Используем транзакцию для атомарности операции чтения-записи
-	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.First(&post, "id = ?", postID).Error; err != nil {
-			return err
+func (s *Store) GetCommentTree(ctx context.Context, postID string) ([]*domain.Comment, error) {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	// Спускаемся от корневых комментариев по parent_id. Путь из ключей (created_at, id)
+	// фиксированной ширины задает порядок обхода в глубину: родитель идет перед своими ответами.
+	var tree []*domain.Comment
+	err := s.db.WithContext(ctx).Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT c.*, ARRAY[to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text] AS path
+			FROM comments c
+			WHERE c.post_id = ? AND c.parent_id IS NULL
+			UNION ALL
+			SELECT c.*, t.path || (to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text)
+			FROM comments c
+			JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT * FROM tree ORDER BY path`, postID).
+		Scan(&tree).Error
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		tree = []*domain.Comment{}
+	}
+	return tree, nil
+}
+
+// treeFrame - продолжение обхода дерева на одном уровне: ответы parentID (при nil - корневые
+// комментарии поста) после after. Комментарии уровня загружаются при первом обращении,
+// ответы на них - одним запросом для всего уровня.
+type treeFrame struct {
+	parentID *string
+	after    *storage.Cursor
+	loaded   bool
+	comments []*domain.Comment
+	expanded bool
+	children map[string][]*domain.Comment
+}
+
+// GetCommentTreePage обходит дерево в глубину от курсора, а не строит его целиком: каждый
+// уровень читается диапазоном по индексу ответов с лимитом в оставшуюся часть страницы.
+// Поэтому стоимость страницы зависит от ее размера и глубины курсора, а не от размера обсуждения.
+func (s *Store) GetCommentTreePage(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	stack, err := s.treeFramesAfter(ctx, postID, args)
+	if err != nil {
+		return nil, err
+	}
+
+	page := []*domain.Comment{}
+	for len(stack) > 0 && len(page) < args.Limit {
+		top := stack[len(stack)-1]
+		// Уровень не может дать больше комментариев, чем осталось места на странице
+		if err := s.loadTreeFrame(ctx, postID, top, args.Limit-len(page), args.IncludeHidden); err != nil {
+			return nil, err
 		}
-		post.CommentsEnabled = enable
-		if err := tx.Save(&post).Error; err != nil {
-			return err
+		if len(top.comments) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
 		}
-		return nil
-	})
+		c := top.comments[0]
+		top.comments = top.comments[1:]
+		page = append(page, c)
+		if children := top.children[c.ID]; len(children) > 0 {
+			stack = append(stack, &treeFrame{parentID: &c.ID, loaded: true, comments: children})
+		}
+	}
+	return page, nil
+}
 
+// treeFramesAfter возвращает стек уровней, с которых продолжается обход после курсора:
+// снизу вверх - корневые комментарии после корня ветки курсора, ответы после каждого
+// его предка, ответы на сам курсор. Курсор находится, даже если его скрыли, но уровни
+// внутри невидимой ветки без IncludeHidden пропускаются: невидимость наследуется ответами.
+func (s *Store) treeFramesAfter(ctx context.Context, postID string, args storage.PaginationArgs) ([]*treeFrame, error) {
+	if args.Cursor == nil {
+		return []*treeFrame{{}}, nil
+	}
+	c, ok := storage.DecodeCursor(*args.Cursor)
+	if !ok {
+		return nil, storage.ErrInvalidCursor
+	}
+	cursor, err := s.GetCommentByID(ctx, c.ID)
+	if errors.Is(err, storage.ErrCommentNotFound) || (err == nil && cursor.PostID != postID) {
+		return nil, storage.ErrInvalidCursor
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &post, nil
+	ancestors, err := s.GetCommentAncestors(ctx, cursor.ID)
+	if err != nil {
+		return nil, err
+	}
+	chain := append(ancestors, cursor)
+	slices.Reverse(chain[:len(ancestors)])
+
+	var stack []*treeFrame
+	visible := true
+	for _, node := range chain {
+		if !visible && !args.IncludeHidden {
+			return stack, nil
+		}
+		after := storage.Cursor{CreatedAt: node.CreatedAt, ID: node.ID}
+		stack = append(stack, &treeFrame{parentID: node.ParentID, after: &after})
+		visible = visible && node.Visible()
+	}
+	if visible || args.IncludeHidden {
+		stack = append(stack, &treeFrame{parentID: &cursor.ID})
+	}
+	return stack, nil
 }
 
-// === Comment Methods ===
+// loadTreeFrame загружает не больше limit комментариев уровня и первые limit ответов на каждый.
+func (s *Store) loadTreeFrame(ctx context.Context, postID string, f *treeFrame, limit int, includeHidden bool) error {
+	if !f.loaded {
+		query := s.db.WithContext(ctx).Where("post_id = ?", postID)
+		if f.parentID == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", *f.parentID)
+		}
+		args := storage.PaginationArgs{Limit: limit, IncludeHidden: includeHidden}
+		if f.after != nil {
+			cursor := f.after.Encode()
+			args.Cursor = &cursor
+		}
+		comments, err := s.findCommentsPage(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		f.comments, f.loaded = comments, true
+	}
+	if !f.expanded && len(f.comments) > 0 {
+		ids := make([]string, len(f.comments))
+		for i, c := range f.comments {
+			ids[i] = c.ID
+		}
+		children, err := s.GetCommentsByParentIDs(ctx, ids, limit, includeHidden)
+		if err != nil {
+			return err
+		}
+		f.children, f.expanded = children, true
+	}
+	return nil
+}
 
-func (s *Store) CreateComment(ctx context.Context, comment *domain.Comment) (*domain.Comment, error) {
-	// Валидация
-	if len(comment.Content) > 2000 {
-		return nil, errors.New("comment content is too long")
+func (s *Store) GetPosts(ctx context.Context, limit, offset int, filter storage.PostFilter) ([]*domain.Post, error) {
+	var posts []*domain.Post
+	err := s.postsQuery(ctx, filter).Limit(limit).Offset(offset).Find(&posts).Error
+	return posts, err
+}
+
+func (s *Store) GetPostsAfter(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) ([]*domain.Post, error) {
+	query := s.postsQuery(ctx, filter).Limit(limit)
+	if cursor != nil {
+		c, ok := storage.DecodeCursor(*cursor)
+		if !ok {
+			return nil, storage.ErrInvalidCursor
+		}
+		// Ключ сортировки берем из курсора, без запроса поста-курсора
+		query = query.Where("("+postSortKey(filter.Order)+", id) < (?, ?)", c.CreatedAt, c.ID)
+	}
+
+	var posts []*domain.Post
+	err := query.Find(&posts).Error
+	return posts, err
+}
+
+// postsQuery применяет фильтр и порядок сортировки постов (ключ DESC, id DESC).
+func (s *Store) postsQuery(ctx context.Context, filter storage.PostFilter) *gorm.DB {
+	query := s.db.WithContext(ctx).Order(postSortKey(filter.Order) + " DESC, id DESC")
+	if filter.CommentsEnabled != nil {
+		query = query.Where("comments_enabled = ?", *filter.CommentsEnabled)
 	}
-	if strings.TrimSpace(comment.Content) == "" {
-		return nil, errors.New("comment content cannot be empty")
+	return applyDrafts(query, filter)
+}
+
+// applyDrafts оставляет в выборке постов только черновики, которые допускает фильтр.
+// Условия совпадают с storage.PostFilter.Includes.
+func applyDrafts(query *gorm.DB, filter storage.PostFilter) *gorm.DB {
+	if filter.IncludeDrafts {
+		return query
+	}
+	if filter.DraftsAuthorID != "" {
+		return query.Where("(NOT draft OR author_id = ?)", filter.DraftsAuthorID)
+	}
+	return query.Where("NOT draft")
+}
+
+// postSortKey - SQL-выражение ключа сортировки, совпадает с storage.PostSortKey.
+func postSortKey(order storage.PostOrder) string {
+	if order == storage.PostOrderLastActivityDesc {
+		return "COALESCE(last_comment_at, created_at)"
+	}
+	return "created_at"
+}
+
+func (s *Store) MergePosts(ctx context.Context, sourceID, targetID string) (*domain.Post, error) {
+	if sourceID == targetID {
+		return nil, storage.ErrMergeSamePost
+	}
+	// Некорректный UUID уронил бы запрос, а такого поста заведомо нет
+	for _, id := range []string{sourceID, targetID} {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("%w: %s", storage.ErrPostNotFound, id)
+		}
 	}
 
-	// Проверяем существование поста и разрешение на комментирование в одной транзакции
+	var target domain.Post
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var post domain.Post
-		if err := tx.Select("comments_enabled").First(&post, "id = ?", comment.PostID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("post not found")
+		// Оба поста блокируются, как в UpdatePost: новые комментарии к исходному посту ждут
+		// конца переноса, а после него не находят пост. Порядок по id исключает взаимную
+		// блокировку двух встречных слияний.
+		var posts []domain.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []string{sourceID, targetID}).Order("id").Find(&posts).Error; err != nil {
+			return err
+		}
+		var source *domain.Post
+		for i := range posts {
+			switch posts[i].ID {
+			case sourceID:
+				source = &posts[i]
+			case targetID:
+				target = posts[i]
 			}
+		}
+		if source == nil {
+			return fmt.Errorf("%w: %s", storage.ErrPostNotFound, sourceID)
+		}
+		if target.ID == "" {
+			return fmt.Errorf("%w: %s", storage.ErrPostNotFound, targetID)
+		}
+
+		if err := tx.Model(&domain.Comment{}).Where("post_id = ?", sourceID).Update("post_id", targetID).Error; err != nil {
 			return err
 		}
-		if !post.CommentsEnabled {
-			return errors.New("comments are disabled for this post")
+		// Подписка на оба поста остается одна - на целевой
+		followsTarget := tx.Model(&domain.PostSubscription{}).Select("user_id").Where("post_id = ?", targetID)
+		if err := tx.Where("post_id = ? AND user_id IN (?)", sourceID, followsTarget).Delete(&domain.PostSubscription{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.PostSubscription{}).Where("post_id = ?", sourceID).Update("post_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", sourceID).Delete(&domain.PostBlock{}).Error; err != nil {
+			return err
 		}
 
-		// Если есть родитель, проверяем его существование
-		if comment.ParentID != nil {
-			var parentCommentCount int64
-			if err := tx.Model(&domain.Comment{}).Where("id = ?", *comment.ParentID).Count(&parentCommentCount).Error; err != nil {
+		if source.LastCommentAt != nil && (target.LastCommentAt == nil || source.LastCommentAt.After(*target.LastCommentAt)) {
+			target.LastCommentAt = source.LastCommentAt
+			if err := tx.Model(&target).Update("last_comment_at", target.LastCommentAt).Error; err != nil {
 				return err
 			}
-			if parentCommentCount == 0 {
-				return errors.New("parent comment not found")
-			}
 		}
+		return tx.Where("id = ?", sourceID).Delete(&domain.Post{}).Error
+	})
 
-		// Создаем комментарий
-		if err := tx.Create(comment).Error; err != nil {
+	if isDuplicateComment(err) {
+		return nil, storage.ErrDuplicateComment
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+func (s *Store) UpdatePost(ctx context.Context, postID string, update storage.PostUpdate) (*domain.Post, error) {
+	var post domain.Post
+	// Используем транзакцию для атомарности операции чтения-записи.
+	// Строка блокируется, чтобы параллельные изменения разных полей не затирали друг друга,
+	// а выключение комментариев дожидалось уже начатых CreateComment (они держат ту же блокировку).
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, "id = ?", postID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", storage.ErrPostNotFound, postID)
+			}
 			return err
 		}
-		return nil
+
+		changes := make(map[string]interface{}, 5)
+		if update.Title != nil {
+			post.Title = *update.Title
+			changes["title"] = post.Title
+		}
+		if update.Content != nil {
+			post.Content = *update.Content
+			changes["content"] = post.Content
+		}
+		if update.CommentsEnabled != nil {
+			post.CommentsEnabled = *update.CommentsEnabled
+			changes["comments_enabled"] = post.CommentsEnabled
+		}
+		if update.Draft != nil {
+			post.Draft = *update.Draft
+			changes["draft"] = post.Draft
+		}
+		if update.DefaultCommentOrder != nil {
+			post.DefaultCommentOrder = *update.DefaultCommentOrder
+			changes["default_comment_order"] = post.DefaultCommentOrder
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Model(&post).Updates(changes).Error
 	})
 
 	if err != nil {
 		return nil, err
 	}
+	return &post, nil
+}
 
-	return comment, nil
+func (s *Store) ToggleCommentsForAuthor(ctx context.Context, authorID string, enable bool) (int, error) {
+	// Один UPDATE вместо чтения и сохранения каждого поста
+	res := s.db.WithContext(ctx).
+		Model(&domain.Post{}).
+		Where("author_id = ?", authorID).
+		Update("comments_enabled", enable)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	return int(res.RowsAffected), nil
 }
 
-// === Pagination Methods ===
+func (s *Store) ToggleCommentsBulk(ctx context.Context, postIDs []string, enable bool) ([]*domain.Post, error) {
+	// Некорректный UUID в IN (...) уронил бы весь запрос, а такого поста заведомо нет
+	ids := make([]string, 0, len(postIDs))
+	for _, id := range postIDs {
+		if _, err := uuid.Parse(id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return []*domain.Post{}, nil
+	}
 
-func (s *Store) GetCommentsByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
-	var comments []*domain.Comment
-	// Выбираем только комментарии верхнего уровня для поста (parent_id IS NULL)
-	query := s.db.WithContext(ctx).
-		Where("post_id = ? AND parent_id IS NULL", postID).
-		Order("created_at ASC").
-		Limit(args.Limit)
+	// Один UPDATE ... RETURNING вместо чтения и сохранения каждого поста
+	var posts []*domain.Post
+	err := s.db.WithContext(ctx).
+		Model(&posts).
+		Clauses(clause.Returning{}).
+		Where("id IN ?", ids).
+		Update("comments_enabled", enable).Error
+	if err != nil {
+		return nil, err
+	}
 
-	// Реализация курсорной пагинации
-	if args.Cursor != nil {
-		var cursorComment domain.Comment
-		// Находим время создания комментария-курсора
-		if err := s.db.First(&cursorComment, "id = ?", *args.Cursor).Error; err == nil {
-			// И выбираем все записи, созданные ПОСЛЕ него
-			query = query.Where("created_at > ?", cursorComment.CreatedAt)
+	// Возвращаем посты в порядке запроса
+	byID := make(map[string]*domain.Post, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+	}
+	updated := make([]*domain.Post, 0, len(posts))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			updated = append(updated, p)
+			delete(byID, id) // повторный ID не дублирует пост в ответе
 		}
 	}
-
-	err := query.Find(&comments).Error
-	return comments, err
+	return updated, nil
 }
 
-func (s *Store) GetCommentsByParentID(ctx context.Context, parentID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
-	var comments []*domain.Comment
-	// Аналогично, но для дочерних комментариев
-	query := s.db.WithContext(ctx).
-		Where("parent_id = ?", parentID).
-		Order("created_at ASC").
-		Limit(args.Limit)
-
-	if args.Cursor != nil {
-		var cursorComment domain.Comment
-		if err := s.db.First(&cursorComment, "id = ?", *args.Cursor).Error; err == nil {
-			query = query.Where("created_at > ?", cursorComment.CreatedAt)
+func (s *Store) ImportThread(ctx context.Context, post *domain.Post, comments []*domain.Comment) error {
+	for _, c := range comments {
+		content, err := s.opts.PrepareCommentContent(c.Content)
+		if err != nil {
+			return err
+		}
+		c.Content = content
+		if err := storage.ValidateAttachments(c.Attachments, s.opts.MaxAttachments); err != nil {
+			return err
+		}
+		if c.Status == "" {
+			c.Status = domain.CommentApproved
 		}
 	}
 
-	err := query.Find(&comments).Error
-	return comments, err
+	// Пост и все комментарии сохраняются целиком или не сохраняются вовсе
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		if len(comments) == 0 {
+			return nil
+		}
+		// post.LastCommentAt заполняется при подготовке импорта
+		// Родители идут раньше ответов, поэтому вставка батчами не нарушает ссылки
+		return tx.CreateInBatches(comments, 500).Error
+	})
 }
 
-// === Dataloader Method ===
-
-func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string) (map[string][]*domain.Comment, error) {
-	var comments []*domain.Comment
-	// Загружаем все дочерние комментарии для всех переданных parentID одним запросом
-	err := s.db.WithContext(ctx).
-		Where("parent_id IN ?", parentIDs).
-		Order("parent_id, created_at ASC"). // Сортируем для правильной группировки и порядка
-		Find(&comments).Error
+// === Comment Methods ===
 
+func (s *Store) CreateComment(ctx context.Context, comment *domain.Comment) (*domain.Comment, error) {
+	// Нормализация и валидация
+	content, err := s.opts.PrepareCommentContent(comment.Content)
 	if err != nil {
 		return nil, err
 	}
+	comment.Content = content
+	if err := storage.ValidateAttachments(comment.Attachments, s.opts.MaxAttachments); err != nil {
+		return nil, err
+	}
+	comment.Status = s.opts.InitialStatus()
 
-	// Группируем результаты в карту map[parentID][]*Comment
-	result := make(map[string][]*domain.Comment, len(parentIDs))
-	for _, c := range comments {
-		if c.ParentID != nil {
-			result[*c.ParentID] = append(result[*c.ParentID], c)
+	// Проверяем существование поста и разрешение на комментирование в одной транзакции.
+	// Строка поста блокируется FOR UPDATE до конца транзакции - так же, как в UpdatePost:
+	// иначе при READ COMMITTED выключение комментариев между проверкой и вставкой не было бы
+	// замечено. Блокировка также упорядочивает создание комментариев в посте, что делает
+	// надежной проверку дубликатов. Цена - комментарии в один пост создаются по очереди
+	// (inmemory-хранилище и так сериализует все записи общей блокировкой).
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var post domain.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("comments_enabled", "last_comment_at").First(&post, "id = ?", comment.PostID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return storage.ErrPostNotFound
+			}
+			return err
+		}
+		if !post.CommentsEnabled {
+			return storage.ErrCommentsDisabled
+		}
+		blocked, err := isAuthorBlocked(tx, comment.PostID, comment.AuthorID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return storage.ErrAuthorBlocked
 		}
-	}
 
-	return result, nil
+		// Если есть родитель, проверяем его существование и пост
+		if comment.ParentID != nil {
+			var parent domain.Comment
+			if err := tx.Select("post_id").Take(&parent, "id = ?", *comment.ParentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return storage.ErrParentNotFound
+				}
+				return err
+			}
+			if err := storage.ValidateParentPost(parent.PostID, comment.PostID); err != nil {
+				return err
+			}
+			locked, err := s.threadLocked(tx, *comment.ParentID)
+			if err != nil {
+				return err
+			}
+			if locked {
+				return storage.ErrThreadLocked
+			}
+		}
+
+		// Антиспам: тот же текст от того же автора в том же посте в пределах окна
+		if s.opts.DuplicateWindow > 0 {
+			var last domain.Comment
+			err := tx.Select("content", "created_at").
+				Where("post_id = ? AND author_id = ?", comment.PostID, comment.AuthorID).
+				Order("created_at DESC").
+				Take(&last).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil && last.Content == comment.Content && s.opts.Clock.Now().Sub(last.CreatedAt) < s.opts.DuplicateWindow {
+				return storage.ErrDuplicateComment
+			}
+		}
+
+		// Создаем комментарий. Время задается под блокировкой поста и строго позже последнего
+		// комментария, поэтому новый комментарий встает в конец списка. Время округляется до
+		// точности Postgres (микросекунды): иначе возвращенный комментарий (например,
+		// в кеше hybrid-хранилища) давал бы курсор, не совпадающий с сохраненной строкой.
+		comment.ID = s.opts.IDs.NewID()
+		comment.CreatedAt = storage.CommentCreationTime(ctx, comment, s.opts.Clock.Now(), post.LastCommentAt, time.Microsecond)
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		// Отмечаем активность поста в той же транзакции. GREATEST нужен для сохраненного
+		// времени из PreserveCreatedAt, которое может быть раньше последнего комментария
+		return tx.Model(&domain.Post{}).
+			Where("id = ?", comment.PostID).
+			Update("last_comment_at", gorm.Expr("GREATEST(last_comment_at, ?)", comment.CreatedAt)).Error
+	})
+
+	if isDuplicateComment(err) {
+		return nil, storage.ErrDuplicateComment
+	}
+	// Строка поста заблокирована, так что его не удалить, пока идет транзакция;
+	// ошибку ключа на всякий случай тоже переводим в понятную клиенту
+	if violatesForeignKey(err, postForeignKey) {
+		return nil, storage.ErrPostNotFound
+	}
+	// Родитель не блокируется и может быть удален между проверкой и вставкой
+	if violatesForeignKey(err, parentForeignKey) {
+		return nil, storage.ErrParentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (s *Store) UpdateComment(ctx context.Context, id string, content string) (*domain.Comment, error) {
+	content, err := s.opts.PrepareCommentContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var comment domain.Comment
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&comment, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return storage.ErrCommentNotFound
+			}
+			return err
+		}
+		comment.Content = content
+		return tx.Model(&comment).Update("content", content).Error
+	})
+	if isDuplicateComment(err) {
+		return nil, storage.ErrDuplicateComment
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (s *Store) ReportComment(ctx context.Context, commentID, reporterID, reason string) (*domain.Comment, error) {
+	var comment domain.Comment
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Блокируем строку комментария, чтобы параллельные жалобы считались последовательно
+		// и порог не проскакивался между подсчетом и обновлением
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comment, "id = ?", commentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+			}
+			return err
+		}
+
+		// Повторная жалоба того же пользователя только обновляет причину
+		report := &domain.Report{CommentID: commentID, ReporterID: reporterID, Reason: reason, CreatedAt: s.opts.Clock.Now().UTC()}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "comment_id"}, {Name: "reporter_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason", "created_at"}),
+		}).Create(report).Error; err != nil {
+			return err
+		}
+
+		var reports int64
+		if err := tx.Model(&domain.Report{}).Where("comment_id = ?", commentID).Count(&reports).Error; err != nil {
+			return err
+		}
+		if comment.Hidden || !s.opts.ShouldHide(int(reports)) {
+			return nil
+		}
+		comment.Hidden = true
+		return tx.Model(&comment).Update("hidden", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (s *Store) SetCommentHidden(ctx context.Context, commentID string, hidden bool) (*domain.Comment, error) {
+	var comment domain.Comment
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comment, "id = ?", commentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+			}
+			return err
+		}
+		if !hidden {
+			// Модератор рассмотрел жалобы - начинаем подсчет заново
+			if err := tx.Where("comment_id = ?", commentID).Delete(&domain.Report{}).Error; err != nil {
+				return err
+			}
+		}
+		comment.Hidden = hidden
+		return tx.Model(&comment).Update("hidden", hidden).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// threadLocked сообщает, закрыт ли для ответов комментарий id или любой из его предков.
+// Сохраненных глубины или пути у комментария нет, поэтому цепочка обходится рекурсивно,
+// как в GetCommentAncestors, с тем же ограничением MaxDepth.
+func (s *Store) threadLocked(tx *gorm.DB, id string) (bool, error) {
+	var locked bool
+	err := tx.Raw(`
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id, locked, 1 AS depth
+			FROM comments
+			WHERE id = ?
+			UNION ALL
+			SELECT c.id, c.parent_id, c.locked, ch.depth + 1
+			FROM comments c
+			JOIN chain ch ON c.id = ch.parent_id
+			WHERE ch.depth < ? AND NOT ch.locked
+		)
+		SELECT EXISTS (SELECT 1 FROM chain WHERE locked)`, id, s.opts.MaxDepth).
+		Scan(&locked).Error
+	return locked, err
+}
+
+func (s *Store) SetCommentLocked(ctx context.Context, commentID string, locked bool) (*domain.Comment, error) {
+	var comment domain.Comment
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&comment, "id = ?", commentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+			}
+			return err
+		}
+		// CreateComment проверяет закрытые ветки под блокировкой поста, поэтому ответ,
+		// создаваемый одновременно с закрытием ветки, либо успеет раньше, либо будет отклонен
+		if err := tx.Exec("SELECT 1 FROM posts WHERE id = ? FOR UPDATE", comment.PostID).Error; err != nil {
+			return err
+		}
+		comment.Locked = locked
+		return tx.Model(&comment).Update("locked", locked).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (s *Store) SetCommentStatus(ctx context.Context, commentID string, status domain.CommentStatus) (*domain.Comment, error) {
+	var comment domain.Comment
+	// RETURNING возвращает комментарий целиком одним запросом
+	res := s.db.WithContext(ctx).Model(&comment).
+		Clauses(clause.Returning{}).
+		Where("id = ?", commentID).
+		Update("status", status)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: %s", storage.ErrCommentNotFound, commentID)
+	}
+	return &comment, nil
+}
+
+// === Pagination Methods ===
+
+func (s *Store) GetCommentsByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	if args.Order == domain.CommentOrderTop {
+		return s.topCommentsPage(ctx, postID, args)
+	}
+	// Выбираем только комментарии верхнего уровня для поста (parent_id IS NULL)
+	query := s.db.WithContext(ctx).Where("post_id = ? AND parent_id IS NULL", postID)
+	return s.findCommentsPage(ctx, query, args)
+}
+
+func (s *Store) GetCommentsPageByPostID(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, int, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, 0, err
+	}
+
+	// Общее число - скалярный подзапрос в запросе страницы. Оконная функция count(*) OVER ()
+	// не подходит: она считает строки после условия на курсор, то есть только оставшиеся.
+	// Подзапрос читает индекс по post_id, а страница по-прежнему выбирается диапазоном
+	// postListingIndex, без чтения всех комментариев поста.
+	topLevel := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&domain.Comment{}).Where("post_id = ? AND parent_id IS NULL", postID)
+	}
+	if args.Order == domain.CommentOrderTop {
+		// Запрос страницы TOP уже группирует комментарии, число считается отдельно
+		comments, err := s.topCommentsPage(ctx, postID, args)
+		if err != nil {
+			return nil, 0, err
+		}
+		var n int64
+		if err := applyHidden(topLevel(), args.IncludeHidden).Count(&n).Error; err != nil {
+			return nil, 0, err
+		}
+		return comments, int(n), nil
+	}
+	total := applyHidden(topLevel().Select("COUNT(*)"), args.IncludeHidden)
+	var rows []struct {
+		domain.Comment
+		Total int
+	}
+	query := s.pageQuery(ctx, topLevel().Select("comments.*, (?) AS total", total), args)
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	comments := make([]*domain.Comment, len(rows))
+	count := 0
+	for i := range rows {
+		comments[i] = &rows[i].Comment
+		count = rows[i].Total
+	}
+	if args.Backward {
+		slices.Reverse(comments)
+	}
+	// Пустой странице (например, курсор в конце списка) негде вернуть число - считаем отдельно
+	if len(rows) == 0 {
+		var n int64
+		if err := applyHidden(topLevel(), args.IncludeHidden).Count(&n).Error; err != nil {
+			return nil, 0, err
+		}
+		count = int(n)
+	}
+	return comments, count, nil
+}
+
+func (s *Store) GetCommentsByParentID(ctx context.Context, parentID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	// Аналогично, но для дочерних комментариев
+	query := s.db.WithContext(ctx).Where("parent_id = ?", parentID)
+	return s.findCommentsPage(ctx, query, args)
+}
+
+func (s *Store) GetPendingComments(ctx context.Context, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Where("status = ?", domain.CommentPending)
+	args.IncludeHidden = true
+	return s.findCommentsPage(ctx, query, args)
+}
+
+func (s *Store) GetUnansweredComments(ctx context.Context, postID, authorID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	// Подзапрос проверяет ответы каждого комментария страницы по childrenListingIndex,
+	// а сама страница выбирается диапазоном postListingIndex, как в GetCommentsByPostID
+	query := s.db.WithContext(ctx).
+		Where("post_id = ? AND parent_id IS NULL AND author_id <> ?", postID, authorID).
+		Where("NOT EXISTS (SELECT 1 FROM comments replies WHERE replies.parent_id = comments.id AND replies.author_id = ?)", authorID)
+	return s.findCommentsPage(ctx, query, args)
+}
+
+func (s *Store) GetRecentCommentsByPostIDs(ctx context.Context, postIDs []string, limit int) ([]*domain.Comment, error) {
+	comments := []*domain.Comment{}
+	// Некорректный UUID в IN (...) уронил бы весь запрос, а такого поста заведомо нет
+	ids := make([]string, 0, len(postIDs))
+	for _, id := range postIDs {
+		if _, err := uuid.Parse(id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || limit <= 0 {
+		return comments, nil
+	}
+	err := applyHidden(s.db.WithContext(ctx).Where("post_id IN ?", ids), false).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&comments).Error
+	return comments, err
+}
+
+func (s *Store) GetCommentsSince(ctx context.Context, postID string, after storage.Cursor, limit int, includeHidden bool) ([]*domain.Comment, error) {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	comments := []*domain.Comment{}
+	if limit <= 0 {
+		return comments, nil
+	}
+	query := s.db.WithContext(ctx).Where("post_id = ?", postID)
+	if after.ID == "" {
+		query = query.Where("created_at > ?", after.CreatedAt)
+	} else {
+		query = query.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+	}
+	err := applyHidden(query, includeHidden).
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&comments).Error
+	return comments, err
+}
+
+func (s *Store) GetAuthorActivity(ctx context.Context, authorID string, args storage.PaginationArgs, filter storage.PostFilter) ([]storage.ActivityItem, error) {
+	if err := args.Normalize(s.opts.MaxPageSize); err != nil {
+		return nil, err
+	}
+
+	posts := applyDrafts(s.db.WithContext(ctx).Model(&domain.Post{}), filter).
+		Select("id, created_at, TRUE AS is_post").Where("author_id = ?", authorID)
+	comments := applyHidden(s.db.WithContext(ctx).Model(&domain.Comment{}), args.IncludeHidden).
+		Select("id, created_at, FALSE AS is_post").Where("author_id = ?", authorID)
+	if args.Cursor != nil {
+		c, ok := storage.DecodeCursor(*args.Cursor)
+		if !ok {
+			return nil, storage.ErrInvalidCursor
+		}
+		posts = posts.Where("(created_at, id) < (?, ?)", c.CreatedAt, c.ID)
+		comments = comments.Where("(created_at, id) < (?, ?)", c.CreatedAt, c.ID)
+	}
+
+	// Сначала выбираем ключи страницы из обеих таблиц, затем загружаем сами записи
+	var keys []struct {
+		ID     string
+		IsPost bool
+	}
+	err := s.db.WithContext(ctx).Raw("(?) UNION ALL (?) ORDER BY created_at DESC, id DESC LIMIT ?", posts, comments, args.Limit).
+		Scan(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var postIDs, commentIDs []string
+	for _, k := range keys {
+		if k.IsPost {
+			postIDs = append(postIDs, k.ID)
+		} else {
+			commentIDs = append(commentIDs, k.ID)
+		}
+	}
+	postsByID, err := s.GetPostsByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	commentsByID, err := s.GetCommentsByIDs(ctx, commentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]storage.ActivityItem, 0, len(keys))
+	for _, k := range keys {
+		// Запись, удаленная между запросами, пропускается
+		if p, ok := postsByID[k.ID]; ok && k.IsPost {
+			items = append(items, storage.ActivityItem{Post: p})
+		} else if c, ok := commentsByID[k.ID]; ok && !k.IsPost {
+			items = append(items, storage.ActivityItem{Comment: c})
+		}
+	}
+	return items, nil
+}
+
+// topCommentsPage выбирает страницу комментариев верхнего уровня поста в порядке
+// domain.CommentOrderTop. Рейтинг считается агрегатом по reactions в каждом запросе:
+// отдельного счетчика в comments нет, и реакции не нужно синхронизировать с ним.
+// Условие на курсор сравнивает кортеж (-рейтинг, created_at, id), чтобы одно сравнение
+// строк задавало порядок с рейтингом по убыванию и временем по возрастанию.
+func (s *Store) topCommentsPage(ctx context.Context, postID string, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	// Веса совпадают с domain.ReactionType.Weight
+	scored := s.db.WithContext(ctx).Model(&domain.Comment{}).
+		Select("comments.*, COALESCE(SUM(CASE reactions.type WHEN ? THEN 1 WHEN ? THEN -1 ELSE 0 END), 0) AS reaction_score",
+			domain.ReactionLike, domain.ReactionDislike).
+		Joins("LEFT JOIN reactions ON reactions.comment_id = comments.id").
+		Where("comments.post_id = ? AND comments.parent_id IS NULL", postID).
+		Group("comments.id")
+	scored = applyHidden(scored, args.IncludeHidden)
+
+	order, cmp := "reaction_score DESC, created_at ASC, id ASC", ">"
+	if args.Backward {
+		order, cmp = "reaction_score ASC, created_at DESC, id DESC", "<"
+	}
+	query := s.db.WithContext(ctx).Table("(?) AS comments", scored).Order(order).Limit(args.Limit)
+	if args.Cursor != nil {
+		c, err := storage.DecodeTopCursor(*args.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(-reaction_score, created_at, id) "+cmp+" (?, ?, ?)", -*c.Score, c.CreatedAt, c.ID)
+	}
+
+	var rows []struct {
+		domain.Comment
+		ReactionScore int
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	comments := make([]*domain.Comment, len(rows))
+	for i := range rows {
+		rows[i].Comment.Score = &rows[i].ReactionScore
+		comments[i] = &rows[i].Comment
+	}
+	if args.Backward {
+		slices.Reverse(comments)
+	}
+	return comments, nil
+}
+
+// findCommentsPage выбирает страницу комментариев по курсору в порядке args.Order.
+// Страница назад выбирается в обратном порядке и разворачивается.
+func (s *Store) findCommentsPage(ctx context.Context, query *gorm.DB, args storage.PaginationArgs) ([]*domain.Comment, error) {
+	var comments []*domain.Comment
+	if err := s.pageQuery(ctx, query, args).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	if args.Backward {
+		slices.Reverse(comments)
+	}
+	return comments, nil
+}
+
+// pageQuery дополняет выборку порядком, курсором, лимитом и условием на видимость страницы.
+// При args.Backward записи идут в обратном для args.Order порядке, и вызывающий должен их развернуть.
+func (s *Store) pageQuery(ctx context.Context, query *gorm.DB, args storage.PaginationArgs) *gorm.DB {
+	order := "created_at ASC, id ASC"
+	if args.Descending() {
+		order = "created_at DESC, id DESC"
+	}
+	query = s.applyCursor(ctx, query.Order(order).Limit(args.Limit), args.Cursor, args.Descending())
+	return applyHidden(query, args.IncludeHidden)
+}
+
+// applyHidden оставляет только видимые всем комментарии - одобренные и не скрытые
+// по жалобам, - если скрытые не запросил модератор.
+func applyHidden(query *gorm.DB, includeHidden bool) *gorm.DB {
+	if includeHidden {
+		return query
+	}
+	return query.Where("hidden = ? AND status = ?", false, domain.CommentApproved)
+}
+
+// applyCursor ограничивает выборку записями строго после курсора в порядке (created_at, id),
+// а при descending - строго перед ним. Курсор сам содержит ключ сортировки, поэтому
+// дополнительный запрос нужен только для курсоров старого формата (ID комментария).
+func (s *Store) applyCursor(ctx context.Context, query *gorm.DB, raw *string, descending bool) *gorm.DB {
+	if raw == nil {
+		return query
+	}
+	cursor, ok := storage.DecodeCursor(*raw)
+	if !ok {
+		var cursorComment domain.Comment
+		if err := s.db.WithContext(ctx).Select("id", "created_at").First(&cursorComment, "id = ?", *raw).Error; err != nil {
+			return query
+		}
+		cursor = storage.Cursor{CreatedAt: cursorComment.CreatedAt, ID: cursorComment.ID}
+	}
+	// Сравнение кортежей корректно продолжает выборку и при одинаковом времени создания
+	if descending {
+		return query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	return query.Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.ID)
+}
+
+// === Dataloader Method ===
+
+func (s *Store) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, perParent int, includeHidden bool) (map[string][]*domain.Comment, error) {
+	var comments []*domain.Comment
+	// Загружаем дочерние комментарии для всех переданных parentID одним запросом
+	query := applyHidden(s.db.WithContext(ctx).Model(&domain.Comment{}).Where("parent_id IN ?", parentIDs), includeHidden)
+	if perParent > 0 {
+		// Нумеруем ответы внутри каждого родителя и отбрасываем все после perParent
+		ranked := query.Select("comments.*, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at ASC, id ASC) AS rn")
+		query = s.db.WithContext(ctx).Table("(?) AS comments", ranked).Where("rn <= ?", perParent)
+	}
+	err := query.
+		Order("parent_id, created_at ASC, id ASC"). // Тот же порядок, что у курсорной пагинации children
+		Find(&comments).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Группируем результаты в карту map[parentID][]*Comment
+	result := make(map[string][]*domain.Comment, len(parentIDs))
+	for _, c := range comments {
+		if c.ParentID != nil {
+			result[*c.ParentID] = append(result[*c.ParentID], c)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Store) BlockAuthor(ctx context.Context, postID, authorID string) error {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return err
+	}
+	block := &domain.PostBlock{PostID: postID, AuthorID: authorID, CreatedAt: s.opts.Clock.Now().UTC()}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(block).Error
+}
+
+func (s *Store) UnblockAuthor(ctx context.Context, postID, authorID string) error {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Where("post_id = ? AND author_id = ?", postID, authorID).Delete(&domain.PostBlock{}).Error
+}
+
+func (s *Store) IsAuthorBlocked(ctx context.Context, postID, authorID string) (bool, error) {
+	return isAuthorBlocked(s.db.WithContext(ctx), postID, authorID)
+}
+
+func isAuthorBlocked(db *gorm.DB, postID, authorID string) (bool, error) {
+	var blocked bool
+	err := db.Raw("SELECT EXISTS (SELECT 1 FROM post_blocks WHERE post_id = ? AND author_id = ?)", postID, authorID).
+		Scan(&blocked).Error
+	return blocked, err
+}
+
+func (s *Store) FollowPost(ctx context.Context, postID, userID string, channel domain.NotificationChannel) (*domain.PostSubscription, error) {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	sub := &domain.PostSubscription{PostID: postID, UserID: userID, Channel: channel, CreatedAt: s.opts.Clock.Now().UTC()}
+	// Повторная подписка меняет только канал, время оформления остается прежним
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "post_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"channel"}),
+	}, clause.Returning{}).Create(sub).Error
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *Store) UnfollowPost(ctx context.Context, postID, userID string) (bool, error) {
+	res := s.db.WithContext(ctx).Where("post_id = ? AND user_id = ?", postID, userID).Delete(&domain.PostSubscription{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (s *Store) GetFollowers(ctx context.Context, postID string) ([]*domain.PostSubscription, error) {
+	var subs []*domain.PostSubscription
+	err := s.db.WithContext(ctx).
+		Where("post_id = ?", postID).
+		Order("created_at ASC, user_id ASC").
+		Find(&subs).Error
+	return subs, err
+}
+
+func (s *Store) AddReaction(ctx context.Context, commentID, userID string, reactionType domain.ReactionType) error {
+	if _, err := s.GetCommentByID(ctx, commentID); err != nil {
+		return err
+	}
+
+	// Повторная реакция того же пользователя заменяет предыдущую
+	reaction := &domain.Reaction{CommentID: commentID, UserID: userID, Type: reactionType, CreatedAt: s.opts.Clock.Now().UTC()}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "comment_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"type", "created_at"}),
+	}).Create(reaction).Error
+}
+
+func (s *Store) GetReactionCountsByCommentIDs(ctx context.Context, ids []string) (map[string]map[domain.ReactionType]int, error) {
+	var rows []struct {
+		CommentID string
+		Type      domain.ReactionType
+		Count     int
+	}
+	// Все счетчики одним запросом с группировкой
+	err := s.db.WithContext(ctx).
+		Model(&domain.Reaction{}).
+		Select("comment_id, type, COUNT(*) AS count").
+		Where("comment_id IN ?", ids).
+		Group("comment_id, type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[domain.ReactionType]int, len(ids))
+	for _, row := range rows {
+		if result[row.CommentID] == nil {
+			result[row.CommentID] = make(map[domain.ReactionType]int)
+		}
+		result[row.CommentID][row.Type] = row.Count
+	}
+	return result, nil
+}
+
+func (s *Store) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*domain.Post, error) {
+	var posts []*domain.Post
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*domain.Post, len(posts))
+	for _, p := range posts {
+		result[p.ID] = p
+	}
+	return result, nil
+}
+
+func (s *Store) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*domain.Comment, error) {
+	var comments []*domain.Comment
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*domain.Comment, len(comments))
+	for _, c := range comments {
+		result[c.ID] = c
+	}
+	return result, nil
+}
+
+func (s *Store) CountCommentsByPostIDs(ctx context.Context, postIDs []string, includeHidden bool) (map[string]int, error) {
+	var rows []struct {
+		PostID string
+		Count  int
+	}
+	err := applyHidden(s.db.WithContext(ctx).Model(&domain.Comment{}), includeHidden).
+		Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).
+		Group("post_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
+func (s *Store) GetDepthHistogram(ctx context.Context, postID string, includeHidden bool) (map[int]int, error) {
+	// Глубина не хранится, поэтому спускаемся от корневых комментариев по parent_id.
+	// Скрытые комментарии проходятся, чтобы учесть их видимые ответы, как в CountCommentsByPostIDs
+	var rows []struct {
+		Depth int
+		Count int
+	}
+	err := s.db.WithContext(ctx).Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT c.id, c.hidden, c.status, 0 AS depth
+			FROM comments c
+			WHERE c.post_id = @post AND c.parent_id IS NULL
+			UNION ALL
+			SELECT c.id, c.hidden, c.status, t.depth + 1
+			FROM comments c
+			JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT depth, COUNT(*) AS count FROM tree
+		WHERE @includeHidden OR (NOT hidden AND status = @approved)
+		GROUP BY depth`,
+		map[string]interface{}{"post": postID, "includeHidden": includeHidden, "approved": domain.CommentApproved}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	histogram := make(map[int]int, len(rows))
+	for _, row := range rows {
+		histogram[row.Depth] = row.Count
+	}
+	return histogram, nil
+}
+
+// searchHeadlineOptions - параметры ts_headline: весь текст комментария с маркерами
+// совпадений, которые RenderHighlight заменяет на <mark> после экранирования. Символы
+// маркеров удаляются из текста до ts_headline, как в storage.StripHighlightMarkers.
+var searchHeadlineOptions = fmt.Sprintf("StartSel=%q, StopSel=%q, HighlightAll=true",
+	storage.HighlightStartMarker, storage.HighlightStopMarker)
+
+func (s *Store) SearchComments(ctx context.Context, query string, args storage.SearchArgs) ([]storage.SearchHit, error) {
+	terms := storage.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	// post_id - uuid, и сравнение с произвольной строкой завершилось бы ошибкой базы
+	if args.PostID != nil {
+		if _, err := uuid.Parse(*args.PostID); err != nil {
+			return nil, nil
+		}
+	}
+
+	// Конфигурация simple не зависит от языка: слова сравниваются в нижнем регистре без
+	// стемминга. ts_headline дорогой, поэтому считается во внешнем запросе только для
+	// выбранной страницы, а не для всех совпадений
+	var rows []struct {
+		ID        string
+		Score     float64
+		Highlight string
+	}
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT hit.id, hit.score,
+			ts_headline('simple', translate(hit.content, @markers, ''), plainto_tsquery('simple', @query), @options) AS highlight
+		FROM (
+			SELECT c.id, c.content, c.created_at,
+				ts_rank(to_tsvector('simple', c.content), q) AS score
+			FROM comments c, plainto_tsquery('simple', @query) q
+			WHERE to_tsvector('simple', c.content) @@ q
+				AND (CAST(@post AS uuid) IS NULL OR c.post_id = CAST(@post AS uuid))
+				AND (@includeHidden OR (NOT c.hidden AND c.status = @approved))
+			ORDER BY score DESC, c.created_at, c.id
+			LIMIT @limit
+		) hit
+		ORDER BY hit.score DESC, hit.created_at, hit.id`,
+		map[string]interface{}{
+			"query":         strings.Join(terms, " "),
+			"options":       searchHeadlineOptions,
+			"markers":       storage.HighlightStartMarker + storage.HighlightStopMarker,
+			"post":          args.PostID,
+			"includeHidden": args.IncludeHidden,
+			"approved":      domain.CommentApproved,
+			"limit":         args.Limit,
+		}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	comments, err := s.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]storage.SearchHit, 0, len(rows))
+	for _, row := range rows {
+		// Комментарий, удаленный между запросами, пропускается
+		if c, ok := comments[row.ID]; ok {
+			hits = append(hits, storage.SearchHit{Comment: c, Highlight: storage.RenderHighlight(row.Highlight), Score: row.Score})
+		}
+	}
+	return hits, nil
+}
+
+func (s *Store) GetPostStats(ctx context.Context, postIDs []string) (map[string]storage.PostStats, error) {
+	// Комментарии и жалобы агрегируются по постам до соединения с posts, чтобы каждая
+	// таблица просматривалась один раз, а строки не размножались соединением. GREATEST
+	// пропускает NULL, поэтому посты без комментариев и жалоб получают время создания
+	var rows []struct {
+		PostID         string
+		CommentCount   int
+		PendingCount   int
+		ReportCount    int
+		LastActivityAt time.Time
+	}
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT p.id AS post_id,
+			COALESCE(c.comment_count, 0) AS comment_count,
+			COALESCE(c.pending_count, 0) AS pending_count,
+			COALESCE(r.report_count, 0) AS report_count,
+			GREATEST(p.created_at, p.last_comment_at, r.last_report_at) AS last_activity_at
+		FROM posts p
+		LEFT JOIN (
+			SELECT post_id, COUNT(*) AS comment_count,
+				COUNT(*) FILTER (WHERE status = @pending) AS pending_count
+			FROM comments
+			WHERE post_id IN @ids
+			GROUP BY post_id
+		) c ON c.post_id = p.id
+		LEFT JOIN (
+			SELECT cm.post_id, COUNT(*) AS report_count, MAX(rp.created_at) AS last_report_at
+			FROM reports rp
+			JOIN comments cm ON cm.id = rp.comment_id
+			WHERE cm.post_id IN @ids
+			GROUP BY cm.post_id
+		) r ON r.post_id = p.id
+		WHERE p.id IN @ids`,
+		map[string]interface{}{"ids": postIDs, "pending": domain.CommentPending}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]storage.PostStats, len(rows))
+	for _, row := range rows {
+		stats[row.PostID] = storage.PostStats{
+			CommentCount:   row.CommentCount,
+			PendingCount:   row.PendingCount,
+			ReportCount:    row.ReportCount,
+			LastActivityAt: row.LastActivityAt,
+		}
+	}
+	return stats, nil
 }
@@ -0,0 +1,1134 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+	"github.com/UkralStul/graphql-comments-service/internal/storage"
+)
+
+// newTestStore подключается к базе из TEST_DATABASE_URL. Без нее тест пропускается,
+// чтобы go test ./... работал и без запущенного PostgreSQL.
+func newTestStore(t testing.TB, opts ...storage.Option) *Store {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	store, err := New(dsn, opts...)
+	require.NoError(t, err)
+	return store
+}
+
+func TestStore_GetCommentsByParentIDs_GroupsAndOrders(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	base := time.Now().UTC().Truncate(time.Microsecond)
+	post := &domain.Post{ID: uuid.NewString(), Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true, CreatedAt: base}
+	comment := func(parent *domain.Comment, offset time.Duration) *domain.Comment {
+		c := &domain.Comment{ID: uuid.NewString(), PostID: post.ID, AuthorID: "user-1", Content: "text", CreatedAt: base.Add(offset)}
+		if parent != nil {
+			c.ParentID = &parent.ID
+		}
+		return c
+	}
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	a, b := comment(nil, 0), comment(nil, time.Millisecond)
+	a1, a2x, a2y, a3 := comment(a, time.Second), comment(a, 3*time.Second), comment(a, 3*time.Second), comment(a, 6*time.Second)
+	b1, b2 := comment(b, 2*time.Second), comment(b, 5*time.Second)
+	// Одновременные ответы упорядочиваются по ID
+	if a2y.ID < a2x.ID {
+		a2x, a2y = a2y, a2x
+	}
+
+	// Ответы двух родителей вставляются вперемешку и не по времени
+	require.NoError(t, store.ImportThread(ctx, post, []*domain.Comment{a, b, b2, a3, a2y, b1, a1, a2x}))
+
+	byParent, err := store.GetCommentsByParentIDs(ctx, []string{b.ID, a.ID}, 0, false)
+	require.NoError(t, err)
+
+	ids := func(comments []*domain.Comment) []string {
+		out := make([]string, len(comments))
+		for i, c := range comments {
+			out[i] = c.ID
+		}
+		return out
+	}
+	assert.Equal(t, []string{a1.ID, a2x.ID, a2y.ID, a3.ID}, ids(byParent[a.ID]))
+	assert.Equal(t, []string{b1.ID, b2.ID}, ids(byParent[b.ID]))
+
+	// С ограничением возвращаются первые ответы каждого родителя в том же порядке
+	bounded, err := store.GetCommentsByParentIDs(ctx, []string{b.ID, a.ID}, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a1.ID, a2x.ID}, ids(bounded[a.ID]))
+	assert.Equal(t, []string{b1.ID, b2.ID}, ids(bounded[b.ID]))
+}
+
+// TestStore_CreateComment_WaitsForConcurrentToggle воспроизводит гонку: комментарии
+// выключаются в транзакции, которая еще не завершена, когда начинается создание комментария.
+// Создание должно дождаться транзакции и увидеть выключенные комментарии.
+func TestStore_CreateComment_WaitsForConcurrentToggle(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	toggle := store.db.WithContext(ctx).Begin()
+	require.NoError(t, toggle.Error)
+	require.NoError(t, toggle.Model(&domain.Post{}).Where("id = ?", post.ID).Update("comments_enabled", false).Error)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "racing"})
+		done <- err
+	}()
+
+	// Пока переключение не зафиксировано, создание ждет блокировку строки поста
+	select {
+	case err := <-done:
+		t.Fatalf("CreateComment finished before the toggle committed: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, toggle.Commit().Error)
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, storage.ErrCommentsDisabled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("CreateComment did not finish after the toggle committed")
+	}
+}
+
+func TestStore_CreatePost_CommentsDisabled(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// false не должен заменяться значением колонки по умолчанию
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: false})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	stored, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.CommentsEnabled)
+}
+
+func TestStore_CreateComment_Attachments(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	title := "Article"
+	attachments := []domain.Attachment{
+		{URL: "https://example.com/cat.png", Type: domain.AttachmentImage},
+		{URL: "https://example.com/article", Type: domain.AttachmentLink, Title: &title},
+	}
+	with, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "with", Attachments: attachments})
+	require.NoError(t, err)
+	without, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "without"})
+	require.NoError(t, err)
+
+	got, err := store.GetCommentByID(ctx, with.ID)
+	require.NoError(t, err)
+	assert.Equal(t, attachments, got.Attachments)
+	got, err = store.GetCommentByID(ctx, without.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Attachments)
+}
+
+func TestStore_DraftPosts(t *testing.T) {
+	store := newTestStore(t, storage.WithDraftPosts(true))
+	ctx := context.Background()
+
+	draft, err := store.CreatePost(ctx, &domain.Post{Title: "Draft", Content: "Content", AuthorID: "draft-author", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, draft.ID) })
+
+	ids := func(filter storage.PostFilter) []string {
+		posts, err := store.GetPostsAfter(ctx, 1000, nil, filter)
+		require.NoError(t, err)
+		ids := make([]string, len(posts))
+		for i, p := range posts {
+			ids[i] = p.ID
+		}
+		return ids
+	}
+	assert.NotContains(t, ids(storage.PostFilter{}), draft.ID)
+	assert.NotContains(t, ids(storage.PostFilter{DraftsAuthorID: "someone-else"}), draft.ID)
+	assert.Contains(t, ids(storage.PostFilter{DraftsAuthorID: "draft-author"}), draft.ID)
+	assert.Contains(t, ids(storage.PostFilter{IncludeDrafts: true}), draft.ID)
+
+	// false должен записываться, а не заменяться значением колонки по умолчанию
+	published := false
+	updated, err := store.UpdatePost(ctx, draft.ID, storage.PostUpdate{Draft: &published})
+	require.NoError(t, err)
+	assert.False(t, updated.Draft)
+	assert.Contains(t, ids(storage.PostFilter{}), draft.ID)
+}
+
+func TestStore_UniqueComments(t *testing.T) {
+	store := newTestStore(t, storage.WithUniqueComments(true))
+	// Индекс общий для всей тестовой базы, остальные тесты работают без него
+	t.Cleanup(func() { _ = migrateUniqueComments(store.db, false) })
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	require.NoError(t, err)
+	other, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Something else"})
+	require.NoError(t, err)
+
+	// Нарушение индекса превращается в ErrDuplicateComment и при создании, и при редактировании
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "Buy now!"})
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+	_, err = store.UpdateComment(ctx, other.ID, "Buy now!")
+	assert.ErrorIs(t, err, storage.ErrDuplicateComment)
+
+	// Тот же текст от другого автора допустим
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-3", Content: "Buy now!"})
+	require.NoError(t, err)
+}
+
+func TestStore_Pagination_NewestFirst(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	assert.Equal(t, domain.CommentOrderOldest, post.DefaultCommentOrder)
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "comment " + string(rune('a'+i))})
+		require.NoError(t, err)
+		want = append([]string{c.ID}, want...)
+	}
+
+	// Листание вперед от новых к старым проходит все комментарии ровно один раз
+	var got []string
+	args := storage.PaginationArgs{Limit: 2, Order: domain.CommentOrderNewest}
+	for {
+		page, err := store.GetCommentsByPostID(ctx, post.ID, args)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, c := range page {
+			got = append(got, c.ID)
+		}
+		cursor := storage.EncodeCursor(page[len(page)-1])
+		args.Cursor = &cursor
+	}
+	assert.Equal(t, want, got)
+
+	// Страница назад с конца списка - самые старые, в том же порядке
+	page, total, err := store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true, Order: domain.CommentOrderNewest})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, want[3:], []string{page[0].ID, page[1].ID})
+	assert.Equal(t, 5, total)
+
+	order := domain.CommentOrderNewest
+	_, err = store.UpdatePost(ctx, post.ID, storage.PostUpdate{DefaultCommentOrder: &order})
+	require.NoError(t, err)
+	stored, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CommentOrderNewest, stored.DefaultCommentOrder)
+}
+
+func TestStore_Pagination_Top(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "comment " + string(rune('a'+i))})
+		require.NoError(t, err)
+		ids = append(ids, c.ID)
+	}
+	require.NoError(t, store.AddReaction(ctx, ids[1], "user-2", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, ids[1], "user-3", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, ids[3], "user-2", domain.ReactionLike))
+	require.NoError(t, store.AddReaction(ctx, ids[4], "user-2", domain.ReactionDislike))
+	want := []string{ids[1], ids[3], ids[0], ids[2], ids[4]}
+
+	// Листание вперед по рейтингу проходит все комментарии ровно один раз
+	var got []string
+	args := storage.PaginationArgs{Limit: 2, Order: domain.CommentOrderTop}
+	for {
+		page, err := store.GetCommentsByPostID(ctx, post.ID, args)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, c := range page {
+			require.NotNil(t, c.Score)
+			got = append(got, c.ID)
+		}
+		cursor := storage.EncodeCursor(page[len(page)-1])
+		args.Cursor = &cursor
+	}
+	assert.Equal(t, want, got)
+
+	// Страница назад с конца списка - комментарии с наименьшим рейтингом, в том же порядке
+	page, total, err := store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true, Order: domain.CommentOrderTop})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, want[3:], []string{page[0].ID, page[1].ID})
+	assert.Equal(t, -1, *page[1].Score)
+	assert.Equal(t, 5, total)
+}
+
+func TestStore_Pagination_InsertBetweenPages(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	var want []string
+	create := func(content string) *domain.Comment {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: content})
+		require.NoError(t, err)
+		want = append(want, c.ID)
+		return c
+	}
+	for _, content := range []string{"1", "2", "3"} {
+		create(content)
+	}
+
+	page, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	got := []string{page[0].ID, page[1].ID}
+	created := create("4")
+
+	// Возвращенный комментарий дает тот же курсор, что и сохраненная строка
+	stored, err := store.GetCommentByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.EncodeCursor(stored), storage.EncodeCursor(created))
+
+	cursor := storage.EncodeCursor(page[1])
+	page, err = store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	for _, c := range page {
+		got = append(got, c.ID)
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestStore_IDGenerator(t *testing.T) {
+	// Генератор запоминает выданные ID, чтобы проверить, что хранилище берет ID из него
+	var issued []string
+	ids := storage.IDGeneratorFunc(func() string {
+		id := uuid.NewString()
+		issued = append(issued, id)
+		return id
+	})
+	store := newTestStore(t, storage.WithIDGenerator(ids))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{post.ID, comment.ID}, issued)
+	stored, err := store.GetCommentByID(ctx, issued[1])
+	require.NoError(t, err)
+	assert.Equal(t, "root", stored.Content)
+}
+
+func TestStore_Clock(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	store := newTestStore(t, storage.WithClock(storage.ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	assert.True(t, now.Equal(post.CreatedAt))
+
+	// Одинаковое время разводится на шаг точности Postgres
+	for i := 0; i < 3; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment " + string(rune('a'+i))})
+		require.NoError(t, err)
+		stored, err := store.GetCommentByID(ctx, c.ID)
+		require.NoError(t, err)
+		assert.True(t, now.Add(time.Duration(i)*time.Microsecond).Equal(stored.CreatedAt))
+	}
+}
+
+func TestStore_PreserveCreatedAt(t *testing.T) {
+	store := newTestStore(t)
+	ctx := storage.PreserveCreatedAt(context.Background())
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true, CreatedAt: start})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	assert.True(t, post.CreatedAt.Equal(start))
+
+	late, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "late", CreatedAt: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	early, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "author", Content: "early", CreatedAt: start.Add(time.Hour)})
+	require.NoError(t, err)
+
+	comments, err := store.GetCommentsByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, []string{early.ID, late.ID}, []string{comments[0].ID, comments[1].ID})
+	assert.True(t, comments[0].CreatedAt.Equal(start.Add(time.Hour)))
+
+	// Более раннее время не откатывает last_comment_at
+	stored, err := store.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastCommentAt)
+	assert.True(t, stored.LastCommentAt.Equal(late.CreatedAt))
+}
+
+func TestStore_GetRecentCommentsByPostIDs(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	var postIDs []string
+	var created []string
+	for i := 0; i < 2; i++ {
+		post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+		postIDs = append(postIDs, post.ID)
+	}
+	for i := 0; i < 4; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: postIDs[i%2], AuthorID: "user-1", Content: "comment " + string(rune('a'+i))})
+		require.NoError(t, err)
+		created = append(created, c.ID)
+	}
+	_, err := store.SetCommentHidden(ctx, created[3], true)
+	require.NoError(t, err)
+
+	recent, err := store.GetRecentCommentsByPostIDs(ctx, postIDs, 2)
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+	assert.Equal(t, []string{created[2], created[1]}, []string{recent[0].ID, recent[1].ID})
+
+	// Некорректный ID поста пропускается, как и несуществующий
+	recent, err = store.GetRecentCommentsByPostIDs(ctx, append([]string{"abc"}, postIDs...), 2)
+	require.NoError(t, err)
+	assert.Len(t, recent, 2)
+}
+
+func TestStore_GetCommentsSince(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	reply, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "hidden"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+	last, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "last"})
+	require.NoError(t, err)
+
+	since, err := store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: root.CreatedAt}, 10, false)
+	require.NoError(t, err)
+	require.Len(t, since, 2)
+	assert.Equal(t, []string{reply.ID, last.ID}, []string{since[0].ID, since[1].ID})
+
+	since, err = store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: root.CreatedAt}, 2, true)
+	require.NoError(t, err)
+	require.Len(t, since, 2)
+	assert.Equal(t, []string{reply.ID, hidden.ID}, []string{since[0].ID, since[1].ID})
+
+	since, err = store.GetCommentsSince(ctx, post.ID, storage.Cursor{CreatedAt: reply.CreatedAt, ID: reply.ID}, 10, false)
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, last.ID, since[0].ID)
+
+	_, err = store.GetCommentsSince(ctx, "00000000-0000-0000-0000-000000000000", storage.Cursor{CreatedAt: root.CreatedAt}, 10, false)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_CreateComment_ParentInOtherPost(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	var posts []*domain.Post
+	for i := 0; i < 2; i++ {
+		post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+		posts = append(posts, post)
+	}
+	parent, err := store.CreateComment(ctx, &domain.Comment{PostID: posts[0].ID, AuthorID: "user-1", Content: "parent"})
+	require.NoError(t, err)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: posts[1].ID, ParentID: &parent.ID, AuthorID: "user-1", Content: "reply"})
+	var validationErr *storage.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "parentId", validationErr.Field)
+	assert.ErrorIs(t, err, storage.ErrParentOtherPost)
+
+	missing := uuid.NewString()
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: posts[1].ID, ParentID: &missing, AuthorID: "user-1", Content: "reply"})
+	assert.ErrorIs(t, err, storage.ErrParentNotFound)
+}
+
+func TestStore_ForeignKeys(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	parent, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "parent"})
+	require.NoError(t, err)
+	reply, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &parent.ID, AuthorID: "user-1", Content: "reply"})
+	require.NoError(t, err)
+
+	// Комментарий без поста или родителя не вставить даже в обход CreateComment
+	orphan := &domain.Comment{ID: uuid.NewString(), PostID: uuid.NewString(), AuthorID: "user-1", Content: "orphan"}
+	assert.True(t, violatesForeignKey(store.db.WithContext(ctx).Create(orphan).Error, postForeignKey))
+	missing := uuid.NewString()
+	orphan = &domain.Comment{ID: uuid.NewString(), PostID: post.ID, ParentID: &missing, AuthorID: "user-1", Content: "orphan"}
+	assert.True(t, violatesForeignKey(store.db.WithContext(ctx).Create(orphan).Error, parentForeignKey))
+	// Комментарий не может быть родителем самого себя
+	require.Error(t, store.db.WithContext(ctx).Exec("UPDATE comments SET parent_id = id WHERE id = ?", reply.ID).Error)
+
+	// Без каскада пост с комментариями и комментарий с ответами не удалить напрямую
+	require.Error(t, store.db.WithContext(ctx).Exec("DELETE FROM posts WHERE id = ?", post.ID).Error)
+	require.Error(t, store.db.WithContext(ctx).Exec("DELETE FROM comments WHERE id = ?", parent.ID).Error)
+
+	// С каскадом ответы удаляются вместе с родителем, а комментарии - вместе с постом
+	cascading := newTestStore(t, storage.WithCascadeDeletes(true))
+	// Ключи общие для всей тестовой базы, остальные тесты работают без каскада
+	t.Cleanup(func() { _ = migrateForeignKeys(store.db, false) })
+	require.NoError(t, cascading.db.WithContext(ctx).Exec("DELETE FROM comments WHERE id = ?", parent.ID).Error)
+	_, err = cascading.GetCommentByID(ctx, reply.ID)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+
+	comment, err := cascading.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+	require.NoError(t, err)
+	require.NoError(t, cascading.db.WithContext(ctx).Exec("DELETE FROM posts WHERE id = ?", post.ID).Error)
+	_, err = cascading.GetCommentByID(ctx, comment.ID)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_GetCommentByID_InvalidID(t *testing.T) {
+	store := newTestStore(t)
+
+	_, err := store.GetCommentByID(context.Background(), "abc")
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_DeletePost_NotFound(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, store.DeletePost(ctx, uuid.NewString()), storage.ErrPostNotFound)
+	assert.ErrorIs(t, store.DeletePost(ctx, "abc"), storage.ErrPostNotFound)
+	_, err := store.GetPostByID(ctx, "abc")
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestStore_GetAuthorActivity(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	author := "author-" + uuid.NewString()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: author, CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	comment, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: author, Content: "comment"})
+	require.NoError(t, err)
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: author, Content: "hidden"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	items, err := store.GetAuthorActivity(ctx, author, storage.PaginationArgs{Limit: 1}, storage.PostFilter{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.NotNil(t, items[0].Comment)
+	assert.Equal(t, comment.ID, items[0].Comment.ID)
+
+	cursor := items[0].Cursor().Encode()
+	items, err = store.GetAuthorActivity(ctx, author, storage.PaginationArgs{Limit: 10, Cursor: &cursor}, storage.PostFilter{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.NotNil(t, items[0].Post)
+	assert.Equal(t, post.ID, items[0].Post.ID)
+
+	items, err = store.GetAuthorActivity(ctx, author, storage.PaginationArgs{Limit: 10, IncludeHidden: true}, storage.PostFilter{})
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+
+	// Черновик виден только автору и модераторам
+	draft := true
+	_, err = store.UpdatePost(ctx, post.ID, storage.PostUpdate{Draft: &draft})
+	require.NoError(t, err)
+	items, err = store.GetAuthorActivity(ctx, author, storage.PaginationArgs{Limit: 10}, storage.PostFilter{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.NotNil(t, items[0].Comment)
+	items, err = store.GetAuthorActivity(ctx, author, storage.PaginationArgs{Limit: 10}, storage.PostFilter{DraftsAuthorID: author})
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestStore_GetCommentTreePage(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "author", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	create := func(content string, parent *domain.Comment) *domain.Comment {
+		c := &domain.Comment{PostID: post.ID, AuthorID: "author", Content: content}
+		if parent != nil {
+			c.ParentID = &parent.ID
+		}
+		created, err := store.CreateComment(ctx, c)
+		require.NoError(t, err)
+		return created
+	}
+	first := create("first", nil)
+	second := create("second", nil)
+	create("first.1", first)
+	hidden := create("second.1", second)
+	create("second.1.1", hidden)
+	create("first.2", first)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	contents := func(comments []*domain.Comment) []string {
+		got := make([]string, len(comments))
+		for i, c := range comments {
+			got[i] = c.Content
+		}
+		return got
+	}
+
+	page, err := store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "first.1"}, contents(page))
+
+	cursor := storage.EncodeCursor(page[1])
+	page, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first.2", "second"}, contents(page))
+
+	page, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "first.1", "first.2", "second", "second.1", "second.1.1"}, contents(page))
+
+	// Постраничный обход с любым размером страницы совпадает с полным деревом
+	tree, err := store.GetCommentTree(ctx, post.ID)
+	require.NoError(t, err)
+	for limit := 1; limit <= 3; limit++ {
+		var all []*domain.Comment
+		args := storage.PaginationArgs{Limit: limit, IncludeHidden: true}
+		for {
+			page, err := store.GetCommentTreePage(ctx, post.ID, args)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			all = append(all, page...)
+			cursor := storage.EncodeCursor(page[len(page)-1])
+			args.Cursor = &cursor
+		}
+		assert.Equal(t, contents(tree), contents(all), "limit %d", limit)
+	}
+
+	invalid := storage.EncodeCursor(&domain.Comment{ID: uuid.NewString()})
+	_, err = store.GetCommentTreePage(ctx, post.ID, storage.PaginationArgs{Limit: 10, Cursor: &invalid})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestStore_MergePosts(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	target, err := store.CreatePost(ctx, &domain.Post{Title: "Target", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, target.ID) })
+	source, err := store.CreatePost(ctx, &domain.Post{Title: "Source", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, source.ID) })
+
+	t1, err := store.CreateComment(ctx, &domain.Comment{PostID: target.ID, AuthorID: "user-2", Content: "t1"})
+	require.NoError(t, err)
+	s1, err := store.CreateComment(ctx, &domain.Comment{PostID: source.ID, AuthorID: "user-3", Content: "s1"})
+	require.NoError(t, err)
+	t2, err := store.CreateComment(ctx, &domain.Comment{PostID: target.ID, AuthorID: "user-2", Content: "t2"})
+	require.NoError(t, err)
+	s2, err := store.CreateComment(ctx, &domain.Comment{PostID: source.ID, ParentID: &s1.ID, AuthorID: "user-2", Content: "s2"})
+	require.NoError(t, err)
+	for _, post := range []string{source.ID, target.ID} {
+		_, err := store.FollowPost(ctx, post, "user-2", domain.NotificationEmail)
+		require.NoError(t, err)
+	}
+	_, err = store.FollowPost(ctx, source.ID, "user-3", domain.NotificationEmail)
+	require.NoError(t, err)
+	require.NoError(t, store.BlockAuthor(ctx, source.ID, "user-4"))
+
+	_, err = store.MergePosts(ctx, source.ID, source.ID)
+	assert.ErrorIs(t, err, storage.ErrMergeSamePost)
+	_, err = store.MergePosts(ctx, source.ID, uuid.NewString())
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+
+	merged, err := store.MergePosts(ctx, source.ID, target.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target.ID, merged.ID)
+	require.NotNil(t, merged.LastCommentAt)
+	assert.True(t, merged.LastCommentAt.Equal(s2.CreatedAt))
+
+	tree, err := store.GetCommentTree(ctx, target.ID)
+	require.NoError(t, err)
+	ids := make([]string, len(tree))
+	for i, c := range tree {
+		ids[i] = c.ID
+	}
+	assert.Equal(t, []string{t1.ID, s1.ID, s2.ID, t2.ID}, ids)
+
+	_, err = store.GetPostByID(ctx, source.ID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	followers, err := store.GetFollowers(ctx, target.ID)
+	require.NoError(t, err)
+	assert.Len(t, followers, 2)
+}
+
+func TestStore_GetPostWithCommentCount(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	empty, err := store.CreatePost(ctx, &domain.Post{Title: "Empty", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "hidden"})
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &hidden.ID, AuthorID: "user-1", Content: "reply"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	got, count, err := store.GetPostWithCommentCount(ctx, post.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, got.ID)
+	assert.Equal(t, "Post", got.Title)
+	assert.Equal(t, 2, count)
+
+	_, count, err = store.GetPostWithCommentCount(ctx, post.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	counts, err := store.CountCommentsByPostIDs(ctx, []string{post.ID, empty.ID}, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{post.ID: 2}, counts)
+
+	// Скрытый ответ выпадает из своего уровня, но его ответ остается на следующем
+	histogram, err := store.GetDepthHistogram(ctx, post.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 2: 1}, histogram)
+	histogram, err = store.GetDepthHistogram(ctx, post.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 1, 1: 1, 2: 1}, histogram)
+	histogram, err = store.GetDepthHistogram(ctx, empty.ID, true)
+	require.NoError(t, err)
+	assert.Empty(t, histogram)
+
+	_, _, err = store.GetPostWithCommentCount(ctx, uuid.NewString(), false)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+// Сравнивает страницу поста со счетчиком за один запрос и за два (пост, затем батч счетчиков).
+func BenchmarkStore_PostWithCommentCount(b *testing.B) {
+	store := newTestStore(b)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(b, err)
+	for i := 0; i < 100; i++ {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+		require.NoError(b, err)
+	}
+
+	b.Run("two queries", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetPostByID(ctx, post.ID); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := store.CountCommentsByPostIDs(ctx, []string{post.ID}, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("single query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := store.GetPostWithCommentCount(ctx, post.ID, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestStore_GetPostStats(t *testing.T) {
+	store := newTestStore(t, storage.WithPremoderation(true))
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	empty, err := store.CreatePost(ctx, &domain.Post{Title: "Empty", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, empty.ID) })
+
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "root"})
+	require.NoError(t, err)
+	_, err = store.SetCommentStatus(ctx, root.ID, domain.CommentApproved)
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, root.ID, true)
+	require.NoError(t, err)
+	reply, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-3", Content: "reply"})
+	require.NoError(t, err)
+	for _, reporter := range []string{"user-4", "user-5"} {
+		_, err := store.ReportComment(ctx, reply.ID, reporter, "spam")
+		require.NoError(t, err)
+	}
+
+	stats, err := store.GetPostStats(ctx, []string{post.ID, empty.ID, uuid.NewString()})
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	got := stats[post.ID]
+	assert.Equal(t, 2, got.CommentCount)
+	assert.Equal(t, 1, got.PendingCount)
+	assert.Equal(t, 2, got.ReportCount)
+	assert.False(t, got.LastActivityAt.Before(reply.CreatedAt))
+	assert.Zero(t, stats[empty.ID].CommentCount+stats[empty.ID].ReportCount)
+	assert.True(t, stats[empty.ID].LastActivityAt.Equal(empty.CreatedAt))
+}
+
+func TestStore_GetUnansweredComments(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	create := func(parentID *string, authorID, content string) *domain.Comment {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: parentID, AuthorID: authorID, Content: content})
+		require.NoError(t, err)
+		return c
+	}
+	first := create(nil, "user-2", "first")
+	answered := create(nil, "user-3", "answered")
+	create(nil, "user-1", "own")
+	deep := create(nil, "user-2", "deep")
+	create(&answered.ID, "user-1", "reply")
+	other := create(&deep.ID, "user-4", "other reply")
+	create(&other.ID, "user-1", "nested reply")
+
+	page, err := store.GetUnansweredComments(ctx, post.ID, "user-1", storage.PaginationArgs{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, first.ID, page[0].ID)
+
+	cursor := storage.EncodeCursor(page[0])
+	page, err = store.GetUnansweredComments(ctx, post.ID, "user-1", storage.PaginationArgs{Limit: 10, Cursor: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, deep.ID, page[0].ID)
+}
+
+func TestStore_SearchComments(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	// Уникальное слово отделяет комментарии теста от остальных в базе
+	word := "w" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	match, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "<b>" + word + "</b> и Go"})
+	require.NoError(t, err)
+	hidden, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: word + " hidden"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, hidden.ID, true)
+	require.NoError(t, err)
+
+	hits, err := store.SearchComments(ctx, strings.ToUpper(word)+" go", storage.SearchArgs{Limit: 10, PostID: &post.ID})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, match.ID, hits[0].Comment.ID)
+	assert.Equal(t, "&lt;b&gt;<mark>"+word+"</mark>&lt;/b&gt; и <mark>Go</mark>", hits[0].Highlight)
+	assert.Greater(t, hits[0].Score, 0.0)
+
+	hits, err = store.SearchComments(ctx, word, storage.SearchArgs{Limit: 10, IncludeHidden: true})
+	require.NoError(t, err)
+	assert.Len(t, hits, 2)
+
+	invalid := "not-a-uuid"
+	hits, err = store.SearchComments(ctx, word, storage.SearchArgs{Limit: 10, PostID: &invalid})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+}
+
+func TestStore_GetCommentsPageByPostID(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	var ids []string
+	for i := 0; i < 4; i++ {
+		c, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "comment"})
+		require.NoError(t, err)
+		ids = append(ids, c.ID)
+	}
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &ids[0], AuthorID: "user-2", Content: "reply"})
+	require.NoError(t, err)
+	_, err = store.SetCommentHidden(ctx, ids[3], true)
+	require.NoError(t, err)
+
+	page, total, err := store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, ids[0], page[0].ID)
+	assert.Equal(t, 3, total)
+
+	// Пустая страница в конце списка тоже возвращает общее число
+	cursor := storage.EncodeCursor(page[1])
+	page, total, err = store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	cursor = storage.EncodeCursor(page[0])
+	page, total, err = store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Cursor: &cursor})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	assert.Equal(t, 3, total)
+
+	page, total, err = store.GetCommentsPageByPostID(ctx, post.ID, storage.PaginationArgs{Limit: 2, Backward: true, IncludeHidden: true})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []string{ids[2], ids[3]}, []string{page[0].ID, page[1].ID})
+	assert.Equal(t, 4, total)
+}
+
+func BenchmarkStore_CommentsPageWithTotal(b *testing.B) {
+	store := newTestStore(b)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(b, err)
+	b.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	for i := 0; i < 100; i++ {
+		_, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "comment"})
+		require.NoError(b, err)
+	}
+	args := storage.PaginationArgs{Limit: 11}
+
+	b.Run("two queries", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetCommentsByPostID(ctx, post.ID, args); err != nil {
+				b.Fatal(err)
+			}
+			var total int64
+			err := applyHidden(store.db.WithContext(ctx).Model(&domain.Comment{}).Where("post_id = ? AND parent_id IS NULL", post.ID), false).
+				Count(&total).Error
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("single query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := store.GetCommentsPageByPostID(ctx, post.ID, args); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGormLogLevel(t *testing.T) {
+	assert.Equal(t, logger.Silent, gormLogLevel(storage.LogSilent))
+	assert.Equal(t, logger.Error, gormLogLevel(storage.LogError))
+	assert.Equal(t, logger.Warn, gormLogLevel(storage.LogWarn))
+	assert.Equal(t, logger.Info, gormLogLevel(storage.LogInfo))
+}
+
+func TestListingIndexes(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	cursor := storage.EncodeCursor(root)
+
+	// explain возвращает план запроса страницы. В маленькой тестовой базе планировщик
+	// предпочел бы последовательное чтение, поэтому оно запрещено внутри транзакции.
+	explain := func(page func(tx *gorm.DB) *gorm.DB) string {
+		query := store.db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			tx = store.applyCursor(ctx, page(tx.Model(&domain.Comment{})), &cursor, false)
+			return applyHidden(tx.Order("created_at ASC, id ASC").Limit(10), false).Find(&[]*domain.Comment{})
+		})
+		var plan []string
+		err := store.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SET LOCAL enable_seqscan = off").Error; err != nil {
+				return err
+			}
+			return tx.Raw("EXPLAIN " + query).Scan(&plan).Error
+		})
+		require.NoError(t, err)
+		return strings.Join(plan, "\n")
+	}
+
+	plan := explain(func(tx *gorm.DB) *gorm.DB { return tx.Where("post_id = ? AND parent_id IS NULL", post.ID) })
+	assert.Contains(t, plan, postListingIndex)
+	assert.NotContains(t, plan, "Sort")
+
+	plan = explain(func(tx *gorm.DB) *gorm.DB { return tx.Where("parent_id = ?", root.ID) })
+	assert.Contains(t, plan, childrenListingIndex)
+	assert.NotContains(t, plan, "Sort")
+
+	plan = explain(func(tx *gorm.DB) *gorm.DB { return tx.Where("post_id = ?", post.ID) })
+	assert.Contains(t, plan, postTimelineIndex)
+	assert.NotContains(t, plan, "Sort")
+}
+
+func TestStore_LockedThread(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+	root, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "root"})
+	require.NoError(t, err)
+	middle, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-2", Content: "middle"})
+	require.NoError(t, err)
+	leaf, err := store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &middle.ID, AuthorID: "user-1", Content: "leaf"})
+	require.NoError(t, err)
+
+	locked, err := store.SetCommentLocked(ctx, middle.ID, true)
+	require.NoError(t, err)
+	assert.True(t, locked.Locked)
+	stored, err := store.GetCommentByID(ctx, middle.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Locked)
+
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &leaf.ID, AuthorID: "user-3", Content: "reply to leaf"})
+	assert.ErrorIs(t, err, storage.ErrThreadLocked)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &root.ID, AuthorID: "user-3", Content: "reply to root"})
+	assert.NoError(t, err)
+
+	_, err = store.SetCommentLocked(ctx, middle.ID, false)
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, ParentID: &leaf.ID, AuthorID: "user-3", Content: "reply to leaf"})
+	assert.NoError(t, err)
+
+	_, err = store.SetCommentLocked(ctx, uuid.NewString(), true)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+}
+
+func TestStore_BlockAuthor(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	post, err := store.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, post.ID) })
+
+	require.NoError(t, store.BlockAuthor(ctx, post.ID, "spammer"))
+	require.NoError(t, store.BlockAuthor(ctx, post.ID, "spammer"))
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "spammer", Content: "spam"})
+	assert.ErrorIs(t, err, storage.ErrAuthorBlocked)
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-2", Content: "hello"})
+	assert.NoError(t, err)
+
+	require.NoError(t, store.UnblockAuthor(ctx, post.ID, "spammer"))
+	_, err = store.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "spammer", Content: "sorry"})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, store.BlockAuthor(ctx, uuid.NewString(), "spammer"), storage.ErrPostNotFound)
+}
+
+func TestStore_WithTransaction(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var postID, commentID string
+	err := store.WithTransaction(ctx, func(tx storage.Storage) error {
+		post, err := tx.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		require.NoError(t, err)
+		postID = post.ID
+		comment, err := tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "first"})
+		require.NoError(t, err)
+		commentID = comment.ID
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	_, err = store.GetPostByID(ctx, postID)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	_, err = store.GetCommentByID(ctx, commentID)
+	assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+
+	err = store.WithTransaction(ctx, func(tx storage.Storage) error {
+		post, err := tx.CreatePost(ctx, &domain.Post{Title: "Post", Content: "Content", AuthorID: "user-1", CommentsEnabled: true})
+		if err != nil {
+			return err
+		}
+		postID = post.ID
+		_, err = tx.CreateComment(ctx, &domain.Comment{PostID: post.ID, AuthorID: "user-1", Content: "first"})
+		return err
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.DeletePost(ctx, postID) })
+	comments, err := store.GetCommentsByPostID(ctx, postID, storage.PaginationArgs{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+}
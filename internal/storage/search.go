@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// HighlightStartMarker и HighlightStopMarker отмечают в тексте границы совпадения, пока
+	// текст не экранирован. HTML-экранирование их не затрагивает. Текст комментария может
+	// содержать эти символы (COMMENT_ALLOWED_CONTROL_CHARS, старые записи), поэтому перед
+	// расстановкой маркеров они удаляются из текста (StripHighlightMarkers).
+	HighlightStartMarker = "\x02"
+	HighlightStopMarker  = "\x03"
+)
+
+// StripHighlightMarkers удаляет из текста символы маркеров совпадений, чтобы RenderHighlight
+// не превратил их в теги.
+func StripHighlightMarkers(content string) string {
+	return strings.NewReplacer(HighlightStartMarker, "", HighlightStopMarker, "").Replace(content)
+}
+
+// SearchTerms разбивает поисковый запрос на слова в нижнем регистре: последовательности
+// букв и цифр, остальные символы считаются разделителями.
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// RenderHighlight экранирует текст с маркерами совпадений для HTML и заменяет маркеры
+// на <mark> и </mark>. Клиент может вывести результат как HTML: других тегов в нем нет.
+func RenderHighlight(marked string) string {
+	return strings.NewReplacer(
+		HighlightStartMarker, "<mark>",
+		HighlightStopMarker, "</mark>",
+	).Replace(html.EscapeString(marked))
+}
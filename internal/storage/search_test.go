@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"привет", "go", "1", "24"}, SearchTerms("  Привет, Go-1.24! "))
+	assert.Empty(t, SearchTerms(" ,.! "))
+}
+
+func TestRenderHighlight(t *testing.T) {
+	// Текст комментария экранируется, теги остаются только у совпадений
+	marked := "<b>" + HighlightStartMarker + "go" + HighlightStopMarker + "</b> & more"
+	assert.Equal(t, "&lt;b&gt;<mark>go</mark>&lt;/b&gt; &amp; more", RenderHighlight(marked))
+}
+
+func TestStripHighlightMarkers(t *testing.T) {
+	// Маркеры в самом тексте не должны превратиться в теги
+	content := "a" + HighlightStartMarker + "<script>" + HighlightStopMarker + "b"
+	assert.Equal(t, "a&lt;script&gt;b", RenderHighlight(StripHighlightMarkers(content)))
+}
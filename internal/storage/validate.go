@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/UkralStul/graphql-comments-service/internal/domain"
+)
+
+// DefaultAllowedControlChars - управляющие символы, допустимые в тексте комментария по умолчанию:
+// табуляция и перевод строки. \r остается от переводов строк Windows, если нормализация выключена.
+const DefaultAllowedControlChars = "\t\n\r"
+
+const (
+	// MaxAttachmentURLLength - максимальная длина ссылки вложения в символах.
+	MaxAttachmentURLLength = 2048
+	// MaxAttachmentTitleLength - максимальная длина подписи вложения в символах.
+	MaxAttachmentTitleLength = 255
+)
+
+// NormalizeCommentContent убирает пробельные символы по краям текста и схлопывает
+// серии пустых строк длиннее maxBlankLines. Переводы строк приводятся к \n.
+func NormalizeCommentContent(content string, maxBlankLines int) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSpace(content)
+
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	blank := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			blank++
+			if blank > maxBlankLines {
+				continue
+			}
+			line = ""
+		} else {
+			blank = 0
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// ValidateCommentContent проверяет текст комментария перед сохранением.
+// Длина считается в символах, а не в байтах, как и у колонки varchar в postgres.
+func ValidateCommentContent(content string, maxLength int) error {
+	if utf8.RuneCountInString(content) > maxLength {
+		return &ValidationError{Field: "content", Code: ValidationCodeTooLong, Limit: maxLength, Err: ErrContentTooLong}
+	}
+	if strings.TrimSpace(content) == "" {
+		return &ValidationError{Field: "content", Code: ValidationCodeBlank, Err: ErrContentEmpty}
+	}
+	return nil
+}
+
+// ValidateControlChars отклоняет текст с управляющими символами (NUL, ESC, DEL, C1 и т.п.),
+// кроме перечисленных в allowed. Такие символы попадают в комментарии при вставке бинарных
+// данных и ломают отображение у клиентов.
+func ValidateControlChars(content, allowed string) error {
+	for _, r := range content {
+		if unicode.IsControl(r) && !strings.ContainsRune(allowed, r) {
+			return &ValidationError{Field: "content", Code: ValidationCodeInvalid,
+				Err: fmt.Errorf("%w: %U", ErrContentControl, r)}
+		}
+	}
+	return nil
+}
+
+// ValidateParentPost проверяет, что родительский комментарий из того же поста,
+// что и новый: ответ на комментарий другого поста нарушил бы дерево обоих постов.
+func ValidateParentPost(parentPostID, postID string) error {
+	if parentPostID != postID {
+		return &ValidationError{Field: "parentId", Code: ValidationCodeInvalid, Err: ErrParentOtherPost}
+	}
+	return nil
+}
+
+// ValidateAttachments проверяет вложения комментария: их не больше maxCount, ссылка - абсолютный
+// http(s) URL, тип известен, длины ссылки и подписи в пределах лимитов. Ссылки с другими схемами
+// (javascript:, data:) отклоняются, потому что клиенты выводят их как есть. Поле ошибки
+// указывает на вложение: attachments[1].url.
+func ValidateAttachments(attachments []domain.Attachment, maxCount int) error {
+	if len(attachments) > maxCount {
+		return &ValidationError{Field: "attachments", Code: ValidationCodeTooLong, Limit: maxCount, Err: ErrTooManyAttachments}
+	}
+	for i, a := range attachments {
+		field := fmt.Sprintf("attachments[%d].", i)
+		if utf8.RuneCountInString(a.URL) > MaxAttachmentURLLength {
+			return &ValidationError{Field: field + "url", Code: ValidationCodeTooLong, Limit: MaxAttachmentURLLength,
+				Err: fmt.Errorf("%w %d: url is too long", ErrAttachmentInvalid, i)}
+		}
+		u, err := url.Parse(a.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &ValidationError{Field: field + "url", Code: ValidationCodeInvalid,
+				Err: fmt.Errorf("%w %d: url must be an absolute http or https URL", ErrAttachmentInvalid, i)}
+		}
+		if a.Type != domain.AttachmentImage && a.Type != domain.AttachmentLink {
+			return &ValidationError{Field: field + "type", Code: ValidationCodeInvalid,
+				Err: fmt.Errorf("%w %d: unknown type %q", ErrAttachmentInvalid, i, a.Type)}
+		}
+		if a.Title != nil && utf8.RuneCountInString(*a.Title) > MaxAttachmentTitleLength {
+			return &ValidationError{Field: field + "title", Code: ValidationCodeTooLong, Limit: MaxAttachmentTitleLength,
+				Err: fmt.Errorf("%w %d: title is too long", ErrAttachmentInvalid, i)}
+		}
+	}
+	return nil
+}